@@ -0,0 +1,165 @@
+package goappbuild
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permission is the access an ACL entry gives to a document
+type Permission string
+
+const (
+	// PermissionRead allows reading the document
+	PermissionRead Permission = "read"
+	// PermissionWrite allows reading and updating the document
+	PermissionWrite Permission = "write"
+)
+
+// Validate returns an error if the permission is unknown
+func (p Permission) Validate() error {
+	switch p {
+	case PermissionRead, PermissionWrite:
+		return nil
+	default:
+		return Errorf(EValidation, "invalid permission: %q", p)
+	}
+}
+
+// Permissions returns the permissions that allow the action on a document.
+// Creating and deleting documents cannot be granted, it returns nil for them.
+func (a Action) Permissions() []Permission {
+	switch a {
+	case ActionRead:
+		return []Permission{PermissionRead, PermissionWrite}
+	case ActionUpdate:
+		return []Permission{PermissionWrite}
+	default:
+		return nil
+	}
+}
+
+// PrincipalType is the kind of principal of an ACL entry
+type PrincipalType string
+
+const (
+	// PrincipalUser is a platform user or an end user of the project
+	PrincipalUser PrincipalType = "user"
+	// PrincipalTeam is a team of the project, its members are granted the entry
+	PrincipalTeam PrincipalType = "team"
+)
+
+// Validate returns an error if the principal type is unknown
+func (p PrincipalType) Validate() error {
+	switch p {
+	case PrincipalUser, PrincipalTeam:
+		return nil
+	default:
+		return Errorf(EValidation, "invalid principal type: %q", p)
+	}
+}
+
+// ACLEntry shares a document with a principal. It lets callers that the
+// collection rules do not allow, or only allow on the documents they own,
+// access the document with the permission of the entry.
+type ACLEntry struct {
+	ID            uuid.UUID
+	ProjectID     uuid.UUID
+	Collection    string
+	DocumentID    string
+	PrincipalType PrincipalType
+	PrincipalID   uuid.UUID
+	Permission    Permission
+	// CreatedBy is the user that shared the document, it is empty for API keys
+	CreatedBy uuid.UUID
+	CreatedAt time.Time
+}
+
+// ShareDocumentRequest is the request to share a document with a principal
+type ShareDocumentRequest struct {
+	ProjectID  uuid.UUID
+	Collection string
+	DocumentID string
+	// PrincipalType is the kind of the principal, empty means a user
+	PrincipalType PrincipalType
+	PrincipalID   uuid.UUID
+	Permission    Permission
+}
+
+// Validate validates the request
+func (o *ShareDocumentRequest) Validate() error {
+	if strings.TrimSpace(o.Collection) == "" {
+		return Errorf(EValidation, "collection is required")
+	}
+
+	if o.DocumentID == "" {
+		return Errorf(EValidation, "document id is required")
+	}
+
+	if o.PrincipalID == uuid.Nil {
+		return Errorf(EValidation, "principal id is required")
+	}
+
+	if o.PrincipalType != "" {
+		if err := o.PrincipalType.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return o.Permission.Validate()
+}
+
+// Access restricts a query to the rows a principal owns or was granted
+type Access struct {
+	ProjectID uuid.UUID
+	// OwnerID selects the rows owned by the principal when set
+	OwnerID uuid.UUID
+	// GranteeID selects the rows shared with the user, or a team of the
+	// user, with one of the permissions when set
+	GranteeID   uuid.UUID
+	Permissions []Permission
+}
+
+// ACLService manages the sharing of the documents with specific users and teams
+type ACLService interface {
+	// Share grants the permission on a document to a principal.
+	// Sharing again replaces the permission.
+	Share(context.Context, ShareDocumentRequest) (ACLEntry, error)
+	// Unshare revokes the access of a principal to a document
+	Unshare(ctx context.Context, projectID uuid.UUID, collection, documentID string, principalID uuid.UUID) error
+	// List returns the entries of a document, oldest first
+	List(ctx context.Context, projectID uuid.UUID, collection, documentID string) ([]ACLEntry, error)
+}
+
+// ACLRepo is the repository of the ACL entries
+type ACLRepo interface {
+	// Upsert stores the entry or replaces the permission of the existing one
+	Upsert(context.Context, *ACLEntry) error
+	Delete(ctx context.Context, projectID uuid.UUID, collection, documentID string, principalID uuid.UUID) error
+	// DeleteDocuments deletes the entries of the documents
+	DeleteDocuments(ctx context.Context, projectID uuid.UUID, collection string, documentIDs ...string) error
+	// DeletePrincipal deletes the entries of the principal
+	DeletePrincipal(ctx context.Context, projectID uuid.UUID, principalType PrincipalType, principalID uuid.UUID) error
+	// List returns the entries of a document, oldest first
+	List(ctx context.Context, projectID uuid.UUID, collection, documentID string) ([]ACLEntry, error)
+	// Exists returns true if any document of the collection is shared with the
+	// user, or a team of the user, with one of the permissions
+	Exists(
+		ctx context.Context,
+		projectID uuid.UUID,
+		collection string,
+		userID uuid.UUID,
+		permissions ...Permission,
+	) (bool, error)
+	// Granted returns true if the document is shared with the user, or a
+	// team of the user, with one of the permissions
+	Granted(
+		ctx context.Context,
+		projectID uuid.UUID,
+		collection, documentID string,
+		userID uuid.UUID,
+		permissions ...Permission,
+	) (bool, error)
+}
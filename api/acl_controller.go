@@ -0,0 +1,229 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// ACLController is the controller for sharing documents with specific users and teams.
+type ACLController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewACLController creates a new ACL controller.
+func NewACLController(app *goappbuild.App) ACLController {
+	return ACLController{
+		app: app,
+	}
+}
+
+// ShareDocumentRequest is the request for the Share method.
+type ShareDocumentRequest struct {
+	// PrincipalType is user (the default) or team
+	PrincipalType goappbuild.PrincipalType `json:"principal_type,omitempty"`
+	// Permission is read or write, write allows updating the document too
+	Permission goappbuild.Permission `json:"permission"`
+}
+
+// Validate validates the request.
+func (o *ShareDocumentRequest) Validate() error {
+	if o.Permission == "" {
+		return errors.New("permission is required")
+	}
+
+	return nil
+}
+
+// ACLEntryResponse is the access of a user or a team to a document.
+type ACLEntryResponse struct {
+	ID            uuid.UUID                `json:"id"`
+	DocumentID    string                   `json:"document_id"`
+	PrincipalType goappbuild.PrincipalType `json:"principal_type"`
+	PrincipalID   uuid.UUID                `json:"principal_id"`
+	Permission    goappbuild.Permission    `json:"permission"`
+	CreatedBy     *uuid.UUID               `json:"created_by,omitempty"`
+	CreatedAt     time.Time                `json:"created_at"`
+}
+
+func newACLEntryResponse(e goappbuild.ACLEntry) ACLEntryResponse {
+	ans := ACLEntryResponse{
+		ID:            e.ID,
+		DocumentID:    e.DocumentID,
+		PrincipalType: e.PrincipalType,
+		PrincipalID:   e.PrincipalID,
+		Permission:    e.Permission,
+		CreatedAt:     e.CreatedAt,
+	}
+
+	if e.CreatedBy != uuid.Nil {
+		ans.CreatedBy = &e.CreatedBy
+	}
+
+	return ans
+}
+
+// Share shares a document with a user or a team
+//
+// @Summary Share a document
+// @Description Grant a platform user, an end user or a team of the project read or write access to a document.
+// @Description Sharing again replaces the permission. The user, or the members of the team, see the document
+// @Description even when the rules do not allow it.
+// @Tags acl
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param id path string true "Document ID"
+// @Param principalID path string true "User, end user or team ID"
+// @Param projectID header string false "Project ID (implied by an API key)"
+// @Param body body ShareDocumentRequest true "The request body"
+// @Success 200 {object} ACLEntryResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName}/{id}/acl/{principalID} [put]
+func (o ACLController) Share(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	principalID, err := uuid.Parse(o.StringURLParam(r, "principalID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload ShareDocumentRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.ShareDocumentRequest{
+		ProjectID:     projectID,
+		Collection:    o.StringURLParam(r, "collectionName"),
+		DocumentID:    o.StringURLParam(r, "id"),
+		PrincipalType: payload.PrincipalType,
+		PrincipalID:   principalID,
+		Permission:    payload.Permission,
+	}
+
+	e, err := o.app.ACL.Share(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newACLEntryResponse(e))
+}
+
+// Unshare revokes the access of a user or a team to a document
+//
+// @Summary Unshare a document
+// @Description Revoke the access a user or a team was given to a document
+// @Tags acl
+// @Param collectionName path string true "Collection Name"
+// @Param id path string true "Document ID"
+// @Param principalID path string true "User, end user or team ID"
+// @Param projectID header string false "Project ID (implied by an API key)"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName}/{id}/acl/{principalID} [delete]
+func (o ACLController) Unshare(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	principalID, err := uuid.Parse(o.StringURLParam(r, "principalID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	err = o.app.ACL.Unshare(
+		r.Context(),
+		projectID,
+		o.StringURLParam(r, "collectionName"),
+		o.StringURLParam(r, "id"),
+		principalID,
+	)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// ListACLResponse is the response for the List method.
+type ListACLResponse struct {
+	Entries []ACLEntryResponse `json:"entries"`
+}
+
+// List lists the users and the teams a document is shared with
+//
+// @Summary List the sharing of a document
+// @Description List the users and the teams a document is shared with, oldest first
+// @Tags acl
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param id path string true "Document ID"
+// @Param projectID header string false "Project ID (implied by an API key)"
+// @Success 200 {object} ListACLResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName}/{id}/acl [get]
+func (o ACLController) List(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := o.app.ACL.List(
+		r.Context(),
+		projectID,
+		o.StringURLParam(r, "collectionName"),
+		o.StringURLParam(r, "id"),
+	)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListACLResponse{
+		Entries: make([]ACLEntryResponse, len(entries)),
+	}
+
+	for i := range entries {
+		ans.Entries[i] = newACLEntryResponse(entries[i])
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
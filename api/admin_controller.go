@@ -0,0 +1,406 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// AdminController is the controller for the management of the users by the
+// admins of the platform.
+type AdminController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewAdminController creates a new admin controller.
+func NewAdminController(app *goappbuild.App) AdminController {
+	return AdminController{
+		app: app,
+	}
+}
+
+// UserResponse is a user as seen by the admins.
+type UserResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	IsAdmin         bool       `json:"is_admin"`
+	DisabledAt      *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func newUserResponse(u goappbuild.User) UserResponse {
+	return UserResponse{
+		ID:              u.ID,
+		Email:           u.Email,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		IsAdmin:         u.IsAdmin,
+		DisabledAt:      u.DisabledAt,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+	}
+}
+
+// ListUsersResponse is the response for the ListUsers method.
+type ListUsersResponse struct {
+	Users []UserResponse `json:"users"`
+}
+
+// UserProjectResponse is a membership of a user.
+type UserProjectResponse struct {
+	ProjectID   uuid.UUID `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ListUserProjectsResponse is the response for the UserProjects method.
+type ListUserProjectsResponse struct {
+	Projects []UserProjectResponse `json:"projects"`
+}
+
+// ListUsers lists the users
+//
+// @Summary List the users
+// @Description List the users ordered by email. Admins only.
+// @Tags admin
+// @Produce json
+// @Param search query string false "Part of the email"
+// @Param disabled query bool false "Only the disabled (true) or the enabled (false) users"
+// @Param limit query int false "Maximum number of users (default 50, max 500)"
+// @Param offset query int false "Number of users to skip"
+// @Success 200 {object} ListUsersResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users [get]
+func (o AdminController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	filter := goappbuild.UserFilter{
+		Search: o.QueryParam(r, "search"),
+	}
+
+	if v := o.QueryParam(r, "disabled"); v != "" {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			o.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		filter.Disabled = &disabled
+	}
+
+	var err error
+
+	if filter.Limit, err = o.intQueryParam(r, "limit"); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if filter.Offset, err = o.intQueryParam(r, "offset"); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	users, err := o.app.Users.List(r.Context(), filter)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListUsersResponse{
+		Users: make([]UserResponse, len(users)),
+	}
+
+	for i := range users {
+		ans.Users[i] = newUserResponse(users[i])
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// GetUser returns a user
+//
+// @Summary Get a user
+// @Description Get a user. Admins only.
+// @Tags admin
+// @Produce json
+// @Param userID path string true "User ID"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{userID} [get]
+func (o AdminController) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := o.app.Users.Get(r.Context(), userID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newUserResponse(u))
+}
+
+// DisableUser disables a user
+//
+// @Summary Disable a user
+// @Description Disable a user and revoke the access and refresh tokens of the user. Admins only.
+// @Tags admin
+// @Produce json
+// @Param userID path string true "User ID"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{userID}/disable [post]
+func (o AdminController) DisableUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := o.app.Users.Disable(r.Context(), userID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newUserResponse(u))
+}
+
+// EnableUser enables a user
+//
+// @Summary Enable a user
+// @Description Enable a disabled user. Admins only.
+// @Tags admin
+// @Produce json
+// @Param userID path string true "User ID"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{userID}/enable [post]
+func (o AdminController) EnableUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := o.app.Users.Enable(r.Context(), userID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newUserResponse(u))
+}
+
+// DeleteUser deletes a user
+//
+// @Summary Delete a user
+// @Description Delete a user that does not own projects. Admins only.
+// @Tags admin
+// @Param userID path string true "User ID"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{userID} [delete]
+func (o AdminController) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Users.Delete(r.Context(), userID); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// LogoutUser logs out a user everywhere
+//
+// @Summary Force the logout of a user
+// @Description Revoke the access and refresh tokens of a user. Admins only.
+// @Tags admin
+// @Param userID path string true "User ID"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{userID}/logout [post]
+func (o AdminController) LogoutUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Users.ForceLogout(r.Context(), userID); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// UserProjects lists the projects of a user
+//
+// @Summary List the projects of a user
+// @Description List the projects the user is a member of with the role. Admins only.
+// @Tags admin
+// @Produce json
+// @Param userID path string true "User ID"
+// @Success 200 {object} ListUserProjectsResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{userID}/projects [get]
+func (o AdminController) UserProjects(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	memberships, err := o.app.Users.Projects(r.Context(), userID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListUserProjectsResponse{
+		Projects: make([]UserProjectResponse, len(memberships)),
+	}
+
+	for i, m := range memberships {
+		ans.Projects[i] = UserProjectResponse{
+			ProjectID:   m.ProjectID,
+			ProjectName: m.ProjectName,
+			Role:        string(m.Role),
+			CreatedAt:   m.CreatedAt,
+		}
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// ImpersonateRequest is the request for the Impersonate method.
+type ImpersonateRequest struct {
+	// Reason is recorded in the security events, like the ticket being debugged
+	Reason string `json:"reason"`
+}
+
+// Validate validates the request.
+func (o *ImpersonateRequest) Validate() error {
+	if o.Reason == "" {
+		return errors.New("reason is required")
+	}
+
+	return nil
+}
+
+// Impersonate issues a token to act as a user
+//
+// @Summary Impersonate a user
+// @Description Get a short lived access token of a user to debug an issue. The token records the admin,
+// @Description it cannot be refreshed and it cannot change the MFA of the user. Admins and disabled users
+// @Description cannot be impersonated. The impersonation is recorded with the reason. Admins only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param userID path string true "User ID"
+// @Param body body ImpersonateRequest true "The request body"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{userID}/impersonate [post]
+func (o AdminController) Impersonate(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload ImpersonateRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.ImpersonateRequest{
+		UserID: userID,
+		Reason: payload.Reason,
+	}
+
+	pair, err := o.app.Auth.Impersonate(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newTokenResponse(pair))
+}
+
+func (o AdminController) intQueryParam(r *http.Request, key string) (int, error) {
+	v := o.QueryParam(r, key)
+	if v == "" {
+		return 0, nil
+	}
+
+	ans, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return ans, nil
+}
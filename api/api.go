@@ -24,10 +24,37 @@ type Router struct {
 	projectController    ProjectController
 	collectionController CollectionController
 	queryController      QueryController
+	batchController      BatchController
+	apiKeyController     APIKeyController
+	memberController     MemberController
+	endUserController    EndUserController
+	ssoController        SSOController
+	mfaController        MFAController
+	securityController   SecurityController
+	adminController      AdminController
+	shareController      ShareController
+	aclController        ACLController
+	teamController       TeamController
+	realtimeController   RealtimeController
+
+	clientIPMiddleware     restapi.Middleware
+	authMiddleware         restapi.Middleware
+	optionalAuthMiddleware restapi.Middleware
+}
+
+// RouterOption configures the router
+type RouterOption func(*Router)
+
+// WithTrustProxy takes the client addresses from the headers of the
+// reverse proxy in front of the API
+func WithTrustProxy(trust bool) RouterOption {
+	return func(o *Router) {
+		o.clientIPMiddleware = NewClientIPMiddleware(trust)
+	}
 }
 
 // NewRouter creates a new router.
-func NewRouter(l *goappbuild.App, specFS embed.FS) (*Router, error) {
+func NewRouter(l *goappbuild.App, specFS embed.FS, opts ...RouterOption) (*Router, error) {
 	swagCfg := restapi.SwaggerUIConfig{
 		SpecName: "GoAppBuild API",
 		SpecFile: "/docs/swagger.json",
@@ -41,16 +68,34 @@ func NewRouter(l *goappbuild.App, specFS embed.FS) (*Router, error) {
 	}
 
 	ans := Router{
-		ChiRouter:            restapi.NewChiRouter(),
-		swaggerController:    sw,
-		swaggerPath:          swagCfg.Path,
-		healthController:     NewHealthController(),
-		userController:       NewUserController(l),
-		projectController:    NewProjectController(l),
-		collectionController: NewCollectionController(l),
-		queryController:      NewQueryController(l),
+		ChiRouter:              restapi.NewChiRouter(),
+		swaggerController:      sw,
+		swaggerPath:            swagCfg.Path,
+		healthController:       NewHealthController(),
+		userController:         NewUserController(l),
+		projectController:      NewProjectController(l),
+		collectionController:   NewCollectionController(l),
+		queryController:        NewQueryController(l),
+		batchController:        NewBatchController(l),
+		apiKeyController:       NewAPIKeyController(l),
+		memberController:       NewMemberController(l),
+		endUserController:      NewEndUserController(l),
+		ssoController:          NewSSOController(l),
+		mfaController:          NewMFAController(l),
+		securityController:     NewSecurityController(l),
+		adminController:        NewAdminController(l),
+		shareController:        NewShareController(l),
+		aclController:          NewACLController(l),
+		teamController:         NewTeamController(l),
+		realtimeController:     NewRealtimeController(l),
+		clientIPMiddleware:     NewClientIPMiddleware(false),
+		authMiddleware:         NewAuthMiddleware(l),
+		optionalAuthMiddleware: NewOptionalAuthMiddleware(l),
 		//idempotencyMiddleware: idempotencyMiddleware,
-		//userContextMiddleware: NewUserContextMiddleware(),
+	}
+
+	for _, opt := range opts {
+		opt(&ans)
 	}
 
 	return &ans, nil
@@ -68,25 +113,146 @@ func (router *Router) Engine() http.Handler {
 	router.R.Handle(sp, http.StripPrefix(router.swaggerPath, router.swaggerController))
 
 	router.R.Route("/api/v1", func(r chi.Router) {
+		r.Use(router.clientIPMiddleware.Handle)
+
 		r.Get("/health", router.healthController.GetHealth)
 
+		// the token of the share link is the authorization
+		r.Get("/shared/{token}", router.shareController.Open)
+
 		r.Route("/users", func(r chi.Router) {
 			r.Post("/", router.userController.Register)
+			r.Post("/login", router.userController.Login)
+			r.Post("/login/mfa", router.userController.LoginMFA)
+			r.Post("/login/passwordless", router.userController.RequestLogin)
+			r.Post("/login/link", router.userController.LoginWithLink)
+			r.Post("/login/code", router.userController.LoginWithCode)
+			r.Post("/token/refresh", router.userController.Refresh)
+			r.Post("/logout", router.userController.Logout)
+			r.Post("/verify-email", router.userController.VerifyEmail)
+			r.With(router.authMiddleware.Handle).Post("/verify-email/request", router.userController.RequestEmailVerification)
+			r.Post("/password/forgot", router.userController.ForgotPassword)
+			r.Post("/password/reset", router.userController.ResetPassword)
+
+			r.Route("/me/mfa", func(r chi.Router) {
+				r.Use(router.authMiddleware.Handle)
+
+				r.Post("/", router.mfaController.Enroll)
+				r.Post("/confirm", router.mfaController.Confirm)
+				r.Post("/disable", router.mfaController.Disable)
+			})
 		})
 
-		r.Route("/projects", func(r chi.Router) {
-			r.Post("/", router.projectController.Create)
+		r.Post("/invitations/decline", router.memberController.Decline)
+
+		r.Route("/projects/{projectID}/auth", func(r chi.Router) {
+			r.Post("/signup", router.endUserController.SignUp)
+			r.Post("/login", router.endUserController.Login)
+			r.Post("/logout", router.endUserController.Logout)
+			r.Post("/anonymous", router.endUserController.SignInAnonymously)
+			r.With(router.authMiddleware.Handle).Post("/link", router.endUserController.Link)
+
+			r.With(router.authMiddleware.Handle).Get("/me", router.endUserController.Profile)
+			r.With(router.authMiddleware.Handle).Patch("/me", router.endUserController.UpdateProfile)
+
+			// anonymous end users that start a sign in are linked to the account
+			r.With(router.optionalAuthMiddleware.Handle).Post("/oidc/{provider}/start", router.ssoController.Start)
+			r.Post("/oidc/{provider}/callback", router.ssoController.Callback)
 		})
 
-		r.Route("/collections", func(r chi.Router) {
-			r.Post("/", router.collectionController.Create)
+		r.Group(func(r chi.Router) {
+			r.Use(router.authMiddleware.Handle)
+
+			r.Route("/projects", func(r chi.Router) {
+				r.Post("/", router.projectController.Create)
+
+				r.Route("/{projectID}/keys", func(r chi.Router) {
+					r.Post("/", router.apiKeyController.Create)
+					r.Get("/", router.apiKeyController.List)
+					r.Post("/{keyID}/rotate", router.apiKeyController.Rotate)
+					r.Delete("/{keyID}", router.apiKeyController.Revoke)
+				})
+
+				r.Route("/{projectID}/members", func(r chi.Router) {
+					r.Get("/", router.memberController.List)
+					r.Patch("/{userID}", router.memberController.UpdateRole)
+					r.Delete("/{userID}", router.memberController.Remove)
+				})
+
+				r.Post("/{projectID}/invitations", router.memberController.Invite)
+
+				r.Route("/{projectID}/teams", func(r chi.Router) {
+					r.Post("/", router.teamController.Create)
+					r.Get("/", router.teamController.List)
+					r.Delete("/{teamID}", router.teamController.Delete)
+					r.Get("/{teamID}/members", router.teamController.ListMembers)
+					r.Put("/{teamID}/members/{userID}", router.teamController.AddMember)
+					r.Delete("/{teamID}/members/{userID}", router.teamController.RemoveMember)
+				})
+
+				r.Route("/{projectID}/identity-providers", func(r chi.Router) {
+					r.Post("/", router.ssoController.CreateProvider)
+					r.Get("/", router.ssoController.ListProviders)
+					r.Delete("/{provider}", router.ssoController.DeleteProvider)
+				})
+			})
+
+			r.Post("/invitations/accept", router.memberController.Accept)
+
+			r.Route("/admin", func(r chi.Router) {
+				r.Get("/security-events", router.securityController.Events)
+
+				r.Route("/users", func(r chi.Router) {
+					r.Get("/", router.adminController.ListUsers)
+					r.Get("/{userID}", router.adminController.GetUser)
+					r.Delete("/{userID}", router.adminController.DeleteUser)
+					r.Post("/{userID}/disable", router.adminController.DisableUser)
+					r.Post("/{userID}/enable", router.adminController.EnableUser)
+					r.Post("/{userID}/logout", router.adminController.LogoutUser)
+					r.Get("/{userID}/projects", router.adminController.UserProjects)
+					r.Post("/{userID}/impersonate", router.adminController.Impersonate)
+					r.Delete("/{userID}/mfa", router.mfaController.Reset)
+				})
+			})
+
+			r.Route("/collections", func(r chi.Router) {
+				r.Post("/", router.collectionController.Create)
+				r.Patch("/{collectionName}/rules", router.collectionController.UpdateRules)
+				r.Patch("/{collectionName}/attributes/{attribute}/access", router.collectionController.UpdateFieldAccess)
+			})
+
+			r.Route("/share-links", func(r chi.Router) {
+				r.Post("/", router.shareController.Create)
+				r.Get("/", router.shareController.List)
+				r.Delete("/{linkID}", router.shareController.Revoke)
+				r.Get("/{linkID}/accesses", router.shareController.Accesses)
+			})
 		})
 
-		r.Route("/queries", func(r chi.Router) {
-			r.Post("/{collectionName}", router.queryController.Create)
-			r.Patch("/{collectionName}/{id}", router.queryController.Update)
-			r.Delete("/{collectionName}/{id}", router.queryController.Delete)
-			r.Get("/{collectionName}/{id}", router.queryController.Get)
+		// the collection rules can allow anonymous callers on the documents
+		r.Group(func(r chi.Router) {
+			r.Use(router.optionalAuthMiddleware.Handle)
+
+			r.Route("/queries", func(r chi.Router) {
+				r.Get("/{collectionName}", router.queryController.List)
+				r.Post("/{collectionName}", router.queryController.Create)
+				r.Patch("/{collectionName}", router.queryController.UpdateWhere)
+				r.Delete("/{collectionName}", router.queryController.DeleteWhere)
+				r.Patch("/{collectionName}/{id}", router.queryController.Update)
+				r.Delete("/{collectionName}/{id}", router.queryController.Delete)
+				r.Get("/{collectionName}/{id}", router.queryController.Get)
+				r.Post("/{collectionName}/{id}/restore", router.queryController.Restore)
+				r.Delete("/{collectionName}/{id}/purge", router.queryController.Purge)
+				r.Get("/{collectionName}/{id}/revisions", router.queryController.Revisions)
+				r.Post("/{collectionName}/{id}/revisions/{revision}/restore", router.queryController.RestoreRevision)
+				r.Get("/{collectionName}/{id}/acl", router.aclController.List)
+				r.Put("/{collectionName}/{id}/acl/{principalID}", router.aclController.Share)
+				r.Delete("/{collectionName}/{id}/acl/{principalID}", router.aclController.Unshare)
+			})
+
+			r.Post("/batch", router.batchController.Execute)
+
+			r.Get("/realtime", router.realtimeController.Connect)
 		})
 	})
 
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// APIKeyController is the controller for the API keys of a project.
+type APIKeyController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewAPIKeyController creates a new API key controller.
+func NewAPIKeyController(app *goappbuild.App) APIKeyController {
+	return APIKeyController{
+		app: app,
+	}
+}
+
+// CreateAPIKeyRequest is the request for the Create method.
+type CreateAPIKeyRequest struct {
+	Name string `json:"name"`
+	// Scopes e.g. collections:write, documents:read or documents:read:<collection>
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is optional, keys without it never expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Validate validates the request.
+func (o *CreateAPIKeyRequest) Validate() error {
+	if o.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(o.Scopes) == 0 {
+		return errors.New("scopes are required")
+	}
+
+	return nil
+}
+
+// APIKeyResponse is an API key. The key is only returned on create and rotate.
+type APIKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(k goappbuild.APIKey, key string) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Key:        key,
+		Scopes:     k.Scopes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+// Create creates an API key
+//
+// @Summary Create an API key
+// @Description Create an API key for the project. The key is only returned once.
+// @Tags apikeys
+// @Accept json
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param body body CreateAPIKeyRequest true "The request body"
+// @Success 201 {object} APIKeyResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/keys [post]
+func (o APIKeyController) Create(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload CreateAPIKeyRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	createReq := goappbuild.CreateAPIKeyRequest{
+		ProjectID: projectID,
+		Name:      payload.Name,
+		Scopes:    payload.Scopes,
+		ExpiresAt: payload.ExpiresAt,
+	}
+
+	k, key, err := o.app.APIKeys.Create(r.Context(), createReq)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusCreated, newAPIKeyResponse(k, key))
+}
+
+// ListAPIKeysResponse is the response for the List method.
+type ListAPIKeysResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
+
+// List lists the API keys
+//
+// @Summary List API keys
+// @Description List the API keys of the project
+// @Tags apikeys
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Success 200 {object} ListAPIKeysResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/keys [get]
+func (o APIKeyController) List(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	keys, err := o.app.APIKeys.List(r.Context(), projectID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListAPIKeysResponse{
+		Keys: make([]APIKeyResponse, len(keys)),
+	}
+
+	for i := range keys {
+		ans.Keys[i] = newAPIKeyResponse(keys[i], "")
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// Rotate rotates an API key
+//
+// @Summary Rotate an API key
+// @Description Replace the key keeping its name, scopes and expiration. The old key stops working immediately.
+// @Tags apikeys
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param keyID path string true "API key ID"
+// @Success 200 {object} APIKeyResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/keys/{keyID}/rotate [post]
+func (o APIKeyController) Rotate(w http.ResponseWriter, r *http.Request) {
+	projectID, keyID, err := o.keyParams(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	k, key, err := o.app.APIKeys.Rotate(r.Context(), projectID, keyID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newAPIKeyResponse(k, key))
+}
+
+// Revoke revokes an API key
+//
+// @Summary Revoke an API key
+// @Description Revoke an API key of the project
+// @Tags apikeys
+// @Param projectID path string true "Project ID"
+// @Param keyID path string true "API key ID"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/keys/{keyID} [delete]
+func (o APIKeyController) Revoke(w http.ResponseWriter, r *http.Request) {
+	projectID, keyID, err := o.keyParams(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.APIKeys.Revoke(r.Context(), projectID, keyID); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+func (o APIKeyController) keyParams(r *http.Request) (projectID, keyID uuid.UUID, err error) {
+	projectID, err = uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	keyID, err = uuid.Parse(o.StringURLParam(r, "keyID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return projectID, keyID, nil
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+var _ restapi.Middleware = (*AuthMiddleware)(nil)
+
+var errMissingToken = errors.New("missing bearer token or api key")
+
+// AuthMiddleware authenticates the requests using the bearer access token
+// of the Authorization header or an API key and places the identity in the
+// request context. API keys are sent in the X-API-Key header or as bearer tokens.
+// The session tokens of the end users are sent as bearer tokens.
+// Optional middlewares let anonymous requests through, so that the services
+// can allow them according to the collection rules.
+type AuthMiddleware struct {
+	restapi.Controller
+
+	app      *goappbuild.App
+	optional bool
+}
+
+// NewAuthMiddleware creates a new authentication middleware.
+func NewAuthMiddleware(app *goappbuild.App) AuthMiddleware {
+	return AuthMiddleware{
+		app: app,
+	}
+}
+
+// NewOptionalAuthMiddleware creates an authentication middleware that
+// lets through the requests without credentials. Invalid credentials
+// are still rejected.
+func NewOptionalAuthMiddleware(app *goappbuild.App) AuthMiddleware {
+	return AuthMiddleware{
+		app:      app,
+		optional: true,
+	}
+}
+
+// Handle implements the restapi.Middleware interface.
+func (o AuthMiddleware) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := requestToken(r)
+
+		if !ok && o.optional {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !ok {
+			o.Error(w, r, http.StatusUnauthorized, errMissingToken)
+			return
+		}
+
+		identity, err := authenticate(r.Context(), o.app, token)
+		if err != nil {
+			o.Error(w, r, errorStatus(err), err)
+			return
+		}
+
+		ctx := goappbuild.ContextWithIdentity(r.Context(), identity)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticate returns the identity of an access token, an API key
+// or the session token of an end user
+func authenticate(ctx context.Context, app *goappbuild.App, token string) (goappbuild.Identity, error) {
+	switch {
+	case goappbuild.IsAPIKey(token):
+		return app.APIKeys.Authenticate(ctx, token)
+	case goappbuild.IsSessionToken(token):
+		return app.EndUsers.Authenticate(ctx, token)
+	default:
+		return app.Auth.Authenticate(ctx, token)
+	}
+}
+
+// requestToken returns the API key of the X-API-Key header or the bearer token
+func requestToken(r *http.Request) (string, bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, true
+	}
+
+	return bearerToken(r)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
+
+// getIdentity returns the identity placed in the context by the AuthMiddleware.
+func getIdentity(r *http.Request) (goappbuild.Identity, error) {
+	identity, ok := goappbuild.IdentityFromContext(r.Context())
+	if !ok {
+		return goappbuild.Identity{}, goappbuild.Errorf(goappbuild.EUnauthorized, "authentication required")
+	}
+
+	return identity, nil
+}
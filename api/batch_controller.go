@@ -0,0 +1,231 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// MaxBatchOperations is the maximum number of operations of a batch request.
+const MaxBatchOperations = 100
+
+// BatchController is the controller for batch requests.
+type BatchController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewBatchController creates a new batch controller.
+func NewBatchController(app *goappbuild.App) BatchController {
+	return BatchController{
+		app: app,
+	}
+}
+
+// BatchOperationRequest is a single operation of a batch request.
+//
+// The id and any of the data values can reference the document returned by
+// an earlier operation using an object in the form {"$ref": "<step>.<field>"}
+// e.g. {"$ref": "0.id"} is the id of the document created by the first operation.
+type BatchOperationRequest struct {
+	// Op is the operation type (create, update, delete, get)
+	Op string `json:"op"`
+	// Collection is the name of the collection
+	Collection string `json:"collection"`
+	// ID is the id of the document (update, delete, get)
+	ID any `json:"id,omitempty"`
+	// Data holds the document values (create, update)
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// BatchRequest is the request for the Execute method.
+type BatchRequest struct {
+	Operations []BatchOperationRequest `json:"operations"`
+}
+
+// Validate validates the request.
+func (o *BatchRequest) Validate() error {
+	if len(o.Operations) == 0 {
+		return errors.New("operations are required")
+	}
+
+	if len(o.Operations) > MaxBatchOperations {
+		return fmt.Errorf("a batch can have at most %d operations", MaxBatchOperations)
+	}
+
+	for i, op := range o.Operations {
+		if op.Collection == "" {
+			return fmt.Errorf("operation %d: collection is required", i)
+		}
+
+		switch goappbuild.BatchOpType(op.Op) {
+		case goappbuild.BatchOpCreate:
+		case goappbuild.BatchOpUpdate, goappbuild.BatchOpDelete, goappbuild.BatchOpGet:
+			if op.ID == nil {
+				return fmt.Errorf("operation %d: id is required", i)
+			}
+		default:
+			return fmt.Errorf("operation %d: invalid op %q", i, op.Op)
+		}
+
+		payload := CreatePayload(op.Data)
+		if err := payload.Validate(); err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (o *BatchRequest) toOperations() ([]goappbuild.BatchOperation, error) {
+	ans := make([]goappbuild.BatchOperation, len(o.Operations))
+
+	for i, op := range o.Operations {
+		id, err := decodeBatchRef(op.ID)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		data := make(map[string]any, len(op.Data))
+
+		for k, v := range op.Data {
+			data[k], err = decodeBatchRef(v)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+		}
+
+		ans[i] = goappbuild.BatchOperation{
+			Type:       goappbuild.BatchOpType(op.Op),
+			Collection: op.Collection,
+			ID:         id,
+			Data:       data,
+		}
+	}
+
+	return ans, nil
+}
+
+// decodeBatchRef converts {"$ref": "<step>.<field>"} objects to references.
+func decodeBatchRef(v any) (any, error) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return v, nil
+	}
+
+	raw, ok := m["$ref"]
+	if !ok {
+		return v, nil
+	}
+
+	s, ok := raw.(string)
+	if !ok {
+		return nil, errors.New("$ref must be a string")
+	}
+
+	return goappbuild.ParseBatchRef(s)
+}
+
+// BatchOperationResponse is the result of a single operation.
+type BatchOperationResponse struct {
+	Op         string               `json:"op"`
+	Collection string               `json:"collection"`
+	Document   *goappbuild.Document `json:"document,omitempty"`
+}
+
+// BatchResponse is the response for the Execute method.
+type BatchResponse struct {
+	Results []BatchOperationResponse `json:"results"`
+}
+
+// BatchErrorResponse is the response when an operation of the batch fails.
+type BatchErrorResponse struct {
+	restapi.ErrorResponse
+	// FailedStep is the index of the failed operation.
+	FailedStep int `json:"failed_step"`
+}
+
+// Execute executes a batch of operations atomically
+//
+// @Summary Execute a batch of operations
+// @Description Executes the operations in order in a single transaction. If an operation fails everything is rolled back.
+// @Tags Queries
+// @Accept json
+// @Produce json
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Param body body BatchRequest true "The request body"
+// @Success 200 {object} BatchResponse
+// @Failure 400 {object} BatchErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} BatchErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/batch [post]
+func (o BatchController) Execute(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	var payload BatchRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	ops, err := payload.toOperations()
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	results, err := o.app.Queries.Batch(r.Context(), projectID, ops)
+	if err != nil {
+		var batchErr *goappbuild.BatchError
+		if !errors.As(err, &batchErr) {
+			o.Error(w, r, errorStatus(err), err)
+
+			return
+		}
+
+		code := errorStatus(batchErr)
+
+		resp := BatchErrorResponse{
+			ErrorResponse: restapi.ErrorResponse{
+				StatusCode: code,
+				ErrorMsg:   batchErr.Error(),
+			},
+			FailedStep: batchErr.Step,
+		}
+
+		o.Success(w, r, code, resp)
+
+		return
+	}
+
+	ans := BatchResponse{
+		Results: make([]BatchOperationResponse, len(results)),
+	}
+
+	for i := range results {
+		ans.Results[i] = BatchOperationResponse{
+			Op:         string(results[i].Type),
+			Collection: results[i].Collection,
+		}
+
+		if results[i].Document.Values != nil {
+			ans.Results[i].Document = &results[i].Document
+		}
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
@@ -0,0 +1,126 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/api"
+)
+
+// batchQueries is a query service that records the batches it executes
+type batchQueries struct {
+	goappbuild.QueryService
+
+	ops     []goappbuild.BatchOperation
+	results []goappbuild.BatchResult
+	err     error
+}
+
+func (o *batchQueries) Batch(_ context.Context, _ uuid.UUID, ops []goappbuild.BatchOperation) ([]goappbuild.BatchResult, error) {
+	o.ops = ops
+
+	return o.results, o.err
+}
+
+func executeBatch(t *testing.T, queries *batchQueries, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := getHTTPRequest(context.Background(), t, http.MethodPost, "/api/v1/batch", payload)
+	req.Header.Set("projectID", uuid.NewString())
+
+	rr := httptest.NewRecorder()
+
+	bc := api.NewBatchController(&goappbuild.App{Queries: queries})
+	http.HandlerFunc(bc.Execute).ServeHTTP(rr, req)
+
+	return rr
+}
+
+func Test_BatchController_Execute(t *testing.T) {
+	payload := api.BatchRequest{
+		Operations: []api.BatchOperationRequest{
+			{Op: "create", Collection: "posts", Data: map[string]any{"title": "hello"}},
+			{
+				Op:         "create",
+				Collection: "comments",
+				Data: map[string]any{
+					"post_id": map[string]any{"$ref": "0.id"},
+					"body":    map[string]any{"text": "a json value"},
+				},
+			},
+			{Op: "get", Collection: "posts", ID: map[string]any{"$ref": "0.id"}},
+		},
+	}
+
+	t.Run("references are decoded", func(t *testing.T) {
+		queries := batchQueries{
+			results: []goappbuild.BatchResult{
+				{Type: goappbuild.BatchOpCreate, Collection: "posts", Document: goappbuild.Document{Values: map[string]any{"id": "1"}}},
+				{Type: goappbuild.BatchOpCreate, Collection: "comments", Document: goappbuild.Document{Values: map[string]any{"id": "2"}}},
+				{Type: goappbuild.BatchOpGet, Collection: "posts", Document: goappbuild.Document{Values: map[string]any{"id": "1"}}},
+			},
+		}
+
+		rr := executeBatch(t, &queries, payload)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		ref := goappbuild.BatchRef{Step: 0, Field: "id"}
+
+		require.Len(t, queries.ops, 3)
+		require.Equal(t, ref, queries.ops[1].Data["post_id"])
+		require.Equal(t, map[string]any{"text": "a json value"}, queries.ops[1].Data["body"])
+		require.Equal(t, ref, queries.ops[2].ID)
+
+		var resp struct {
+			Results []struct {
+				Op         string         `json:"op"`
+				Collection string         `json:"collection"`
+				Document   map[string]any `json:"document"`
+			} `json:"results"`
+		}
+
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.Len(t, resp.Results, 3)
+		require.Equal(t, "comments", resp.Results[1].Collection)
+		require.Equal(t, "2", resp.Results[1].Document["id"])
+	})
+
+	t.Run("the failed step is returned", func(t *testing.T) {
+		queries := batchQueries{
+			err: &goappbuild.BatchError{
+				Step: 2,
+				Err:  goappbuild.Errorf(goappbuild.ENotFound, "document not found"),
+			},
+		}
+
+		rr := executeBatch(t, &queries, payload)
+		require.Equal(t, http.StatusNotFound, rr.Code)
+
+		var resp api.BatchErrorResponse
+
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		require.Equal(t, 2, resp.FailedStep)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("invalid references are rejected", func(t *testing.T) {
+		queries := batchQueries{}
+
+		invalid := api.BatchRequest{
+			Operations: []api.BatchOperationRequest{
+				{Op: "get", Collection: "posts", ID: map[string]any{"$ref": "id"}},
+			},
+		}
+
+		rr := executeBatch(t, &queries, invalid)
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Nil(t, queries.ops)
+	})
+}
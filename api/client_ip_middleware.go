@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+var _ restapi.Middleware = (*ClientIPMiddleware)(nil)
+
+// ClientIPMiddleware places the address of the client in the request context,
+// the logins are limited per address. The X-Forwarded-For and X-Real-IP headers
+// are only trusted behind a proxy, otherwise the clients could pick any address.
+type ClientIPMiddleware struct {
+	trustProxy bool
+}
+
+// NewClientIPMiddleware creates a new client address middleware.
+func NewClientIPMiddleware(trustProxy bool) ClientIPMiddleware {
+	return ClientIPMiddleware{
+		trustProxy: trustProxy,
+	}
+}
+
+// Handle implements the restapi.Middleware interface.
+func (o ClientIPMiddleware) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := goappbuild.ContextWithClientIP(r.Context(), o.clientIP(r))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (o ClientIPMiddleware) clientIP(r *http.Request) string {
+	if o.trustProxy {
+		// the proxy appends the address it received the request from
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			addrs := strings.Split(fwd, ",")
+
+			if ip := net.ParseIP(strings.TrimSpace(addrs[len(addrs)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+
+		if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+			return ip.String()
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+
+	return host
+}
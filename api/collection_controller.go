@@ -1,8 +1,11 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/gosom/goappbuild"
 	"github.com/gosom/goappbuild/pkg/restapi"
@@ -24,12 +27,126 @@ func NewCollectionController(app *goappbuild.App) CollectionController {
 
 // CreateCollectionRequest is the request for the CreateCollection method.
 type CreateCollectionRequest struct {
-	Name      string
+	Name string
+	// ProjectID can be omitted when an API key is used
 	ProjectID uuid.UUID
+	// SoftDelete moves deleted documents to the trash instead of removing them
+	SoftDelete bool
+	// Retention is how long trashed documents are kept (e.g. 720h).
+	// Empty keeps them until they are purged.
+	Retention string
+	// History keeps the revisions of the documents
+	History bool
+	// IDStrategy is the strategy for the document ids:
+	// uuid (default), uuidv7, ulid, serial or string (supplied by the client)
+	IDStrategy string
+	// Rules declares who besides the project members can read, create,
+	// update and delete the documents
+	Rules CollectionRulesRequest
+	// Attributes are the attributes of the documents besides
+	// id, created_at, updated_at, deleted_at and owner_id
+	Attributes []AttributeRequest
+}
+
+// AttributeRequest declares an attribute of the documents of a collection.
+type AttributeRequest struct {
+	Name string
+	// Type is one of string, integer, numeric, float, boolean, time, uuid or json
+	Type     string
+	Required bool
+	Unique   bool
+	Index    bool
+	Access   FieldAccessRequest
+}
+
+func (o AttributeRequest) toModel() goappbuild.Attribute {
+	return goappbuild.Attribute{
+		Name:     o.Name,
+		Type:     goappbuild.AttributeType(o.Type),
+		Required: o.Required,
+		Unique:   o.Unique,
+		Index:    o.Index,
+		Access:   o.Access.toModel(),
+	}
+}
+
+// FieldAccessRequest restricts who can read and write an attribute.
+type FieldAccessRequest struct {
+	// Private attributes are never returned
+	Private bool
+	// ReadOnly attributes can only be written by API keys
+	ReadOnly bool
+	// WriteOnce attributes can only be set when the document is created
+	WriteOnce bool
+	// ReadRole is the minimum project role that can read the attribute:
+	// owner, admin, developer or viewer. Empty allows everyone that can
+	// read the document.
+	ReadRole string
+}
+
+// Validate ...
+func (o *FieldAccessRequest) Validate() error {
+	return o.toModel().Validate()
+}
+
+func (o FieldAccessRequest) toModel() goappbuild.FieldAccess {
+	return goappbuild.FieldAccess{
+		Private:   o.Private,
+		ReadOnly:  o.ReadOnly,
+		WriteOnce: o.WriteOnce,
+		ReadRole:  goappbuild.Role(o.ReadRole),
+	}
+}
+
+func newFieldAccessResponse(access goappbuild.FieldAccess) FieldAccessRequest {
+	return FieldAccessRequest{
+		Private:   access.Private,
+		ReadOnly:  access.ReadOnly,
+		WriteOnce: access.WriteOnce,
+		ReadRole:  string(access.ReadRole),
+	}
+}
+
+// CollectionRulesRequest holds the permission rules of a collection.
+// Every rule is one of members (default), public, authenticated or owner.
+type CollectionRulesRequest struct {
+	Read   string
+	Create string
+	Update string
+	Delete string
+}
+
+// Validate ...
+func (o *CollectionRulesRequest) Validate() error {
+	return o.toModel().Validate()
+}
+
+func (o CollectionRulesRequest) toModel() goappbuild.CollectionRules {
+	return goappbuild.CollectionRules{
+		Read:   goappbuild.Rule(o.Read),
+		Create: goappbuild.Rule(o.Create),
+		Update: goappbuild.Rule(o.Update),
+		Delete: goappbuild.Rule(o.Delete),
+	}
+}
+
+func newCollectionRulesResponse(rules goappbuild.CollectionRules) CollectionRulesRequest {
+	return CollectionRulesRequest{
+		Read:   string(rules.Rule(goappbuild.ActionRead)),
+		Create: string(rules.Rule(goappbuild.ActionCreate)),
+		Update: string(rules.Rule(goappbuild.ActionUpdate)),
+		Delete: string(rules.Rule(goappbuild.ActionDelete)),
+	}
 }
 
 // Validate ...
 func (o *CreateCollectionRequest) Validate() error {
+	if o.Retention != "" {
+		if _, err := time.ParseDuration(o.Retention); err != nil {
+			return fmt.Errorf("invalid retention: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -48,7 +165,10 @@ type CreateCollectionResponse struct {
 // @Param body body CreateCollectionRequest true "The request body"
 // @Success 200 {object} CreateCollectionResponse
 // @Failure 400 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
 // @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/collections [post]
 func (o CollectionController) Create(w http.ResponseWriter, r *http.Request) {
 	var payload CreateCollectionRequest
@@ -58,14 +178,32 @@ func (o CollectionController) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if identity, ok := goappbuild.IdentityFromContext(r.Context()); ok && identity.IsAPIKey() && payload.ProjectID == uuid.Nil {
+		payload.ProjectID = identity.ProjectID
+	}
+
 	cr := goappbuild.CollectionCreateRequest{
 		Name:      payload.Name,
 		ProjectID: payload.ProjectID,
+		Options: goappbuild.CollectionOptions{
+			SoftDelete: payload.SoftDelete,
+			History:    payload.History,
+			IDStrategy: goappbuild.IDStrategy(payload.IDStrategy),
+			Rules:      payload.Rules.toModel(),
+		},
+	}
+
+	for _, attr := range payload.Attributes {
+		cr.Attributes = append(cr.Attributes, attr.toModel())
+	}
+
+	if payload.Retention != "" {
+		cr.Options.Retention, _ = time.ParseDuration(payload.Retention)
 	}
 
 	c, err := o.app.Collections.Create(r.Context(), cr)
 	if err != nil {
-		o.Error(w, r, http.StatusInternalServerError, err)
+		o.Error(w, r, errorStatus(err), err)
 		return
 	}
 
@@ -75,3 +213,101 @@ func (o CollectionController) Create(w http.ResponseWriter, r *http.Request) {
 
 	o.Success(w, r, http.StatusOK, ans)
 }
+
+// UpdateRules changes the permission rules of a collection
+//
+// @Summary Update the rules of a collection
+// @Description Changes who besides the project members can access the documents of the collection
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Param body body CollectionRulesRequest true "The request body"
+// @Success 200 {object} CollectionRulesRequest
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/collections/{collectionName}/rules [patch]
+func (o CollectionController) UpdateRules(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload CollectionRulesRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.UpdateRulesRequest{
+		ProjectID:  projectID,
+		Collection: chi.URLParam(r, "collectionName"),
+		Rules:      payload.toModel(),
+	}
+
+	c, err := o.app.Collections.UpdateRules(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newCollectionRulesResponse(c.Options.Rules))
+}
+
+// UpdateFieldAccess changes who can read and write an attribute
+//
+// @Summary Update the access of an attribute
+// @Description Changes whether an attribute of the documents is private, read only, write once or readable by a minimum role only
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param attribute path string true "Attribute Name"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Param body body FieldAccessRequest true "The request body"
+// @Success 200 {object} FieldAccessRequest
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/collections/{collectionName}/attributes/{attribute}/access [patch]
+func (o CollectionController) UpdateFieldAccess(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload FieldAccessRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.UpdateFieldAccessRequest{
+		ProjectID:  projectID,
+		Collection: chi.URLParam(r, "collectionName"),
+		Attribute:  chi.URLParam(r, "attribute"),
+		Access:     payload.toModel(),
+	}
+
+	c, err := o.app.Collections.UpdateFieldAccess(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newFieldAccessResponse(c.Attributes[req.Attribute].Access))
+}
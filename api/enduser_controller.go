@@ -0,0 +1,366 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// EndUserController is the controller for the authentication of the end
+// users of the apps built on a project.
+type EndUserController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewEndUserController creates a new end user controller.
+func NewEndUserController(app *goappbuild.App) EndUserController {
+	return EndUserController{
+		app: app,
+	}
+}
+
+// EndUserSignUpRequest is the request for the SignUp method.
+type EndUserSignUpRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name"`
+}
+
+// Validate validates the request.
+func (o *EndUserSignUpRequest) Validate() error {
+	if o.Email == "" || o.Password == "" {
+		return errors.New("email and password are required")
+	}
+
+	return nil
+}
+
+// EndUserResponse is the profile of an end user.
+type EndUserResponse struct {
+	ID        uuid.UUID      `json:"id"`
+	Email     string         `json:"email"`
+	Name      string         `json:"name"`
+	Metadata  map[string]any `json:"metadata"`
+	Anonymous bool           `json:"anonymous"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func newEndUserResponse(u goappbuild.EndUser) EndUserResponse {
+	ans := EndUserResponse{
+		ID:        u.ID,
+		Email:     u.Email,
+		Name:      u.Name,
+		Metadata:  u.Metadata,
+		Anonymous: u.Anonymous,
+		CreatedAt: u.CreatedAt,
+	}
+
+	if ans.Metadata == nil {
+		ans.Metadata = map[string]any{}
+	}
+
+	return ans
+}
+
+// SessionResponse is the response of the SignUp and Login methods.
+type SessionResponse struct {
+	User      EndUserResponse `json:"user"`
+	Token     string          `json:"token"`
+	TokenType string          `json:"token_type"`
+	// ExpiresIn is the lifetime of the session in seconds
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+func newSessionResponse(s goappbuild.EndUserSession) SessionResponse {
+	return SessionResponse{
+		User:      newEndUserResponse(s.User),
+		Token:     s.Token,
+		TokenType: "Bearer",
+		ExpiresIn: int64(time.Until(s.ExpiresAt).Seconds()),
+	}
+}
+
+// SignUp signs up an end user
+//
+// @Summary Sign up an end user
+// @Description Create an end user of the project and start a session
+// @Tags end users
+// @Accept json
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param body body EndUserSignUpRequest true "The request body"
+// @Success 200 {object} SessionResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/projects/{projectID}/auth/signup [post]
+func (o EndUserController) SignUp(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload EndUserSignUpRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.EndUserSignUpRequest{
+		ProjectID: projectID,
+		Email:     payload.Email,
+		Password:  payload.Password,
+		Name:      payload.Name,
+	}
+
+	session, err := o.app.EndUsers.SignUp(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newSessionResponse(session))
+}
+
+// Login logs in an end user
+//
+// @Summary Log in an end user
+// @Description Authenticate an end user of the project with email and password and start a session
+// @Tags end users
+// @Accept json
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param body body LoginRequest true "The request body"
+// @Success 200 {object} SessionResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 429 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/projects/{projectID}/auth/login [post]
+func (o EndUserController) Login(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload LoginRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.EndUserLoginRequest{
+		ProjectID: projectID,
+		Email:     payload.Email,
+		Password:  payload.Password,
+	}
+
+	session, err := o.app.EndUsers.Login(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newSessionResponse(session))
+}
+
+// SignInAnonymously signs in an anonymous end user
+//
+// @Summary Sign in anonymously
+// @Description Create an anonymous end user of the project and start a session. The anonymous end user can be linked to an account later.
+// @Tags end users
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Success 200 {object} SessionResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/projects/{projectID}/auth/anonymous [post]
+func (o EndUserController) SignInAnonymously(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	session, err := o.app.EndUsers.SignInAnonymously(r.Context(), projectID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newSessionResponse(session))
+}
+
+// Link links an anonymous end user to an account
+//
+// @Summary Link an anonymous end user
+// @Description Link the authenticated anonymous end user to an account with an email and a password. A new email creates the account, the email of an existing account requires its password. The documents of the anonymous end user are given to the account and a session of the account is started.
+// @Tags end users
+// @Accept json
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param body body EndUserSignUpRequest true "The request body"
+// @Success 200 {object} SessionResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 429 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/auth/link [post]
+func (o EndUserController) Link(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload EndUserSignUpRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.EndUserLinkRequest{
+		ProjectID: projectID,
+		Email:     payload.Email,
+		Password:  payload.Password,
+		Name:      payload.Name,
+	}
+
+	session, err := o.app.EndUsers.Link(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newSessionResponse(session))
+}
+
+// Logout logs out an end user
+//
+// @Summary Log out an end user
+// @Description Revoke the session of the bearer token
+// @Tags end users
+// @Param projectID path string true "Project ID"
+// @Success 204
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/auth/logout [post]
+func (o EndUserController) Logout(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		o.Error(w, r, http.StatusUnauthorized, errMissingToken)
+		return
+	}
+
+	if err := o.app.EndUsers.Logout(r.Context(), token); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// Profile returns the profile of the end user
+//
+// @Summary Get the profile
+// @Description Get the profile of the authenticated end user
+// @Tags end users
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Success 200 {object} EndUserResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/auth/me [get]
+func (o EndUserController) Profile(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := o.app.EndUsers.Profile(r.Context(), projectID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newEndUserResponse(u))
+}
+
+// UpdateProfileRequest is the request for the UpdateProfile method.
+// Omitted fields are left unchanged.
+type UpdateProfileRequest struct {
+	Name     *string        `json:"name"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+// Validate validates the request.
+func (o *UpdateProfileRequest) Validate() error {
+	return nil
+}
+
+// UpdateProfile changes the profile of the end user
+//
+// @Summary Update the profile
+// @Description Change the name and the metadata of the authenticated end user
+// @Tags end users
+// @Accept json
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param body body UpdateProfileRequest true "The request body"
+// @Success 200 {object} EndUserResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/auth/me [patch]
+func (o EndUserController) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload UpdateProfileRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.UpdateProfileRequest{
+		ProjectID: projectID,
+		Name:      payload.Name,
+		Metadata:  payload.Metadata,
+	}
+
+	u, err := o.app.EndUsers.UpdateProfile(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newEndUserResponse(u))
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gosom/goappbuild"
+)
+
+// errorStatus maps the application error codes to http status codes.
+func errorStatus(err error) int {
+	switch goappbuild.ErrorCode(err) {
+	case goappbuild.EValidation:
+		return http.StatusBadRequest
+	case goappbuild.ENotFound:
+		return http.StatusNotFound
+	case goappbuild.EConflict:
+		return http.StatusConflict
+	case goappbuild.EUnauthorized:
+		return http.StatusUnauthorized
+	case goappbuild.EForbidden:
+		return http.StatusForbidden
+	case goappbuild.ETooManyRequests:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import "github.com/gosom/goappbuild"
+
+// Filter is a condition used to select documents.
+type Filter struct {
+	// Column is the name of the attribute.
+	Column string `json:"column"`
+	// Op is the operator. One of eq, neq, lt, lte, gt, gte, null,
+	// not_null, starts_with, ends_with.
+	Op string `json:"op"`
+	// Value is the value to compare with (ignored for null and not_null).
+	Value any `json:"value,omitempty"`
+}
+
+// Validate validates the filter.
+func (f *Filter) Validate() error {
+	_, err := f.apply(goappbuild.Q{})
+
+	return err
+}
+
+func (f *Filter) apply(q goappbuild.Q) (goappbuild.Q, error) {
+	return f.filter().Apply(q)
+}
+
+func (f *Filter) filter() goappbuild.Filter {
+	return goappbuild.Filter{
+		Column: f.Column,
+		Op:     f.Op,
+		Value:  f.Value,
+	}
+}
+
+// Filters is a list of filters that are combined with AND.
+type Filters []Filter
+
+// Validate validates all the filters.
+func (o Filters) Validate() error {
+	for i := range o {
+		if err := o[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply adds the filters to the query.
+func (o Filters) Apply(q goappbuild.Q) (goappbuild.Q, error) {
+	var err error
+
+	for i := range o {
+		q, err = o[i].apply(q)
+		if err != nil {
+			return q, err
+		}
+	}
+
+	return q, nil
+}
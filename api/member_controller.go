@@ -0,0 +1,340 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// MemberController is the controller for the members of a project.
+type MemberController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewMemberController creates a new member controller.
+func NewMemberController(app *goappbuild.App) MemberController {
+	return MemberController{
+		app: app,
+	}
+}
+
+// MemberResponse is a member of a project.
+type MemberResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newMemberResponse(m goappbuild.ProjectMember) MemberResponse {
+	return MemberResponse{
+		UserID:    m.UserID,
+		Email:     m.Email,
+		Role:      string(m.Role),
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// ListMembersResponse is the response for the List method.
+type ListMembersResponse struct {
+	Members []MemberResponse `json:"members"`
+}
+
+// List lists the members of a project
+//
+// @Summary List members
+// @Description List the members of the project
+// @Tags members
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Success 200 {object} ListMembersResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/members [get]
+func (o MemberController) List(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	items, err := o.app.Members.List(r.Context(), projectID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListMembersResponse{
+		Members: make([]MemberResponse, len(items)),
+	}
+
+	for i := range items {
+		ans.Members[i] = newMemberResponse(items[i])
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// UpdateMemberRequest is the request for the UpdateRole method.
+type UpdateMemberRequest struct {
+	// Role is one of owner, admin, developer, viewer
+	Role string `json:"role"`
+}
+
+// Validate validates the request.
+func (o *UpdateMemberRequest) Validate() error {
+	if o.Role == "" {
+		return errors.New("role is required")
+	}
+
+	return nil
+}
+
+// UpdateRole changes the role of a member
+//
+// @Summary Change the role of a member
+// @Description Change the role of a member. Only owners can grant or revoke the owner role.
+// @Tags members
+// @Accept json
+// @Param projectID path string true "Project ID"
+// @Param userID path string true "User ID"
+// @Param body body UpdateMemberRequest true "The request body"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/members/{userID} [patch]
+func (o MemberController) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, err := o.memberParams(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload UpdateMemberRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Members.UpdateRole(r.Context(), projectID, userID, goappbuild.Role(payload.Role)); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// Remove removes a member from a project
+//
+// @Summary Remove a member
+// @Description Remove a member from the project. Members can remove themselves.
+// @Tags members
+// @Param projectID path string true "Project ID"
+// @Param userID path string true "User ID"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/members/{userID} [delete]
+func (o MemberController) Remove(w http.ResponseWriter, r *http.Request) {
+	projectID, userID, err := o.memberParams(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Members.Remove(r.Context(), projectID, userID); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// InviteMemberRequest is the request for the Invite method.
+type InviteMemberRequest struct {
+	Email string `json:"email"`
+	// Role is one of owner, admin, developer, viewer
+	Role string `json:"role"`
+}
+
+// Validate validates the request.
+func (o *InviteMemberRequest) Validate() error {
+	if o.Email == "" || o.Role == "" {
+		return errors.New("email and role are required")
+	}
+
+	return nil
+}
+
+// InvitationResponse is the response for the Invite method.
+type InvitationResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Token is used to accept or decline the invitation.
+	// It is only returned once and has to be shared with the invited user.
+	Token string `json:"token"`
+}
+
+// Invite invites a user to a project
+//
+// @Summary Invite a member
+// @Description Invite a user by email to join the project
+// @Tags members
+// @Accept json
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param body body InviteMemberRequest true "The request body"
+// @Success 201 {object} InvitationResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/invitations [post]
+func (o MemberController) Invite(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload InviteMemberRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	inviteReq := goappbuild.InviteMemberRequest{
+		ProjectID: projectID,
+		Email:     payload.Email,
+		Role:      goappbuild.Role(payload.Role),
+	}
+
+	inv, token, err := o.app.Members.Invite(r.Context(), inviteReq)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := InvitationResponse{
+		ID:        inv.ID,
+		Email:     inv.Email,
+		Role:      string(inv.Role),
+		ExpiresAt: inv.ExpiresAt,
+		Token:     token,
+	}
+
+	o.Success(w, r, http.StatusCreated, ans)
+}
+
+// InvitationTokenRequest is the request for the Accept and Decline methods.
+type InvitationTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// Validate validates the request.
+func (o *InvitationTokenRequest) Validate() error {
+	if o.Token == "" {
+		return errors.New("token is required")
+	}
+
+	return nil
+}
+
+// Accept accepts an invitation
+//
+// @Summary Accept an invitation
+// @Description Join the project of the invitation. The invitation must have been sent to the email of the authenticated user.
+// @Tags members
+// @Accept json
+// @Produce json
+// @Param body body InvitationTokenRequest true "The request body"
+// @Success 200 {object} MemberResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/invitations/accept [post]
+func (o MemberController) Accept(w http.ResponseWriter, r *http.Request) {
+	var payload InvitationTokenRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	m, err := o.app.Members.Accept(r.Context(), payload.Token)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newMemberResponse(m))
+}
+
+// Decline declines an invitation
+//
+// @Summary Decline an invitation
+// @Description Decline an invitation
+// @Tags members
+// @Accept json
+// @Param body body InvitationTokenRequest true "The request body"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/invitations/decline [post]
+func (o MemberController) Decline(w http.ResponseWriter, r *http.Request) {
+	var payload InvitationTokenRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Members.Decline(r.Context(), payload.Token); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+func (o MemberController) memberParams(r *http.Request) (projectID, userID uuid.UUID, err error) {
+	projectID, err = uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	userID, err = uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return projectID, userID, nil
+}
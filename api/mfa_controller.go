@@ -0,0 +1,175 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// MFAController is the controller for the second factor of the users.
+type MFAController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewMFAController creates a new mfa controller.
+func NewMFAController(app *goappbuild.App) MFAController {
+	return MFAController{
+		app: app,
+	}
+}
+
+// MFAEnrollmentResponse is the response for the Enroll method.
+type MFAEnrollmentResponse struct {
+	// Secret is the base32 secret for the apps that cannot scan the URI
+	Secret string `json:"secret"`
+	// URI is the otpauth URI, usually shown as a QR code
+	URI string `json:"uri"`
+}
+
+// Enroll starts the enrollment of a TOTP factor
+//
+// @Summary Enroll MFA
+// @Description Create a TOTP secret for an authenticator app. MFA is enabled once confirmed with a code.
+// @Tags mfa
+// @Produce json
+// @Success 200 {object} MFAEnrollmentResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/users/me/mfa [post]
+func (o MFAController) Enroll(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := o.app.MFA.Enroll(r.Context())
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := MFAEnrollmentResponse{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// MFACodeRequest is the request for the Confirm and Disable methods.
+type MFACodeRequest struct {
+	// Code is a code of the authenticator app, Disable also accepts a recovery code
+	Code string `json:"code"`
+}
+
+// Validate validates the request.
+func (o *MFACodeRequest) Validate() error {
+	if o.Code == "" {
+		return errors.New("code is required")
+	}
+
+	return nil
+}
+
+// RecoveryCodesResponse is the response for the Confirm method.
+type RecoveryCodesResponse struct {
+	// RecoveryCodes can be used once each instead of a code.
+	// They are not shown again.
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Confirm enables MFA
+//
+// @Summary Confirm MFA
+// @Description Enable MFA with a code of the authenticator app and get the recovery codes
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Param body body MFACodeRequest true "The request body"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/users/me/mfa/confirm [post]
+func (o MFAController) Confirm(w http.ResponseWriter, r *http.Request) {
+	var payload MFACodeRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	codes, err := o.app.MFA.Confirm(r.Context(), payload.Code)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable disables MFA
+//
+// @Summary Disable MFA
+// @Description Remove the TOTP factor and the recovery codes
+// @Tags mfa
+// @Accept json
+// @Param body body MFACodeRequest true "The request body"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/users/me/mfa/disable [post]
+func (o MFAController) Disable(w http.ResponseWriter, r *http.Request) {
+	var payload MFACodeRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.MFA.Disable(r.Context(), payload.Code); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// Reset resets the MFA of a user
+//
+// @Summary Reset the MFA of a user
+// @Description Remove the TOTP factor and the recovery codes of a user that lost access to them. Admins only.
+// @Tags admin
+// @Param userID path string true "User ID"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{userID}/mfa [delete]
+func (o MFAController) Reset(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.MFA.Reset(r.Context(), userID); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
@@ -22,8 +22,7 @@ func NewProjectController(app *goappbuild.App) ProjectController {
 
 // CreateProjectRequest is the request for the CreateProject method.
 type CreateProjectRequest struct {
-	UserID uuid.UUID `json:"user_id"`
-	Name   string    `json:"name"`
+	Name string `json:"name"`
 }
 
 // Validate validates the request.
@@ -47,7 +46,10 @@ type CreateProjectResponse struct {
 // @Param body body CreateProjectRequest true "The request body"
 // @Success 200 {object} CreateProjectResponse
 // @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
 // @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
 // @Router /api/v1/projects [post]
 func (o ProjectController) Create(w http.ResponseWriter, r *http.Request) {
 	var payload CreateProjectRequest
@@ -57,14 +59,20 @@ func (o ProjectController) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	identity, err := getIdentity(r)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
 	createReq := goappbuild.CreateProjectRequest{
-		UserID: payload.UserID,
+		UserID: identity.UserID,
 		Name:   payload.Name,
 	}
 
 	project, err := o.app.Projects.Create(r.Context(), createReq)
 	if err != nil {
-		o.Error(w, r, http.StatusInternalServerError, err)
+		o.Error(w, r, errorStatus(err), err)
 		return
 	}
 
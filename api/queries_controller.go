@@ -1,9 +1,12 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -32,38 +35,63 @@ func NewQueryController(app *goappbuild.App) QueryController {
 // @Produce json
 // @Param collectionName path string true "Collection Name"
 // @Param id path string true "Document ID"
-// @Param projectID header string true "Project ID"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Param include_deleted query bool false "Include trashed documents (project members only)"
+// @Param as_of query string false "RFC3339 time, returns the document as it was at that time (collections with history)"
 // @Success 200 {object} map[string]any
 // @Failure 400 {object} restapi.ErrorResponse
 // @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
 // @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/queries/{collectionName}/{id} [get]
 func (o QueryController) Get(w http.ResponseWriter, r *http.Request) {
-	projectID, err := o.getProjectID(r)
+	projectID, err := getProjectID(r)
 	if err != nil {
 		o.Error(w, r, http.StatusBadRequest, err)
 
 		return
 	}
 
-	project, err := o.app.Projects.Get(r.Context(), projectID)
-	if err != nil {
-		o.Error(w, r, http.StatusInternalServerError, fmt.Errorf("error getting project: %v", err))
+	collectionName := o.StringURLParam(r, "collectionName")
+	id := o.StringURLParam(r, "id")
+
+	if asOf := o.QueryParam(r, "as_of"); asOf != "" {
+		o.getAsOf(w, r, projectID, collectionName, id, asOf)
 
 		return
 	}
 
-	collectionName := o.StringURLParam(r, "collectionName")
-	id := o.StringURLParam(r, "id")
-
 	q := goappbuild.Q{}.
-		Schema(project.Name).
 		Table(collectionName).
 		Equal("id", id)
 
-	doc, err := o.app.Queries.Get(r.Context(), q)
+	if o.QueryParam(r, "include_deleted") == "true" {
+		q = q.IncludeDeleted()
+	}
+
+	doc, err := o.app.Queries.Get(r.Context(), projectID, q)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, doc)
+}
+
+func (o QueryController) getAsOf(w http.ResponseWriter, r *http.Request, projectID uuid.UUID, collectionName, id, asOf string) {
+	at, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, fmt.Errorf("invalid as_of: %v", err))
+
+		return
+	}
+
+	doc, err := o.app.Queries.GetAsOf(r.Context(), projectID, collectionName, id, at)
 	if err != nil {
-		o.Error(w, r, http.StatusInternalServerError, err)
+		o.Error(w, r, errorStatus(err), err)
 
 		return
 	}
@@ -71,10 +99,134 @@ func (o QueryController) Get(w http.ResponseWriter, r *http.Request) {
 	o.Success(w, r, http.StatusOK, doc)
 }
 
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// List returns the documents matching a filter
+//
+// @Summary List documents
+// @Description List the documents matching the filter
+// @Tags Queries
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Param where query string false "JSON encoded list of filters (same format as the where of the bulk operations)"
+// @Param order_by query string false "Comma separated columns, prefix with - for descending order"
+// @Param limit query int false "Maximum number of documents (default 100, max 1000)"
+// @Param offset query int false "Number of documents to skip"
+// @Param include_deleted query bool false "Include trashed documents (project members only)"
+// @Param only_deleted query bool false "Only trashed documents (project members only)"
+// @Success 200 {array} map[string]any
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName} [get]
+func (o QueryController) List(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	q, err := o.listQuery(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	docs, err := o.app.Queries.List(r.Context(), projectID, q)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, docs)
+}
+
+func (o QueryController) listQuery(r *http.Request) (goappbuild.Q, error) {
+	q := goappbuild.Q{}.Table(o.StringURLParam(r, "collectionName"))
+
+	if where := o.QueryParam(r, "where"); where != "" {
+		var filters Filters
+		if err := json.Unmarshal([]byte(where), &filters); err != nil {
+			return q, fmt.Errorf("invalid where: %w", err)
+		}
+
+		if err := filters.Validate(); err != nil {
+			return q, err
+		}
+
+		var err error
+
+		q, err = filters.Apply(q)
+		if err != nil {
+			return q, err
+		}
+	}
+
+	if orderBy := o.QueryParam(r, "order_by"); orderBy != "" {
+		for _, col := range strings.Split(orderBy, ",") {
+			if desc := strings.TrimPrefix(col, "-"); desc != col {
+				q = q.OrderDesc(desc)
+			} else {
+				q = q.OrderAsc(col)
+			}
+		}
+	}
+
+	limit, err := o.intQueryParam(r, "limit", defaultListLimit)
+	if err != nil {
+		return q, err
+	}
+
+	if limit <= 0 || limit > maxListLimit {
+		return q, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+	}
+
+	offset, err := o.intQueryParam(r, "offset", 0)
+	if err != nil {
+		return q, err
+	}
+
+	q = q.Limit(limit).Offset(offset)
+
+	switch {
+	case o.QueryParam(r, "only_deleted") == "true":
+		q = q.OnlyDeleted()
+	case o.QueryParam(r, "include_deleted") == "true":
+		q = q.IncludeDeleted()
+	}
+
+	return q, nil
+}
+
+func (o QueryController) intQueryParam(r *http.Request, key string, def int) (int, error) {
+	v := o.QueryParam(r, key)
+	if v == "" {
+		return def, nil
+	}
+
+	ans, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return ans, nil
+}
+
 type CreatePayload map[string]any
 
 func (o *CreatePayload) Validate() error {
-	reserved := []string{"id", "created_at", "updated_at"}
+	reserved := []string{"created_at", "updated_at", "deleted_at"}
 	for _, r := range reserved {
 		if _, ok := (*o)[r]; ok {
 			return fmt.Errorf("reserved field: %s", r)
@@ -87,20 +239,23 @@ func (o *CreatePayload) Validate() error {
 // Create creates a new document
 //
 // @Summary Create a document
-// @Description Create a document
+// @Description Create a document. The id is generated by the server unless the collection uses client supplied string ids.
 // @Tags Queries
 // @Accept json
 // @Produce json
 // @Param collectionName path string true "Collection Name"
-// @Param projectID header string true "Project ID"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
 // @Param body body CreatePayload true "Document"
 // @Success 201 {object} map[string]any
 // @Failure 400 {object} restapi.ErrorResponse
 // @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
 // @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/queries/{collectionName} [post]
 func (o QueryController) Create(w http.ResponseWriter, r *http.Request) {
-	projectID, err := o.getProjectID(r)
+	projectID, err := getProjectID(r)
 	if err != nil {
 		o.Error(w, r, http.StatusBadRequest, err)
 
@@ -117,13 +272,9 @@ func (o QueryController) Create(w http.ResponseWriter, r *http.Request) {
 
 	collecitonName := o.StringURLParam(r, "collectionName")
 
-	payload["id"] = uuid.New()
-	payload["created_at"] = time.Now().UTC()
-	payload["updated_at"] = time.Now().UTC()
-
 	ans, err := o.app.Queries.Create(r.Context(), projectID, collecitonName, payload)
 	if err != nil {
-		o.Error(w, r, http.StatusInternalServerError, err)
+		o.Error(w, r, errorStatus(err), err)
 
 		return
 	}
@@ -140,15 +291,18 @@ func (o QueryController) Create(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param collectionName path string true "Collection Name"
 // @Param id path string true "Document ID"
-// @Param projectID header string true "Project ID"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
 // @Param body body CreatePayload true "Document"
 // @Success 200 {object} map[string]any
 // @Failure 400 {object} restapi.ErrorResponse
 // @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
 // @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/queries/{collectionName}/{id} [patch]
 func (o QueryController) Update(w http.ResponseWriter, r *http.Request) {
-	projectID, err := o.getProjectID(r)
+	projectID, err := getProjectID(r)
 	if err != nil {
 		o.Error(w, r, http.StatusBadRequest, err)
 
@@ -165,26 +319,17 @@ func (o QueryController) Update(w http.ResponseWriter, r *http.Request) {
 
 	collectionName := o.StringURLParam(r, "collectionName")
 
-	payload["updated_at"] = time.Now().UTC()
-
-	sid := o.StringURLParam(r, "id")
-	if sid == "" {
+	id := o.StringURLParam(r, "id")
+	if id == "" {
 		o.Error(w, r, http.StatusBadRequest, errors.New("id is required"))
 
 		return
 
 	}
 
-	id, err := uuid.Parse(sid)
-	if err != nil {
-		o.Error(w, r, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
-
-		return
-	}
-
 	ans, err := o.app.Queries.Update(r.Context(), projectID, collectionName, id, payload)
 	if err != nil {
-		o.Error(w, r, http.StatusInternalServerError, err)
+		o.Error(w, r, errorStatus(err), err)
 
 		return
 	}
@@ -201,31 +346,277 @@ func (o QueryController) Update(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param collectionName path string true "Collection Name"
 // @Param id path string true "Document ID"
-// @Param projectID header string true "Project ID"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
 // @Success 204 "No Content"
 // @Failure 400 {object} restapi.ErrorResponse
 // @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
 // @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
 // @Router /api/v1/queries/{collectionName}/{id} [delete]
 func (o QueryController) Delete(w http.ResponseWriter, r *http.Request) {
-	id, err := uuid.Parse(o.StringURLParam(r, "id"))
+	id := o.StringURLParam(r, "id")
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	collectionName := o.StringURLParam(r, "collectionName")
+
+	if err := o.app.Queries.Delete(r.Context(), projectID, collectionName, id); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// BulkUpdateRequest is the request for updating all the documents matching a filter
+type BulkUpdateRequest struct {
+	// Where holds the filters selecting the documents
+	Where Filters `json:"where"`
+	// Data holds the values to set
+	Data map[string]any `json:"data"`
+	// Force allows updating without a filter
+	Force bool `json:"force"`
+	// DryRun only counts the matching documents
+	DryRun bool `json:"dry_run"`
+	// MaxAffected lowers the maximum number of documents that may be affected
+	MaxAffected int `json:"max_affected"`
+	// ReturnIDs returns the ids of the affected documents
+	ReturnIDs bool `json:"return_ids"`
+}
+
+// Validate validates the request.
+func (o *BulkUpdateRequest) Validate() error {
+	if len(o.Data) == 0 {
+		return errors.New("data is required")
+	}
+
+	payload := CreatePayload(o.Data)
+	if err := payload.Validate(); err != nil {
+		return err
+	}
+
+	return o.Where.Validate()
+}
+
+// BulkDeleteRequest is the request for deleting all the documents matching a filter
+type BulkDeleteRequest struct {
+	// Where holds the filters selecting the documents
+	Where Filters `json:"where"`
+	// Force allows deleting without a filter
+	Force bool `json:"force"`
+	// DryRun only counts the matching documents
+	DryRun bool `json:"dry_run"`
+	// MaxAffected lowers the maximum number of documents that may be affected
+	MaxAffected int `json:"max_affected"`
+	// ReturnIDs returns the ids of the affected documents
+	ReturnIDs bool `json:"return_ids"`
+}
+
+// Validate validates the request.
+func (o *BulkDeleteRequest) Validate() error {
+	return o.Where.Validate()
+}
+
+// BulkResponse is the response of the set based operations
+type BulkResponse struct {
+	// Affected is the number of affected (or matched when dry_run) documents
+	Affected int64 `json:"affected"`
+	// DryRun indicates that nothing was modified
+	DryRun bool `json:"dry_run"`
+	// IDs holds the ids of the affected documents if requested
+	IDs []any `json:"ids,omitempty"`
+}
+
+// UpdateWhere updates all the documents matching a filter
+//
+// @Summary Update documents by query
+// @Description Update all the documents matching the filter. An empty filter is refused unless force is set.
+// @Tags Queries
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Param body body BulkUpdateRequest true "The request body"
+// @Success 200 {object} BulkResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName} [patch]
+func (o QueryController) UpdateWhere(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
 	if err != nil {
-		o.Error(w, r, http.StatusBadRequest, fmt.Errorf("invalid id: %v", err))
+		o.Error(w, r, http.StatusBadRequest, err)
 
 		return
 	}
 
-	projectID, err := o.getProjectID(r)
+	var payload BulkUpdateRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	q, err := payload.Where.Apply(goappbuild.Q{}.Table(o.StringURLParam(r, "collectionName")))
 	if err != nil {
 		o.Error(w, r, http.StatusBadRequest, err)
 
 		return
 	}
 
-	collectionName := o.StringURLParam(r, "collectionName")
+	opts := goappbuild.BulkOptions{
+		Force:       payload.Force,
+		DryRun:      payload.DryRun,
+		MaxAffected: payload.MaxAffected,
+		ReturnIDs:   payload.ReturnIDs,
+	}
 
-	if err := o.app.Queries.Delete(r.Context(), projectID, collectionName, id); err != nil {
-		o.Error(w, r, http.StatusInternalServerError, err)
+	res, err := o.app.Queries.UpdateWhere(r.Context(), projectID, q, payload.Data, opts)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newBulkResponse(res))
+}
+
+// DeleteWhere deletes all the documents matching a filter
+//
+// @Summary Delete documents by query
+// @Description Delete all the documents matching the filter. An empty filter is refused unless force is set.
+// @Tags Queries
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Param body body BulkDeleteRequest true "The request body"
+// @Success 200 {object} BulkResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName} [delete]
+func (o QueryController) DeleteWhere(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	var payload BulkDeleteRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	q, err := payload.Where.Apply(goappbuild.Q{}.Table(o.StringURLParam(r, "collectionName")))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	opts := goappbuild.BulkOptions{
+		Force:       payload.Force,
+		DryRun:      payload.DryRun,
+		MaxAffected: payload.MaxAffected,
+		ReturnIDs:   payload.ReturnIDs,
+	}
+
+	res, err := o.app.Queries.DeleteWhere(r.Context(), projectID, q, opts)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newBulkResponse(res))
+}
+
+// Restore restores a trashed document
+//
+// @Summary Restore a document
+// @Description Move a document of a soft delete collection out of the trash
+// @Tags Queries
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param id path string true "Document ID"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName}/{id}/restore [post]
+func (o QueryController) Restore(w http.ResponseWriter, r *http.Request) {
+	id := o.StringURLParam(r, "id")
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	doc, err := o.app.Queries.Restore(r.Context(), projectID, o.StringURLParam(r, "collectionName"), id)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, doc)
+}
+
+// Purge permanently deletes a trashed document
+//
+// @Summary Purge a document
+// @Description Permanently delete a trashed document of a soft delete collection
+// @Tags Queries
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param id path string true "Document ID"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Success 204 "No Content"
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName}/{id}/purge [delete]
+func (o QueryController) Purge(w http.ResponseWriter, r *http.Request) {
+	id := o.StringURLParam(r, "id")
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if err := o.app.Queries.Purge(r.Context(), projectID, o.StringURLParam(r, "collectionName"), id); err != nil {
+		o.Error(w, r, errorStatus(err), err)
 
 		return
 	}
@@ -233,10 +624,137 @@ func (o QueryController) Delete(w http.ResponseWriter, r *http.Request) {
 	o.Success(w, r, http.StatusNoContent, nil)
 }
 
-func (o QueryController) getProjectID(r *http.Request) (uuid.UUID, error) {
-	sprojectID := o.HeaderKey(r, "projectID")
+// RevisionResponse is a revision of a document
+type RevisionResponse struct {
+	Revision  int64          `json:"revision"`
+	Operation string         `json:"operation"`
+	ChangedAt time.Time      `json:"changed_at"`
+	Data      map[string]any `json:"data"`
+}
+
+// Revisions returns the revisions of a document
+//
+// @Summary List the revisions of a document
+// @Description List the revisions of a document of a collection with history, newest first
+// @Tags Queries
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param id path string true "Document ID"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Success 200 {array} RevisionResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName}/{id}/revisions [get]
+func (o QueryController) Revisions(w http.ResponseWriter, r *http.Request) {
+	id := o.StringURLParam(r, "id")
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	revisions, err := o.app.Queries.Revisions(r.Context(), projectID, o.StringURLParam(r, "collectionName"), id)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+
+		return
+	}
+
+	ans := make([]RevisionResponse, len(revisions))
+	for i := range revisions {
+		ans[i] = RevisionResponse{
+			Revision:  revisions[i].Revision,
+			Operation: revisions[i].Operation,
+			ChangedAt: revisions[i].ChangedAt,
+			Data:      revisions[i].Data,
+		}
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// RestoreRevision restores a document to a revision
+//
+// @Summary Restore a revision
+// @Description Restore a document to the state of a revision. Deleted documents are recreated.
+// @Tags Queries
+// @Accept json
+// @Produce json
+// @Param collectionName path string true "Collection Name"
+// @Param id path string true "Document ID"
+// @Param revision path int true "Revision"
+// @Param projectID header string false "Project ID (implied by an API key or an end user session)"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/queries/{collectionName}/{id}/revisions/{revision}/restore [post]
+func (o QueryController) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	id := o.StringURLParam(r, "id")
+
+	revision, err := strconv.ParseInt(o.StringURLParam(r, "revision"), 10, 64)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, fmt.Errorf("invalid revision: %v", err))
+
+		return
+	}
+
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+
+		return
+	}
+
+	doc, err := o.app.Queries.RestoreRevision(r.Context(), projectID, o.StringURLParam(r, "collectionName"), id, revision)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, doc)
+}
+
+func newBulkResponse(res goappbuild.BulkResult) BulkResponse {
+	return BulkResponse{
+		Affected: res.Affected,
+		DryRun:   res.DryRun,
+		IDs:      res.IDs,
+	}
+}
+
+// getProjectID returns the project of the request. API keys identify
+// their project on their own, users have to send the projectID header.
+func getProjectID(r *http.Request) (uuid.UUID, error) {
+	identity, _ := goappbuild.IdentityFromContext(r.Context())
+
+	return projectOf(identity, r.Header.Get("projectID"))
+}
+
+// projectOf returns the project of the API keys and the end users,
+// which the requested project must match, or the requested project
+func projectOf(identity goappbuild.Identity, sprojectID string) (uuid.UUID, error) {
+	if identity.IsAPIKey() || identity.IsEndUser() {
+		if sprojectID != "" && sprojectID != identity.ProjectID.String() {
+			return uuid.UUID{}, errors.New("projectID does not match the project of the caller")
+		}
+
+		return identity.ProjectID, nil
+	}
+
 	if sprojectID == "" {
-		return uuid.UUID{}, errors.New("missing projectID header")
+		return uuid.UUID{}, errors.New("missing projectID")
 	}
 
 	projectID, err := uuid.Parse(sprojectID)
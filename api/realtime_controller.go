@@ -0,0 +1,491 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+const (
+	// realtimeWriteWait is the time allowed to write a message
+	realtimeWriteWait = 10 * time.Second
+	// realtimePongWait is the time allowed to read the next pong
+	realtimePongWait = 60 * time.Second
+	// realtimePingPeriod is how often the server pings, less than the pong wait
+	realtimePingPeriod = realtimePongWait * 9 / 10
+	// realtimeReadLimit is the maximum size of a client message
+	realtimeReadLimit = 64 << 10
+	// maxRealtimeSubscriptions is the maximum number of subscriptions of a connection
+	maxRealtimeSubscriptions = 32
+	// realtimeAuthPeriod is how often the credentials of a connection are
+	// authenticated again
+	realtimeAuthPeriod = time.Minute
+)
+
+var errRealtimeRevoked = goappbuild.Errorf(goappbuild.EUnauthorized, "the credentials were revoked, authenticate again")
+
+// The types of the realtime messages
+const (
+	realtimeAuth          = "auth"
+	realtimeAuthenticated = "authenticated"
+	realtimeSubscribe     = "subscribe"
+	realtimeSubscribed    = "subscribed"
+	realtimeUnsubscribe   = "unsubscribe"
+	realtimeUnsubscribed  = "unsubscribed"
+	realtimeChange        = "change"
+	realtimeClosed        = "closed"
+	realtimeError         = "error"
+)
+
+// RealtimeController is the controller for the realtime document subscriptions.
+type RealtimeController struct {
+	restapi.Controller
+
+	app      *goappbuild.App
+	upgrader websocket.Upgrader
+}
+
+// NewRealtimeController creates a new realtime controller.
+func NewRealtimeController(app *goappbuild.App) RealtimeController {
+	return RealtimeController{
+		app: app,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			// the credentials are sent in the headers or in the auth message,
+			// never in cookies, so any origin can connect
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+	}
+}
+
+// RealtimeRequest is a message of the client.
+type RealtimeRequest struct {
+	// Type is auth, subscribe or unsubscribe
+	Type string `json:"type"`
+	// ID is the id the client gives to a subscription
+	ID string `json:"id,omitempty"`
+	// Token is the access token, API key or session token of the auth message
+	Token string `json:"token,omitempty"`
+	// ProjectID is the project of the subscription, implied by API keys
+	// and end user sessions
+	ProjectID  string              `json:"project_id,omitempty"`
+	Collection string              `json:"collection,omitempty"`
+	DocumentID string              `json:"document_id,omitempty"`
+	Filters    []goappbuild.Filter `json:"filters,omitempty"`
+}
+
+// RealtimeResponse is a message of the server.
+type RealtimeResponse struct {
+	// Type is authenticated, subscribed, unsubscribed, change, closed or error
+	Type string `json:"type"`
+	// ID is the id of the subscription of the message
+	ID     string          `json:"id,omitempty"`
+	Change *ChangeResponse `json:"change,omitempty"`
+	// Code and Error describe why a request failed or a subscription closed
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ChangeResponse is a change of a document.
+type ChangeResponse struct {
+	Type       goappbuild.EventType `json:"type"`
+	Collection string               `json:"collection"`
+	DocumentID string               `json:"document_id"`
+	Before     *goappbuild.Document `json:"before,omitempty"`
+	After      *goappbuild.Document `json:"after,omitempty"`
+	OccurredAt time.Time            `json:"occurred_at"`
+}
+
+func newChangeResponse(c goappbuild.Change) *ChangeResponse {
+	return &ChangeResponse{
+		Type:       c.Type,
+		Collection: c.Collection,
+		DocumentID: c.DocumentID,
+		Before:     c.Before,
+		After:      c.After,
+		OccurredAt: c.OccurredAt,
+	}
+}
+
+// Connect opens a realtime connection
+//
+// @Summary Subscribe to document changes
+// @Description Upgrade to a WebSocket connection that delivers the changes of the documents as they happen.
+// @Description Clients send JSON messages: {"type":"auth","token":"..."} authenticates the connection when
+// @Description the request had no credentials, {"type":"subscribe","id":"...","project_id":"...","collection":"...",
+// @Description "document_id":"...","filters":[...]} subscribes to a collection, a single document or the documents
+// @Description matching the filters, and {"type":"unsubscribe","id":"..."} ends a subscription.
+// @Description The server answers with authenticated, subscribed, unsubscribed and error messages and delivers
+// @Description change messages. A closed message tells that a subscription ended because the caller lost access,
+// @Description its credentials expired or were revoked, or it fell behind. After the credentials expire or are
+// @Description revoked an auth message authenticates the connection again. The changes only contain the attributes
+// @Description the caller can read.
+// @Tags realtime
+// @Param projectID header string false "Project ID (implied by an API key)"
+// @Success 101
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/realtime [get]
+func (o RealtimeController) Connect(w http.ResponseWriter, r *http.Request) {
+	ws, err := o.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has written the error response
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	conn := realtimeConn{
+		app:    o.app,
+		ws:     ws,
+		out:    make(chan RealtimeResponse, 64),
+		subs:   make(map[string]goappbuild.Subscription),
+		header: r.Header.Get("projectID"),
+	}
+
+	_, conn.authenticated = goappbuild.IdentityFromContext(ctx)
+	if conn.authenticated {
+		conn.token, _ = requestToken(r)
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		conn.write(ctx, cancel)
+	}()
+
+	go func() {
+		defer wg.Done()
+		conn.watch(ctx)
+	}()
+
+	conn.read(ctx)
+
+	cancel()
+	conn.close()
+	wg.Wait()
+
+	_ = ws.Close()
+}
+
+// realtimeConn is a realtime connection. The handler goroutine reads the
+// messages of the client, a goroutine writes the messages of the server and
+// every subscription forwards its changes in its own goroutine.
+type realtimeConn struct {
+	app *goappbuild.App
+	ws  *websocket.Conn
+	out chan RealtimeResponse
+	// header is the project of the projectID header of the request
+	header        string
+	authenticated bool
+
+	mu   sync.Mutex
+	subs map[string]goappbuild.Subscription
+	wg   sync.WaitGroup
+	// token is the credential of the connection and revoked is true when it
+	// is no longer valid
+	token   string
+	revoked bool
+}
+
+// read handles the messages of the client until the connection fails
+func (o *realtimeConn) read(ctx context.Context) {
+	o.ws.SetReadLimit(realtimeReadLimit)
+	_ = o.ws.SetReadDeadline(time.Now().Add(realtimePongWait))
+
+	o.ws.SetPongHandler(func(string) error {
+		return o.ws.SetReadDeadline(time.Now().Add(realtimePongWait))
+	})
+
+	for {
+		_, data, err := o.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg RealtimeRequest
+
+		if err := json.Unmarshal(data, &msg); err != nil {
+			o.send(ctx, errorMessage("", realtimeError, goappbuild.Errorf(goappbuild.EValidation, "invalid message: %v", err)))
+			continue
+		}
+
+		switch msg.Type {
+		case realtimeAuth:
+			ctx, err = o.authenticate(ctx, msg)
+		case realtimeSubscribe:
+			err = o.subscribe(ctx, msg)
+		case realtimeUnsubscribe:
+			err = o.unsubscribe(ctx, msg)
+		default:
+			err = goappbuild.Errorf(goappbuild.EValidation, "invalid message type: %q", msg.Type)
+		}
+
+		if err != nil {
+			o.send(ctx, errorMessage(msg.ID, realtimeError, err))
+		}
+	}
+}
+
+// close ends the subscriptions and waits for their goroutines
+func (o *realtimeConn) close() {
+	o.mu.Lock()
+	for id, sub := range o.subs {
+		sub.Close()
+		delete(o.subs, id)
+	}
+	o.mu.Unlock()
+
+	o.wg.Wait()
+}
+
+// authenticate places the identity of the token in the context of the
+// subscriptions that follow. A connection can authenticate again once its
+// credentials expired or were revoked.
+func (o *realtimeConn) authenticate(ctx context.Context, msg RealtimeRequest) (context.Context, error) {
+	current, _ := goappbuild.IdentityFromContext(ctx)
+
+	o.mu.Lock()
+	renew := o.revoked || current.Expired(time.Now())
+	o.mu.Unlock()
+
+	if o.authenticated && !renew {
+		return ctx, goappbuild.Errorf(goappbuild.EConflict, "the connection is already authenticated")
+	}
+
+	identity, err := authenticate(ctx, o.app, msg.Token)
+	if err != nil {
+		return ctx, err
+	}
+
+	o.authenticated = true
+
+	o.mu.Lock()
+	o.token = msg.Token
+	o.revoked = false
+	o.mu.Unlock()
+
+	o.send(ctx, RealtimeResponse{Type: realtimeAuthenticated})
+
+	return goappbuild.ContextWithIdentity(ctx, identity), nil
+}
+
+// subscribe starts a subscription and forwards its changes
+func (o *realtimeConn) subscribe(ctx context.Context, msg RealtimeRequest) error {
+	if msg.ID == "" {
+		return goappbuild.Errorf(goappbuild.EValidation, "subscription id is required")
+	}
+
+	sproject := msg.ProjectID
+	if sproject == "" {
+		sproject = o.header
+	}
+
+	identity, _ := goappbuild.IdentityFromContext(ctx)
+
+	projectID, err := projectOf(identity, sproject)
+	if err != nil {
+		return goappbuild.Errorf(goappbuild.EValidation, "%s", err.Error())
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.revoked {
+		return errRealtimeRevoked
+	}
+
+	if _, ok := o.subs[msg.ID]; ok {
+		return goappbuild.Errorf(goappbuild.EConflict, "subscription %s already exists", msg.ID)
+	}
+
+	if len(o.subs) >= maxRealtimeSubscriptions {
+		return goappbuild.Errorf(goappbuild.ETooManyRequests, "a connection can have at most %d subscriptions", maxRealtimeSubscriptions)
+	}
+
+	req := goappbuild.SubscribeRequest{
+		ProjectID:  projectID,
+		Collection: msg.Collection,
+		DocumentID: msg.DocumentID,
+		Filters:    msg.Filters,
+	}
+
+	sub, err := o.app.Realtime.Subscribe(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	o.subs[msg.ID] = sub
+
+	o.send(ctx, RealtimeResponse{Type: realtimeSubscribed, ID: msg.ID})
+
+	o.wg.Add(1)
+
+	go func() {
+		defer o.wg.Done()
+		o.forward(ctx, msg.ID, sub)
+	}()
+
+	return nil
+}
+
+// unsubscribe ends a subscription
+func (o *realtimeConn) unsubscribe(ctx context.Context, msg RealtimeRequest) error {
+	o.mu.Lock()
+	sub, ok := o.subs[msg.ID]
+	delete(o.subs, msg.ID)
+	o.mu.Unlock()
+
+	if !ok {
+		return goappbuild.Errorf(goappbuild.ENotFound, "subscription %s not found", msg.ID)
+	}
+
+	sub.Close()
+
+	o.send(ctx, RealtimeResponse{Type: realtimeUnsubscribed, ID: msg.ID})
+
+	return nil
+}
+
+// watch authenticates the token of the connection again periodically, since
+// the user can be disabled or logged out and the API keys and the sessions
+// revoked while the connection is open. The subscriptions end when the token
+// is no longer valid.
+func (o *realtimeConn) watch(ctx context.Context) {
+	ticker := time.NewTicker(realtimeAuthPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		o.mu.Lock()
+		token := o.token
+		o.mu.Unlock()
+
+		if token == "" {
+			continue
+		}
+
+		// the subscriptions are kept when the credentials cannot be checked
+		_, err := authenticate(ctx, o.app, token)
+		if goappbuild.ErrorCode(err) == goappbuild.EUnauthorized {
+			o.revoke(ctx, token)
+		}
+	}
+}
+
+// revoke ends the subscriptions of the token unless the connection
+// authenticated again meanwhile
+func (o *realtimeConn) revoke(ctx context.Context, token string) {
+	o.mu.Lock()
+
+	if o.token != token {
+		o.mu.Unlock()
+		return
+	}
+
+	o.token = ""
+	o.revoked = true
+
+	ids := make([]string, 0, len(o.subs))
+
+	for id, sub := range o.subs {
+		sub.Close()
+		delete(o.subs, id)
+
+		ids = append(ids, id)
+	}
+
+	o.mu.Unlock()
+
+	for _, id := range ids {
+		o.send(ctx, errorMessage(id, realtimeClosed, errRealtimeRevoked))
+	}
+}
+
+// forward sends the changes of a subscription until it ends. The client
+// is told when the subscription ends on its own.
+func (o *realtimeConn) forward(ctx context.Context, id string, sub goappbuild.Subscription) {
+	for c := range sub.Changes() {
+		o.send(ctx, RealtimeResponse{Type: realtimeChange, ID: id, Change: newChangeResponse(c)})
+	}
+
+	err := sub.Err()
+	if err == nil {
+		return
+	}
+
+	o.mu.Lock()
+	if o.subs[id] == sub {
+		delete(o.subs, id)
+	}
+	o.mu.Unlock()
+
+	o.send(ctx, errorMessage(id, realtimeClosed, err))
+}
+
+// send queues a message, waiting while the client is slow
+func (o *realtimeConn) send(ctx context.Context, msg RealtimeResponse) {
+	select {
+	case o.out <- msg:
+	case <-ctx.Done():
+	}
+}
+
+// write writes the queued messages and pings the client until the context
+// ends. It cancels the connection when a write fails.
+func (o *realtimeConn) write(ctx context.Context, cancel context.CancelFunc) {
+	defer cancel()
+
+	ticker := time.NewTicker(realtimePingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			deadline := time.Now().Add(realtimeWriteWait)
+			_ = o.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+
+			return
+		case msg := <-o.out:
+			_ = o.ws.SetWriteDeadline(time.Now().Add(realtimeWriteWait))
+
+			if err := o.ws.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			deadline := time.Now().Add(realtimeWriteWait)
+
+			if err := o.ws.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func errorMessage(id, typ string, err error) RealtimeResponse {
+	return RealtimeResponse{
+		Type:  typ,
+		ID:    id,
+		Code:  goappbuild.ErrorCode(err),
+		Error: goappbuild.ErrorMessage(err),
+	}
+}
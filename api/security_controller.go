@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// SecurityController is the controller for the review of the security events.
+type SecurityController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewSecurityController creates a new security controller.
+func NewSecurityController(app *goappbuild.App) SecurityController {
+	return SecurityController{
+		app: app,
+	}
+}
+
+// SecurityEventResponse is a security event.
+type SecurityEventResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Kind      string    `json:"kind"`
+	UserID    uuid.UUID `json:"user_id,omitempty"`
+	ProjectID uuid.UUID `json:"project_id,omitempty"`
+	// ActorID is the admin that performed the action
+	ActorID   uuid.UUID `json:"actor_id,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListSecurityEventsResponse is the response for the Events method.
+type ListSecurityEventsResponse struct {
+	Events []SecurityEventResponse `json:"events"`
+}
+
+func newSecurityEventResponse(e goappbuild.SecurityEvent) SecurityEventResponse {
+	return SecurityEventResponse{
+		ID:        e.ID,
+		Kind:      string(e.Kind),
+		UserID:    e.UserID,
+		ProjectID: e.ProjectID,
+		ActorID:   e.ActorID,
+		Email:     e.Email,
+		IP:        e.IP,
+		Detail:    e.Detail,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// Events lists the security events
+//
+// @Summary List the security events
+// @Description List the failed logins, the lockouts, the rejected breached passwords and the actions
+// @Description of the admins on the users, newest first. Admins only.
+// @Tags admin
+// @Produce json
+// @Param kind query string false "Event kind" Enums(login_failed, lockout, breached_password, user_disabled, user_enabled, user_deleted, forced_logout, impersonation)
+// @Param email query string false "Email"
+// @Param ip query string false "Client address"
+// @Param project_id query string false "Project ID"
+// @Param user_id query string false "User ID"
+// @Param limit query int false "Maximum number of events (default 100, max 500)"
+// @Success 200 {object} ListSecurityEventsResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/security-events [get]
+func (o SecurityController) Events(w http.ResponseWriter, r *http.Request) {
+	filter := goappbuild.SecurityEventFilter{
+		Kind:  goappbuild.SecurityEventKind(o.QueryParam(r, "kind")),
+		Email: o.QueryParam(r, "email"),
+		IP:    o.QueryParam(r, "ip"),
+	}
+
+	if v := o.QueryParam(r, "project_id"); v != "" {
+		projectID, err := uuid.Parse(v)
+		if err != nil {
+			o.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		filter.ProjectID = projectID
+	}
+
+	if v := o.QueryParam(r, "user_id"); v != "" {
+		userID, err := uuid.Parse(v)
+		if err != nil {
+			o.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		filter.UserID = userID
+	}
+
+	if v := o.QueryParam(r, "limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			o.Error(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		filter.Limit = limit
+	}
+
+	events, err := o.app.Security.Events(r.Context(), filter)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListSecurityEventsResponse{
+		Events: make([]SecurityEventResponse, len(events)),
+	}
+
+	for i := range events {
+		ans.Events[i] = newSecurityEventResponse(events[i])
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
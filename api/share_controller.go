@@ -0,0 +1,379 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// ShareController is the controller for the share links of the documents.
+type ShareController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewShareController creates a new share controller.
+func NewShareController(app *goappbuild.App) ShareController {
+	return ShareController{
+		app: app,
+	}
+}
+
+// CreateShareLinkRequest is the request for the Create method.
+// Exactly one of document_id and filters is set.
+type CreateShareLinkRequest struct {
+	Collection string `json:"collection"`
+	// DocumentID shares a single document
+	DocumentID string `json:"document_id,omitempty"`
+	// Filters share the documents that match all of them
+	Filters Filters `json:"filters,omitempty"`
+	// TTLSeconds is the lifetime of the link, 7 days when omitted
+	TTLSeconds int64 `json:"ttl_seconds,omitempty"`
+}
+
+// Validate validates the request.
+func (o *CreateShareLinkRequest) Validate() error {
+	if o.Collection == "" {
+		return errors.New("collection is required")
+	}
+
+	if o.TTLSeconds < 0 {
+		return errors.New("ttl_seconds must not be negative")
+	}
+
+	return o.Filters.Validate()
+}
+
+// ShareLinkResponse is a share link. The token is only returned on create.
+type ShareLinkResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Collection string     `json:"collection"`
+	DocumentID string     `json:"document_id,omitempty"`
+	Filters    Filters    `json:"filters,omitempty"`
+	Token      string     `json:"token,omitempty"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newShareLinkResponse(l goappbuild.ShareLink, token string) ShareLinkResponse {
+	ans := ShareLinkResponse{
+		ID:         l.ID,
+		Collection: l.Collection,
+		DocumentID: l.DocumentID,
+		Token:      token,
+		ExpiresAt:  l.ExpiresAt,
+		RevokedAt:  l.RevokedAt,
+		CreatedAt:  l.CreatedAt,
+	}
+
+	for _, f := range l.Filters {
+		ans.Filters = append(ans.Filters, Filter{Column: f.Column, Op: f.Op, Value: f.Value})
+	}
+
+	if l.CreatedBy != uuid.Nil {
+		ans.CreatedBy = &l.CreatedBy
+	}
+
+	return ans
+}
+
+// Create creates a share link
+//
+// @Summary Create a share link
+// @Description Share a document, or the documents matching the filters, with anyone holding the token.
+// @Description The token is only returned once. The filters can only use the attributes anonymous callers can read.
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Param projectID header string false "Project ID (implied by an API key)"
+// @Param body body CreateShareLinkRequest true "The request body"
+// @Success 201 {object} ShareLinkResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/share-links [post]
+func (o ShareController) Create(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload CreateShareLinkRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.CreateShareLinkRequest{
+		ProjectID:  projectID,
+		Collection: payload.Collection,
+		DocumentID: payload.DocumentID,
+		TTL:        time.Duration(payload.TTLSeconds) * time.Second,
+	}
+
+	for _, f := range payload.Filters {
+		req.Filters = append(req.Filters, f.filter())
+	}
+
+	l, token, err := o.app.Shares.Create(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusCreated, newShareLinkResponse(l, token))
+}
+
+// ListShareLinksResponse is the response for the List method.
+type ListShareLinksResponse struct {
+	Links []ShareLinkResponse `json:"links"`
+}
+
+// List lists the share links of a collection
+//
+// @Summary List share links
+// @Description List the share links of a collection, newest first
+// @Tags shares
+// @Produce json
+// @Param projectID header string false "Project ID (implied by an API key)"
+// @Param collection query string true "Collection name"
+// @Success 200 {object} ListShareLinksResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/share-links [get]
+func (o ShareController) List(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	collection := o.QueryParam(r, "collection")
+	if collection == "" {
+		o.Error(w, r, http.StatusBadRequest, errors.New("collection is required"))
+		return
+	}
+
+	links, err := o.app.Shares.List(r.Context(), projectID, collection)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListShareLinksResponse{
+		Links: make([]ShareLinkResponse, len(links)),
+	}
+
+	for i := range links {
+		ans.Links[i] = newShareLinkResponse(links[i], "")
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// Revoke revokes a share link
+//
+// @Summary Revoke a share link
+// @Description Revoke a share link, its token stops working
+// @Tags shares
+// @Param projectID header string false "Project ID (implied by an API key)"
+// @Param linkID path string true "Share link ID"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/share-links/{linkID} [delete]
+func (o ShareController) Revoke(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	linkID, err := uuid.Parse(o.StringURLParam(r, "linkID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Shares.Revoke(r.Context(), projectID, linkID); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// ShareAccessResponse is an access of a share link.
+type ShareAccessResponse struct {
+	ID        uuid.UUID `json:"id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListShareAccessesResponse is the response for the Accesses method.
+type ListShareAccessesResponse struct {
+	Accesses []ShareAccessResponse `json:"accesses"`
+}
+
+// Accesses lists the accesses of a share link
+//
+// @Summary List share link accesses
+// @Description List the latest reads of a share link, newest first
+// @Tags shares
+// @Produce json
+// @Param projectID header string false "Project ID (implied by an API key)"
+// @Param linkID path string true "Share link ID"
+// @Param limit query int false "Maximum number of accesses (default 100, max 500)"
+// @Success 200 {object} ListShareAccessesResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /api/v1/share-links/{linkID}/accesses [get]
+func (o ShareController) Accesses(w http.ResponseWriter, r *http.Request) {
+	projectID, err := getProjectID(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	linkID, err := uuid.Parse(o.StringURLParam(r, "linkID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	limit, err := o.intQueryParam(r, "limit")
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	accesses, err := o.app.Shares.Accesses(r.Context(), projectID, linkID, limit)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListShareAccessesResponse{
+		Accesses: make([]ShareAccessResponse, len(accesses)),
+	}
+
+	for i, a := range accesses {
+		ans.Accesses[i] = ShareAccessResponse{
+			ID:        a.ID,
+			IP:        a.IP,
+			UserAgent: a.UserAgent,
+			CreatedAt: a.CreatedAt,
+		}
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// SharedResponse is the content of a share link.
+type SharedResponse struct {
+	Collection string    `json:"collection"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Document is set for the links of a single document
+	Document *goappbuild.Document `json:"document,omitempty"`
+	// Documents is set for the shared views
+	Documents []goappbuild.Document `json:"documents,omitempty"`
+}
+
+// Open returns the content of a share link
+//
+// @Summary Open a share link
+// @Description Return the shared document, or a page of the shared view, without authentication.
+// @Description Only the attributes that anonymous callers can read are returned. Every access is logged.
+// @Tags shares
+// @Produce json
+// @Param token path string true "Share token"
+// @Param limit query int false "Maximum number of documents of a view (default 100, max 1000)"
+// @Param offset query int false "Number of documents of a view to skip"
+// @Success 200 {object} SharedResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/shared/{token} [get]
+func (o ShareController) Open(w http.ResponseWriter, r *http.Request) {
+	req := goappbuild.OpenShareRequest{
+		Token:     o.StringURLParam(r, "token"),
+		UserAgent: r.UserAgent(),
+	}
+
+	var err error
+
+	if req.Limit, err = o.intQueryParam(r, "limit"); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.Offset, err = o.intQueryParam(r, "offset"); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	content, err := o.app.Shares.Open(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := SharedResponse{
+		Collection: content.Link.Collection,
+		ExpiresAt:  content.Link.ExpiresAt,
+	}
+
+	if content.Link.IsView() {
+		ans.Documents = content.Documents
+	} else if len(content.Documents) > 0 {
+		ans.Document = &content.Documents[0]
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+func (o ShareController) intQueryParam(r *http.Request, key string) (int, error) {
+	v := o.QueryParam(r, key)
+	if v == "" {
+		return 0, nil
+	}
+
+	ans, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	return ans, nil
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// SSOController is the controller for the OpenID Connect providers
+// the end users of a project sign in with.
+type SSOController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewSSOController creates a new sso controller.
+func NewSSOController(app *goappbuild.App) SSOController {
+	return SSOController{
+		app: app,
+	}
+}
+
+// CreateIdentityProviderRequest is the request for the CreateProvider method.
+type CreateIdentityProviderRequest struct {
+	// Name identifies the provider in the urls (e.g. google)
+	Name string `json:"name"`
+	// Issuer is the issuer url, the discovery document is fetched from it
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// RedirectURL is the url of the app the provider sends the end users back to.
+	// The app passes the code and the state to the callback.
+	RedirectURL string `json:"redirect_url"`
+	// Scopes are requested besides openid, usually email and profile
+	Scopes []string `json:"scopes"`
+}
+
+// Validate validates the request.
+func (o *CreateIdentityProviderRequest) Validate() error {
+	if o.Name == "" || o.Issuer == "" || o.ClientID == "" || o.RedirectURL == "" {
+		return errors.New("name, issuer, client_id and redirect_url are required")
+	}
+
+	return nil
+}
+
+// IdentityProviderResponse is an identity provider. The client secret is never returned.
+type IdentityProviderResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Issuer      string    `json:"issuer"`
+	ClientID    string    `json:"client_id"`
+	RedirectURL string    `json:"redirect_url"`
+	Scopes      []string  `json:"scopes"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newIdentityProviderResponse(p goappbuild.IdentityProvider) IdentityProviderResponse {
+	ans := IdentityProviderResponse{
+		ID:          p.ID,
+		Name:        p.Name,
+		Issuer:      p.Issuer,
+		ClientID:    p.ClientID,
+		RedirectURL: p.RedirectURL,
+		Scopes:      p.Scopes,
+		CreatedAt:   p.CreatedAt,
+	}
+
+	if ans.Scopes == nil {
+		ans.Scopes = []string{}
+	}
+
+	return ans
+}
+
+// CreateProvider adds an identity provider
+//
+// @Summary Add an identity provider
+// @Description Add an OpenID Connect provider the end users of the project can sign in with
+// @Tags identity providers
+// @Accept json
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param body body CreateIdentityProviderRequest true "The request body"
+// @Success 201 {object} IdentityProviderResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/identity-providers [post]
+func (o SSOController) CreateProvider(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload CreateIdentityProviderRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.CreateIdentityProviderRequest{
+		ProjectID:    projectID,
+		Name:         payload.Name,
+		Issuer:       payload.Issuer,
+		ClientID:     payload.ClientID,
+		ClientSecret: payload.ClientSecret,
+		RedirectURL:  payload.RedirectURL,
+		Scopes:       payload.Scopes,
+	}
+
+	p, err := o.app.SSO.CreateProvider(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusCreated, newIdentityProviderResponse(p))
+}
+
+// ListIdentityProvidersResponse is the response for the ListProviders method.
+type ListIdentityProvidersResponse struct {
+	Providers []IdentityProviderResponse `json:"providers"`
+}
+
+// ListProviders lists the identity providers
+//
+// @Summary List identity providers
+// @Description List the identity providers of the project
+// @Tags identity providers
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Success 200 {object} ListIdentityProvidersResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/identity-providers [get]
+func (o SSOController) ListProviders(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	providers, err := o.app.SSO.ListProviders(r.Context(), projectID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListIdentityProvidersResponse{
+		Providers: make([]IdentityProviderResponse, len(providers)),
+	}
+
+	for i := range providers {
+		ans.Providers[i] = newIdentityProviderResponse(providers[i])
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// DeleteProvider removes an identity provider
+//
+// @Summary Remove an identity provider
+// @Description Remove an identity provider of the project. The end users keep their accounts.
+// @Tags identity providers
+// @Param projectID path string true "Project ID"
+// @Param provider path string true "Provider name"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/identity-providers/{provider} [delete]
+func (o SSOController) DeleteProvider(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.SSO.DeleteProvider(r.Context(), projectID, o.StringURLParam(r, "provider")); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// SSOStartResponse is the response for the Start method.
+type SSOStartResponse struct {
+	// URL is the url of the provider the end user is sent to
+	URL string `json:"url"`
+}
+
+// Start begins a sign in with an identity provider
+//
+// @Summary Start a sign in with an identity provider
+// @Description Return the url of the provider the end user is sent to. The provider redirects back to the redirect url of the provider with a code and a state. When the caller is an anonymous end user the account of the provider is linked to it.
+// @Tags end users
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param provider path string true "Provider name"
+// @Success 200 {object} SSOStartResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/auth/oidc/{provider}/start [post]
+func (o SSOController) Start(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := o.app.SSO.Start(r.Context(), projectID, o.StringURLParam(r, "provider"))
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, SSOStartResponse{URL: u})
+}
+
+// SSOCallbackRequest is the request for the Callback method.
+type SSOCallbackRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// Validate validates the request.
+func (o *SSOCallbackRequest) Validate() error {
+	if o.Code == "" || o.State == "" {
+		return errors.New("code and state are required")
+	}
+
+	return nil
+}
+
+// Callback finishes a sign in with an identity provider
+//
+// @Summary Finish a sign in with an identity provider
+// @Description Exchange the code the provider redirected with and start a session. Unknown accounts create an end user, or are linked to the end user with the same verified email.
+// @Tags end users
+// @Accept json
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param provider path string true "Provider name"
+// @Param body body SSOCallbackRequest true "The request body"
+// @Success 200 {object} SessionResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/projects/{projectID}/auth/oidc/{provider}/callback [post]
+func (o SSOController) Callback(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload SSOCallbackRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.SSOCallbackRequest{
+		ProjectID: projectID,
+		Provider:  o.StringURLParam(r, "provider"),
+		Code:      payload.Code,
+		State:     payload.State,
+	}
+
+	session, err := o.app.SSO.Callback(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newSessionResponse(session))
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/restapi"
+)
+
+// TeamController is the controller for the teams of a project.
+type TeamController struct {
+	restapi.Controller
+
+	app *goappbuild.App
+}
+
+// NewTeamController creates a new team controller.
+func NewTeamController(app *goappbuild.App) TeamController {
+	return TeamController{
+		app: app,
+	}
+}
+
+// TeamResponse is a team of a project.
+type TeamResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newTeamResponse(t goappbuild.Team) TeamResponse {
+	return TeamResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+// CreateTeamRequest is the request for the Create method.
+type CreateTeamRequest struct {
+	Name string `json:"name"`
+}
+
+// Validate validates the request.
+func (o *CreateTeamRequest) Validate() error {
+	if o.Name == "" {
+		return errors.New("name is required")
+	}
+
+	return nil
+}
+
+// Create creates a team
+//
+// @Summary Create a team
+// @Description Create a team of platform users and end users. Documents can be shared with the team.
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param body body CreateTeamRequest true "The request body"
+// @Success 201 {object} TeamResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/teams [post]
+func (o TeamController) Create(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload CreateTeamRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	t, err := o.app.Teams.Create(r.Context(), goappbuild.CreateTeamRequest{
+		ProjectID: projectID,
+		Name:      payload.Name,
+	})
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusCreated, newTeamResponse(t))
+}
+
+// ListTeamsResponse is the response for the List method.
+type ListTeamsResponse struct {
+	Teams []TeamResponse `json:"teams"`
+}
+
+// List lists the teams of a project
+//
+// @Summary List teams
+// @Description List the teams of the project ordered by name
+// @Tags teams
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Success 200 {object} ListTeamsResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/teams [get]
+func (o TeamController) List(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	items, err := o.app.Teams.List(r.Context(), projectID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListTeamsResponse{
+		Teams: make([]TeamResponse, len(items)),
+	}
+
+	for i := range items {
+		ans.Teams[i] = newTeamResponse(items[i])
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// Delete deletes a team
+//
+// @Summary Delete a team
+// @Description Delete a team. The documents shared with the team are not shared with its members anymore.
+// @Tags teams
+// @Param projectID path string true "Project ID"
+// @Param teamID path string true "Team ID"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/teams/{teamID} [delete]
+func (o TeamController) Delete(w http.ResponseWriter, r *http.Request) {
+	projectID, teamID, err := o.teamParams(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Teams.Delete(r.Context(), projectID, teamID); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// TeamMemberResponse is a member of a team.
+type TeamMemberResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListTeamMembersResponse is the response for the ListMembers method.
+type ListTeamMembersResponse struct {
+	Members []TeamMemberResponse `json:"members"`
+}
+
+// ListMembers lists the members of a team
+//
+// @Summary List the members of a team
+// @Description List the users and end users of a team, oldest first
+// @Tags teams
+// @Produce json
+// @Param projectID path string true "Project ID"
+// @Param teamID path string true "Team ID"
+// @Success 200 {object} ListTeamMembersResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/teams/{teamID}/members [get]
+func (o TeamController) ListMembers(w http.ResponseWriter, r *http.Request) {
+	projectID, teamID, err := o.teamParams(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	items, err := o.app.Teams.ListMembers(r.Context(), projectID, teamID)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := ListTeamMembersResponse{
+		Members: make([]TeamMemberResponse, len(items)),
+	}
+
+	for i := range items {
+		ans.Members[i] = TeamMemberResponse{UserID: items[i].UserID, CreatedAt: items[i].CreatedAt}
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// AddMember adds a user to a team
+//
+// @Summary Add a member to a team
+// @Description Add a platform user or an end user of the project to a team. Adding a member again is a no-op.
+// @Tags teams
+// @Param projectID path string true "Project ID"
+// @Param teamID path string true "Team ID"
+// @Param userID path string true "User or end user ID"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/teams/{teamID}/members/{userID} [put]
+func (o TeamController) AddMember(w http.ResponseWriter, r *http.Request) {
+	projectID, teamID, userID, err := o.memberParams(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Teams.AddMember(r.Context(), projectID, teamID, userID); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// RemoveMember removes a user from a team
+//
+// @Summary Remove a member from a team
+// @Description Remove a user or an end user from a team
+// @Tags teams
+// @Param projectID path string true "Project ID"
+// @Param teamID path string true "Team ID"
+// @Param userID path string true "User or end user ID"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 404 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/projects/{projectID}/teams/{teamID}/members/{userID} [delete]
+func (o TeamController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	projectID, teamID, userID, err := o.memberParams(r)
+	if err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Teams.RemoveMember(r.Context(), projectID, teamID, userID); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+func (o TeamController) teamParams(r *http.Request) (projectID, teamID uuid.UUID, err error) {
+	projectID, err = uuid.Parse(o.StringURLParam(r, "projectID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	teamID, err = uuid.Parse(o.StringURLParam(r, "teamID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return projectID, teamID, nil
+}
+
+func (o TeamController) memberParams(r *http.Request) (projectID, teamID, userID uuid.UUID, err error) {
+	projectID, teamID, err = o.teamParams(r)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, uuid.Nil, err
+	}
+
+	userID, err = uuid.Parse(o.StringURLParam(r, "userID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, uuid.Nil, err
+	}
+
+	return projectID, teamID, userID, nil
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -25,16 +27,23 @@ func NewUserController(app *goappbuild.App) UserController {
 
 // RegisterUserRequest is the request for the RegisterUser method.
 type RegisterUserRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
 }
 
 // Validate validates the request.
 func (o *RegisterUserRequest) Validate() error {
+	if o.Email == "" || o.Password == "" {
+		return errors.New("email and password are required")
+	}
+
 	return nil
 }
 
 // RegisterUserResponse is the response for the RegisterUser method.
 type RegisterUserResponse struct {
-	ID uuid.UUID `json:"id"`
+	ID    uuid.UUID `json:"id"`
+	Email string    `json:"email"`
 }
 
 // Register register a user
@@ -47,6 +56,7 @@ type RegisterUserResponse struct {
 // @Param body body RegisterUserRequest true "The request body"
 // @Success 200 {object} RegisterUserResponse
 // @Failure 400 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
 // @Failure 500 {object} restapi.ErrorResponse
 // @Router /api/v1/users [post]
 func (o UserController) Register(w http.ResponseWriter, r *http.Request) {
@@ -57,17 +67,553 @@ func (o UserController) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	registerReq := goappbuild.RegisterUserRequest{}
+	registerReq := goappbuild.RegisterUserRequest{
+		Email:    payload.Email,
+		Password: payload.Password,
+	}
 
 	u, err := o.app.Users.Register(r.Context(), registerReq)
 	if err != nil {
-		o.Error(w, r, http.StatusInternalServerError, err)
+		o.Error(w, r, errorStatus(err), err)
 		return
 	}
 
 	ans := RegisterUserResponse{
-		ID: u.ID,
+		ID:    u.ID,
+		Email: u.Email,
+	}
+
+	o.Success(w, r, http.StatusOK, ans)
+}
+
+// LoginRequest is the request for the Login method.
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Validate validates the request.
+func (o *LoginRequest) Validate() error {
+	if o.Email == "" || o.Password == "" {
+		return errors.New("email and password are required")
+	}
+
+	return nil
+}
+
+// TokenResponse is the response of the Login and Refresh methods.
+// When MFA is required only the MFA token is returned.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	// ExpiresIn is the lifetime of the access token (or the MFA token) in seconds
+	ExpiresIn   int64 `json:"expires_in"`
+	MFARequired bool  `json:"mfa_required"`
+	// MFAToken is exchanged for the tokens with a code at /users/login/mfa
+	MFAToken string `json:"mfa_token,omitempty"`
+}
+
+func newTokenResponse(pair goappbuild.TokenPair) TokenResponse {
+	ans := TokenResponse{
+		ExpiresIn: int64(time.Until(pair.ExpiresAt).Seconds()),
+	}
+
+	if pair.MFAToken != "" {
+		ans.MFARequired = true
+		ans.MFAToken = pair.MFAToken
+
+		return ans
+	}
+
+	ans.AccessToken = pair.AccessToken
+	ans.RefreshToken = pair.RefreshToken
+	ans.TokenType = "Bearer"
+
+	return ans
+}
+
+// Login authenticates a user
+//
+// @Summary Login
+// @Description Authenticate a user with email and password and get an access and a refresh token.
+// @Description Users with MFA enabled get an MFA token to complete the login with a code.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body LoginRequest true "The request body"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 429 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/login [post]
+func (o UserController) Login(w http.ResponseWriter, r *http.Request) {
+	var payload LoginRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	loginReq := goappbuild.LoginRequest{
+		Email:    payload.Email,
+		Password: payload.Password,
+	}
+
+	pair, err := o.app.Auth.Login(r.Context(), loginReq)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newTokenResponse(pair))
+}
+
+// LoginMFARequest is the request for the LoginMFA method.
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token"`
+	// Code is a code of the authenticator app or a recovery code
+	Code string `json:"code"`
+}
+
+// Validate validates the request.
+func (o *LoginMFARequest) Validate() error {
+	if o.MFAToken == "" || o.Code == "" {
+		return errors.New("mfa_token and code are required")
+	}
+
+	return nil
+}
+
+// LoginMFA completes the login of a user with MFA enabled
+//
+// @Summary Login with MFA
+// @Description Exchange the MFA token of the login and a code for an access and a refresh token.
+// @Description An MFA token accepts a few wrong codes and the wrong codes delay and lock out the user like the wrong passwords.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body LoginMFARequest true "The request body"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 429 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/login/mfa [post]
+func (o UserController) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var payload LoginMFARequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.LoginMFARequest{
+		MFAToken: payload.MFAToken,
+		Code:     payload.Code,
+	}
+
+	pair, err := o.app.Auth.LoginMFA(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newTokenResponse(pair))
+}
+
+// PasswordlessLoginRequest is the request for the RequestLogin method.
+type PasswordlessLoginRequest struct {
+	Email string `json:"email"`
+	// Method is link for a magic link or code for a one-time code
+	Method string `json:"method"`
+	// BindDevice binds the link or the code to the device token of the response
+	BindDevice bool `json:"bind_device"`
+}
+
+// Validate validates the request.
+func (o *PasswordlessLoginRequest) Validate() error {
+	if o.Email == "" || o.Method == "" {
+		return errors.New("email and method are required")
+	}
+
+	return nil
+}
+
+// PasswordlessLoginResponse is the response of the RequestLogin method.
+type PasswordlessLoginResponse struct {
+	// DeviceToken is sent with the link or the code when the login is bound to the device
+	DeviceToken string    `json:"device_token,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// RequestLogin sends a magic link or a one-time code
+//
+// @Summary Request a passwordless login
+// @Description Email a magic link or a one-time code to log in without a password.
+// @Description The response is the same whether the email is registered or not.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body PasswordlessLoginRequest true "The request body"
+// @Success 200 {object} PasswordlessLoginResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/login/passwordless [post]
+func (o UserController) RequestLogin(w http.ResponseWriter, r *http.Request) {
+	var payload PasswordlessLoginRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.PasswordlessRequest{
+		Email:      payload.Email,
+		Method:     goappbuild.PasswordlessMethod(payload.Method),
+		BindDevice: payload.BindDevice,
+	}
+
+	challenge, err := o.app.Users.RequestLogin(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	ans := PasswordlessLoginResponse{
+		DeviceToken: challenge.DeviceToken,
+		ExpiresAt:   challenge.ExpiresAt,
 	}
 
 	o.Success(w, r, http.StatusOK, ans)
 }
+
+// LoginLinkRequest is the request for the LoginWithLink method.
+type LoginLinkRequest struct {
+	Token       string `json:"token"`
+	DeviceToken string `json:"device_token"`
+}
+
+// Validate validates the request.
+func (o *LoginLinkRequest) Validate() error {
+	if o.Token == "" {
+		return errors.New("token is required")
+	}
+
+	return nil
+}
+
+// LoginWithLink authenticates a user with a magic link
+//
+// @Summary Login with a magic link
+// @Description Exchange the token of a magic link for an access and a refresh token. The token can be used only once.
+// @Description Users with MFA enabled get an MFA token to complete the login with a code.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body LoginLinkRequest true "The request body"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/login/link [post]
+func (o UserController) LoginWithLink(w http.ResponseWriter, r *http.Request) {
+	var payload LoginLinkRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.LoginLinkRequest{
+		Token:       payload.Token,
+		DeviceToken: payload.DeviceToken,
+	}
+
+	pair, err := o.app.Auth.LoginWithLink(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newTokenResponse(pair))
+}
+
+// LoginCodeRequest is the request for the LoginWithCode method.
+type LoginCodeRequest struct {
+	Email       string `json:"email"`
+	Code        string `json:"code"`
+	DeviceToken string `json:"device_token"`
+}
+
+// Validate validates the request.
+func (o *LoginCodeRequest) Validate() error {
+	if o.Email == "" || o.Code == "" {
+		return errors.New("email and code are required")
+	}
+
+	return nil
+}
+
+// LoginWithCode authenticates a user with a one-time code
+//
+// @Summary Login with a one-time code
+// @Description Exchange the emailed one-time code for an access and a refresh token. The code can be used only once.
+// @Description Users with MFA enabled get an MFA token to complete the login with a code.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body LoginCodeRequest true "The request body"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 429 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/login/code [post]
+func (o UserController) LoginWithCode(w http.ResponseWriter, r *http.Request) {
+	var payload LoginCodeRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.LoginCodeRequest{
+		Email:       payload.Email,
+		Code:        payload.Code,
+		DeviceToken: payload.DeviceToken,
+	}
+
+	pair, err := o.app.Auth.LoginWithCode(r.Context(), req)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newTokenResponse(pair))
+}
+
+// RefreshTokenRequest is the request for the Refresh and Logout methods.
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Validate validates the request.
+func (o *RefreshTokenRequest) Validate() error {
+	if o.RefreshToken == "" {
+		return errors.New("refresh_token is required")
+	}
+
+	return nil
+}
+
+// Refresh rotates a refresh token
+//
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access and refresh token. The refresh token can be used only once.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body RefreshTokenRequest true "The request body"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/token/refresh [post]
+func (o UserController) Refresh(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	pair, err := o.app.Auth.Refresh(r.Context(), payload.RefreshToken)
+	if err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusOK, newTokenResponse(pair))
+}
+
+// Logout revokes a refresh token
+//
+// @Summary Logout
+// @Description Revoke the refresh token and all the tokens issued from the same login
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body RefreshTokenRequest true "The request body"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/logout [post]
+func (o UserController) Logout(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Auth.Logout(r.Context(), payload.RefreshToken); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// RequestEmailVerification sends a new verification email
+//
+// @Summary Request email verification
+// @Description Send a new email verification link to the authenticated user
+// @Tags users
+// @Success 204
+// @Failure 401 {object} restapi.ErrorResponse
+// @Failure 403 {object} restapi.ErrorResponse
+// @Failure 409 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/users/verify-email/request [post]
+func (o UserController) RequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	if err := o.app.Users.RequestEmailVerification(r.Context()); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// VerifyEmailRequest is the request for the VerifyEmail method.
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// Validate validates the request.
+func (o *VerifyEmailRequest) Validate() error {
+	if o.Token == "" {
+		return errors.New("token is required")
+	}
+
+	return nil
+}
+
+// VerifyEmail verifies the email of a user
+//
+// @Summary Verify email
+// @Description Verify the email of a user with the token of the verification link. The token can be used only once.
+// @Tags users
+// @Accept json
+// @Param body body VerifyEmailRequest true "The request body"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/verify-email [post]
+func (o UserController) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyEmailRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Users.VerifyEmail(r.Context(), payload.Token); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// ForgotPasswordRequest is the request for the ForgotPassword method.
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// Validate validates the request.
+func (o *ForgotPasswordRequest) Validate() error {
+	if o.Email == "" {
+		return errors.New("email is required")
+	}
+
+	return nil
+}
+
+// ForgotPassword sends a password reset email
+//
+// @Summary Forgot password
+// @Description Send a password reset link to the email. The response is the same whether the email is registered or not.
+// @Tags users
+// @Accept json
+// @Param body body ForgotPasswordRequest true "The request body"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/password/forgot [post]
+func (o UserController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := o.app.Users.RequestPasswordReset(r.Context(), payload.Email); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
+
+// ResetPasswordRequest is the request for the ResetPassword method.
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// Validate validates the request.
+func (o *ResetPasswordRequest) Validate() error {
+	if o.Token == "" || o.Password == "" {
+		return errors.New("token and password are required")
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password
+//
+// @Summary Reset password
+// @Description Set a new password with the token of the password reset link. All the sessions of the user are logged out.
+// @Tags users
+// @Accept json
+// @Param body body ResetPasswordRequest true "The request body"
+// @Success 204
+// @Failure 400 {object} restapi.ErrorResponse
+// @Failure 500 {object} restapi.ErrorResponse
+// @Router /api/v1/users/password/reset [post]
+func (o UserController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordRequest
+
+	if err := o.DecodeBody(r, &payload); err != nil {
+		o.Error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := goappbuild.ResetPasswordRequest{
+		Token:    payload.Token,
+		Password: payload.Password,
+	}
+
+	if err := o.app.Users.ResetPassword(r.Context(), req); err != nil {
+		o.Error(w, r, errorStatus(err), err)
+		return
+	}
+
+	o.Success(w, r, http.StatusNoContent, nil)
+}
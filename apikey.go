@@ -0,0 +1,165 @@
+package goappbuild
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ScopeAll grants everything
+	ScopeAll = "*"
+	// ScopeCollectionsRead allows reading the collections of the project
+	ScopeCollectionsRead = "collections:read"
+	// ScopeCollectionsWrite allows creating and changing collections
+	ScopeCollectionsWrite = "collections:write"
+	// ScopeDocumentsRead allows reading the documents of all the collections.
+	// Use DocumentsReadScope to restrict it to a collection.
+	ScopeDocumentsRead = "documents:read"
+	// ScopeDocumentsWrite allows writing the documents of all the collections.
+	// Use DocumentsWriteScope to restrict it to a collection.
+	ScopeDocumentsWrite = "documents:write"
+)
+
+// APIKeyPrefix is the prefix of all the API keys
+const APIKeyPrefix = "gab_"
+
+// apiKeyDisplayLen is the number of characters of a key that are stored in clear
+const apiKeyDisplayLen = len(APIKeyPrefix) + 8
+
+// DocumentsReadScope returns the scope to read the documents of a collection
+func DocumentsReadScope(collection string) string {
+	return ScopeDocumentsRead + ":" + collection
+}
+
+// DocumentsWriteScope returns the scope to write the documents of a collection
+func DocumentsWriteScope(collection string) string {
+	return ScopeDocumentsWrite + ":" + collection
+}
+
+// ValidateScope returns an error if the scope is not known
+func ValidateScope(scope string) error {
+	switch scope {
+	case ScopeAll, ScopeCollectionsRead, ScopeCollectionsWrite, ScopeDocumentsRead, ScopeDocumentsWrite:
+		return nil
+	}
+
+	for _, prefix := range []string{ScopeDocumentsRead + ":", ScopeDocumentsWrite + ":"} {
+		if collection, ok := strings.CutPrefix(scope, prefix); ok && collection != "" {
+			return nil
+		}
+	}
+
+	return Errorf(EValidation, "invalid scope: %q", scope)
+}
+
+// ScopesAllow returns true if one of the granted scopes allows the required one.
+// A scope allows itself and all the scopes it is a prefix of,
+// e.g. documents:read allows documents:read:posts.
+func ScopesAllow(granted []string, required string) bool {
+	for _, s := range granted {
+		if s == ScopeAll || s == required || strings.HasPrefix(required, s+":") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAPIKey returns true if the token looks like an API key
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// APIKeyDisplayPrefix returns the part of the key that is safe to display
+func APIKeyDisplayPrefix(key string) string {
+	if len(key) < apiKeyDisplayLen {
+		return key
+	}
+
+	return key[:apiKeyDisplayLen]
+}
+
+// APIKey is an API key of a project. Only the hash of the key is stored.
+type APIKey struct {
+	ID         uuid.UUID
+	ProjectID  uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// IsActive returns true if the key is not revoked or expired
+func (o *APIKey) IsActive(now time.Time) bool {
+	if o.RevokedAt != nil {
+		return false
+	}
+
+	return o.ExpiresAt == nil || o.ExpiresAt.After(now)
+}
+
+// CreateAPIKeyRequest is the request to create an API key
+type CreateAPIKeyRequest struct {
+	ProjectID uuid.UUID
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// Validate validates the request
+func (o *CreateAPIKeyRequest) Validate() error {
+	if strings.TrimSpace(o.Name) == "" {
+		return Errorf(EValidation, "name is required")
+	}
+
+	if len(o.Scopes) == 0 {
+		return Errorf(EValidation, "at least one scope is required")
+	}
+
+	for _, s := range o.Scopes {
+		if err := ValidateScope(s); err != nil {
+			return err
+		}
+	}
+
+	if o.ExpiresAt != nil && !o.ExpiresAt.After(time.Now()) {
+		return Errorf(EValidation, "expires_at must be in the future")
+	}
+
+	return nil
+}
+
+// APIKeyRepo is the repository of the API keys
+type APIKeyRepo interface {
+	Create(context.Context, *APIKey) error
+	Get(ctx context.Context, projectID, id uuid.UUID) (APIKey, error)
+	// GetByHash returns the key with the given hash
+	GetByHash(context.Context, string) (APIKey, error)
+	// List returns the keys of a project
+	List(ctx context.Context, projectID uuid.UUID) ([]APIKey, error)
+	// UpdateKey replaces the prefix and the hash of a key
+	UpdateKey(context.Context, *APIKey) error
+	Revoke(ctx context.Context, projectID, id uuid.UUID) error
+	// Touch updates the last used timestamp of a key
+	Touch(context.Context, uuid.UUID) error
+}
+
+// APIKeyService is the service that manages the API keys.
+// The plain key is returned only when a key is created or rotated.
+type APIKeyService interface {
+	Create(context.Context, CreateAPIKeyRequest) (APIKey, string, error)
+	List(ctx context.Context, projectID uuid.UUID) ([]APIKey, error)
+	// Rotate replaces the key keeping its name, scopes and expiration
+	Rotate(ctx context.Context, projectID, id uuid.UUID) (APIKey, string, error)
+	Revoke(ctx context.Context, projectID, id uuid.UUID) error
+	// Authenticate returns the identity of an API key
+	Authenticate(context.Context, string) (Identity, error)
+}
@@ -0,0 +1,55 @@
+package goappbuild_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+)
+
+func Test_Scopes(t *testing.T) {
+	t.Run("test validate", func(t *testing.T) {
+		for _, s := range []string{"*", "collections:write", "documents:read", "documents:write:posts"} {
+			require.NoError(t, goappbuild.ValidateScope(s), s)
+		}
+
+		for _, s := range []string{"", "documents", "documents:read:", "collections:write:posts", "admin"} {
+			require.Error(t, goappbuild.ValidateScope(s), s)
+		}
+	})
+
+	t.Run("test allow", func(t *testing.T) {
+		granted := []string{"documents:read", "documents:write:posts"}
+
+		require.True(t, goappbuild.ScopesAllow(granted, goappbuild.DocumentsReadScope("comments")))
+		require.True(t, goappbuild.ScopesAllow(granted, goappbuild.DocumentsWriteScope("posts")))
+		require.False(t, goappbuild.ScopesAllow(granted, goappbuild.DocumentsWriteScope("comments")))
+		require.False(t, goappbuild.ScopesAllow(granted, goappbuild.DocumentsWriteScope("posts_archive")))
+		require.False(t, goappbuild.ScopesAllow(granted, goappbuild.ScopeCollectionsWrite))
+		require.True(t, goappbuild.ScopesAllow([]string{"*"}, goappbuild.ScopeCollectionsWrite))
+	})
+
+	t.Run("test check scope", func(t *testing.T) {
+		projectID := uuid.New()
+
+		ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{
+			APIKeyID:  uuid.New(),
+			ProjectID: projectID,
+			Scopes:    []string{"documents:read:posts"},
+		})
+
+		require.NoError(t, goappbuild.CheckScope(ctx, projectID, goappbuild.DocumentsReadScope("posts")))
+
+		err := goappbuild.CheckScope(ctx, projectID, goappbuild.DocumentsWriteScope("posts"))
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		err = goappbuild.CheckScope(ctx, uuid.New(), goappbuild.DocumentsReadScope("posts"))
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		userCtx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: uuid.New()})
+		require.NoError(t, goappbuild.CheckScope(userCtx, projectID, goappbuild.ScopeCollectionsWrite))
+	})
+}
@@ -0,0 +1,186 @@
+package apikeys
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+	"github.com/gosom/goappbuild/pkg/securetoken"
+)
+
+var _ goappbuild.APIKeyService = (*service)(nil)
+
+var errInvalidKey = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid or expired api key")
+
+type service struct {
+	storage goappbuild.Storage
+}
+
+// New returns a new API key service
+func New(storage goappbuild.Storage) goappbuild.APIKeyService {
+	return &service{
+		storage: storage,
+	}
+}
+
+func (s *service) Create(ctx context.Context, req goappbuild.CreateAPIKeyRequest) (goappbuild.APIKey, string, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if err := checkOwner(ctx, uw, req.ProjectID); err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	k := goappbuild.APIKey{
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+		Prefix:    goappbuild.APIKeyDisplayPrefix(key),
+		KeyHash:   securetoken.Hash(key),
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	if err := uw.APIKeys().Create(ctx, &k); err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	return k, key, nil
+}
+
+func (s *service) List(ctx context.Context, projectID uuid.UUID) ([]goappbuild.APIKey, error) {
+	if err := checkOwner(ctx, s.storage, projectID); err != nil {
+		return nil, err
+	}
+
+	return s.storage.APIKeys().List(ctx, projectID)
+}
+
+func (s *service) Rotate(ctx context.Context, projectID, id uuid.UUID) (goappbuild.APIKey, string, error) {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if err := checkOwner(ctx, uw, projectID); err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	k, err := uw.APIKeys().Get(ctx, projectID, id)
+	if err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	if !k.IsActive(time.Now().UTC()) {
+		return goappbuild.APIKey{}, "", goappbuild.Errorf(goappbuild.EValidation, "revoked or expired keys cannot be rotated")
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	k.Prefix = goappbuild.APIKeyDisplayPrefix(key)
+	k.KeyHash = securetoken.Hash(key)
+
+	if err := uw.APIKeys().UpdateKey(ctx, &k); err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.APIKey{}, "", err
+	}
+
+	return k, key, nil
+}
+
+func (s *service) Revoke(ctx context.Context, projectID, id uuid.UUID) error {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if err := checkOwner(ctx, uw, projectID); err != nil {
+		return err
+	}
+
+	if err := uw.APIKeys().Revoke(ctx, projectID, id); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+func (s *service) Authenticate(ctx context.Context, key string) (goappbuild.Identity, error) {
+	if !goappbuild.IsAPIKey(key) {
+		return goappbuild.Identity{}, errInvalidKey
+	}
+
+	k, err := s.storage.APIKeys().GetByHash(ctx, securetoken.Hash(key))
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.Identity{}, errInvalidKey
+		}
+
+		return goappbuild.Identity{}, err
+	}
+
+	if !k.IsActive(time.Now().UTC()) {
+		return goappbuild.Identity{}, errInvalidKey
+	}
+
+	if err := s.storage.APIKeys().Touch(ctx, k.ID); err != nil {
+		return goappbuild.Identity{}, err
+	}
+
+	ans := goappbuild.Identity{
+		APIKeyID:  k.ID,
+		ProjectID: k.ProjectID,
+		Scopes:    k.Scopes,
+	}
+
+	if k.ExpiresAt != nil {
+		ans.ExpiresAt = *k.ExpiresAt
+	}
+
+	return ans, nil
+}
+
+// checkOwner returns an error if the caller is not an admin of the project.
+// API keys cannot manage API keys.
+func checkOwner(ctx context.Context, storage goappbuild.Storage, projectID uuid.UUID) error {
+	if _, err := authz.User(ctx); err != nil {
+		return err
+	}
+
+	_, err := authz.Project(ctx, storage, projectID, goappbuild.RoleAdmin)
+
+	return err
+}
+
+func newKey() (string, error) {
+	return securetoken.Generate(goappbuild.APIKeyPrefix, securetoken.DefaultSize)
+}
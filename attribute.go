@@ -1,6 +1,7 @@
 package goappbuild
 
 import (
+	"regexp"
 	"time"
 )
 
@@ -40,8 +41,13 @@ type Attribute struct {
 	Primary bool
 	// Index is a boolean that indicates if the attribute is indexed
 	Index bool
+	// AutoIncrement indicates that the database generates the values
+	// of the (integer) attribute from a sequence
+	AutoIncrement bool
 	// Relationships holds the relationships of the attribute
 	Relationships []Relationship
+	// Access restricts who can read and write the attribute
+	Access FieldAccess
 	// CreatedAt is the time the attribute was created
 	CreatedAt time.Time
 	// UpdatedAt is the time the attribute was last updated
@@ -52,3 +58,68 @@ type Attribute struct {
 type Relationship struct {
 	Reference string
 }
+
+// FieldAccess restricts who can read and write an attribute of the documents.
+// The zero value lets everyone that can access a document read and write it.
+type FieldAccess struct {
+	// Private attributes are never returned and cannot be filtered on
+	Private bool
+	// ReadOnly attributes are set by the server only: API keys and the
+	// system can write them, users and end users cannot
+	ReadOnly bool
+	// WriteOnce attributes can only be set when the document is created
+	WriteOnce bool
+	// ReadRole is the minimum project role that can read the attribute.
+	// Callers that are not members cannot read it, API keys always can.
+	// Empty allows everyone that can read the document.
+	ReadRole Role
+}
+
+// Validate returns an error if the access is invalid
+func (o FieldAccess) Validate() error {
+	if o.ReadRole != "" {
+		return o.ReadRole.Validate()
+	}
+
+	return nil
+}
+
+var attributeNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// systemAttributes are managed by the server, they cannot be declared
+var systemAttributes = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"deleted_at": true,
+	OwnerColumn:  true,
+}
+
+// Validate returns an error if the attribute cannot be declared by a user
+func (o *Attribute) Validate() error {
+	if !attributeNameRe.MatchString(o.Name) {
+		return Errorf(EValidation, "attribute name %q must be lowercase letters, digits or _", o.Name)
+	}
+
+	if systemAttributes[o.Name] {
+		return Errorf(EValidation, "attribute %s is managed by the server", o.Name)
+	}
+
+	switch o.Type {
+	case AttributeTypeString, AttributeTypeInteger, AttributeTypeNumeric, AttributeTypeFloat,
+		AttributeTypeBoolean, AttributeTypeTime, AttributeTypeUUID, AttributeTypeJSON:
+	default:
+		return Errorf(EValidation, "attribute %s has an invalid type: %q", o.Name, o.Type)
+	}
+
+	if o.Primary || o.AutoIncrement {
+		return Errorf(EValidation, "attribute %s cannot be a primary key", o.Name)
+	}
+
+	// clients could never create a document with a required read only attribute
+	if o.Required && o.Access.ReadOnly {
+		return Errorf(EValidation, "read only attribute %s cannot be required", o.Name)
+	}
+
+	return o.Access.Validate()
+}
@@ -0,0 +1,195 @@
+package goappbuild
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity is the authenticated caller of a request
+type Identity struct {
+	// UserID is the id of the authenticated user, a platform user
+	// or the end user of a project
+	UserID uuid.UUID
+	// APIKeyID is the id of the API key when the caller used one
+	APIKeyID uuid.UUID
+	// SessionID is the session of the caller when it is an end user
+	SessionID uuid.UUID
+	// ProjectID is the project the API key or the end user belongs to
+	ProjectID uuid.UUID
+	// Scopes are the scopes granted to the API key
+	Scopes []string
+	// MFA is true if the user verified a second factor at login
+	MFA bool
+	// ImpersonatorID is the admin acting as the user
+	ImpersonatorID uuid.UUID
+	// ExpiresAt is when the credentials of the caller expire,
+	// zero if they do not
+	ExpiresAt time.Time
+}
+
+// IsAuthenticated returns true if the identity belongs to an authenticated caller
+func (o Identity) IsAuthenticated() bool {
+	return o.UserID != uuid.Nil || o.IsAPIKey()
+}
+
+// IsAPIKey returns true if the caller authenticated with an API key
+func (o Identity) IsAPIKey() bool {
+	return o.APIKeyID != uuid.Nil
+}
+
+// IsImpersonated returns true if an admin is acting as the user
+func (o Identity) IsImpersonated() bool {
+	return o.ImpersonatorID != uuid.Nil
+}
+
+// Expired returns true if the credentials of the caller expired at the time
+func (o Identity) Expired(now time.Time) bool {
+	return !o.ExpiresAt.IsZero() && !now.Before(o.ExpiresAt)
+}
+
+// IsEndUser returns true if the caller is an end user of a project
+func (o Identity) IsEndUser() bool {
+	return o.SessionID != uuid.Nil
+}
+
+// HasScope returns true if the identity is allowed the scope.
+// Scopes only restrict API keys.
+func (o Identity) HasScope(scope string) bool {
+	if !o.IsAPIKey() {
+		return true
+	}
+
+	return ScopesAllow(o.Scopes, scope)
+}
+
+// CheckScope returns an error if the caller of the context authenticated with
+// an API key of another project or an API key that does not grant the scope
+func CheckScope(ctx context.Context, projectID uuid.UUID, scope string) error {
+	identity, ok := IdentityFromContext(ctx)
+	if !ok || !identity.IsAPIKey() {
+		return nil
+	}
+
+	if identity.ProjectID != projectID {
+		return Errorf(EForbidden, "api key does not belong to the project")
+	}
+
+	if !identity.HasScope(scope) {
+		return Errorf(EForbidden, "api key is missing the %q scope", scope)
+	}
+
+	return nil
+}
+
+type identityKey struct{}
+
+// ContextWithIdentity returns a copy of the context that carries the identity
+func ContextWithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity of the context if present
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+
+	return identity, ok && identity.IsAuthenticated()
+}
+
+type clientIPKey struct{}
+
+// ContextWithClientIP returns a copy of the context that carries the
+// address of the client of the request
+func ContextWithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext returns the address of the client of the request
+// or an empty string
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+
+	return ip
+}
+
+type systemKey struct{}
+
+// ContextWithSystem returns a copy of the context for the internal jobs that
+// do not act on behalf of a caller. The storage does not restrict their rows.
+func ContextWithSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// IsSystem returns true if the context belongs to an internal job
+func IsSystem(ctx context.Context) bool {
+	system, _ := ctx.Value(systemKey{}).(bool)
+
+	return system
+}
+
+// TokenPair holds the tokens issued on login
+type TokenPair struct {
+	// AccessToken is the short lived signed token used to authenticate requests
+	AccessToken string
+	// RefreshToken is the long lived opaque token used to get a new pair
+	RefreshToken string
+	// ExpiresAt is the expiration time of the access token
+	ExpiresAt time.Time
+	// MFAToken is returned instead of the tokens when the user has MFA
+	// enabled. It is exchanged for the tokens with AuthService.LoginMFA.
+	MFAToken string
+}
+
+// RefreshToken is a stored refresh token.
+// Refresh tokens are rotated on every use. All the tokens issued from the same
+// login share a family, if a revoked token is reused the whole family is revoked.
+type RefreshToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+	TokenHash string
+	// MFA is true if the login verified a second factor,
+	// the access tokens of the family keep it
+	MFA       bool
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// RefreshTokenRepo is the repository of the refresh tokens
+type RefreshTokenRepo interface {
+	Create(context.Context, *RefreshToken) error
+	// GetByHash returns the refresh token with the given hash
+	GetByHash(context.Context, string) (RefreshToken, error)
+	// Revoke revokes a single refresh token
+	Revoke(context.Context, uuid.UUID) error
+	// RevokeFamily revokes all the refresh tokens of a family
+	RevokeFamily(context.Context, uuid.UUID) error
+	// RevokeUser revokes all the refresh tokens of a user
+	RevokeUser(ctx context.Context, userID uuid.UUID) error
+}
+
+// AuthService is the service that issues and verifies tokens
+type AuthService interface {
+	// Login authenticates a user with email and password and issues a token pair.
+	// Users with MFA enabled get an MFA token instead.
+	Login(context.Context, LoginRequest) (TokenPair, error)
+	// LoginMFA verifies the second factor of a login and issues a token pair
+	LoginMFA(context.Context, LoginMFARequest) (TokenPair, error)
+	// LoginWithLink authenticates a user with a magic link and issues a token pair.
+	// Users with MFA enabled get an MFA token instead.
+	LoginWithLink(context.Context, LoginLinkRequest) (TokenPair, error)
+	// LoginWithCode authenticates a user with a one-time code and issues a token pair.
+	// Users with MFA enabled get an MFA token instead.
+	LoginWithCode(context.Context, LoginCodeRequest) (TokenPair, error)
+	// Refresh rotates a refresh token and issues a new token pair
+	Refresh(context.Context, string) (TokenPair, error)
+	// Logout revokes the refresh token
+	Logout(context.Context, string) error
+	// Authenticate verifies an access token and returns the identity of the caller
+	Authenticate(context.Context, string) (Identity, error)
+	// Impersonate issues a short lived access token of the user for the admin
+	// of the context. The token is flagged as impersonated and cannot be refreshed.
+	Impersonate(context.Context, ImpersonateRequest) (TokenPair, error)
+}
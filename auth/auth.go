@@ -0,0 +1,562 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+	"github.com/gosom/goappbuild/mfa"
+	"github.com/gosom/goappbuild/pkg/securetoken"
+	"github.com/gosom/goappbuild/security"
+)
+
+var _ goappbuild.AuthService = (*service)(nil)
+
+const (
+	// DefaultAccessTokenTTL is the default lifetime of an access token
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL is the default lifetime of a refresh token
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+	// DefaultIssuer is the default issuer of the access tokens
+	DefaultIssuer = "goappbuild"
+	// DefaultImpersonationTTL is the default lifetime of an impersonation token
+	DefaultImpersonationTTL = 10 * time.Minute
+
+	// mfaAudience is the audience of the MFA tokens, access tokens have none
+	mfaAudience = "mfa"
+	// maxMFATokenAttempts is the number of wrong codes after which an MFA
+	// token is rejected, the user has to log in with the password again
+	maxMFATokenAttempts = 5
+	// the authentication methods (RFC 8176) recorded in the access tokens
+	amrPassword = "pwd"
+	amrMFA      = "mfa"
+)
+
+var (
+	errInvalidToken        = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid or expired token")
+	errInvalidRefreshToken = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid or expired refresh token")
+	errInvalidMFAToken     = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid or expired mfa token")
+)
+
+// accessClaims are the claims of the access tokens
+type accessClaims struct {
+	jwt.RegisteredClaims
+	// AMR are the methods the user authenticated with
+	AMR []string `json:"amr,omitempty"`
+	// Act is the admin impersonating the user (RFC 8693)
+	Act *actorClaim `json:"act,omitempty"`
+}
+
+// actorClaim identifies the party acting as the subject of a token
+type actorClaim struct {
+	Subject string `json:"sub"`
+}
+
+// mfa returns true if the user verified a second factor
+func (o *accessClaims) mfa() bool {
+	for _, m := range o.AMR {
+		if m == amrMFA {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Config is the configuration of the auth service
+type Config struct {
+	// Secret is the key used to sign the access tokens
+	Secret []byte
+	// Issuer is the issuer of the access tokens
+	Issuer string
+	// AccessTokenTTL is the lifetime of an access token
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL is the lifetime of a refresh token
+	RefreshTokenTTL time.Duration
+	// ImpersonationTTL is the lifetime of an impersonation token
+	ImpersonationTTL time.Duration
+	// Guard protects the verification of the second factor of the logins.
+	// It should be the guard of the user service.
+	Guard goappbuild.LoginGuard
+}
+
+type service struct {
+	storage goappbuild.Storage
+	users   goappbuild.UserService
+	cfg     Config
+}
+
+// New returns a new auth service. Access tokens are HS256 signed JWTs and
+// refresh tokens are opaque random strings stored hashed.
+func New(storage goappbuild.Storage, users goappbuild.UserService, cfg Config) goappbuild.AuthService {
+	if cfg.Issuer == "" {
+		cfg.Issuer = DefaultIssuer
+	}
+
+	if cfg.AccessTokenTTL <= 0 {
+		cfg.AccessTokenTTL = DefaultAccessTokenTTL
+	}
+
+	if cfg.RefreshTokenTTL <= 0 {
+		cfg.RefreshTokenTTL = DefaultRefreshTokenTTL
+	}
+
+	if cfg.ImpersonationTTL <= 0 {
+		cfg.ImpersonationTTL = DefaultImpersonationTTL
+	}
+
+	if cfg.Guard == nil {
+		cfg.Guard = security.NewGuard(storage, security.Config{})
+	}
+
+	return &service{
+		storage: storage,
+		users:   users,
+		cfg:     cfg,
+	}
+}
+
+func (s *service) Login(ctx context.Context, req goappbuild.LoginRequest) (goappbuild.TokenPair, error) {
+	u, err := s.users.Login(ctx, req)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	return s.login(ctx, u)
+}
+
+// LoginWithLink authenticates a user with the token of a magic link
+func (s *service) LoginWithLink(ctx context.Context, req goappbuild.LoginLinkRequest) (goappbuild.TokenPair, error) {
+	u, err := s.users.LoginWithLink(ctx, req)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	return s.login(ctx, u)
+}
+
+// LoginWithCode authenticates a user with a one-time code
+func (s *service) LoginWithCode(ctx context.Context, req goappbuild.LoginCodeRequest) (goappbuild.TokenPair, error) {
+	u, err := s.users.LoginWithCode(ctx, req)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	return s.login(ctx, u)
+}
+
+// login issues the tokens of the authenticated user, or an MFA token
+// when the user has MFA enabled
+func (s *service) login(ctx context.Context, u goappbuild.User) (goappbuild.TokenPair, error) {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	factor, err := uw.MFA().GetFactor(ctx, u.ID)
+
+	switch {
+	case err == nil && factor.IsConfirmed():
+		return s.signMFAToken(u.ID, time.Now().UTC())
+	case err != nil && goappbuild.ErrorCode(err) != goappbuild.ENotFound:
+		return goappbuild.TokenPair{}, err
+	}
+
+	ans, err := s.issue(ctx, uw, u.ID, uuid.New(), false)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	return ans, nil
+}
+
+// LoginMFA verifies the second factor of a login. The MFA token is valid for
+// a few minutes and a few wrong codes, the user has to log in with the
+// password again after that. The wrong codes delay and lock out the user
+// and the client like the wrong passwords.
+func (s *service) LoginMFA(ctx context.Context, req goappbuild.LoginMFARequest) (goappbuild.TokenPair, error) {
+	claims := jwt.RegisteredClaims{}
+
+	_, err := jwt.ParseWithClaims(
+		req.MFAToken,
+		&claims,
+		func(*jwt.Token) (any, error) {
+			return s.cfg.Secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.cfg.Issuer),
+		jwt.WithAudience(mfaAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return goappbuild.TokenPair{}, errInvalidMFAToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil || claims.ID == "" {
+		return goappbuild.TokenPair{}, errInvalidMFAToken
+	}
+
+	tokenKey := "mfa_token:" + claims.ID
+
+	attempts, err := s.storage.LoginCounters().Get(ctx, tokenKey)
+
+	switch {
+	case err == nil && attempts.Failures >= maxMFATokenAttempts:
+		return goappbuild.TokenPair{}, errInvalidMFAToken
+	case err != nil && goappbuild.ErrorCode(err) != goappbuild.ENotFound:
+		return goappbuild.TokenPair{}, err
+	}
+
+	attempt := goappbuild.LoginAttempt{UserID: userID, MFA: true}
+
+	if err := s.cfg.Guard.Check(ctx, attempt); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if err := mfa.Verify(ctx, uw, userID, req.Code); err != nil {
+		if goappbuild.ErrorCode(err) != goappbuild.EUnauthorized {
+			return goappbuild.TokenPair{}, err
+		}
+
+		// the failures are recorded outside of the unit of work, which is
+		// rolled back, and the ones of the token only during its lifetime
+		since := time.Now().UTC().Add(-goappbuild.MFAChallengeTTL)
+
+		if _, ferr := s.storage.LoginCounters().Fail(ctx, tokenKey, since); ferr != nil {
+			return goappbuild.TokenPair{}, ferr
+		}
+
+		if ferr := s.cfg.Guard.Fail(ctx, attempt); ferr != nil {
+			return goappbuild.TokenPair{}, ferr
+		}
+
+		return goappbuild.TokenPair{}, err
+	}
+
+	ans, err := s.issue(ctx, uw, userID, uuid.New(), true)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	if err := s.cfg.Guard.Succeed(ctx, attempt); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	return ans, nil
+}
+
+// Refresh rotates the refresh token. When a token that was already rotated
+// is presented again all the tokens of its family are revoked, since either
+// the client or an attacker holds a stolen token.
+func (s *service) Refresh(ctx context.Context, token string) (goappbuild.TokenPair, error) {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	rt, err := s.getRefreshToken(ctx, uw, token)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	if rt.RevokedAt != nil {
+		if err := uw.RefreshTokens().RevokeFamily(ctx, rt.FamilyID); err != nil {
+			return goappbuild.TokenPair{}, err
+		}
+
+		if err := uw.Commit(ctx); err != nil {
+			return goappbuild.TokenPair{}, err
+		}
+
+		return goappbuild.TokenPair{}, errInvalidRefreshToken
+	}
+
+	if !rt.ExpiresAt.After(time.Now().UTC()) {
+		return goappbuild.TokenPair{}, errInvalidRefreshToken
+	}
+
+	if err := uw.RefreshTokens().Revoke(ctx, rt.ID); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	ans, err := s.issue(ctx, uw, rt.UserID, rt.FamilyID, rt.MFA)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	return ans, nil
+}
+
+// Logout revokes all the refresh tokens issued from the same login
+func (s *service) Logout(ctx context.Context, token string) error {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	rt, err := s.getRefreshToken(ctx, uw, token)
+	if err != nil {
+		return err
+	}
+
+	if err := uw.RefreshTokens().RevokeFamily(ctx, rt.FamilyID); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// Authenticate returns the identity of an access token. The tokens of the
+// disabled users and the tokens issued before a forced logout are rejected.
+func (s *service) Authenticate(ctx context.Context, token string) (goappbuild.Identity, error) {
+	claims := accessClaims{}
+
+	_, err := jwt.ParseWithClaims(
+		token,
+		&claims,
+		func(*jwt.Token) (any, error) {
+			return s.cfg.Secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.cfg.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	// MFA tokens are not access tokens
+	if err != nil || len(claims.Audience) > 0 {
+		return goappbuild.Identity{}, errInvalidToken
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return goappbuild.Identity{}, errInvalidToken
+	}
+
+	u, err := s.storage.Users().Get(ctx, userID)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.Identity{}, errInvalidToken
+		}
+
+		return goappbuild.Identity{}, err
+	}
+
+	if u.IsDisabled() || claims.IssuedAt == nil || u.TokenRevoked(claims.IssuedAt.Time) {
+		return goappbuild.Identity{}, errInvalidToken
+	}
+
+	ans := goappbuild.Identity{UserID: userID, MFA: claims.mfa(), ExpiresAt: claims.ExpiresAt.Time}
+
+	if claims.Act != nil {
+		ans.ImpersonatorID, err = uuid.Parse(claims.Act.Subject)
+		if err != nil {
+			return goappbuild.Identity{}, errInvalidToken
+		}
+	}
+
+	return ans, nil
+}
+
+// Impersonate issues an access token of the user for the admin of the context.
+// The token records the admin, it is not refreshed and it does not carry MFA.
+// The admins and the disabled users cannot be impersonated.
+func (s *service) Impersonate(ctx context.Context, req goappbuild.ImpersonateRequest) (goappbuild.TokenPair, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	admin, err := authz.Admin(ctx, uw)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	u, err := uw.Users().Get(ctx, req.UserID)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	switch {
+	case u.IsAdmin:
+		return goappbuild.TokenPair{}, goappbuild.Errorf(goappbuild.EForbidden, "admins cannot be impersonated")
+	case u.IsDisabled():
+		return goappbuild.TokenPair{}, goappbuild.Errorf(goappbuild.EConflict, "disabled users cannot be impersonated")
+	}
+
+	if err := security.Audit(ctx, uw, goappbuild.EventImpersonation, admin, u, req.Reason); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(s.cfg.ImpersonationTTL)
+
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.cfg.Issuer,
+			Subject:   u.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        uuid.NewString(),
+		},
+		Act: &actorClaim{Subject: admin.ID.String()},
+	}
+
+	token, err := s.sign(claims)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	return goappbuild.TokenPair{AccessToken: token, ExpiresAt: expiresAt}, nil
+}
+
+func (s *service) getRefreshToken(ctx context.Context, uw goappbuild.Storage, token string) (goappbuild.RefreshToken, error) {
+	if token == "" {
+		return goappbuild.RefreshToken{}, errInvalidRefreshToken
+	}
+
+	rt, err := uw.RefreshTokens().GetByHash(ctx, securetoken.Hash(token))
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.RefreshToken{}, errInvalidRefreshToken
+		}
+
+		return goappbuild.RefreshToken{}, err
+	}
+
+	return rt, nil
+}
+
+// issue creates a new access token and a new refresh token of the family
+func (s *service) issue(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	userID, familyID uuid.UUID,
+	verifiedMFA bool,
+) (goappbuild.TokenPair, error) {
+	now := time.Now().UTC()
+
+	access, expiresAt, err := s.signAccessToken(userID, verifiedMFA, now)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	refresh, err := securetoken.Generate("", securetoken.DefaultSize)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	rt := goappbuild.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: securetoken.Hash(refresh),
+		MFA:       verifiedMFA,
+		ExpiresAt: now.Add(s.cfg.RefreshTokenTTL),
+	}
+
+	if err := uw.RefreshTokens().Create(ctx, &rt); err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	ans := goappbuild.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresAt:    expiresAt,
+	}
+
+	return ans, nil
+}
+
+func (s *service) signAccessToken(userID uuid.UUID, verifiedMFA bool, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(s.cfg.AccessTokenTTL)
+
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.cfg.Issuer,
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        uuid.NewString(),
+		},
+		AMR: []string{amrPassword},
+	}
+
+	if verifiedMFA {
+		claims.AMR = append(claims.AMR, amrMFA)
+	}
+
+	token, err := s.sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// signMFAToken returns the token of the second step of a login
+func (s *service) signMFAToken(userID uuid.UUID, now time.Time) (goappbuild.TokenPair, error) {
+	expiresAt := now.Add(goappbuild.MFAChallengeTTL)
+
+	claims := jwt.RegisteredClaims{
+		Issuer:    s.cfg.Issuer,
+		Subject:   userID.String(),
+		Audience:  jwt.ClaimStrings{mfaAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		ID:        uuid.NewString(),
+	}
+
+	token, err := s.sign(claims)
+	if err != nil {
+		return goappbuild.TokenPair{}, err
+	}
+
+	return goappbuild.TokenPair{MFAToken: token, ExpiresAt: expiresAt}, nil
+}
+
+func (s *service) sign(claims jwt.Claims) (string, error) {
+	if len(s.cfg.Secret) == 0 {
+		return "", errors.New("auth secret is not configured")
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.cfg.Secret)
+}
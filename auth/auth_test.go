@@ -0,0 +1,401 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/auth"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/mfa"
+	"github.com/gosom/goappbuild/pkg/totp"
+	"github.com/gosom/goappbuild/security"
+	"github.com/gosom/goappbuild/users"
+)
+
+func Test_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("test-secret")
+	storage := memstore.New()
+	svc := auth.New(storage, nil, auth.Config{Secret: secret})
+
+	newUser := func(t *testing.T) goappbuild.User {
+		t.Helper()
+
+		u := goappbuild.User{Email: uuid.NewString() + "@example.com", PasswordHash: "hash"}
+		require.NoError(t, storage.Users().Create(context.Background(), &u))
+
+		return u
+	}
+
+	userID := newUser(t).ID
+
+	sign := func(t *testing.T, method jwt.SigningMethod, key any, claims jwt.RegisteredClaims) string {
+		t.Helper()
+
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+
+		return token
+	}
+
+	claims := func(exp time.Time) jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Issuer:    auth.DefaultIssuer,
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+
+		exp := time.Now().Add(time.Minute)
+		token := sign(t, jwt.SigningMethodHS256, secret, claims(exp))
+
+		identity, err := svc.Authenticate(context.Background(), token)
+		require.NoError(t, err)
+		require.Equal(t, userID, identity.UserID)
+		require.WithinDuration(t, exp, identity.ExpiresAt, time.Second)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		t.Parallel()
+
+		token := sign(t, jwt.SigningMethodHS256, secret, claims(time.Now().Add(-time.Minute)))
+
+		_, err := svc.Authenticate(context.Background(), token)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		t.Parallel()
+
+		token := sign(t, jwt.SigningMethodHS256, []byte("other"), claims(time.Now().Add(time.Minute)))
+
+		_, err := svc.Authenticate(context.Background(), token)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("none algorithm", func(t *testing.T) {
+		t.Parallel()
+
+		token := sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims(time.Now().Add(time.Minute)))
+
+		_, err := svc.Authenticate(context.Background(), token)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("wrong issuer", func(t *testing.T) {
+		t.Parallel()
+
+		c := claims(time.Now().Add(time.Minute))
+		c.Issuer = "other"
+
+		token := sign(t, jwt.SigningMethodHS256, secret, c)
+
+		_, err := svc.Authenticate(context.Background(), token)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		t.Parallel()
+
+		c := claims(time.Now().Add(time.Minute))
+		c.Subject = uuid.NewString()
+
+		token := sign(t, jwt.SigningMethodHS256, secret, c)
+
+		_, err := svc.Authenticate(context.Background(), token)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("disabled user", func(t *testing.T) {
+		t.Parallel()
+
+		u := newUser(t)
+		now := time.Now().UTC()
+		u.DisabledAt = &now
+		require.NoError(t, storage.Users().Update(context.Background(), &u))
+
+		c := claims(time.Now().Add(time.Minute))
+		c.Subject = u.ID.String()
+
+		token := sign(t, jwt.SigningMethodHS256, secret, c)
+
+		_, err := svc.Authenticate(context.Background(), token)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("revoked tokens", func(t *testing.T) {
+		t.Parallel()
+
+		u := newUser(t)
+		revokedAt := time.Now().UTC()
+		u.TokensRevokedAt = &revokedAt
+		require.NoError(t, storage.Users().Update(context.Background(), &u))
+
+		c := claims(time.Now().Add(time.Minute))
+		c.Subject = u.ID.String()
+		c.IssuedAt = jwt.NewNumericDate(revokedAt.Add(-time.Second))
+
+		_, err := svc.Authenticate(context.Background(), sign(t, jwt.SigningMethodHS256, secret, c))
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		c.IssuedAt = jwt.NewNumericDate(revokedAt.Add(time.Second))
+
+		identity, err := svc.Authenticate(context.Background(), sign(t, jwt.SigningMethodHS256, secret, c))
+		require.NoError(t, err)
+		require.Equal(t, u.ID, identity.UserID)
+	})
+}
+
+func Test_LoginMFA(t *testing.T) {
+	storage := memstore.New()
+	userService := users.New(storage, users.Config{})
+	svc := auth.New(storage, userService, auth.Config{Secret: []byte("test-secret")})
+	mfaService := mfa.New(storage, mfa.Config{})
+	ctx := context.Background()
+
+	u, err := userService.Register(ctx, goappbuild.RegisterUserRequest{Email: "jane@example.com", Password: "correct horse"})
+	require.NoError(t, err)
+
+	login := goappbuild.LoginRequest{Email: u.Email, Password: "correct horse"}
+
+	pair, err := svc.Login(ctx, login)
+	require.NoError(t, err)
+	require.Empty(t, pair.MFAToken)
+
+	identity, err := svc.Authenticate(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	require.False(t, identity.MFA)
+
+	userCtx := goappbuild.ContextWithIdentity(ctx, identity)
+
+	enrollment, err := mfaService.Enroll(userCtx)
+	require.NoError(t, err)
+
+	code := func(offset int64) string {
+		c, err := totp.Code(enrollment.Secret, totp.Step(time.Now())+offset)
+		require.NoError(t, err)
+
+		return c
+	}
+
+	_, err = mfaService.Confirm(userCtx, code(0))
+	require.NoError(t, err)
+
+	pair, err = svc.Login(ctx, login)
+	require.NoError(t, err)
+	require.NotEmpty(t, pair.MFAToken)
+	require.Empty(t, pair.AccessToken)
+	require.Empty(t, pair.RefreshToken)
+
+	t.Run("test mfa token is not an access token", func(t *testing.T) {
+		_, err := svc.Authenticate(ctx, pair.MFAToken)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test invalid code", func(t *testing.T) {
+		_, err := svc.LoginMFA(ctx, goappbuild.LoginMFARequest{MFAToken: pair.MFAToken, Code: "000000"})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test access token is not an mfa token", func(t *testing.T) {
+		_, err := svc.LoginMFA(ctx, goappbuild.LoginMFARequest{MFAToken: "invalid", Code: code(1)})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	verified, err := svc.LoginMFA(ctx, goappbuild.LoginMFARequest{MFAToken: pair.MFAToken, Code: code(1)})
+	require.NoError(t, err)
+
+	identity, err = svc.Authenticate(ctx, verified.AccessToken)
+	require.NoError(t, err)
+	require.True(t, identity.MFA)
+
+	t.Run("test refreshed tokens keep mfa", func(t *testing.T) {
+		refreshed, err := svc.Refresh(ctx, verified.RefreshToken)
+		require.NoError(t, err)
+
+		identity, err := svc.Authenticate(ctx, refreshed.AccessToken)
+		require.NoError(t, err)
+		require.True(t, identity.MFA)
+	})
+}
+
+func Test_LoginMFA_Guard(t *testing.T) {
+	storage := memstore.New()
+	ctx := context.Background()
+
+	newService := func(threshold int) goappbuild.AuthService {
+		guard := security.NewGuard(storage, security.Config{
+			DelayAfter:         threshold,
+			LockoutThreshold:   threshold,
+			IPLockoutThreshold: threshold,
+			LockoutDuration:    time.Hour,
+		})
+
+		userService := users.New(storage, users.Config{Guard: guard})
+
+		return auth.New(storage, userService, auth.Config{Secret: []byte("test-secret"), Guard: guard})
+	}
+
+	strict, lenient := newService(3), newService(100)
+	userService := users.New(storage, users.Config{})
+	mfaService := mfa.New(storage, mfa.Config{})
+
+	// newUser returns the login of a user with mfa enabled and a valid code
+	newUser := func(t *testing.T, email string) (goappbuild.LoginRequest, string) {
+		t.Helper()
+
+		_, err := userService.Register(ctx, goappbuild.RegisterUserRequest{Email: email, Password: "correct horse"})
+		require.NoError(t, err)
+
+		login := goappbuild.LoginRequest{Email: email, Password: "correct horse"}
+
+		pair, err := lenient.Login(ctx, login)
+		require.NoError(t, err)
+
+		identity, err := lenient.Authenticate(ctx, pair.AccessToken)
+		require.NoError(t, err)
+
+		userCtx := goappbuild.ContextWithIdentity(ctx, identity)
+
+		enrollment, err := mfaService.Enroll(userCtx)
+		require.NoError(t, err)
+
+		c, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+		require.NoError(t, err)
+
+		_, err = mfaService.Confirm(userCtx, c)
+		require.NoError(t, err)
+
+		c, err = totp.Code(enrollment.Secret, totp.Step(time.Now())+1)
+		require.NoError(t, err)
+
+		return login, c
+	}
+
+	t.Run("test wrong codes lock out the user", func(t *testing.T) {
+		login, code := newUser(t, "locked@example.com")
+
+		pair, err := strict.Login(ctx, login)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := strict.LoginMFA(ctx, goappbuild.LoginMFARequest{MFAToken: pair.MFAToken, Code: "000000"})
+			require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+		}
+
+		_, err = strict.LoginMFA(ctx, goappbuild.LoginMFARequest{MFAToken: pair.MFAToken, Code: code})
+		require.Equal(t, goappbuild.ETooManyRequests, goappbuild.ErrorCode(err))
+
+		// a new login with the password does not clear the failures
+		pair, err = strict.Login(ctx, login)
+		require.NoError(t, err)
+
+		_, err = strict.LoginMFA(ctx, goappbuild.LoginMFARequest{MFAToken: pair.MFAToken, Code: code})
+		require.Equal(t, goappbuild.ETooManyRequests, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test mfa tokens allow a few wrong codes", func(t *testing.T) {
+		login, code := newUser(t, "token@example.com")
+
+		pair, err := lenient.Login(ctx, login)
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			_, err := lenient.LoginMFA(ctx, goappbuild.LoginMFARequest{MFAToken: pair.MFAToken, Code: "000000"})
+			require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+		}
+
+		_, err = lenient.LoginMFA(ctx, goappbuild.LoginMFARequest{MFAToken: pair.MFAToken, Code: code})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		pair, err = lenient.Login(ctx, login)
+		require.NoError(t, err)
+
+		_, err = lenient.LoginMFA(ctx, goappbuild.LoginMFARequest{MFAToken: pair.MFAToken, Code: code})
+		require.NoError(t, err)
+	})
+}
+
+func Test_Impersonate(t *testing.T) {
+	storage := memstore.New()
+	userService := users.New(storage, users.Config{})
+	svc := auth.New(storage, userService, auth.Config{Secret: []byte("test-secret")})
+	mfaService := mfa.New(storage, mfa.Config{})
+	ctx := goappbuild.ContextWithClientIP(context.Background(), "192.0.2.10")
+
+	admin := goappbuild.User{Email: "admin@example.com", IsAdmin: true}
+	require.NoError(t, storage.UserRepo.Create(ctx, &admin))
+
+	other := goappbuild.User{Email: "other-admin@example.com", IsAdmin: true}
+	require.NoError(t, storage.UserRepo.Create(ctx, &other))
+
+	u, err := userService.Register(ctx, goappbuild.RegisterUserRequest{Email: "jane@example.com", Password: "correct horse"})
+	require.NoError(t, err)
+
+	adminCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: admin.ID})
+	req := goappbuild.ImpersonateRequest{UserID: u.ID, Reason: "ticket 42"}
+
+	t.Run("test only admins", func(t *testing.T) {
+		userCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: u.ID})
+
+		_, err := svc.Impersonate(userCtx, goappbuild.ImpersonateRequest{UserID: admin.ID, Reason: "x"})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test invalid", func(t *testing.T) {
+		_, err := svc.Impersonate(adminCtx, goappbuild.ImpersonateRequest{UserID: u.ID})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		_, err = svc.Impersonate(adminCtx, goappbuild.ImpersonateRequest{UserID: other.ID, Reason: "x"})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	pair, err := svc.Impersonate(adminCtx, req)
+	require.NoError(t, err)
+	require.Empty(t, pair.RefreshToken)
+	require.WithinDuration(t, time.Now().Add(auth.DefaultImpersonationTTL), pair.ExpiresAt, time.Minute)
+
+	identity, err := svc.Authenticate(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, u.ID, identity.UserID)
+	require.Equal(t, admin.ID, identity.ImpersonatorID)
+	require.True(t, identity.IsImpersonated())
+	require.False(t, identity.MFA)
+
+	events, err := storage.SecurityEventRepo.List(ctx, goappbuild.SecurityEventFilter{
+		Kind:  goappbuild.EventImpersonation,
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, u.ID, events[0].UserID)
+	require.Equal(t, admin.ID, events[0].ActorID)
+	require.Equal(t, "ticket 42", events[0].Detail)
+	require.Equal(t, "192.0.2.10", events[0].IP)
+
+	t.Run("test impersonated sessions cannot change the credentials", func(t *testing.T) {
+		impersonated := goappbuild.ContextWithIdentity(ctx, identity)
+
+		_, err := mfaService.Enroll(impersonated)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test disabled users", func(t *testing.T) {
+		_, err := userService.Disable(adminCtx, u.ID)
+		require.NoError(t, err)
+
+		_, err = svc.Impersonate(adminCtx, req)
+		require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+	})
+}
@@ -0,0 +1,300 @@
+// Package authz contains the authorization checks shared by the services.
+package authz
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+)
+
+var (
+	errUnauthenticated = goappbuild.Errorf(goappbuild.EUnauthorized, "authentication required")
+	errNoAccess        = goappbuild.Errorf(goappbuild.EForbidden, "you do not have access to the project")
+	errImpersonated    = goappbuild.Errorf(goappbuild.EForbidden, "impersonated sessions are not allowed to perform this operation")
+)
+
+// Project returns the project if the caller of the context is allowed to access it
+// with at least the given role. Users must be members of the project and API keys
+// must belong to it; what API keys can do is restricted by their scopes instead.
+// End users are never allowed.
+func Project(
+	ctx context.Context,
+	storage goappbuild.Storage,
+	projectID uuid.UUID,
+	role goappbuild.Role,
+) (goappbuild.Project, error) {
+	project, _, err := Member(ctx, storage, projectID, role)
+
+	return project, err
+}
+
+// Member is like Project and returns the grant of the member too
+func Member(
+	ctx context.Context,
+	storage goappbuild.Storage,
+	projectID uuid.UUID,
+	role goappbuild.Role,
+) (goappbuild.Project, Grant, error) {
+	identity, ok := goappbuild.IdentityFromContext(ctx)
+	if !ok {
+		return goappbuild.Project{}, Grant{}, errUnauthenticated
+	}
+
+	if identity.IsEndUser() || (identity.IsAPIKey() && identity.ProjectID != projectID) {
+		return goappbuild.Project{}, Grant{}, errNoAccess
+	}
+
+	project, err := storage.Projects().Get(ctx, projectID)
+	if err != nil {
+		return goappbuild.Project{}, Grant{}, err
+	}
+
+	if identity.IsAPIKey() {
+		return project, Grant{Member: true}, nil
+	}
+
+	member, err := storage.Members().Get(ctx, projectID, identity.UserID)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.Project{}, Grant{}, errNoAccess
+		}
+
+		return goappbuild.Project{}, Grant{}, err
+	}
+
+	if !member.Role.AtLeast(role) {
+		return goappbuild.Project{}, Grant{}, goappbuild.Errorf(
+			goappbuild.EForbidden,
+			"the %s role is required, you are %s", role, member.Role,
+		)
+	}
+
+	return project, Grant{Member: true, Role: member.Role}, nil
+}
+
+// User returns the identity of the caller if it is a platform user.
+// API keys and end users are rejected.
+func User(ctx context.Context) (goappbuild.Identity, error) {
+	identity, ok := goappbuild.IdentityFromContext(ctx)
+	if !ok {
+		return goappbuild.Identity{}, errUnauthenticated
+	}
+
+	if identity.IsAPIKey() {
+		return goappbuild.Identity{}, goappbuild.Errorf(goappbuild.EForbidden, "api keys are not allowed to perform this operation")
+	}
+
+	if identity.IsEndUser() {
+		return goappbuild.Identity{}, goappbuild.Errorf(goappbuild.EForbidden, "end users are not allowed to perform this operation")
+	}
+
+	return identity, nil
+}
+
+// Self returns the identity of the caller if it is a platform user acting as
+// themselves. The admins impersonating a user cannot change the credentials.
+func Self(ctx context.Context) (goappbuild.Identity, error) {
+	identity, err := User(ctx)
+	if err != nil {
+		return goappbuild.Identity{}, err
+	}
+
+	if identity.IsImpersonated() {
+		return goappbuild.Identity{}, errImpersonated
+	}
+
+	return identity, nil
+}
+
+// Admin returns the user of the caller if it is an admin of the platform.
+// Impersonated sessions are never admins.
+func Admin(ctx context.Context, storage goappbuild.Storage) (goappbuild.User, error) {
+	identity, err := Self(ctx)
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	u, err := storage.Users().Get(ctx, identity.UserID)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.User{}, errUnauthenticated
+		}
+
+		return goappbuild.User{}, err
+	}
+
+	if !u.IsAdmin {
+		return goappbuild.User{}, goappbuild.Errorf(goappbuild.EForbidden, "admin access is required")
+	}
+
+	return u, nil
+}
+
+// Grant is the access of the caller to the documents of a collection
+type Grant struct {
+	// Member is true when the caller is a project member or an API key
+	// of the project. Members are not restricted by the collection rules.
+	Member bool
+	// Role is the role of the member, it is empty for API keys
+	Role goappbuild.Role
+	// OwnerID is set when the caller can only access the documents it owns
+	OwnerID uuid.UUID
+	// SharedWith is set when the caller can access the documents shared with it
+	// through the ACL with one of the permissions
+	SharedWith  uuid.UUID
+	Permissions []goappbuild.Permission
+}
+
+// Restricted returns true if the caller can only access the documents it owns
+// or that are shared with it
+func (o Grant) Restricted() bool {
+	return o.OwnerID != uuid.Nil || o.SharedWith != uuid.Nil
+}
+
+// Access returns the restriction of the queries of a restricted caller
+func (o Grant) Access(projectID uuid.UUID) goappbuild.Access {
+	return goappbuild.Access{
+		ProjectID:   projectID,
+		OwnerID:     o.OwnerID,
+		GranteeID:   o.SharedWith,
+		Permissions: o.Permissions,
+	}
+}
+
+// Documents authorizes the action on the documents of a collection.
+// Project members are allowed according to their role and API keys according
+// to their scopes, everyone else according to the rules of the collection.
+// Authenticated callers are also allowed on the documents shared with them.
+func Documents(
+	ctx context.Context,
+	storage goappbuild.Storage,
+	projectID uuid.UUID,
+	collectionName string,
+	action goappbuild.Action,
+) (goappbuild.Project, goappbuild.Collection, Grant, error) {
+	identity, authenticated := goappbuild.IdentityFromContext(ctx)
+
+	if identity.IsAPIKey() {
+		if err := goappbuild.CheckScope(ctx, projectID, action.Scope(collectionName)); err != nil {
+			return goappbuild.Project{}, goappbuild.Collection{}, Grant{}, err
+		}
+	}
+
+	// end users are only known to their project
+	if identity.IsEndUser() && identity.ProjectID != projectID {
+		return goappbuild.Project{}, goappbuild.Collection{}, Grant{}, errNoAccess
+	}
+
+	project, err := storage.Projects().Get(ctx, projectID)
+	if err != nil {
+		return goappbuild.Project{}, goappbuild.Collection{}, Grant{}, err
+	}
+
+	role, member, err := isMember(ctx, storage, identity, projectID, action.Role())
+	if err != nil {
+		return goappbuild.Project{}, goappbuild.Collection{}, Grant{}, err
+	}
+
+	collection, err := storage.Collections().GetByName(ctx, projectID, collectionName)
+	if err != nil {
+		// do not reveal the collections of the project to non members
+		if !member && goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			err = denied(authenticated)
+		}
+
+		return goappbuild.Project{}, goappbuild.Collection{}, Grant{}, err
+	}
+
+	if member {
+		return project, collection, Grant{Member: true, Role: role}, nil
+	}
+
+	var grant Grant
+
+	switch collection.Options.Rules.Rule(action) {
+	case goappbuild.RulePublic:
+	case goappbuild.RuleAuthenticated:
+		if !authenticated {
+			return goappbuild.Project{}, goappbuild.Collection{}, Grant{}, errUnauthenticated
+		}
+	case goappbuild.RuleOwner:
+		if !authenticated || identity.UserID == uuid.Nil {
+			return goappbuild.Project{}, goappbuild.Collection{}, Grant{}, errUnauthenticated
+		}
+
+		grant.OwnerID = identity.UserID
+		grant.SharedWith, grant.Permissions = identity.UserID, action.Permissions()
+	default:
+		shared, err := isShared(ctx, storage, identity, projectID, collection.Name, action)
+		if err != nil {
+			return goappbuild.Project{}, goappbuild.Collection{}, Grant{}, err
+		}
+
+		if !shared {
+			return goappbuild.Project{}, goappbuild.Collection{}, Grant{}, denied(authenticated)
+		}
+
+		grant.SharedWith, grant.Permissions = identity.UserID, action.Permissions()
+	}
+
+	return project, collection, grant, nil
+}
+
+// isMember returns true if the identity is an API key of the project or
+// a member of the project with at least the role. End users are never members.
+// The role of the member is returned too.
+func isMember(
+	ctx context.Context,
+	storage goappbuild.Storage,
+	identity goappbuild.Identity,
+	projectID uuid.UUID,
+	role goappbuild.Role,
+) (goappbuild.Role, bool, error) {
+	if identity.IsAPIKey() {
+		return "", true, nil
+	}
+
+	if identity.UserID == uuid.Nil || identity.IsEndUser() {
+		return "", false, nil
+	}
+
+	m, err := storage.Members().Get(ctx, projectID, identity.UserID)
+	if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	return m.Role, m.Role.AtLeast(role), nil
+}
+
+// isShared returns true if any document of the collection is shared with
+// the user of the identity with a permission that allows the action
+func isShared(
+	ctx context.Context,
+	storage goappbuild.Storage,
+	identity goappbuild.Identity,
+	projectID uuid.UUID,
+	collection string,
+	action goappbuild.Action,
+) (bool, error) {
+	permissions := action.Permissions()
+
+	if identity.UserID == uuid.Nil || len(permissions) == 0 {
+		return false, nil
+	}
+
+	return storage.ACL().Exists(ctx, projectID, collection, identity.UserID, permissions...)
+}
+
+func denied(authenticated bool) error {
+	if !authenticated {
+		return errUnauthenticated
+	}
+
+	return errNoAccess
+}
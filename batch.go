@@ -0,0 +1,89 @@
+package goappbuild
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BatchOpType is the type of a batch operation
+type BatchOpType string
+
+const (
+	// BatchOpCreate creates a document
+	BatchOpCreate BatchOpType = "create"
+	// BatchOpUpdate updates a document
+	BatchOpUpdate BatchOpType = "update"
+	// BatchOpDelete deletes a document
+	BatchOpDelete BatchOpType = "delete"
+	// BatchOpGet reads a document
+	BatchOpGet BatchOpType = "get"
+)
+
+// BatchOperation is a single operation of a batch
+type BatchOperation struct {
+	// Type is the type of the operation
+	Type BatchOpType
+	// Collection is the name of the collection the operation applies to
+	Collection string
+	// ID is the id of the document for update, delete and get.
+	// It can be a BatchRef to the result of an earlier operation.
+	ID any
+	// Data holds the values for create and update.
+	// Any value can be a BatchRef to the result of an earlier operation.
+	Data map[string]any
+}
+
+// BatchRef references a field of the document returned by an earlier
+// operation of the same batch
+type BatchRef struct {
+	// Step is the index of the referenced operation
+	Step int
+	// Field is the name of the referenced field
+	Field string
+}
+
+// ParseBatchRef parses a reference in the form <step>.<field> (e.g 0.id)
+func ParseBatchRef(s string) (BatchRef, error) {
+	step, field, ok := strings.Cut(s, ".")
+	if !ok || field == "" {
+		return BatchRef{}, Errorf(EValidation, "invalid reference %q: expected <step>.<field>", s)
+	}
+
+	n, err := strconv.Atoi(step)
+	if err != nil || n < 0 {
+		return BatchRef{}, Errorf(EValidation, "invalid reference %q: invalid step", s)
+	}
+
+	return BatchRef{Step: n, Field: field}, nil
+}
+
+// BatchResult is the result of a single batch operation
+type BatchResult struct {
+	// Type is the type of the operation
+	Type BatchOpType
+	// Collection is the collection the operation applied to
+	Collection string
+	// Document is the document returned by the operation
+	// (empty for delete)
+	Document Document
+}
+
+// BatchError is returned when an operation of a batch fails.
+// All the operations of the batch are rolled back.
+type BatchError struct {
+	// Step is the index of the failed operation
+	Step int
+	// Err is the error of the failed operation
+	Err error
+}
+
+// Error implements the error interface.
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch operation %d failed: %v", e.Step, e.Err)
+}
+
+// Unwrap returns the error of the failed operation.
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,4 @@
+-- The application connects with its own role: the row level security policies
+-- of the collection tables do not apply to superusers and BYPASSRLS roles.
+CREATE ROLE goappbuild LOGIN PASSWORD 'goappbuild' NOSUPERUSER NOBYPASSRLS;
+CREATE DATABASE goappbuild OWNER goappbuild;
@@ -4,11 +4,20 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"log"
 	"os"
+	"time"
 
 	"github.com/gosom/goappbuild"
 	"github.com/gosom/goappbuild/api"
+	"github.com/gosom/goappbuild/apikeys"
+	"github.com/gosom/goappbuild/auth"
 	"github.com/gosom/goappbuild/collections"
+	"github.com/gosom/goappbuild/endusers"
+	"github.com/gosom/goappbuild/events"
+	"github.com/gosom/goappbuild/mailer"
+	"github.com/gosom/goappbuild/members"
+	"github.com/gosom/goappbuild/mfa"
 	"github.com/gosom/goappbuild/pkg/cfgreader"
 	"github.com/gosom/goappbuild/pkg/httpext"
 	"github.com/gosom/goappbuild/pkg/restapi"
@@ -16,6 +25,9 @@ import (
 	"github.com/gosom/goappbuild/postgres"
 	"github.com/gosom/goappbuild/projects"
 	"github.com/gosom/goappbuild/queries"
+	"github.com/gosom/goappbuild/security"
+	"github.com/gosom/goappbuild/sso"
+	"github.com/gosom/goappbuild/teams"
 	"github.com/gosom/goappbuild/users"
 )
 
@@ -36,6 +48,15 @@ var specFs embed.FS
 // @accept json
 // @produce json
 // @query.collection.format multi
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and the access token or the API key.
+
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
 func main() {
 	ctx := context.Background()
 
@@ -51,21 +72,98 @@ func run(ctx context.Context) error {
 		return err
 	}
 
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
 	db, err := sqlext.OpenPsqlConn(cfg.getDBConn())
 	if err != nil {
 		return err
 	}
 
+	// the row level security policies do not apply to superusers
+	if err := postgres.CheckRole(ctx, db); err != nil {
+		return err
+	}
+
+	// the tables of the collections created before the policies get them
+	if err := postgres.SyncPolicies(ctx, db); err != nil {
+		return err
+	}
+
 	storage := postgres.NewUnitOfWork(db)
 
+	m, err := newMailer(cfg)
+	if err != nil {
+		return err
+	}
+
+	guard, err := newGuard(storage, cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	userCfg := users.Config{
+		Mailer:           m,
+		VerifyEmailURL:   cfg.AppURL + "/verify-email",
+		ResetPasswordURL: cfg.AppURL + "/reset-password",
+		LoginLinkURL:     cfg.AppURL + "/login/link",
+		Guard:            guard,
+
+		PasswordlessLimit:  cfg.PasswordlessLimit,
+		PasswordlessWindow: cfg.PasswordlessWindow,
+		Logger:             logger,
+	}
+
+	userService := users.New(storage, userCfg)
+
+	authCfg := auth.Config{
+		Secret:           []byte(cfg.AuthSecret),
+		Issuer:           cfg.AuthIssuer,
+		AccessTokenTTL:   cfg.AccessTokenTTL,
+		RefreshTokenTTL:  cfg.RefreshTokenTTL,
+		ImpersonationTTL: cfg.ImpersonationTTL,
+		Guard:            guard,
+	}
+
+	bus := events.New(events.WithLogger(logger))
+	// cluster carries the document events of all the instances
+	cluster := events.New(events.WithLogger(logger))
+	relay := postgres.NewEventRelay(db, bus, cluster, logger)
+
+	go func() {
+		_ = relay.Run(ctx)
+	}()
+
+	queryOpts := []queries.Option{
+		queries.WithMaxAffectedRows(cfg.QueryMaxAffectedRows),
+		queries.WithEvents(bus),
+	}
+
 	app := goappbuild.App{
-		Users:       users.New(storage),
-		Projects:    projects.New(storage),
-		Collections: collections.New(storage),
-		Queries:     queries.New(storage),
+		Users:       userService,
+		Projects:    projects.New(storage, projects.WithEvents(bus)),
+		Collections: collections.New(storage, collections.WithEvents(bus)),
+		Queries:     queries.New(storage, queryOpts...),
+		Auth:        auth.New(storage, userService, authCfg),
+		APIKeys:     apikeys.New(storage),
+		Members:     members.New(storage),
+		EndUsers:    endusers.New(storage, endusers.Config{SessionTTL: cfg.SessionTTL, Guard: guard}),
+		SSO:         sso.New(storage, sso.Config{SessionTTL: cfg.SessionTTL}),
+		MFA:         mfa.New(storage, mfa.Config{Issuer: cfg.AuthIssuer}),
+		Security:    security.New(storage),
+		Shares:      queries.NewShareService(storage, queries.ShareConfig{Secret: []byte(cfg.AuthSecret)}),
+		ACL:         queries.NewACLService(storage),
+		Teams:       teams.New(storage),
+		Events:      bus,
+		Realtime:    queries.NewRealtimeService(storage, cluster),
 	}
 
-	router, err := api.NewRouter(&app, specFs)
+	sweeper := queries.NewSweeper(storage, cfg.TrashSweepInterval, logger)
+
+	go func() {
+		_ = sweeper.Run(ctx)
+	}()
+
+	router, err := api.NewRouter(&app, specFs, api.WithTrustProxy(cfg.TrustProxy))
 	if err != nil {
 		return err
 	}
@@ -90,17 +188,87 @@ type Config struct {
 	PostgresHost string `envconfig:"POSTGRES_HOST" default:"localhost"`
 	// PostgresPort is the port of the postgres database.
 	PostgresPort int `envconfig:"POSTGRES_PORT" default:"5432"`
-	// PostgresUser is the user of the postgres database.
-	PostgresUser string `envconfig:"POSTGRES_USER" default:"postgres"`
+	// PostgresUser is the user of the postgres database. It must be neither
+	// a superuser nor have BYPASSRLS, the application refuses to start otherwise.
+	PostgresUser string `envconfig:"POSTGRES_USER" default:"goappbuild"`
 	// PostgresPassword is the password of the postgres database.
-	PostgresPassword string `envconfig:"POSTGRES_PASSWORD" default:"postgres"`
+	PostgresPassword string `envconfig:"POSTGRES_PASSWORD" default:"goappbuild"`
 	// PostgresDatabase is the database of the postgres database.
-	PostgresDB string `envconfig:"POSTGRES_DB" default:"postgres"`
+	PostgresDB string `envconfig:"POSTGRES_DB" default:"goappbuild"`
 
 	// ServerHost is the host of the server.
 	ServerHost string `envconfig:"SERVER_HOST" default:"127.0.0.1"`
 	// ServerPort is the port of the server.
 	ServerPort int `envconfig:"SERVER_PORT" default:"8080"`
+	// TrustProxy takes the client addresses from the X-Forwarded-For and
+	// X-Real-IP headers. Enable it only behind a reverse proxy that sets them.
+	TrustProxy bool `envconfig:"TRUST_PROXY" default:"false"`
+
+	// QueryMaxAffectedRows is the maximum number of documents an update
+	// or delete by query is allowed to affect (0 disables the limit).
+	QueryMaxAffectedRows int `envconfig:"QUERY_MAX_AFFECTED_ROWS" default:"1000"`
+	// TrashSweepInterval is how often the trashed documents that exceeded
+	// the retention period of their collection are purged.
+	TrashSweepInterval time.Duration `envconfig:"TRASH_SWEEP_INTERVAL" default:"1h"`
+
+	// AuthSecret is the secret used to sign the access tokens.
+	AuthSecret string `envconfig:"AUTH_SECRET" required:"true"`
+	// AuthIssuer is the issuer of the access tokens.
+	AuthIssuer string `envconfig:"AUTH_ISSUER" default:"goappbuild"`
+	// AccessTokenTTL is the lifetime of the access tokens.
+	AccessTokenTTL time.Duration `envconfig:"ACCESS_TOKEN_TTL" default:"15m"`
+	// RefreshTokenTTL is the lifetime of the refresh tokens.
+	RefreshTokenTTL time.Duration `envconfig:"REFRESH_TOKEN_TTL" default:"720h"`
+	// ImpersonationTTL is the lifetime of the tokens the admins get to act as a user.
+	ImpersonationTTL time.Duration `envconfig:"IMPERSONATION_TTL" default:"10m"`
+	// SessionTTL is the lifetime of the sessions of the end users.
+	SessionTTL time.Duration `envconfig:"SESSION_TTL" default:"720h"`
+
+	// AppURL is the url of the frontend, the links in the emails point to it.
+	AppURL string `envconfig:"APP_URL" default:"http://localhost:3000"`
+	// MailDriver is how the emails are sent: file or smtp.
+	MailDriver string `envconfig:"MAIL_DRIVER" default:"file"`
+	// MailFrom is the sender of the emails.
+	MailFrom string `envconfig:"MAIL_FROM" default:"goappbuild <no-reply@localhost>"`
+	// MailFile is the file the file driver appends the emails to (- is stdout).
+	MailFile string `envconfig:"MAIL_FILE" default:"-"`
+	// SMTPHost is the host of the smtp server.
+	SMTPHost string `envconfig:"SMTP_HOST" default:"localhost"`
+	// SMTPPort is the port of the smtp server.
+	SMTPPort int `envconfig:"SMTP_PORT" default:"587"`
+	// SMTPUsername is the username of the smtp server.
+	SMTPUsername string `envconfig:"SMTP_USERNAME"`
+	// SMTPPassword is the password of the smtp server.
+	SMTPPassword string `envconfig:"SMTP_PASSWORD"`
+
+	// LoginDelayAfter is the number of failed logins of an account after
+	// which the next attempts are delayed, the delay doubles every failure.
+	LoginDelayAfter int `envconfig:"LOGIN_DELAY_AFTER" default:"3"`
+	// LoginBaseDelay is the first delay.
+	LoginBaseDelay time.Duration `envconfig:"LOGIN_BASE_DELAY" default:"1s"`
+	// LoginMaxDelay caps the delays.
+	LoginMaxDelay time.Duration `envconfig:"LOGIN_MAX_DELAY" default:"1m"`
+	// LoginLockoutThreshold is the number of failed logins that lock out an account.
+	LoginLockoutThreshold int `envconfig:"LOGIN_LOCKOUT_THRESHOLD" default:"10"`
+	// LoginIPLockoutThreshold is the number of failed logins that lock out a client address.
+	LoginIPLockoutThreshold int `envconfig:"LOGIN_IP_LOCKOUT_THRESHOLD" default:"100"`
+	// LoginLockoutDuration is the duration of the first lockout, it doubles with every lockout.
+	LoginLockoutDuration time.Duration `envconfig:"LOGIN_LOCKOUT_DURATION" default:"15m"`
+	// LoginMaxLockoutDuration caps the lockouts.
+	LoginMaxLockoutDuration time.Duration `envconfig:"LOGIN_MAX_LOCKOUT_DURATION" default:"24h"`
+	// LoginFailureWindow is how long the failed logins are remembered.
+	LoginFailureWindow time.Duration `envconfig:"LOGIN_FAILURE_WINDOW" default:"24h"`
+	// PasswordMinLength is the minimum number of characters of a new password.
+	PasswordMinLength int `envconfig:"PASSWORD_MIN_LENGTH" default:"8"`
+	// BreachedPasswordsFile is a file of breached passwords that are rejected as
+	// new passwords, one plain text password or SHA-1 hash per line. They are
+	// added to the embedded list of common passwords.
+	BreachedPasswordsFile string `envconfig:"BREACHED_PASSWORDS_FILE"`
+	// PasswordlessLimit is the number of magic links and login codes that can be
+	// requested for an email in the PasswordlessWindow.
+	PasswordlessLimit int `envconfig:"PASSWORDLESS_LIMIT" default:"5"`
+	// PasswordlessWindow is the window of the PasswordlessLimit, at most 24h.
+	PasswordlessWindow time.Duration `envconfig:"PASSWORDLESS_WINDOW" default:"1h"`
 }
 
 func (o *Config) getDBConn() string {
@@ -113,3 +281,62 @@ func (o *Config) getDBConn() string {
 		o.PostgresDB,
 	)
 }
+
+func newMailer(cfg Config) (goappbuild.Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}), nil
+	case "file":
+		if cfg.MailFile == "-" {
+			return mailer.NewFile(os.Stdout, cfg.MailFrom), nil
+		}
+
+		f, err := os.OpenFile(cfg.MailFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, err
+		}
+
+		return mailer.NewFile(f, cfg.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
+	}
+}
+
+func newGuard(storage goappbuild.Storage, cfg Config, logger goappbuild.Logger) (goappbuild.LoginGuard, error) {
+	breached := security.DefaultPasswordList()
+
+	if cfg.BreachedPasswordsFile != "" {
+		f, err := os.Open(cfg.BreachedPasswordsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+
+		if err := breached.Load(f); err != nil {
+			return nil, fmt.Errorf("loading the breached passwords: %w", err)
+		}
+	}
+
+	guardCfg := security.Config{
+		DelayAfter:         cfg.LoginDelayAfter,
+		BaseDelay:          cfg.LoginBaseDelay,
+		MaxDelay:           cfg.LoginMaxDelay,
+		LockoutThreshold:   cfg.LoginLockoutThreshold,
+		IPLockoutThreshold: cfg.LoginIPLockoutThreshold,
+		LockoutDuration:    cfg.LoginLockoutDuration,
+		MaxLockoutDuration: cfg.LoginMaxLockoutDuration,
+		FailureWindow:      cfg.LoginFailureWindow,
+		MinPasswordLength:  cfg.PasswordMinLength,
+		BreachedPasswords:  breached,
+		Logger:             logger,
+	}
+
+	return security.NewGuard(storage, guardCfg), nil
+}
@@ -18,6 +18,8 @@ type Collection struct {
 	Name string
 	// Attributes holds the attributes of the document
 	Attributes map[string]Attribute
+	// Options holds the behaviour options of the collection
+	Options CollectionOptions
 	// CreatedAt is the time the collection was created
 	CreatedAt time.Time
 	// UpdatedAt is the time the collection was last updated
@@ -29,18 +31,125 @@ func (o *Collection) TableName() string {
 	return `"` + strings.Replace(o.Name, `"`, `""`, -1) + `"`
 }
 
+// CollectionOptions holds the options of a collection
+type CollectionOptions struct {
+	// SoftDelete indicates that deleted documents are moved to the trash
+	// (their deleted_at attribute is set) instead of being removed
+	SoftDelete bool
+	// Retention is how long trashed documents are kept before they are
+	// purged. Zero keeps them until they are purged explicitly.
+	Retention time.Duration
+	// History records every change of the documents so previous
+	// revisions can be inspected and restored
+	History bool
+	// IDStrategy is the strategy for the ids of the documents.
+	// Empty means IDStrategyUUID.
+	IDStrategy IDStrategy
+	// Rules declare who besides the project members can access the documents
+	Rules CollectionRules
+}
+
 // CollectionRepo is the interface that wraps the basic CRUD operations for a collection
 type CollectionRepo interface {
 	Create(context.Context, string, *Collection) error
+	// GetByName returns the collection of the project with the given name
+	GetByName(ctx context.Context, projectID uuid.UUID, name string) (Collection, error)
+	// List returns the collections of the project
+	List(ctx context.Context, projectID uuid.UUID) ([]Collection, error)
+	// ListWithRetention returns the soft delete collections that have a retention period
+	ListWithRetention(context.Context) ([]Collection, error)
+	// Update stores the attributes and the options of the collection
+	Update(context.Context, *Collection) error
 }
 
 // CollectionCreateRequest is a struct that represents a request to create a collection
 type CollectionCreateRequest struct {
 	Name      string
 	ProjectID uuid.UUID
+	Options   CollectionOptions
+	// Attributes are the attributes of the documents
+	// besides the ones the server manages
+	Attributes []Attribute
+}
+
+// Validate returns an error if the request is invalid
+func (o *CollectionCreateRequest) Validate() error {
+	if o.Name == "" {
+		return Errorf(EValidation, "name is required")
+	}
+
+	if strings.HasSuffix(o.Name, HistorySuffix) {
+		return Errorf(EValidation, "name cannot end with %s", HistorySuffix)
+	}
+
+	if err := o.Options.IDStrategy.Validate(); err != nil {
+		return err
+	}
+
+	if o.Options.Retention < 0 {
+		return Errorf(EValidation, "retention cannot be negative")
+	}
+
+	if o.Options.Retention > 0 && !o.Options.SoftDelete {
+		return Errorf(EValidation, "retention requires soft delete")
+	}
+
+	seen := make(map[string]bool, len(o.Attributes))
+
+	for i := range o.Attributes {
+		if err := o.Attributes[i].Validate(); err != nil {
+			return err
+		}
+
+		if seen[o.Attributes[i].Name] {
+			return Errorf(EValidation, "duplicate attribute %s", o.Attributes[i].Name)
+		}
+
+		seen[o.Attributes[i].Name] = true
+	}
+
+	return o.Options.Rules.Validate()
+}
+
+// UpdateRulesRequest is the request to change the rules of a collection
+type UpdateRulesRequest struct {
+	ProjectID  uuid.UUID
+	Collection string
+	Rules      CollectionRules
+}
+
+// Validate returns an error if the request is invalid
+func (o *UpdateRulesRequest) Validate() error {
+	if o.Collection == "" {
+		return Errorf(EValidation, "collection is required")
+	}
+
+	return o.Rules.Validate()
+}
+
+// UpdateFieldAccessRequest is the request to change who can read and write
+// an attribute of a collection
+type UpdateFieldAccessRequest struct {
+	ProjectID  uuid.UUID
+	Collection string
+	Attribute  string
+	Access     FieldAccess
+}
+
+// Validate returns an error if the request is invalid
+func (o *UpdateFieldAccessRequest) Validate() error {
+	if o.Collection == "" || o.Attribute == "" {
+		return Errorf(EValidation, "collection and attribute are required")
+	}
+
+	return o.Access.Validate()
 }
 
 // CollectionService is an interface that represents a service for managing collections
 type CollectionService interface {
 	Create(context.Context, CollectionCreateRequest) (Collection, error)
+	// UpdateRules changes the permission rules of a collection
+	UpdateRules(context.Context, UpdateRulesRequest) (Collection, error)
+	// UpdateFieldAccess changes the access flags of an attribute
+	UpdateFieldAccess(context.Context, UpdateFieldAccessRequest) (Collection, error)
 }
@@ -4,21 +4,47 @@ import (
 	"context"
 
 	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
 )
 
 type collectionService struct {
 	storage goappbuild.Storage
+	events  goappbuild.EventBus
+}
+
+// Option configures the collection service
+type Option func(*collectionService)
+
+// WithEvents publishes the changes of the collections to the bus
+func WithEvents(bus goappbuild.EventBus) Option {
+	return func(s *collectionService) {
+		s.events = bus
+	}
 }
 
 // NewCollectionService returns a new instance of a collection service
-func New(storage goappbuild.Storage) goappbuild.CollectionService {
-	return &collectionService{
+func New(storage goappbuild.Storage, opts ...Option) goappbuild.CollectionService {
+	ans := collectionService{
 		storage: storage,
 	}
+
+	for _, opt := range opts {
+		opt(&ans)
+	}
+
+	return &ans
 }
 
 // Create creates a new collection
 func (s *collectionService) Create(ctx context.Context, req goappbuild.CollectionCreateRequest) (goappbuild.Collection, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	if err := goappbuild.CheckScope(ctx, req.ProjectID, goappbuild.ScopeCollectionsWrite); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
 	uw, err := s.storage.New(ctx)
 	if err != nil {
 		return goappbuild.Collection{}, err
@@ -26,7 +52,7 @@ func (s *collectionService) Create(ctx context.Context, req goappbuild.Collectio
 
 	defer uw.Rollback(ctx)
 
-	project, err := s.storage.Projects().Get(ctx, req.ProjectID)
+	project, err := authz.Project(ctx, uw, req.ProjectID, goappbuild.RoleAdmin)
 	if err != nil {
 		return goappbuild.Collection{}, err
 	}
@@ -34,20 +60,37 @@ func (s *collectionService) Create(ctx context.Context, req goappbuild.Collectio
 	collection := goappbuild.Collection{
 		ProjectID:  req.ProjectID,
 		Name:       req.Name,
-		Attributes: s.getDefaultAttributes(),
+		Attributes: s.getDefaultAttributes(req.Options.IDStrategy),
+		Options:    req.Options,
+	}
+
+	if collection.Options.SoftDelete {
+		collection.Attributes["deleted_at"] = goappbuild.Attribute{
+			Name:  "deleted_at",
+			Type:  goappbuild.AttributeTypeTime,
+			Index: true,
+		}
 	}
 
-	err = s.storage.Collections().Create(ctx, project.SchemaName(), &collection)
+	if collection.Options.Rules.NeedsOwner() {
+		collection.Attributes[goappbuild.OwnerColumn] = goappbuild.OwnerAttribute()
+	}
+
+	for _, attr := range req.Attributes {
+		collection.Attributes[attr.Name] = attr
+	}
+
+	err = uw.Collections().Create(ctx, project.SchemaName(), &collection)
 	if err != nil {
 		return goappbuild.Collection{}, err
 	}
 
-	err = s.storage.Databases().CreateTable(ctx, project.SchemaName(), collection.TableName())
+	err = uw.Databases().CreateTable(ctx, project.SchemaName(), collection.TableName())
 	if err != nil {
 		return goappbuild.Collection{}, err
 	}
 
-	err = s.storage.Databases().CreateColumns(
+	err = uw.Databases().CreateColumns(
 		ctx,
 		project.SchemaName(),
 		collection.TableName(), collection.Attributes,
@@ -57,22 +100,183 @@ func (s *collectionService) Create(ctx context.Context, req goappbuild.Collectio
 		return goappbuild.Collection{}, err
 	}
 
+	if collection.Options.History {
+		if err := uw.Databases().CreateHistory(ctx, project.SchemaName(), collection); err != nil {
+			return goappbuild.Collection{}, err
+		}
+	}
+
+	if err := uw.Databases().CreatePolicies(ctx, project.SchemaName(), collection); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
 	if err := uw.Commit(ctx); err != nil {
 		return goappbuild.Collection{}, err
 	}
 
+	s.publish(ctx, goappbuild.EventCollectionCreated, nil, &collection)
+
 	return collection, nil
 }
 
-func (s *collectionService) getDefaultAttributes() map[string]goappbuild.Attribute {
-	attributes := make(map[string]goappbuild.Attribute)
+// UpdateRules changes the permission rules of a collection.
+// The owner attribute is added when the new rules need it.
+func (s *collectionService) UpdateRules(ctx context.Context, req goappbuild.UpdateRulesRequest) (goappbuild.Collection, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.Collection{}, err
+	}
 
-	attributes["id"] = goappbuild.Attribute{
-		Name:     "id",
-		Type:     goappbuild.AttributeTypeUUID,
-		Required: true,
-		Primary:  true,
+	if err := goappbuild.CheckScope(ctx, req.ProjectID, goappbuild.ScopeCollectionsWrite); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	project, err := authz.Project(ctx, uw, req.ProjectID, goappbuild.RoleAdmin)
+	if err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	collection, err := uw.Collections().GetByName(ctx, project.ID, req.Collection)
+	if err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	before := clone(collection)
+
+	if _, ok := collection.Attributes[goappbuild.OwnerColumn]; !ok && req.Rules.NeedsOwner() {
+		owner := map[string]goappbuild.Attribute{
+			goappbuild.OwnerColumn: goappbuild.OwnerAttribute(),
+		}
+
+		err = uw.Databases().CreateColumns(ctx, project.SchemaName(), collection.TableName(), owner)
+		if err != nil {
+			return goappbuild.Collection{}, err
+		}
+
+		collection.Attributes[goappbuild.OwnerColumn] = owner[goappbuild.OwnerColumn]
+	}
+
+	collection.Options.Rules = req.Rules
+
+	if err := uw.Collections().Update(ctx, &collection); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	if err := uw.Databases().CreatePolicies(ctx, project.SchemaName(), collection); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	s.publish(ctx, goappbuild.EventCollectionUpdated, &before, &collection)
+
+	return collection, nil
+}
+
+// UpdateFieldAccess changes who can read and write an attribute of the
+// documents. The system attributes cannot be changed.
+func (s *collectionService) UpdateFieldAccess(
+	ctx context.Context,
+	req goappbuild.UpdateFieldAccessRequest,
+) (goappbuild.Collection, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	if err := goappbuild.CheckScope(ctx, req.ProjectID, goappbuild.ScopeCollectionsWrite); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	project, err := authz.Project(ctx, uw, req.ProjectID, goappbuild.RoleAdmin)
+	if err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	collection, err := uw.Collections().GetByName(ctx, project.ID, req.Collection)
+	if err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	before := clone(collection)
+
+	attr, ok := collection.Attributes[req.Attribute]
+	if !ok {
+		return goappbuild.Collection{}, goappbuild.Errorf(goappbuild.ENotFound, "attribute %s not found", req.Attribute)
+	}
+
+	attr.Access = req.Access
+
+	if err := attr.Validate(); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	collection.Attributes[attr.Name] = attr
+
+	if err := uw.Collections().Update(ctx, &collection); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	s.publish(ctx, goappbuild.EventCollectionUpdated, &before, &collection)
+
+	return collection, nil
+}
+
+// publish publishes a change of a collection, before is nil for creations
+func (s *collectionService) publish(
+	ctx context.Context,
+	typ goappbuild.EventType,
+	before, after *goappbuild.Collection,
+) {
+	if s.events == nil {
+		return
+	}
+
+	e := goappbuild.NewEvent(ctx, typ, after.ProjectID)
+	e.Collection = after.Name
+	e.After = *after
+
+	if before != nil {
+		e.Before = *before
 	}
+
+	s.events.Publish(ctx, e)
+}
+
+// clone returns a copy of the collection that does not share its attributes
+func clone(c goappbuild.Collection) goappbuild.Collection {
+	attributes := make(map[string]goappbuild.Attribute, len(c.Attributes))
+	for k, v := range c.Attributes {
+		attributes[k] = v
+	}
+
+	c.Attributes = attributes
+
+	return c
+}
+
+func (s *collectionService) getDefaultAttributes(ids goappbuild.IDStrategy) map[string]goappbuild.Attribute {
+	attributes := make(map[string]goappbuild.Attribute)
+
+	attributes["id"] = ids.Attribute()
 	attributes["created_at"] = goappbuild.Attribute{
 		Name:     "created_at",
 		Type:     goappbuild.AttributeTypeTime,
@@ -0,0 +1,174 @@
+package collections_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/collections"
+	"github.com/gosom/goappbuild/internal/memstore"
+)
+
+func Test_CollectionService_Create(t *testing.T) {
+	storage := memstore.New()
+	svc := collections.New(storage)
+
+	project := goappbuild.Project{UserID: uuid.New(), Name: "owned"}
+	require.NoError(t, storage.ProjectRepo.Create(context.Background(), &project))
+
+	owner := goappbuild.ProjectMember{ProjectID: project.ID, UserID: project.UserID, Role: goappbuild.RoleOwner}
+	require.NoError(t, storage.MemberRepo.Create(context.Background(), &owner))
+
+	req := goappbuild.CollectionCreateRequest{ProjectID: project.ID, Name: "posts"}
+
+	t.Run("test other user is forbidden", func(t *testing.T) {
+		ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: uuid.New()})
+
+		_, err := svc.Create(ctx, req)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test api key of other project is forbidden", func(t *testing.T) {
+		ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{
+			APIKeyID:  uuid.New(),
+			ProjectID: uuid.New(),
+			Scopes:    []string{goappbuild.ScopeAll},
+		})
+
+		_, err := svc.Create(ctx, req)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test owner can create", func(t *testing.T) {
+		ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+
+		c, err := svc.Create(ctx, req)
+		require.NoError(t, err)
+		require.Equal(t, project.ID, c.ProjectID)
+	})
+}
+
+func Test_CollectionService_UpdateRules(t *testing.T) {
+	storage := memstore.New()
+	svc := collections.New(storage)
+
+	project := goappbuild.Project{UserID: uuid.New(), Name: "owned"}
+	require.NoError(t, storage.ProjectRepo.Create(context.Background(), &project))
+
+	owner := goappbuild.ProjectMember{ProjectID: project.ID, UserID: project.UserID, Role: goappbuild.RoleOwner}
+	require.NoError(t, storage.MemberRepo.Create(context.Background(), &owner))
+
+	ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+
+	_, err := svc.Create(ctx, goappbuild.CollectionCreateRequest{ProjectID: project.ID, Name: "notes"})
+	require.NoError(t, err)
+
+	req := goappbuild.UpdateRulesRequest{
+		ProjectID:  project.ID,
+		Collection: "notes",
+		Rules:      goappbuild.CollectionRules{Read: goappbuild.RuleOwner},
+	}
+
+	t.Run("test invalid rule", func(t *testing.T) {
+		invalid := req
+		invalid.Rules.Delete = "everyone"
+
+		_, err := svc.UpdateRules(ctx, invalid)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test developer is forbidden", func(t *testing.T) {
+		developer := goappbuild.ProjectMember{ProjectID: project.ID, UserID: uuid.New(), Role: goappbuild.RoleDeveloper}
+		require.NoError(t, storage.MemberRepo.Create(context.Background(), &developer))
+
+		devCtx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: developer.UserID})
+
+		_, err := svc.UpdateRules(devCtx, req)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test owner rule adds the owner attribute", func(t *testing.T) {
+		c, err := svc.UpdateRules(ctx, req)
+		require.NoError(t, err)
+		require.Equal(t, goappbuild.RuleOwner, c.Options.Rules.Read)
+		require.Contains(t, c.Attributes, goappbuild.OwnerColumn)
+
+		stored, err := storage.CollectionRepo.GetByName(context.Background(), project.ID, "notes")
+		require.NoError(t, err)
+		require.Equal(t, c.Options.Rules, stored.Options.Rules)
+	})
+}
+
+func Test_CollectionService_FieldAccess(t *testing.T) {
+	storage := memstore.New()
+	svc := collections.New(storage)
+
+	project := goappbuild.Project{UserID: uuid.New(), Name: "owned"}
+	require.NoError(t, storage.ProjectRepo.Create(context.Background(), &project))
+
+	owner := goappbuild.ProjectMember{ProjectID: project.ID, UserID: project.UserID, Role: goappbuild.RoleOwner}
+	require.NoError(t, storage.MemberRepo.Create(context.Background(), &owner))
+
+	ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+
+	t.Run("test invalid attributes", func(t *testing.T) {
+		invalid := [][]goappbuild.Attribute{
+			{{Name: "Title", Type: goappbuild.AttributeTypeString}},
+			{{Name: "title", Type: "text"}},
+			{{Name: "id", Type: goappbuild.AttributeTypeString}},
+			{{Name: "title", Type: goappbuild.AttributeTypeString}, {Name: "title", Type: goappbuild.AttributeTypeString}},
+			{{Name: "title", Type: goappbuild.AttributeTypeString, Access: goappbuild.FieldAccess{ReadRole: "guest"}}},
+			{{Name: "title", Type: goappbuild.AttributeTypeString, Required: true, Access: goappbuild.FieldAccess{ReadOnly: true}}},
+		}
+
+		for _, attrs := range invalid {
+			_, err := svc.Create(ctx, goappbuild.CollectionCreateRequest{ProjectID: project.ID, Name: "notes", Attributes: attrs})
+			require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+		}
+	})
+
+	_, err := svc.Create(ctx, goappbuild.CollectionCreateRequest{
+		ProjectID: project.ID,
+		Name:      "notes",
+		Attributes: []goappbuild.Attribute{
+			{Name: "body", Type: goappbuild.AttributeTypeString},
+		},
+	})
+	require.NoError(t, err)
+
+	req := goappbuild.UpdateFieldAccessRequest{
+		ProjectID:  project.ID,
+		Collection: "notes",
+		Attribute:  "body",
+		Access:     goappbuild.FieldAccess{Private: true},
+	}
+
+	t.Run("test system attributes cannot be changed", func(t *testing.T) {
+		invalid := req
+		invalid.Attribute = "created_at"
+
+		_, err := svc.UpdateFieldAccess(ctx, invalid)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test unknown attribute", func(t *testing.T) {
+		invalid := req
+		invalid.Attribute = "title"
+
+		_, err := svc.UpdateFieldAccess(ctx, invalid)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test update access", func(t *testing.T) {
+		c, err := svc.UpdateFieldAccess(ctx, req)
+		require.NoError(t, err)
+		require.True(t, c.Attributes["body"].Access.Private)
+
+		stored, err := storage.CollectionRepo.GetByName(context.Background(), project.ID, "notes")
+		require.NoError(t, err)
+		require.True(t, stored.Attributes["body"].Access.Private)
+	})
+}
@@ -6,4 +6,10 @@ type DatabaseRepo interface {
 	CreateSchema(context.Context, string) error
 	CreateTable(context.Context, string, string) error
 	CreateColumns(context.Context, string, string, map[string]Attribute) error
+	// CreateHistory creates the history table of a collection table and the
+	// trigger that records every change
+	CreateHistory(ctx context.Context, schema string, collection Collection) error
+	// CreatePolicies enables row level security on the table of the collection
+	// and replaces its policies with the ones of the collection rules
+	CreatePolicies(ctx context.Context, schema string, collection Collection) error
 }
@@ -0,0 +1,163 @@
+package goappbuild
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionTokenPrefix is the prefix of the session tokens of the end users
+const SessionTokenPrefix = "gas_"
+
+// IsSessionToken returns true if the token looks like an end user session token
+func IsSessionToken(token string) bool {
+	return strings.HasPrefix(token, SessionTokenPrefix)
+}
+
+// EndUser is a user of an app built on a project. End users belong to a
+// single project and are unrelated to the platform users that manage projects.
+type EndUser struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	Email     string
+	// Password is the plain text password. It is only set when
+	// signing up and it is never stored.
+	Password string
+	// PasswordHash is the hash of the password. It is empty for the end
+	// users that only sign in with identity providers.
+	PasswordHash string
+	// Name is the display name of the end user
+	Name string
+	// Metadata holds arbitrary profile data of the app
+	Metadata map[string]any
+	// Anonymous end users have neither an email nor a password. They can
+	// own documents and be linked to an account later.
+	Anonymous bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Validate returns an error if the end user is invalid.
+func (u *EndUser) Validate() error {
+	if u.ProjectID == uuid.Nil {
+		return Errorf(EValidation, "project is required")
+	}
+
+	if u.Anonymous {
+		return nil
+	}
+
+	if err := ValidateEmail(u.Email); err != nil {
+		return err
+	}
+
+	if u.Password == "" && u.PasswordHash == "" {
+		return Errorf(EValidation, "password is required")
+	}
+
+	if u.Password != "" {
+		return ValidatePassword(u.Password)
+	}
+
+	return nil
+}
+
+// Session is a login session of an end user
+type Session struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	EndUserID uuid.UUID
+	// TokenHash is the hash of the session token, the token itself is never stored
+	TokenHash string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// IsActive returns true if the session is neither revoked nor expired
+func (o *Session) IsActive(now time.Time) bool {
+	return o.RevokedAt == nil && now.Before(o.ExpiresAt)
+}
+
+// EndUserSignUpRequest is the request to sign up an end user
+type EndUserSignUpRequest struct {
+	ProjectID uuid.UUID
+	Email     string
+	Password  string
+	Name      string
+}
+
+// EndUserLoginRequest is the request to log in an end user
+type EndUserLoginRequest struct {
+	ProjectID uuid.UUID
+	Email     string
+	Password  string
+}
+
+// EndUserLinkRequest is the request to link an anonymous end user to an
+// account. A new email creates the account, the email of an existing account
+// requires its password.
+type EndUserLinkRequest struct {
+	ProjectID uuid.UUID
+	Email     string
+	Password  string
+	Name      string
+}
+
+// UpdateProfileRequest is the request to change the profile of an end user.
+// Nil fields are left unchanged.
+type UpdateProfileRequest struct {
+	ProjectID uuid.UUID
+	Name      *string
+	Metadata  map[string]any
+}
+
+// EndUserSession is returned when an end user signs up or logs in
+type EndUserSession struct {
+	User EndUser
+	// Token is the opaque session token sent as a bearer token
+	Token     string
+	ExpiresAt time.Time
+}
+
+// EndUserRepo represents a repository for managing the end users of the projects.
+type EndUserRepo interface {
+	Create(context.Context, *EndUser) error
+	Get(ctx context.Context, projectID, id uuid.UUID) (EndUser, error)
+	// GetByEmail returns the end user of the project with the given (normalized) email
+	GetByEmail(ctx context.Context, projectID uuid.UUID, email string) (EndUser, error)
+	// Update stores the profile and the credentials of the end user
+	Update(context.Context, *EndUser) error
+	Delete(ctx context.Context, projectID, id uuid.UUID) error
+}
+
+// SessionRepo represents a repository for managing the sessions of the end users.
+type SessionRepo interface {
+	Create(context.Context, *Session) error
+	GetByHash(context.Context, string) (Session, error)
+	Revoke(context.Context, uuid.UUID) error
+	// RevokeEndUser revokes all the sessions of the end user
+	RevokeEndUser(ctx context.Context, endUserID uuid.UUID) error
+}
+
+// EndUserService represents a service for the authentication of the end users.
+type EndUserService interface {
+	SignUp(context.Context, EndUserSignUpRequest) (EndUserSession, error)
+	Login(context.Context, EndUserLoginRequest) (EndUserSession, error)
+	// SignInAnonymously creates an anonymous end user and starts a session
+	SignInAnonymously(ctx context.Context, projectID uuid.UUID) (EndUserSession, error)
+	// Link links the anonymous end user of the context to an account with an
+	// email and a password and starts a session of the account. The documents
+	// of the anonymous end user are given to the account.
+	Link(context.Context, EndUserLinkRequest) (EndUserSession, error)
+	// Logout revokes the session of the token
+	Logout(ctx context.Context, token string) error
+	// Authenticate returns the identity of a valid session token
+	Authenticate(ctx context.Context, token string) (Identity, error)
+	// Profile returns the end user of the context
+	Profile(ctx context.Context, projectID uuid.UUID) (EndUser, error)
+	// UpdateProfile changes the profile of the end user of the context
+	UpdateProfile(context.Context, UpdateProfileRequest) (EndUser, error)
+}
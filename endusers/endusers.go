@@ -0,0 +1,503 @@
+package endusers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/securetoken"
+	"github.com/gosom/goappbuild/security"
+	"github.com/gosom/goappbuild/users"
+)
+
+var _ goappbuild.EndUserService = (*service)(nil)
+
+// DefaultSessionTTL is the default lifetime of a session
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+var (
+	errInvalidCredentials = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid email or password")
+	errInvalidSession     = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid or expired session")
+	errNotEndUser         = goappbuild.Errorf(goappbuild.EForbidden, "only the end users of the project have a profile")
+	errNotAnonymous       = goappbuild.Errorf(goappbuild.EConflict, "the end user is already linked to an account")
+)
+
+// Config is the configuration of the end user service
+type Config struct {
+	// SessionTTL is the lifetime of a session
+	SessionTTL time.Duration
+	// Guard protects the logins and checks the new passwords.
+	// Defaults to a guard with the default limits.
+	Guard goappbuild.LoginGuard
+}
+
+type service struct {
+	storage goappbuild.Storage
+	cfg     Config
+	// dummyHash is compared when the end user does not exist so that
+	// the response time does not reveal registered emails
+	dummyHash []byte
+}
+
+// New returns a new end user service. Sessions are opaque random tokens
+// stored hashed.
+func New(storage goappbuild.Storage, cfg Config) goappbuild.EndUserService {
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = DefaultSessionTTL
+	}
+
+	if cfg.Guard == nil {
+		cfg.Guard = security.NewGuard(storage, security.Config{})
+	}
+
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("goappbuild-dummy-password"), bcrypt.DefaultCost)
+
+	return &service{
+		storage:   storage,
+		cfg:       cfg,
+		dummyHash: dummyHash,
+	}
+}
+
+// SignUp creates an end user of the project and starts a session
+func (s *service) SignUp(ctx context.Context, req goappbuild.EndUserSignUpRequest) (goappbuild.EndUserSession, error) {
+	u := goappbuild.EndUser{
+		ProjectID: req.ProjectID,
+		Email:     goappbuild.NormalizeEmail(req.Email),
+		Password:  req.Password,
+		Name:      req.Name,
+	}
+
+	if err := u.Validate(); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if err := s.cfg.Guard.CheckPassword(ctx, u.Email, u.Password); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	hash, err := users.HashPassword(u.Password)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	u.Password = ""
+	u.PasswordHash = hash
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if _, err := uw.Projects().Get(ctx, req.ProjectID); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if err := uw.EndUsers().Create(ctx, &u); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	ans, err := StartSession(ctx, uw, u, s.cfg.SessionTTL)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	return ans, nil
+}
+
+// Login checks the credentials of an end user of the project and starts a session.
+// The failures delay and lock out the email and the client.
+func (s *service) Login(ctx context.Context, req goappbuild.EndUserLoginRequest) (goappbuild.EndUserSession, error) {
+	attempt := goappbuild.LoginAttempt{
+		ProjectID: req.ProjectID,
+		Email:     goappbuild.NormalizeEmail(req.Email),
+	}
+
+	if err := s.cfg.Guard.Check(ctx, attempt); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	u, err := uw.EndUsers().GetByEmail(ctx, req.ProjectID, attempt.Email)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(req.Password))
+
+			return goappbuild.EndUserSession{}, s.fail(ctx, attempt)
+		}
+
+		return goappbuild.EndUserSession{}, err
+	}
+
+	attempt.UserID = u.ID
+
+	if !users.CheckPassword(u.PasswordHash, req.Password) {
+		return goappbuild.EndUserSession{}, s.fail(ctx, attempt)
+	}
+
+	if err := s.cfg.Guard.Succeed(ctx, attempt); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	ans, err := StartSession(ctx, uw, u, s.cfg.SessionTTL)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	return ans, nil
+}
+
+// SignInAnonymously creates an anonymous end user of the project and starts a session
+func (s *service) SignInAnonymously(ctx context.Context, projectID uuid.UUID) (goappbuild.EndUserSession, error) {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if _, err := uw.Projects().Get(ctx, projectID); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	u := goappbuild.EndUser{
+		ProjectID: projectID,
+		Anonymous: true,
+	}
+
+	if err := uw.EndUsers().Create(ctx, &u); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	ans, err := StartSession(ctx, uw, u, s.cfg.SessionTTL)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	return ans, nil
+}
+
+// Link links the anonymous end user of the context to an account. A new
+// email turns the anonymous end user into the account, the email of an
+// existing account requires its password and the anonymous end user is
+// merged into it. The sessions of the anonymous end user are revoked.
+func (s *service) Link(ctx context.Context, req goappbuild.EndUserLinkRequest) (goappbuild.EndUserSession, error) {
+	identity, err := endUser(ctx, req.ProjectID)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	// the documents of the anonymous end user are reassigned regardless of
+	// the row level security policies
+	ctx = goappbuild.ContextWithSystem(ctx)
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	anon, err := uw.EndUsers().Get(ctx, identity.ProjectID, identity.UserID)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if !anon.Anonymous {
+		return goappbuild.EndUserSession{}, errNotAnonymous
+	}
+
+	attempt := goappbuild.LoginAttempt{
+		ProjectID: req.ProjectID,
+		Email:     goappbuild.NormalizeEmail(req.Email),
+	}
+
+	account, err := uw.EndUsers().GetByEmail(ctx, req.ProjectID, attempt.Email)
+
+	switch {
+	case err == nil:
+		// linking to an existing account is a login to it
+		if err := s.cfg.Guard.Check(ctx, attempt); err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+
+		attempt.UserID = account.ID
+
+		if !users.CheckPassword(account.PasswordHash, req.Password) {
+			return goappbuild.EndUserSession{}, s.fail(ctx, attempt)
+		}
+
+		if err := s.cfg.Guard.Succeed(ctx, attempt); err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+
+		if err := Merge(ctx, uw, anon, account); err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+	case goappbuild.ErrorCode(err) == goappbuild.ENotFound:
+		account = anon
+		account.Email = attempt.Email
+		account.Password = req.Password
+		account.Anonymous = false
+
+		if req.Name != "" {
+			account.Name = req.Name
+		}
+
+		if err := account.Validate(); err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+
+		if err := s.cfg.Guard.CheckPassword(ctx, account.Email, account.Password); err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+
+		account.PasswordHash, err = users.HashPassword(account.Password)
+		if err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+
+		account.Password = ""
+
+		if err := uw.EndUsers().Update(ctx, &account); err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+
+		if err := uw.Sessions().RevokeEndUser(ctx, anon.ID); err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+	default:
+		return goappbuild.EndUserSession{}, err
+	}
+
+	ans, err := StartSession(ctx, uw, account, s.cfg.SessionTTL)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	return ans, nil
+}
+
+// fail records the failed login and returns the error for the caller
+func (s *service) fail(ctx context.Context, attempt goappbuild.LoginAttempt) error {
+	if err := s.cfg.Guard.Fail(ctx, attempt); err != nil {
+		return err
+	}
+
+	return errInvalidCredentials
+}
+
+// Logout revokes the session of the token
+func (s *service) Logout(ctx context.Context, token string) error {
+	session, err := s.session(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return s.storage.Sessions().Revoke(ctx, session.ID)
+}
+
+// Authenticate returns the identity of the end user of a valid session token
+func (s *service) Authenticate(ctx context.Context, token string) (goappbuild.Identity, error) {
+	session, err := s.session(ctx, token)
+	if err != nil {
+		return goappbuild.Identity{}, err
+	}
+
+	ans := goappbuild.Identity{
+		UserID:    session.EndUserID,
+		SessionID: session.ID,
+		ProjectID: session.ProjectID,
+		ExpiresAt: session.ExpiresAt,
+	}
+
+	return ans, nil
+}
+
+// Profile returns the end user of the context
+func (s *service) Profile(ctx context.Context, projectID uuid.UUID) (goappbuild.EndUser, error) {
+	identity, err := endUser(ctx, projectID)
+	if err != nil {
+		return goappbuild.EndUser{}, err
+	}
+
+	return s.storage.EndUsers().Get(ctx, identity.ProjectID, identity.UserID)
+}
+
+// UpdateProfile changes the profile of the end user of the context
+func (s *service) UpdateProfile(ctx context.Context, req goappbuild.UpdateProfileRequest) (goappbuild.EndUser, error) {
+	identity, err := endUser(ctx, req.ProjectID)
+	if err != nil {
+		return goappbuild.EndUser{}, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.EndUser{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	u, err := uw.EndUsers().Get(ctx, identity.ProjectID, identity.UserID)
+	if err != nil {
+		return goappbuild.EndUser{}, err
+	}
+
+	if req.Name != nil {
+		u.Name = *req.Name
+	}
+
+	if req.Metadata != nil {
+		u.Metadata = req.Metadata
+	}
+
+	if err := uw.EndUsers().Update(ctx, &u); err != nil {
+		return goappbuild.EndUser{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.EndUser{}, err
+	}
+
+	return u, nil
+}
+
+// StartSession creates a session of the end user that lasts ttl.
+// It is shared with the other ways the end users sign in.
+func StartSession(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	u goappbuild.EndUser,
+	ttl time.Duration,
+) (goappbuild.EndUserSession, error) {
+	token, err := securetoken.Generate(goappbuild.SessionTokenPrefix, securetoken.DefaultSize)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	session := goappbuild.Session{
+		ProjectID: u.ProjectID,
+		EndUserID: u.ID,
+		TokenHash: securetoken.Hash(token),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+
+	if err := uw.Sessions().Create(ctx, &session); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	ans := goappbuild.EndUserSession{
+		User:      u,
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+	}
+
+	return ans, nil
+}
+
+// Merge gives the documents of the anonymous end user to the account and
+// deletes the anonymous end user with its sessions. The unit of work has to
+// run in a system context, since the documents are updated regardless of the
+// row level security policies.
+func Merge(ctx context.Context, uw goappbuild.Storage, anon, account goappbuild.EndUser) error {
+	if !anon.Anonymous || anon.ProjectID != account.ProjectID || anon.ID == account.ID {
+		return goappbuild.Errorf(goappbuild.EValidation, "only an anonymous end user can be merged into another end user")
+	}
+
+	project, err := uw.Projects().Get(ctx, anon.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	collections, err := uw.Collections().List(ctx, project.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range collections {
+		if _, ok := c.Attributes[goappbuild.OwnerColumn]; !ok {
+			continue
+		}
+
+		// the trashed documents are reassigned too
+		q := goappbuild.Q{}.Schema(project.Name).Table(c.Name).Equal(goappbuild.OwnerColumn, anon.ID)
+
+		data := map[string]any{
+			goappbuild.OwnerColumn: account.ID,
+			"updated_at":           time.Now().UTC(),
+		}
+
+		if _, err := uw.Queries().UpdateWhere(ctx, q, data); err != nil {
+			return err
+		}
+	}
+
+	if err := uw.Sessions().RevokeEndUser(ctx, anon.ID); err != nil {
+		return err
+	}
+
+	return uw.EndUsers().Delete(ctx, anon.ProjectID, anon.ID)
+}
+
+// session returns the active session of the token
+func (s *service) session(ctx context.Context, token string) (goappbuild.Session, error) {
+	if !goappbuild.IsSessionToken(token) {
+		return goappbuild.Session{}, errInvalidSession
+	}
+
+	session, err := s.storage.Sessions().GetByHash(ctx, securetoken.Hash(token))
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.Session{}, errInvalidSession
+		}
+
+		return goappbuild.Session{}, err
+	}
+
+	if !session.IsActive(time.Now().UTC()) {
+		return goappbuild.Session{}, errInvalidSession
+	}
+
+	return session, nil
+}
+
+// endUser returns the identity of the caller if it is an end user of the project
+func endUser(ctx context.Context, projectID uuid.UUID) (goappbuild.Identity, error) {
+	identity, ok := goappbuild.IdentityFromContext(ctx)
+	if !ok {
+		return goappbuild.Identity{}, goappbuild.Errorf(goappbuild.EUnauthorized, "authentication required")
+	}
+
+	if !identity.IsEndUser() || identity.ProjectID != projectID {
+		return goappbuild.Identity{}, errNotEndUser
+	}
+
+	return identity, nil
+}
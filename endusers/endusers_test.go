@@ -0,0 +1,248 @@
+package endusers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/endusers"
+	"github.com/gosom/goappbuild/internal/memstore"
+)
+
+func setup(t *testing.T) (*memstore.Storage, goappbuild.Project) {
+	t.Helper()
+
+	storage := memstore.New()
+
+	project := goappbuild.Project{UserID: uuid.New(), Name: "app"}
+	require.NoError(t, storage.ProjectRepo.Create(context.Background(), &project))
+
+	return storage, project
+}
+
+func Test_EndUserService_Session(t *testing.T) {
+	storage, project := setup(t)
+	svc := endusers.New(storage, endusers.Config{})
+	ctx := context.Background()
+
+	signUp := goappbuild.EndUserSignUpRequest{
+		ProjectID: project.ID,
+		Email:     "Jane@example.com",
+		Password:  "correct horse",
+		Name:      "Jane",
+	}
+
+	session, err := svc.SignUp(ctx, signUp)
+	require.NoError(t, err)
+	require.Equal(t, "jane@example.com", session.User.Email)
+	require.True(t, goappbuild.IsSessionToken(session.Token))
+
+	t.Run("test duplicate email", func(t *testing.T) {
+		_, err := svc.SignUp(ctx, signUp)
+		require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test unknown project", func(t *testing.T) {
+		req := signUp
+		req.ProjectID = uuid.New()
+
+		_, err := svc.SignUp(ctx, req)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test login is scoped to the project", func(t *testing.T) {
+		other, err := svc.Login(ctx, goappbuild.EndUserLoginRequest{
+			ProjectID: uuid.New(),
+			Email:     signUp.Email,
+			Password:  signUp.Password,
+		})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+		require.Empty(t, other.Token)
+
+		_, err = svc.Login(ctx, goappbuild.EndUserLoginRequest{
+			ProjectID: project.ID,
+			Email:     signUp.Email,
+			Password:  "wrong password",
+		})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test authenticate and logout", func(t *testing.T) {
+		login, err := svc.Login(ctx, goappbuild.EndUserLoginRequest{
+			ProjectID: project.ID,
+			Email:     signUp.Email,
+			Password:  signUp.Password,
+		})
+		require.NoError(t, err)
+
+		identity, err := svc.Authenticate(ctx, login.Token)
+		require.NoError(t, err)
+		require.True(t, identity.IsEndUser())
+		require.False(t, identity.IsAPIKey())
+		require.Equal(t, session.User.ID, identity.UserID)
+		require.Equal(t, project.ID, identity.ProjectID)
+
+		require.NoError(t, svc.Logout(ctx, login.Token))
+
+		_, err = svc.Authenticate(ctx, login.Token)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		// the other sessions are still valid
+		_, err = svc.Authenticate(ctx, session.Token)
+		require.NoError(t, err)
+	})
+}
+
+func Test_EndUserService_Profile(t *testing.T) {
+	storage, project := setup(t)
+	svc := endusers.New(storage, endusers.Config{})
+
+	session, err := svc.SignUp(context.Background(), goappbuild.EndUserSignUpRequest{
+		ProjectID: project.ID,
+		Email:     "jane@example.com",
+		Password:  "correct horse",
+	})
+	require.NoError(t, err)
+
+	identity, err := svc.Authenticate(context.Background(), session.Token)
+	require.NoError(t, err)
+
+	ctx := goappbuild.ContextWithIdentity(context.Background(), identity)
+
+	name := "Jane"
+
+	u, err := svc.UpdateProfile(ctx, goappbuild.UpdateProfileRequest{
+		ProjectID: project.ID,
+		Name:      &name,
+		Metadata:  map[string]any{"theme": "dark"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Jane", u.Name)
+
+	u, err = svc.Profile(ctx, project.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Jane", u.Name)
+	require.Equal(t, "dark", u.Metadata["theme"])
+
+	_, err = svc.Profile(ctx, uuid.New())
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+	platform := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+
+	_, err = svc.Profile(platform, project.ID)
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+}
+
+func Test_EndUserService_Anonymous(t *testing.T) {
+	storage, project := setup(t)
+	svc := endusers.New(storage, endusers.Config{})
+	ctx := context.Background()
+
+	notes := goappbuild.Collection{
+		ProjectID:  project.ID,
+		Name:       "notes",
+		Attributes: map[string]goappbuild.Attribute{goappbuild.OwnerColumn: goappbuild.OwnerAttribute()},
+	}
+	require.NoError(t, storage.CollectionRepo.Create(ctx, project.Name, &notes))
+
+	posts := goappbuild.Collection{ProjectID: project.ID, Name: "posts"}
+	require.NoError(t, storage.CollectionRepo.Create(ctx, project.Name, &posts))
+
+	signIn := func(t *testing.T) (goappbuild.EndUserSession, context.Context) {
+		t.Helper()
+
+		session, err := svc.SignInAnonymously(ctx, project.ID)
+		require.NoError(t, err)
+		require.True(t, session.User.Anonymous)
+		require.Empty(t, session.User.Email)
+
+		identity, err := svc.Authenticate(ctx, session.Token)
+		require.NoError(t, err)
+
+		return session, goappbuild.ContextWithIdentity(ctx, identity)
+	}
+
+	t.Run("test link with a new email", func(t *testing.T) {
+		anon, anonCtx := signIn(t)
+
+		linked, err := svc.Link(anonCtx, goappbuild.EndUserLinkRequest{
+			ProjectID: project.ID,
+			Email:     "Jane@example.com",
+			Password:  "correct horse",
+		})
+		require.NoError(t, err)
+		require.Equal(t, anon.User.ID, linked.User.ID)
+		require.False(t, linked.User.Anonymous)
+		require.Equal(t, "jane@example.com", linked.User.Email)
+
+		_, err = svc.Authenticate(ctx, anon.Token)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		_, err = svc.Login(ctx, goappbuild.EndUserLoginRequest{
+			ProjectID: project.ID,
+			Email:     "jane@example.com",
+			Password:  "correct horse",
+		})
+		require.NoError(t, err)
+
+		identity, err := svc.Authenticate(ctx, linked.Token)
+		require.NoError(t, err)
+
+		_, err = svc.Link(goappbuild.ContextWithIdentity(ctx, identity), goappbuild.EndUserLinkRequest{
+			ProjectID: project.ID,
+			Email:     "other@example.com",
+			Password:  "correct horse",
+		})
+		require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test link with an existing account", func(t *testing.T) {
+		anon, anonCtx := signIn(t)
+
+		req := goappbuild.EndUserLinkRequest{
+			ProjectID: project.ID,
+			Email:     "jane@example.com",
+			Password:  "wrong password",
+		}
+
+		_, err := svc.Link(anonCtx, req)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		req.Password = "correct horse"
+
+		linked, err := svc.Link(anonCtx, req)
+		require.NoError(t, err)
+		require.NotEqual(t, anon.User.ID, linked.User.ID)
+
+		// the documents of the anonymous end user were given to the account
+		q := storage.QueryRepo.Last()
+		require.Equal(t, "notes", q.GetTable())
+		require.Len(t, q.Where(), 1)
+		require.Equal(t, goappbuild.OwnerColumn, q.Where()[0].Column())
+		require.Equal(t, anon.User.ID, q.Where()[0].Value())
+		require.Equal(t, 1, storage.QueryRepo.Calls())
+
+		_, err = storage.EndUserRepo.Get(ctx, project.ID, anon.User.ID)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		_, err = svc.Authenticate(ctx, anon.Token)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test invalid link", func(t *testing.T) {
+		_, anonCtx := signIn(t)
+
+		_, err := svc.Link(anonCtx, goappbuild.EndUserLinkRequest{
+			ProjectID: project.ID,
+			Email:     "invalid",
+			Password:  "correct horse",
+		})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		_, err = svc.Link(ctx, goappbuild.EndUserLinkRequest{ProjectID: project.ID})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+}
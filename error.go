@@ -8,7 +8,17 @@ import (
 const (
 	// EValidation is the validation error code.
 	EValidation = "invalid"
-	EInternal   = "internal"
+	// ENotFound is the error code when a resource does not exist.
+	ENotFound = "not_found"
+	// EConflict is the error code when a resource already exists.
+	EConflict = "conflict"
+	// EUnauthorized is the error code when the caller is not authenticated.
+	EUnauthorized = "unauthorized"
+	// EForbidden is the error code when the caller is not allowed to perform the operation.
+	EForbidden = "forbidden"
+	// ETooManyRequests is the error code when the caller has to wait before retrying.
+	ETooManyRequests = "too_many_requests"
+	EInternal        = "internal"
 )
 
 // Error represents an error.
@@ -28,7 +38,7 @@ func ErrorCode(err error) string {
 		return ""
 	}
 	var e *Error
-	if !errors.As(err, &e) {
+	if errors.As(err, &e) {
 		return e.Code
 	}
 
@@ -41,7 +51,7 @@ func ErrorMessage(err error) string {
 		return ""
 	}
 	var e *Error
-	if !errors.As(err, &e) {
+	if errors.As(err, &e) {
 		return e.Message
 	}
 
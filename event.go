@@ -0,0 +1,90 @@
+package goappbuild
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType is the kind of change an event carries
+type EventType string
+
+const (
+	EventDocumentCreated EventType = "document.created"
+	EventDocumentUpdated EventType = "document.updated"
+	// EventDocumentDeleted is published when a document is deleted or
+	// moved to the trash. Purging a trashed document publishes nothing.
+	EventDocumentDeleted EventType = "document.deleted"
+
+	EventCollectionCreated EventType = "collection.created"
+	// EventCollectionUpdated is published when the rules or the field
+	// access of a collection change
+	EventCollectionUpdated EventType = "collection.updated"
+
+	EventProjectCreated EventType = "project.created"
+)
+
+// IsDocument returns true for the events of the documents
+func (t EventType) IsDocument() bool {
+	switch t {
+	case EventDocumentCreated, EventDocumentUpdated, EventDocumentDeleted:
+		return true
+	default:
+		return false
+	}
+}
+
+// Event is a change of the data of a project. The events are published after
+// the unit of work of the change commits.
+type Event struct {
+	ID        uuid.UUID
+	Type      EventType
+	ProjectID uuid.UUID
+	// Collection is the collection of the document and collection events
+	Collection string
+	// DocumentID is the id of the document of the document events
+	DocumentID string
+	// Before and After are the snapshots around the change: all the values of
+	// a document, a Collection or a Project. Before is nil for creations and
+	// After is nil for deletions. The document snapshots are not filtered by
+	// the field access of the collection, the subscribers have to.
+	Before any
+	After  any
+	// ActorID is the user or the API key that made the change,
+	// empty for anonymous callers
+	ActorID    uuid.UUID
+	OccurredAt time.Time
+}
+
+// NewEvent returns an event of the caller of the context
+func NewEvent(ctx context.Context, typ EventType, projectID uuid.UUID) Event {
+	ans := Event{
+		ID:         uuid.New(),
+		Type:       typ,
+		ProjectID:  projectID,
+		OccurredAt: time.Now().UTC(),
+	}
+
+	if identity, ok := IdentityFromContext(ctx); ok {
+		ans.ActorID = identity.UserID
+		if identity.IsAPIKey() {
+			ans.ActorID = identity.APIKeyID
+		}
+	}
+
+	return ans
+}
+
+// EventHandler handles the events of a subscription. Handlers run in the
+// goroutine of the publisher, so they must not block.
+type EventHandler func(context.Context, Event)
+
+// EventBus delivers the events to the subscribers of the process
+type EventBus interface {
+	// Publish delivers the events to the subscribers of their types
+	Publish(context.Context, ...Event)
+	// Subscribe registers the handler for the types, all the types when none
+	// is given. The returned function cancels the subscription.
+	Subscribe(handler EventHandler, types ...EventType) func()
+}
@@ -0,0 +1,124 @@
+// Package events contains the in process event bus.
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gosom/goappbuild"
+)
+
+var _ goappbuild.EventBus = (*bus)(nil)
+
+type subscription struct {
+	id      int
+	handler goappbuild.EventHandler
+	types   map[goappbuild.EventType]bool
+}
+
+type bus struct {
+	mu     sync.RWMutex
+	next   int
+	subs   []subscription
+	logger goappbuild.Logger
+}
+
+// Option configures the event bus
+type Option func(*bus)
+
+// WithLogger sets the logger that reports the handlers that panic
+func WithLogger(logger goappbuild.Logger) Option {
+	return func(b *bus) {
+		b.logger = logger
+	}
+}
+
+// New returns an event bus that delivers the events synchronously to the
+// subscribers of the process, in the order they subscribed
+func New(opts ...Option) goappbuild.EventBus {
+	ans := bus{
+		logger: goappbuild.NopLogger(),
+	}
+
+	for _, o := range opts {
+		o(&ans)
+	}
+
+	return &ans
+}
+
+// Publish delivers the events to the subscribers of their types.
+// A handler that panics does not affect the other subscribers.
+func (b *bus) Publish(ctx context.Context, events ...goappbuild.Event) {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for i := range events {
+		for j := range subs {
+			if len(subs[j].types) > 0 && !subs[j].types[events[i].Type] {
+				continue
+			}
+
+			b.deliver(ctx, subs[j].handler, events[i])
+		}
+	}
+}
+
+// Subscribe registers the handler for the types, all the types when none
+// is given. The returned function cancels the subscription.
+func (b *bus) Subscribe(handler goappbuild.EventHandler, types ...goappbuild.EventType) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.next++
+
+	sub := subscription{
+		id:      b.next,
+		handler: handler,
+		types:   make(map[goappbuild.EventType]bool, len(types)),
+	}
+
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	// the subscriptions are copied on write, so that Publish can
+	// iterate over them without holding the lock
+	subs := make([]subscription, 0, len(b.subs)+1)
+	subs = append(subs, b.subs...)
+	b.subs = append(subs, sub)
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			b.unsubscribe(sub.id)
+		})
+	}
+}
+
+func (b *bus) unsubscribe(id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := make([]subscription, 0, len(b.subs))
+
+	for i := range b.subs {
+		if b.subs[i].id != id {
+			subs = append(subs, b.subs[i])
+		}
+	}
+
+	b.subs = subs
+}
+
+func (b *bus) deliver(ctx context.Context, handler goappbuild.EventHandler, e goappbuild.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.logger.Printf("events: handler panicked on %s %s: %v", e.Type, e.ID, r)
+		}
+	}()
+
+	handler(ctx, e)
+}
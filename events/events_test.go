@@ -0,0 +1,78 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/events"
+)
+
+func Test_Bus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("test subscribers receive the events of their types", func(t *testing.T) {
+		bus := events.New()
+
+		var all, created []goappbuild.EventType
+
+		bus.Subscribe(func(_ context.Context, e goappbuild.Event) {
+			all = append(all, e.Type)
+		})
+
+		bus.Subscribe(func(_ context.Context, e goappbuild.Event) {
+			created = append(created, e.Type)
+		}, goappbuild.EventDocumentCreated)
+
+		bus.Publish(
+			ctx,
+			goappbuild.NewEvent(ctx, goappbuild.EventDocumentCreated, uuid.New()),
+			goappbuild.NewEvent(ctx, goappbuild.EventDocumentDeleted, uuid.New()),
+		)
+
+		require.Equal(t, []goappbuild.EventType{goappbuild.EventDocumentCreated, goappbuild.EventDocumentDeleted}, all)
+		require.Equal(t, []goappbuild.EventType{goappbuild.EventDocumentCreated}, created)
+	})
+
+	t.Run("test unsubscribe", func(t *testing.T) {
+		bus := events.New()
+
+		n := 0
+		cancel := bus.Subscribe(func(context.Context, goappbuild.Event) { n++ })
+
+		bus.Publish(ctx, goappbuild.NewEvent(ctx, goappbuild.EventProjectCreated, uuid.New()))
+		cancel()
+		cancel()
+		bus.Publish(ctx, goappbuild.NewEvent(ctx, goappbuild.EventProjectCreated, uuid.New()))
+
+		require.Equal(t, 1, n)
+	})
+
+	t.Run("test a panicking handler does not affect the others", func(t *testing.T) {
+		bus := events.New()
+
+		n := 0
+		bus.Subscribe(func(context.Context, goappbuild.Event) { panic("boom") })
+		bus.Subscribe(func(context.Context, goappbuild.Event) { n++ })
+
+		bus.Publish(ctx, goappbuild.NewEvent(ctx, goappbuild.EventProjectCreated, uuid.New()))
+
+		require.Equal(t, 1, n)
+	})
+
+	t.Run("test the actor is the caller of the context", func(t *testing.T) {
+		userID, keyID := uuid.New(), uuid.New()
+
+		e := goappbuild.NewEvent(goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: userID}), goappbuild.EventProjectCreated, uuid.New())
+		require.Equal(t, userID, e.ActorID)
+
+		e = goappbuild.NewEvent(goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{APIKeyID: keyID}), goappbuild.EventProjectCreated, uuid.New())
+		require.Equal(t, keyID, e.ActorID)
+
+		e = goappbuild.NewEvent(ctx, goappbuild.EventProjectCreated, uuid.New())
+		require.Equal(t, uuid.Nil, e.ActorID)
+	})
+}
@@ -15,6 +15,18 @@ type App struct {
 	Projects    ProjectService
 	Collections CollectionService
 	Queries     QueryService
+	Auth        AuthService
+	APIKeys     APIKeyService
+	Members     MemberService
+	EndUsers    EndUserService
+	SSO         SSOService
+	MFA         MFAService
+	Security    SecurityService
+	Shares      ShareService
+	ACL         ACLService
+	Teams       TeamService
+	Events      EventBus
+	Realtime    RealtimeService
 }
 
 // Storage  is a struct that represents the unit of work
@@ -28,4 +40,21 @@ type Storage interface {
 	Collections() CollectionRepo
 	Databases() DatabaseRepo
 	Queries() QueryRepo
+	History() HistoryRepo
+	RefreshTokens() RefreshTokenRepo
+	APIKeys() APIKeyRepo
+	Members() MemberRepo
+	Invitations() InvitationRepo
+	EndUsers() EndUserRepo
+	Sessions() SessionRepo
+	IdentityProviders() IdentityProviderRepo
+	AuthRequests() AuthRequestRepo
+	ExternalIdentities() ExternalIdentityRepo
+	MFA() MFARepo
+	UserTokens() UserTokenRepo
+	LoginCounters() LoginCounterRepo
+	SecurityEvents() SecurityEventRepo
+	ShareLinks() ShareLinkRepo
+	ACL() ACLRepo
+	Teams() TeamRepo
 }
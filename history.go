@@ -0,0 +1,44 @@
+package goappbuild
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// RevisionOpInsert is the operation of a revision created by an insert
+	RevisionOpInsert = "INSERT"
+	// RevisionOpUpdate is the operation of a revision created by an update
+	RevisionOpUpdate = "UPDATE"
+	// RevisionOpDelete is the operation of a revision created by a delete
+	RevisionOpDelete = "DELETE"
+)
+
+// HistorySuffix is appended to the table name of a collection
+// to get the name of its history table
+const HistorySuffix = "__history"
+
+// Revision is the state of a document after a change
+// (or before it, for deletes)
+type Revision struct {
+	// Revision is the sequence number of the revision
+	Revision int64
+	// DocumentID is the id of the document
+	DocumentID string
+	// Operation is the operation that created the revision
+	Operation string
+	// Data holds the values of the document
+	Data map[string]any
+	// ChangedAt is the time of the change
+	ChangedAt time.Time
+}
+
+// HistoryRepo is the repository of the document revisions
+type HistoryRepo interface {
+	// List returns the revisions of a document, newest first
+	List(ctx context.Context, schema, table, id string) ([]Revision, error)
+	// Get returns a single revision of a document
+	Get(ctx context.Context, schema, table, id string, revision int64) (Revision, error)
+	// AsOf returns the latest revision of a document at the given time
+	AsOf(ctx context.Context, schema, table, id string, at time.Time) (Revision, error)
+}
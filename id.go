@@ -0,0 +1,109 @@
+package goappbuild
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// IDStrategy is the strategy used for the ids of the documents of a collection
+type IDStrategy string
+
+const (
+	// IDStrategyUUID uses random (v4) UUIDs. It is the default.
+	IDStrategyUUID IDStrategy = "uuid"
+	// IDStrategyUUIDv7 uses time ordered (v7) UUIDs
+	IDStrategyUUIDv7 IDStrategy = "uuidv7"
+	// IDStrategyULID uses ULIDs
+	IDStrategyULID IDStrategy = "ulid"
+	// IDStrategySerial uses auto increment bigint ids generated by the database
+	IDStrategySerial IDStrategy = "serial"
+	// IDStrategyString uses string ids supplied by the client
+	IDStrategyString IDStrategy = "string"
+)
+
+// Validate returns an error if the strategy is unknown
+func (s IDStrategy) Validate() error {
+	switch s {
+	case "", IDStrategyUUID, IDStrategyUUIDv7, IDStrategyULID, IDStrategySerial, IDStrategyString:
+		return nil
+	default:
+		return Errorf(EValidation, "invalid id strategy: %q", s)
+	}
+}
+
+// Attribute returns the id attribute for the strategy
+func (s IDStrategy) Attribute() Attribute {
+	ans := Attribute{
+		Name:     "id",
+		Required: true,
+		Primary:  true,
+	}
+
+	switch s {
+	case IDStrategyULID, IDStrategyString:
+		ans.Type = AttributeTypeString
+	case IDStrategySerial:
+		ans.Type = AttributeTypeInteger
+		ans.AutoIncrement = true
+	default:
+		ans.Type = AttributeTypeUUID
+	}
+
+	return ans
+}
+
+// ServerGenerated returns true if the client is not allowed to set the id
+func (s IDStrategy) ServerGenerated() bool {
+	return s != IDStrategyString
+}
+
+// NewID returns a new id. It returns nil when the id is generated
+// by the database or supplied by the client.
+func (s IDStrategy) NewID() (any, error) {
+	switch s {
+	case IDStrategyUUIDv7:
+		return uuid.NewV7()
+	case IDStrategyULID:
+		return ulid.Make().String(), nil
+	case IDStrategySerial, IDStrategyString:
+		return nil, nil
+	default:
+		return uuid.New(), nil
+	}
+}
+
+// ParseID parses the string representation of an id
+func (s IDStrategy) ParseID(id string) (any, error) {
+	switch s {
+	case IDStrategyULID:
+		ans, err := ulid.ParseStrict(id)
+		if err != nil {
+			return nil, Errorf(EValidation, "invalid id: %v", err)
+		}
+
+		return ans.String(), nil
+	case IDStrategySerial:
+		ans, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, Errorf(EValidation, "invalid id: %v", err)
+		}
+
+		return ans, nil
+	case IDStrategyString:
+		if strings.TrimSpace(id) == "" {
+			return nil, Errorf(EValidation, "id cannot be empty")
+		}
+
+		return id, nil
+	default:
+		ans, err := uuid.Parse(id)
+		if err != nil {
+			return nil, Errorf(EValidation, "invalid id: %v", err)
+		}
+
+		return ans, nil
+	}
+}
@@ -0,0 +1,69 @@
+package goappbuild_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+)
+
+func Test_IDStrategy(t *testing.T) {
+	t.Run("test uuid is the default", func(t *testing.T) {
+		var ids goappbuild.IDStrategy
+
+		id, err := ids.NewID()
+		require.NoError(t, err)
+
+		u, ok := id.(uuid.UUID)
+		require.True(t, ok)
+		require.Equal(t, uuid.Version(4), u.Version())
+		require.Equal(t, goappbuild.AttributeTypeUUID, ids.Attribute().Type)
+
+		_, err = ids.ParseID("not-a-uuid")
+		require.Error(t, err)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test uuidv7", func(t *testing.T) {
+		id, err := goappbuild.IDStrategyUUIDv7.NewID()
+		require.NoError(t, err)
+		require.Equal(t, uuid.Version(7), id.(uuid.UUID).Version())
+	})
+
+	t.Run("test ulid", func(t *testing.T) {
+		id, err := goappbuild.IDStrategyULID.NewID()
+		require.NoError(t, err)
+
+		parsed, err := goappbuild.IDStrategyULID.ParseID(id.(string))
+		require.NoError(t, err)
+		require.Equal(t, id, parsed)
+		require.Equal(t, goappbuild.AttributeTypeString, goappbuild.IDStrategyULID.Attribute().Type)
+	})
+
+	t.Run("test serial", func(t *testing.T) {
+		id, err := goappbuild.IDStrategySerial.NewID()
+		require.NoError(t, err)
+		require.Nil(t, id)
+
+		parsed, err := goappbuild.IDStrategySerial.ParseID("42")
+		require.NoError(t, err)
+		require.Equal(t, int64(42), parsed)
+
+		attr := goappbuild.IDStrategySerial.Attribute()
+		require.Equal(t, goappbuild.AttributeTypeInteger, attr.Type)
+		require.True(t, attr.AutoIncrement)
+	})
+
+	t.Run("test string", func(t *testing.T) {
+		require.False(t, goappbuild.IDStrategyString.ServerGenerated())
+
+		_, err := goappbuild.IDStrategyString.ParseID(" ")
+		require.Error(t, err)
+	})
+
+	t.Run("test invalid", func(t *testing.T) {
+		require.Error(t, goappbuild.IDStrategy("snowflake").Validate())
+	})
+}
@@ -0,0 +1,1712 @@
+// Package memstore is an in memory goappbuild.Storage for the service tests.
+// Only the repositories the tests need are implemented, calling any other
+// repository panics.
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+)
+
+var _ goappbuild.Storage = (*Storage)(nil)
+
+// Storage is an in memory storage. Transactions are not supported,
+// New returns the same storage and Commit/Rollback are no-ops.
+type Storage struct {
+	goappbuild.Storage
+
+	ProjectRepo    *ProjectRepo
+	CollectionRepo *CollectionRepo
+	DatabaseRepo   *DatabaseRepo
+	QueryRepo      *QueryRepo
+	MemberRepo     *MemberRepo
+	UserRepo       *UserRepo
+	InvitationRepo *InvitationRepo
+	EndUserRepo    *EndUserRepo
+	SessionRepo    *SessionRepo
+
+	IdentityProviderRepo *IdentityProviderRepo
+	AuthRequestRepo      *AuthRequestRepo
+	ExternalIdentityRepo *ExternalIdentityRepo
+	RefreshTokenRepo     *RefreshTokenRepo
+	MFARepo              *MFARepo
+	UserTokenRepo        *UserTokenRepo
+	LoginCounterRepo     *LoginCounterRepo
+	SecurityEventRepo    *SecurityEventRepo
+	ShareLinkRepo        *ShareLinkRepo
+	ACLRepo              *ACLRepo
+	TeamRepo             *TeamRepo
+}
+
+// New returns a new empty storage
+func New() *Storage {
+	teams := &TeamRepo{}
+
+	return &Storage{
+		ProjectRepo:    &ProjectRepo{items: map[uuid.UUID]goappbuild.Project{}},
+		CollectionRepo: &CollectionRepo{},
+		DatabaseRepo:   &DatabaseRepo{},
+		QueryRepo:      &QueryRepo{},
+		MemberRepo:     &MemberRepo{},
+		UserRepo:       &UserRepo{},
+		InvitationRepo: &InvitationRepo{},
+		EndUserRepo:    &EndUserRepo{},
+		SessionRepo:    &SessionRepo{},
+
+		IdentityProviderRepo: &IdentityProviderRepo{},
+		AuthRequestRepo:      &AuthRequestRepo{},
+		ExternalIdentityRepo: &ExternalIdentityRepo{},
+		RefreshTokenRepo:     &RefreshTokenRepo{},
+		MFARepo:              &MFARepo{factors: map[uuid.UUID]goappbuild.TOTPFactor{}},
+		UserTokenRepo:        &UserTokenRepo{},
+		LoginCounterRepo:     &LoginCounterRepo{items: map[string]goappbuild.LoginCounter{}},
+		SecurityEventRepo:    &SecurityEventRepo{},
+		ShareLinkRepo:        &ShareLinkRepo{},
+		ACLRepo:              &ACLRepo{teams: teams},
+		TeamRepo:             teams,
+	}
+}
+
+func (s *Storage) New(context.Context) (goappbuild.Storage, error) {
+	return s, nil
+}
+
+func (s *Storage) Commit(context.Context) error {
+	return nil
+}
+
+func (s *Storage) Rollback(context.Context) error {
+	return nil
+}
+
+func (s *Storage) Projects() goappbuild.ProjectRepo {
+	return s.ProjectRepo
+}
+
+func (s *Storage) Collections() goappbuild.CollectionRepo {
+	return s.CollectionRepo
+}
+
+func (s *Storage) Databases() goappbuild.DatabaseRepo {
+	return s.DatabaseRepo
+}
+
+func (s *Storage) Queries() goappbuild.QueryRepo {
+	return s.QueryRepo
+}
+
+func (s *Storage) Members() goappbuild.MemberRepo {
+	return s.MemberRepo
+}
+
+func (s *Storage) Users() goappbuild.UserRepo {
+	return s.UserRepo
+}
+
+func (s *Storage) Invitations() goappbuild.InvitationRepo {
+	return s.InvitationRepo
+}
+
+func (s *Storage) EndUsers() goappbuild.EndUserRepo {
+	return s.EndUserRepo
+}
+
+func (s *Storage) Sessions() goappbuild.SessionRepo {
+	return s.SessionRepo
+}
+
+func (s *Storage) IdentityProviders() goappbuild.IdentityProviderRepo {
+	return s.IdentityProviderRepo
+}
+
+func (s *Storage) AuthRequests() goappbuild.AuthRequestRepo {
+	return s.AuthRequestRepo
+}
+
+func (s *Storage) ExternalIdentities() goappbuild.ExternalIdentityRepo {
+	return s.ExternalIdentityRepo
+}
+
+func (s *Storage) RefreshTokens() goappbuild.RefreshTokenRepo {
+	return s.RefreshTokenRepo
+}
+
+func (s *Storage) MFA() goappbuild.MFARepo {
+	return s.MFARepo
+}
+
+func (s *Storage) UserTokens() goappbuild.UserTokenRepo {
+	return s.UserTokenRepo
+}
+
+func (s *Storage) LoginCounters() goappbuild.LoginCounterRepo {
+	return s.LoginCounterRepo
+}
+
+func (s *Storage) SecurityEvents() goappbuild.SecurityEventRepo {
+	return s.SecurityEventRepo
+}
+
+func (s *Storage) ShareLinks() goappbuild.ShareLinkRepo {
+	return s.ShareLinkRepo
+}
+
+func (s *Storage) ACL() goappbuild.ACLRepo {
+	return s.ACLRepo
+}
+
+func (s *Storage) Teams() goappbuild.TeamRepo {
+	return s.TeamRepo
+}
+
+// ProjectRepo is an in memory goappbuild.ProjectRepo
+type ProjectRepo struct {
+	mu    sync.Mutex
+	items map[uuid.UUID]goappbuild.Project
+}
+
+func (o *ProjectRepo) Create(_ context.Context, p *goappbuild.Project) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	p.ID = uuid.New()
+	p.CreatedAt = time.Now().UTC()
+	p.UpdatedAt = p.CreatedAt
+
+	o.items[p.ID] = *p
+
+	return nil
+}
+
+func (o *ProjectRepo) Get(_ context.Context, id uuid.UUID) (goappbuild.Project, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	p, ok := o.items[id]
+	if !ok {
+		return goappbuild.Project{}, goappbuild.Errorf(goappbuild.ENotFound, "project not found")
+	}
+
+	return p, nil
+}
+
+// CollectionRepo is an in memory goappbuild.CollectionRepo
+type CollectionRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.Collection
+}
+
+func (o *CollectionRepo) Create(_ context.Context, _ string, c *goappbuild.Collection) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	c.ID = uuid.New()
+	c.CreatedAt = time.Now().UTC()
+	c.UpdatedAt = c.CreatedAt
+
+	o.items = append(o.items, *c)
+
+	return nil
+}
+
+func (o *CollectionRepo) GetByName(_ context.Context, projectID uuid.UUID, name string) (goappbuild.Collection, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, c := range o.items {
+		if c.ProjectID == projectID && c.Name == name {
+			return c, nil
+		}
+	}
+
+	return goappbuild.Collection{}, goappbuild.Errorf(goappbuild.ENotFound, "collection %s not found", name)
+}
+
+func (o *CollectionRepo) List(_ context.Context, projectID uuid.UUID) ([]goappbuild.Collection, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.Collection
+
+	for _, c := range o.items {
+		if c.ProjectID == projectID {
+			ans = append(ans, c)
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *CollectionRepo) ListWithRetention(context.Context) ([]goappbuild.Collection, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.Collection
+
+	for _, c := range o.items {
+		if c.Options.SoftDelete && c.Options.Retention > 0 {
+			ans = append(ans, c)
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *CollectionRepo) Update(_ context.Context, c *goappbuild.Collection) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].ID == c.ID {
+			c.UpdatedAt = time.Now().UTC()
+			o.items[i] = *c
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "collection %s not found", c.Name)
+}
+
+// DatabaseRepo is a goappbuild.DatabaseRepo that does nothing
+type DatabaseRepo struct{}
+
+func (o *DatabaseRepo) CreateSchema(context.Context, string) error {
+	return nil
+}
+
+func (o *DatabaseRepo) CreateTable(context.Context, string, string) error {
+	return nil
+}
+
+func (o *DatabaseRepo) CreateColumns(context.Context, string, string, map[string]goappbuild.Attribute) error {
+	return nil
+}
+
+func (o *DatabaseRepo) CreateHistory(context.Context, string, goappbuild.Collection) error {
+	return nil
+}
+
+func (o *DatabaseRepo) CreatePolicies(context.Context, string, goappbuild.Collection) error {
+	return nil
+}
+
+// QueryRepo is a goappbuild.QueryRepo that counts the calls and returns the
+// data it was given. Tests use Calls to assert that the data were not touched.
+type QueryRepo struct {
+	mu    sync.Mutex
+	calls int
+	last  goappbuild.Q
+}
+
+// Calls returns the number of calls to the repository
+func (o *QueryRepo) Calls() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.calls
+}
+
+// Last returns the last query passed to the repository
+func (o *QueryRepo) Last() goappbuild.Q {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.last
+}
+
+func (o *QueryRepo) called() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.calls++
+}
+
+func (o *QueryRepo) queried(q goappbuild.Q) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.calls++
+	o.last = q
+}
+
+func (o *QueryRepo) Get(_ context.Context, q goappbuild.Q) (map[string]any, error) {
+	o.queried(q)
+
+	return map[string]any{}, nil
+}
+
+func (o *QueryRepo) List(_ context.Context, q goappbuild.Q) ([]map[string]any, error) {
+	o.queried(q)
+
+	return nil, nil
+}
+
+func (o *QueryRepo) Create(_ context.Context, _, _ string, data map[string]any) (map[string]any, error) {
+	o.called()
+
+	return data, nil
+}
+
+func (o *QueryRepo) Update(_ context.Context, _, _ string, _ any, data map[string]any) (map[string]any, error) {
+	o.called()
+
+	return data, nil
+}
+
+func (o *QueryRepo) Delete(context.Context, string, string, any) error {
+	o.called()
+
+	return nil
+}
+
+func (o *QueryRepo) Count(_ context.Context, q goappbuild.Q) (int64, error) {
+	o.queried(q)
+
+	return 0, nil
+}
+
+func (o *QueryRepo) UpdateWhere(_ context.Context, q goappbuild.Q, _ map[string]any) ([]any, error) {
+	o.queried(q)
+
+	return nil, nil
+}
+
+func (o *QueryRepo) DeleteWhere(_ context.Context, q goappbuild.Q) ([]any, error) {
+	o.queried(q)
+
+	return nil, nil
+}
+
+// MemberRepo is an in memory goappbuild.MemberRepo
+type MemberRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.ProjectMember
+}
+
+func (o *MemberRepo) Create(_ context.Context, m *goappbuild.ProjectMember) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, item := range o.items {
+		if item.ProjectID == m.ProjectID && item.UserID == m.UserID {
+			return goappbuild.Errorf(goappbuild.EConflict, "user is already a member of the project")
+		}
+	}
+
+	m.CreatedAt = time.Now().UTC()
+	m.UpdatedAt = m.CreatedAt
+
+	o.items = append(o.items, *m)
+
+	return nil
+}
+
+func (o *MemberRepo) Get(_ context.Context, projectID, userID uuid.UUID) (goappbuild.ProjectMember, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	i := o.index(projectID, userID)
+	if i < 0 {
+		return goappbuild.ProjectMember{}, goappbuild.Errorf(goappbuild.ENotFound, "member not found")
+	}
+
+	return o.items[i], nil
+}
+
+// ListByUser returns the memberships of the user. The project names are
+// the ones the memberships were created with.
+func (o *MemberRepo) ListByUser(_ context.Context, userID uuid.UUID) ([]goappbuild.ProjectMember, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.ProjectMember
+
+	for _, m := range o.items {
+		if m.UserID == userID {
+			ans = append(ans, m)
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *MemberRepo) List(_ context.Context, projectID uuid.UUID) ([]goappbuild.ProjectMember, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.ProjectMember
+
+	for _, m := range o.items {
+		if m.ProjectID == projectID {
+			ans = append(ans, m)
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *MemberRepo) UpdateRole(_ context.Context, projectID, userID uuid.UUID, role goappbuild.Role) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	i := o.index(projectID, userID)
+	if i < 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "member not found")
+	}
+
+	o.items[i].Role = role
+
+	return nil
+}
+
+func (o *MemberRepo) Delete(_ context.Context, projectID, userID uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	i := o.index(projectID, userID)
+	if i < 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "member not found")
+	}
+
+	o.items = append(o.items[:i], o.items[i+1:]...)
+
+	return nil
+}
+
+func (o *MemberRepo) CountRole(_ context.Context, projectID uuid.UUID, role goappbuild.Role) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans int
+
+	for _, m := range o.items {
+		if m.ProjectID == projectID && m.Role == role {
+			ans++
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *MemberRepo) index(projectID, userID uuid.UUID) int {
+	for i, m := range o.items {
+		if m.ProjectID == projectID && m.UserID == userID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// UserRepo is an in memory goappbuild.UserRepo
+type UserRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.User
+}
+
+func (o *UserRepo) Create(_ context.Context, u *goappbuild.User) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, item := range o.items {
+		if u.Email != "" && item.Email == u.Email {
+			return goappbuild.Errorf(goappbuild.EConflict, "email is already registered")
+		}
+	}
+
+	u.ID = uuid.New()
+	u.CreatedAt = time.Now().UTC()
+	u.UpdatedAt = u.CreatedAt
+
+	o.items = append(o.items, *u)
+
+	return nil
+}
+
+func (o *UserRepo) Get(_ context.Context, id uuid.UUID) (goappbuild.User, error) {
+	return o.find(func(u goappbuild.User) bool { return u.ID == id })
+}
+
+func (o *UserRepo) GetByEmail(_ context.Context, email string) (goappbuild.User, error) {
+	return o.find(func(u goappbuild.User) bool { return u.Email == email })
+}
+
+func (o *UserRepo) Update(_ context.Context, u *goappbuild.User) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].ID == u.ID {
+			u.UpdatedAt = time.Now().UTC()
+			o.items[i] = *u
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "user not found")
+}
+
+func (o *UserRepo) List(_ context.Context, filter goappbuild.UserFilter) ([]goappbuild.User, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.User
+
+	for _, u := range o.items {
+		switch {
+		case filter.Search != "" && !strings.Contains(u.Email, strings.ToLower(filter.Search)),
+			filter.Disabled != nil && u.IsDisabled() != *filter.Disabled:
+			continue
+		}
+
+		ans = append(ans, u)
+	}
+
+	sort.Slice(ans, func(i, j int) bool { return ans[i].Email < ans[j].Email })
+
+	if filter.Offset >= len(ans) {
+		return nil, nil
+	}
+
+	ans = ans[filter.Offset:]
+
+	if len(ans) > filter.Limit {
+		ans = ans[:filter.Limit]
+	}
+
+	return ans, nil
+}
+
+func (o *UserRepo) Delete(_ context.Context, id uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].ID == id {
+			o.items = append(o.items[:i], o.items[i+1:]...)
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "user not found")
+}
+
+func (o *UserRepo) find(fn func(goappbuild.User) bool) (goappbuild.User, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, u := range o.items {
+		if fn(u) {
+			return u, nil
+		}
+	}
+
+	return goappbuild.User{}, goappbuild.Errorf(goappbuild.ENotFound, "user not found")
+}
+
+// InvitationRepo is an in memory goappbuild.InvitationRepo
+type InvitationRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.Invitation
+}
+
+func (o *InvitationRepo) Create(_ context.Context, inv *goappbuild.Invitation) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	inv.ID = uuid.New()
+	inv.CreatedAt = time.Now().UTC()
+	inv.UpdatedAt = inv.CreatedAt
+
+	o.items = append(o.items, *inv)
+
+	return nil
+}
+
+func (o *InvitationRepo) GetByHash(_ context.Context, hash string) (goappbuild.Invitation, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, inv := range o.items {
+		if inv.TokenHash == hash {
+			return inv, nil
+		}
+	}
+
+	return goappbuild.Invitation{}, goappbuild.Errorf(goappbuild.ENotFound, "invitation not found")
+}
+
+func (o *InvitationRepo) UpdateStatus(_ context.Context, id uuid.UUID, status goappbuild.InvitationStatus) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].ID == id {
+			o.items[i].Status = status
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "invitation not found")
+}
+
+// EndUserRepo is an in memory goappbuild.EndUserRepo
+type EndUserRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.EndUser
+}
+
+func (o *EndUserRepo) Create(_ context.Context, u *goappbuild.EndUser) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.taken(*u) {
+		return goappbuild.Errorf(goappbuild.EConflict, "email is already registered")
+	}
+
+	u.ID = uuid.New()
+	u.CreatedAt = time.Now().UTC()
+	u.UpdatedAt = u.CreatedAt
+
+	o.items = append(o.items, *u)
+
+	return nil
+}
+
+func (o *EndUserRepo) Get(_ context.Context, projectID, id uuid.UUID) (goappbuild.EndUser, error) {
+	return o.find(func(u goappbuild.EndUser) bool { return u.ProjectID == projectID && u.ID == id })
+}
+
+func (o *EndUserRepo) GetByEmail(_ context.Context, projectID uuid.UUID, email string) (goappbuild.EndUser, error) {
+	return o.find(func(u goappbuild.EndUser) bool {
+		return u.ProjectID == projectID && u.Email != "" && u.Email == email
+	})
+}
+
+func (o *EndUserRepo) Update(_ context.Context, u *goappbuild.EndUser) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.taken(*u) {
+		return goappbuild.Errorf(goappbuild.EConflict, "email is already registered")
+	}
+
+	for i := range o.items {
+		if o.items[i].ProjectID == u.ProjectID && o.items[i].ID == u.ID {
+			u.UpdatedAt = time.Now().UTC()
+			o.items[i] = *u
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "end user not found")
+}
+
+func (o *EndUserRepo) Delete(_ context.Context, projectID, id uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].ProjectID == projectID && o.items[i].ID == id {
+			o.items = append(o.items[:i], o.items[i+1:]...)
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "end user not found")
+}
+
+// taken returns true if another end user of the project has the email
+func (o *EndUserRepo) taken(u goappbuild.EndUser) bool {
+	for _, item := range o.items {
+		if item.ProjectID == u.ProjectID && item.ID != u.ID && u.Email != "" && item.Email == u.Email {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o *EndUserRepo) find(fn func(goappbuild.EndUser) bool) (goappbuild.EndUser, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, u := range o.items {
+		if fn(u) {
+			return u, nil
+		}
+	}
+
+	return goappbuild.EndUser{}, goappbuild.Errorf(goappbuild.ENotFound, "end user not found")
+}
+
+// SessionRepo is an in memory goappbuild.SessionRepo
+type SessionRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.Session
+}
+
+func (o *SessionRepo) Create(_ context.Context, s *goappbuild.Session) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s.ID = uuid.New()
+	s.CreatedAt = time.Now().UTC()
+
+	o.items = append(o.items, *s)
+
+	return nil
+}
+
+func (o *SessionRepo) GetByHash(_ context.Context, hash string) (goappbuild.Session, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, s := range o.items {
+		if s.TokenHash == hash {
+			return s, nil
+		}
+	}
+
+	return goappbuild.Session{}, goappbuild.Errorf(goappbuild.ENotFound, "session not found")
+}
+
+func (o *SessionRepo) Revoke(_ context.Context, id uuid.UUID) error {
+	return o.revoke(func(s goappbuild.Session) bool { return s.ID == id })
+}
+
+func (o *SessionRepo) RevokeEndUser(_ context.Context, endUserID uuid.UUID) error {
+	return o.revoke(func(s goappbuild.Session) bool { return s.EndUserID == endUserID })
+}
+
+func (o *SessionRepo) revoke(fn func(goappbuild.Session) bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if fn(o.items[i]) && o.items[i].RevokedAt == nil {
+			now := time.Now().UTC()
+			o.items[i].RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+// IdentityProviderRepo is an in memory goappbuild.IdentityProviderRepo
+type IdentityProviderRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.IdentityProvider
+}
+
+func (o *IdentityProviderRepo) Create(_ context.Context, p *goappbuild.IdentityProvider) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, item := range o.items {
+		if item.ProjectID == p.ProjectID && item.Name == p.Name {
+			return goappbuild.Errorf(goappbuild.EConflict, "identity provider %s already exists", p.Name)
+		}
+	}
+
+	p.ID = uuid.New()
+	p.CreatedAt = time.Now().UTC()
+	p.UpdatedAt = p.CreatedAt
+
+	o.items = append(o.items, *p)
+
+	return nil
+}
+
+func (o *IdentityProviderRepo) GetByName(
+	_ context.Context,
+	projectID uuid.UUID,
+	name string,
+) (goappbuild.IdentityProvider, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, p := range o.items {
+		if p.ProjectID == projectID && p.Name == name {
+			return p, nil
+		}
+	}
+
+	return goappbuild.IdentityProvider{}, goappbuild.Errorf(goappbuild.ENotFound, "identity provider not found")
+}
+
+func (o *IdentityProviderRepo) List(_ context.Context, projectID uuid.UUID) ([]goappbuild.IdentityProvider, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.IdentityProvider
+
+	for _, p := range o.items {
+		if p.ProjectID == projectID {
+			ans = append(ans, p)
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *IdentityProviderRepo) Delete(_ context.Context, projectID uuid.UUID, name string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, p := range o.items {
+		if p.ProjectID == projectID && p.Name == name {
+			o.items = append(o.items[:i], o.items[i+1:]...)
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "identity provider not found")
+}
+
+// AuthRequestRepo is an in memory goappbuild.AuthRequestRepo
+type AuthRequestRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.AuthRequest
+}
+
+func (o *AuthRequestRepo) Create(_ context.Context, r *goappbuild.AuthRequest) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	r.ID = uuid.New()
+	r.CreatedAt = time.Now().UTC()
+
+	o.items = append(o.items, *r)
+
+	return nil
+}
+
+func (o *AuthRequestRepo) Take(_ context.Context, stateHash string) (goappbuild.AuthRequest, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, r := range o.items {
+		if r.StateHash == stateHash {
+			o.items = append(o.items[:i], o.items[i+1:]...)
+
+			return r, nil
+		}
+	}
+
+	return goappbuild.AuthRequest{}, goappbuild.Errorf(goappbuild.ENotFound, "auth request not found")
+}
+
+// ExternalIdentityRepo is an in memory goappbuild.ExternalIdentityRepo
+type ExternalIdentityRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.ExternalIdentity
+}
+
+func (o *ExternalIdentityRepo) Create(_ context.Context, e *goappbuild.ExternalIdentity) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, item := range o.items {
+		if item.ProviderID == e.ProviderID && item.Subject == e.Subject {
+			return goappbuild.Errorf(goappbuild.EConflict, "the account is already linked")
+		}
+	}
+
+	e.ID = uuid.New()
+	e.CreatedAt = time.Now().UTC()
+
+	o.items = append(o.items, *e)
+
+	return nil
+}
+
+func (o *ExternalIdentityRepo) Get(
+	_ context.Context,
+	providerID uuid.UUID,
+	subject string,
+) (goappbuild.ExternalIdentity, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, e := range o.items {
+		if e.ProviderID == providerID && e.Subject == subject {
+			return e, nil
+		}
+	}
+
+	return goappbuild.ExternalIdentity{}, goappbuild.Errorf(goappbuild.ENotFound, "external identity not found")
+}
+
+// RefreshTokenRepo is an in memory goappbuild.RefreshTokenRepo
+type RefreshTokenRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.RefreshToken
+}
+
+func (o *RefreshTokenRepo) Create(_ context.Context, rt *goappbuild.RefreshToken) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	rt.ID = uuid.New()
+	rt.CreatedAt = time.Now().UTC()
+
+	o.items = append(o.items, *rt)
+
+	return nil
+}
+
+func (o *RefreshTokenRepo) GetByHash(_ context.Context, hash string) (goappbuild.RefreshToken, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, rt := range o.items {
+		if rt.TokenHash == hash {
+			return rt, nil
+		}
+	}
+
+	return goappbuild.RefreshToken{}, goappbuild.Errorf(goappbuild.ENotFound, "refresh token not found")
+}
+
+func (o *RefreshTokenRepo) Revoke(_ context.Context, id uuid.UUID) error {
+	return o.revoke(func(rt goappbuild.RefreshToken) bool { return rt.ID == id })
+}
+
+func (o *RefreshTokenRepo) RevokeFamily(_ context.Context, familyID uuid.UUID) error {
+	return o.revoke(func(rt goappbuild.RefreshToken) bool { return rt.FamilyID == familyID })
+}
+
+func (o *RefreshTokenRepo) RevokeUser(_ context.Context, userID uuid.UUID) error {
+	return o.revoke(func(rt goappbuild.RefreshToken) bool { return rt.UserID == userID })
+}
+
+func (o *RefreshTokenRepo) revoke(fn func(goappbuild.RefreshToken) bool) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	for i := range o.items {
+		if fn(o.items[i]) && o.items[i].RevokedAt == nil {
+			o.items[i].RevokedAt = &now
+		}
+	}
+
+	return nil
+}
+
+// MFARepo is an in memory goappbuild.MFARepo
+type MFARepo struct {
+	mu      sync.Mutex
+	factors map[uuid.UUID]goappbuild.TOTPFactor
+	// codes are the recovery code hashes of the users, used codes are removed
+	codes map[uuid.UUID][]string
+}
+
+func (o *MFARepo) GetFactor(_ context.Context, userID uuid.UUID) (goappbuild.TOTPFactor, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	f, ok := o.factors[userID]
+	if !ok {
+		return goappbuild.TOTPFactor{}, goappbuild.Errorf(goappbuild.ENotFound, "mfa is not enabled")
+	}
+
+	return f, nil
+}
+
+func (o *MFARepo) SaveFactor(_ context.Context, f *goappbuild.TOTPFactor) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	if existing, ok := o.factors[f.UserID]; ok {
+		f.CreatedAt = existing.CreatedAt
+	} else {
+		f.CreatedAt = now
+	}
+
+	f.UpdatedAt = now
+	o.factors[f.UserID] = *f
+
+	return nil
+}
+
+func (o *MFARepo) UseStep(_ context.Context, userID uuid.UUID, step int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	f, ok := o.factors[userID]
+	if !ok || f.LastStep >= step {
+		return goappbuild.Errorf(goappbuild.ENotFound, "code already used")
+	}
+
+	f.LastStep = step
+	o.factors[userID] = f
+
+	return nil
+}
+
+func (o *MFARepo) DeleteFactor(_ context.Context, userID uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.codes, userID)
+
+	if _, ok := o.factors[userID]; !ok {
+		return goappbuild.Errorf(goappbuild.ENotFound, "mfa is not enabled")
+	}
+
+	delete(o.factors, userID)
+
+	return nil
+}
+
+func (o *MFARepo) ReplaceRecoveryCodes(_ context.Context, userID uuid.UUID, hashes []string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.codes == nil {
+		o.codes = map[uuid.UUID][]string{}
+	}
+
+	o.codes[userID] = append([]string(nil), hashes...)
+
+	return nil
+}
+
+func (o *MFARepo) UseRecoveryCode(_ context.Context, userID uuid.UUID, hash string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, h := range o.codes[userID] {
+		if h == hash {
+			o.codes[userID] = append(o.codes[userID][:i], o.codes[userID][i+1:]...)
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "recovery code not found")
+}
+
+// UserTokenRepo is an in memory goappbuild.UserTokenRepo
+type UserTokenRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.UserToken
+}
+
+func (o *UserTokenRepo) Create(_ context.Context, t *goappbuild.UserToken) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].TokenHash == t.TokenHash {
+			return goappbuild.Errorf(goappbuild.EConflict, "token already exists")
+		}
+	}
+
+	t.ID = uuid.New()
+	t.CreatedAt = time.Now().UTC()
+
+	o.items = append(o.items, *t)
+
+	return nil
+}
+
+func (o *UserTokenRepo) Count(
+	_ context.Context,
+	userID uuid.UUID,
+	since time.Time,
+	purposes ...goappbuild.TokenPurpose,
+) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ans := 0
+
+	for _, t := range o.items {
+		if t.UserID != userID || !t.CreatedAt.After(since) {
+			continue
+		}
+
+		for _, p := range purposes {
+			if t.Purpose == p {
+				ans++
+
+				break
+			}
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *UserTokenRepo) Use(
+	_ context.Context,
+	purpose goappbuild.TokenPurpose,
+	hash string,
+) (goappbuild.UserToken, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	for i, t := range o.items {
+		if t.Purpose == purpose && t.TokenHash == hash && t.UsedAt == nil && t.ExpiresAt.After(now) {
+			o.items[i].UsedAt = &now
+
+			return o.items[i], nil
+		}
+	}
+
+	return goappbuild.UserToken{}, goappbuild.Errorf(goappbuild.ENotFound, "token not found")
+}
+
+// LoginCounterRepo is an in memory goappbuild.LoginCounterRepo
+type LoginCounterRepo struct {
+	mu    sync.Mutex
+	items map[string]goappbuild.LoginCounter
+}
+
+func (o *LoginCounterRepo) Get(_ context.Context, key string) (goappbuild.LoginCounter, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	c, ok := o.items[key]
+	if !ok {
+		return goappbuild.LoginCounter{}, goappbuild.Errorf(goappbuild.ENotFound, "login counter not found")
+	}
+
+	return c, nil
+}
+
+func (o *LoginCounterRepo) Fail(_ context.Context, key string, since time.Time) (goappbuild.LoginCounter, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	c, ok := o.items[key]
+	if !ok || c.LastFailureAt.Before(since) {
+		c = goappbuild.LoginCounter{Key: key, LockedUntil: c.LockedUntil}
+	}
+
+	c.Failures++
+	c.LastFailureAt = time.Now().UTC()
+
+	o.items[key] = c
+
+	return c, nil
+}
+
+func (o *LoginCounterRepo) Lock(_ context.Context, key string, until time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	c, ok := o.items[key]
+	if !ok {
+		return goappbuild.Errorf(goappbuild.ENotFound, "login counter not found")
+	}
+
+	c.LockedUntil = &until
+	o.items[key] = c
+
+	return nil
+}
+
+func (o *LoginCounterRepo) Reset(_ context.Context, key string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.items, key)
+
+	return nil
+}
+
+// SecurityEventRepo is an in memory goappbuild.SecurityEventRepo
+type SecurityEventRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.SecurityEvent
+}
+
+func (o *SecurityEventRepo) Create(_ context.Context, e *goappbuild.SecurityEvent) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	e.ID = uuid.New()
+	e.CreatedAt = time.Now().UTC()
+
+	o.items = append(o.items, *e)
+
+	return nil
+}
+
+func (o *SecurityEventRepo) List(
+	_ context.Context,
+	filter goappbuild.SecurityEventFilter,
+) ([]goappbuild.SecurityEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.SecurityEvent
+
+	for i := len(o.items) - 1; i >= 0 && len(ans) < filter.Limit; i-- {
+		e := o.items[i]
+
+		switch {
+		case filter.Kind != "" && e.Kind != filter.Kind,
+			filter.Email != "" && e.Email != filter.Email,
+			filter.IP != "" && e.IP != filter.IP,
+			filter.ProjectID != uuid.Nil && e.ProjectID != filter.ProjectID,
+			filter.UserID != uuid.Nil && e.UserID != filter.UserID:
+			continue
+		}
+
+		ans = append(ans, e)
+	}
+
+	return ans, nil
+}
+
+// ShareLinkRepo is an in memory goappbuild.ShareLinkRepo
+type ShareLinkRepo struct {
+	mu       sync.Mutex
+	items    []goappbuild.ShareLink
+	accesses []goappbuild.ShareAccess
+}
+
+func (o *ShareLinkRepo) Create(_ context.Context, l *goappbuild.ShareLink) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	l.ID = uuid.New()
+	l.CreatedAt = time.Now().UTC()
+
+	o.items = append(o.items, *l)
+
+	return nil
+}
+
+func (o *ShareLinkRepo) Get(_ context.Context, id uuid.UUID) (goappbuild.ShareLink, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, l := range o.items {
+		if l.ID == id {
+			return l, nil
+		}
+	}
+
+	return goappbuild.ShareLink{}, goappbuild.Errorf(goappbuild.ENotFound, "share link not found")
+}
+
+func (o *ShareLinkRepo) List(
+	_ context.Context,
+	projectID uuid.UUID,
+	collection string,
+) ([]goappbuild.ShareLink, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.ShareLink
+
+	for i := len(o.items) - 1; i >= 0; i-- {
+		if o.items[i].ProjectID == projectID && o.items[i].Collection == collection {
+			ans = append(ans, o.items[i])
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *ShareLinkRepo) Revoke(_ context.Context, projectID, id uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].ProjectID == projectID && o.items[i].ID == id {
+			if o.items[i].RevokedAt == nil {
+				now := time.Now().UTC()
+				o.items[i].RevokedAt = &now
+			}
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "share link not found")
+}
+
+func (o *ShareLinkRepo) CreateAccess(_ context.Context, a *goappbuild.ShareAccess) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	a.ID = uuid.New()
+	a.CreatedAt = time.Now().UTC()
+
+	o.accesses = append(o.accesses, *a)
+
+	return nil
+}
+
+func (o *ShareLinkRepo) ListAccesses(
+	_ context.Context,
+	linkID uuid.UUID,
+	limit int,
+) ([]goappbuild.ShareAccess, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.ShareAccess
+
+	for i := len(o.accesses) - 1; i >= 0 && len(ans) < limit; i-- {
+		if o.accesses[i].LinkID == linkID {
+			ans = append(ans, o.accesses[i])
+		}
+	}
+
+	return ans, nil
+}
+
+// ACLRepo is an in memory goappbuild.ACLRepo. The entries of the teams
+// are granted to the members of the teams of the storage.
+type ACLRepo struct {
+	mu    sync.Mutex
+	items []goappbuild.ACLEntry
+	teams *TeamRepo
+}
+
+func (o *ACLRepo) Upsert(_ context.Context, e *goappbuild.ACLEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].ProjectID == e.ProjectID && o.items[i].Collection == e.Collection &&
+			o.items[i].DocumentID == e.DocumentID && o.items[i].PrincipalType == e.PrincipalType &&
+			o.items[i].PrincipalID == e.PrincipalID {
+			o.items[i].Permission = e.Permission
+			o.items[i].CreatedBy = e.CreatedBy
+			*e = o.items[i]
+
+			return nil
+		}
+	}
+
+	e.ID = uuid.New()
+	e.CreatedAt = time.Now().UTC()
+
+	o.items = append(o.items, *e)
+
+	return nil
+}
+
+func (o *ACLRepo) Delete(
+	_ context.Context,
+	projectID uuid.UUID,
+	collection, documentID string,
+	principalID uuid.UUID,
+) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].ProjectID == projectID && o.items[i].Collection == collection &&
+			o.items[i].DocumentID == documentID && o.items[i].PrincipalID == principalID {
+			o.items = append(o.items[:i], o.items[i+1:]...)
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "acl entry not found")
+}
+
+func (o *ACLRepo) DeleteDocuments(
+	_ context.Context,
+	projectID uuid.UUID,
+	collection string,
+	documentIDs ...string,
+) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	deleted := make(map[string]bool, len(documentIDs))
+	for _, id := range documentIDs {
+		deleted[id] = true
+	}
+
+	items := o.items[:0]
+
+	for _, e := range o.items {
+		if e.ProjectID == projectID && e.Collection == collection && deleted[e.DocumentID] {
+			continue
+		}
+
+		items = append(items, e)
+	}
+
+	o.items = items
+
+	return nil
+}
+
+func (o *ACLRepo) List(
+	_ context.Context,
+	projectID uuid.UUID,
+	collection, documentID string,
+) ([]goappbuild.ACLEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.ACLEntry
+
+	for _, e := range o.items {
+		if e.ProjectID == projectID && e.Collection == collection && e.DocumentID == documentID {
+			ans = append(ans, e)
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *ACLRepo) DeletePrincipal(
+	_ context.Context,
+	projectID uuid.UUID,
+	principalType goappbuild.PrincipalType,
+	principalID uuid.UUID,
+) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	items := o.items[:0]
+
+	for _, e := range o.items {
+		if e.ProjectID == projectID && e.PrincipalType == principalType && e.PrincipalID == principalID {
+			continue
+		}
+
+		items = append(items, e)
+	}
+
+	o.items = items
+
+	return nil
+}
+
+func (o *ACLRepo) Exists(
+	_ context.Context,
+	projectID uuid.UUID,
+	collection string,
+	userID uuid.UUID,
+	permissions ...goappbuild.Permission,
+) (bool, error) {
+	return o.granted(projectID, collection, nil, userID, permissions), nil
+}
+
+func (o *ACLRepo) Granted(
+	_ context.Context,
+	projectID uuid.UUID,
+	collection, documentID string,
+	userID uuid.UUID,
+	permissions ...goappbuild.Permission,
+) (bool, error) {
+	return o.granted(projectID, collection, &documentID, userID, permissions), nil
+}
+
+// granted returns true if an entry of the collection, or of the document
+// when set, grants one of the permissions to the user or a team of the user
+func (o *ACLRepo) granted(
+	projectID uuid.UUID,
+	collection string,
+	documentID *string,
+	userID uuid.UUID,
+	permissions []goappbuild.Permission,
+) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, e := range o.items {
+		if e.ProjectID != projectID || e.Collection != collection {
+			continue
+		}
+
+		if documentID != nil && e.DocumentID != *documentID {
+			continue
+		}
+
+		switch e.PrincipalType {
+		case goappbuild.PrincipalTeam:
+			if !o.teams.isMember(e.PrincipalID, userID) {
+				continue
+			}
+		default:
+			if e.PrincipalID != userID {
+				continue
+			}
+		}
+
+		for _, p := range permissions {
+			if e.Permission == p {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// TeamRepo is an in memory goappbuild.TeamRepo
+type TeamRepo struct {
+	mu      sync.Mutex
+	items   []goappbuild.Team
+	members []goappbuild.TeamMember
+}
+
+func (o *TeamRepo) Create(_ context.Context, t *goappbuild.Team) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, item := range o.items {
+		if item.ProjectID == t.ProjectID && item.Name == t.Name {
+			return goappbuild.Errorf(goappbuild.EConflict, "team %s already exists", t.Name)
+		}
+	}
+
+	t.ID = uuid.New()
+	t.CreatedAt = time.Now().UTC()
+	t.UpdatedAt = t.CreatedAt
+
+	o.items = append(o.items, *t)
+
+	return nil
+}
+
+func (o *TeamRepo) Get(_ context.Context, projectID, id uuid.UUID) (goappbuild.Team, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, item := range o.items {
+		if item.ProjectID == projectID && item.ID == id {
+			return item, nil
+		}
+	}
+
+	return goappbuild.Team{}, goappbuild.Errorf(goappbuild.ENotFound, "team not found")
+}
+
+func (o *TeamRepo) List(_ context.Context, projectID uuid.UUID) ([]goappbuild.Team, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.Team
+
+	for _, item := range o.items {
+		if item.ProjectID == projectID {
+			ans = append(ans, item)
+		}
+	}
+
+	sort.Slice(ans, func(i, j int) bool {
+		return ans[i].Name < ans[j].Name
+	})
+
+	return ans, nil
+}
+
+func (o *TeamRepo) Delete(_ context.Context, projectID, id uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.items {
+		if o.items[i].ProjectID == projectID && o.items[i].ID == id {
+			o.items = append(o.items[:i], o.items[i+1:]...)
+
+			members := o.members[:0]
+
+			for _, m := range o.members {
+				if m.TeamID != id {
+					members = append(members, m)
+				}
+			}
+
+			o.members = members
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "team not found")
+}
+
+func (o *TeamRepo) AddMember(_ context.Context, teamID, userID uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, m := range o.members {
+		if m.TeamID == teamID && m.UserID == userID {
+			return nil
+		}
+	}
+
+	o.members = append(o.members, goappbuild.TeamMember{TeamID: teamID, UserID: userID, CreatedAt: time.Now().UTC()})
+
+	return nil
+}
+
+func (o *TeamRepo) RemoveMember(_ context.Context, teamID, userID uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.members {
+		if o.members[i].TeamID == teamID && o.members[i].UserID == userID {
+			o.members = append(o.members[:i], o.members[i+1:]...)
+
+			return nil
+		}
+	}
+
+	return goappbuild.Errorf(goappbuild.ENotFound, "team member not found")
+}
+
+func (o *TeamRepo) ListMembers(_ context.Context, teamID uuid.UUID) ([]goappbuild.TeamMember, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var ans []goappbuild.TeamMember
+
+	for _, m := range o.members {
+		if m.TeamID == teamID {
+			ans = append(ans, m)
+		}
+	}
+
+	return ans, nil
+}
+
+func (o *TeamRepo) isMember(teamID, userID uuid.UUID) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, m := range o.members {
+		if m.TeamID == teamID && m.UserID == userID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,16 @@
+package goappbuild
+
+// Logger reports the errors that do not fail an operation, like the errors
+// of the background jobs. *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// NopLogger returns a logger that discards everything
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...any) {}
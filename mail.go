@@ -0,0 +1,16 @@
+package goappbuild
+
+import "context"
+
+// Message is an email
+type Message struct {
+	To      []string
+	Subject string
+	// Text is the plain text body
+	Text string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(context.Context, Message) error
+}
@@ -0,0 +1,46 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/gosom/goappbuild"
+)
+
+var _ goappbuild.Mailer = (*File)(nil)
+
+// File writes the messages to a writer instead of sending them,
+// for development. Messages are separated by a blank line.
+type File struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+// NewFile returns a new file mailer
+func NewFile(w io.Writer, from string) *File {
+	return &File{
+		w:    w,
+		from: from,
+	}
+}
+
+// Send writes the message
+func (o *File) Send(_ context.Context, m goappbuild.Message) error {
+	data, err := build(o.from, m)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, err := o.w.Write(data); err != nil {
+		return err
+	}
+
+	_, err = o.w.Write([]byte("\r\n"))
+
+	return err
+}
@@ -0,0 +1,110 @@
+// Package mailer sends the emails of the platform. Messages are rendered
+// from the embedded templates and delivered with SMTP, or written to a file
+// for development.
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+)
+
+const (
+	// TemplateVerifyEmail is the email verification message
+	TemplateVerifyEmail = "verify_email"
+	// TemplateResetPassword is the password reset message
+	TemplateResetPassword = "reset_password"
+	// TemplateLoginLink is the magic login link message
+	TemplateLoginLink = "login_link"
+	// TemplateLoginCode is the one-time login code message
+	TemplateLoginCode = "login_code"
+)
+
+//go:embed templates/*.tmpl
+var templatesFS embed.FS
+
+// Every template defines a subject and a text block
+var templates = template.Must(template.ParseFS(templatesFS, "templates/*.tmpl"))
+
+// Render returns the message of the template with the data
+func Render(name string, to string, data any) (goappbuild.Message, error) {
+	subject, err := execute(name+".subject", data)
+	if err != nil {
+		return goappbuild.Message{}, err
+	}
+
+	text, err := execute(name+".text", data)
+	if err != nil {
+		return goappbuild.Message{}, err
+	}
+
+	ans := goappbuild.Message{
+		To:      []string{to},
+		Subject: strings.TrimSpace(subject),
+		Text:    strings.TrimSpace(text) + "\n",
+	}
+
+	return ans, nil
+}
+
+func execute(name string, data any) (string, error) {
+	var buf bytes.Buffer
+
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("mailer: template %s: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// build returns the message in the internet message format
+func build(from string, m goappbuild.Message) ([]byte, error) {
+	if len(m.To) == 0 {
+		return nil, fmt.Errorf("mailer: message without recipients")
+	}
+
+	var buf bytes.Buffer
+
+	headers := [][2]string{
+		{"From", from},
+		{"To", strings.Join(m.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", time.Now().UTC().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + uuid.NewString() + "@goappbuild>"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+
+	for _, h := range headers {
+		// header values must not break the message
+		if strings.ContainsAny(h[1], "\r\n") {
+			return nil, fmt.Errorf("mailer: invalid %s header", h[0])
+		}
+
+		fmt.Fprintf(&buf, "%s: %s\r\n", h[0], h[1])
+	}
+
+	buf.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+
+	if _, err := w.Write([]byte(strings.ReplaceAll(m.Text, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,67 @@
+package mailer_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/mailer"
+	"github.com/gosom/goappbuild/mailer/smtptest"
+)
+
+func Test_SMTP(t *testing.T) {
+	srv := smtptest.New()
+	defer srv.Close()
+
+	m := mailer.NewSMTP(mailer.SMTPConfig{
+		Host: srv.Host(),
+		Port: srv.Port(),
+		From: "goappbuild <no-reply@example.com>",
+	})
+
+	msg, err := mailer.Render(mailer.TemplateResetPassword, "jane@example.com", map[string]any{
+		"URL":       "https://app.example.com/reset-password?token=abc",
+		"ExpiresIn": "1h0m0s",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Reset your password", msg.Subject)
+
+	require.NoError(t, m.Send(context.Background(), msg))
+
+	sent := srv.Messages()
+	require.Len(t, sent, 1)
+	require.Equal(t, "no-reply@example.com", sent[0].From)
+	require.Equal(t, []string{"jane@example.com"}, sent[0].To)
+	require.Equal(t, "Reset your password", sent[0].Subject)
+	require.Contains(t, sent[0].Text, "https://app.example.com/reset-password?token=abc")
+
+	t.Run("test header injection", func(t *testing.T) {
+		err := m.Send(context.Background(), goappbuild.Message{
+			To:      []string{"jane@example.com\r\nBcc: eve@example.com"},
+			Subject: "hi",
+			Text:    "hi",
+		})
+		require.Error(t, err)
+		require.Len(t, srv.Messages(), 1)
+	})
+}
+
+func Test_File(t *testing.T) {
+	var buf bytes.Buffer
+
+	m := mailer.NewFile(&buf, "no-reply@example.com")
+
+	msg, err := mailer.Render(mailer.TemplateVerifyEmail, "jane@example.com", map[string]any{
+		"URL":       "https://app.example.com/verify-email?token=abc",
+		"ExpiresIn": "24h0m0s",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, m.Send(context.Background(), msg))
+	require.True(t, strings.Contains(buf.String(), "Subject: Verify your email"))
+	require.Contains(t, buf.String(), "To: jane@example.com")
+}
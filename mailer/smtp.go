@@ -0,0 +1,112 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+
+	"github.com/gosom/goappbuild"
+)
+
+var _ goappbuild.Mailer = (*SMTP)(nil)
+
+// SMTPConfig is the configuration of an SMTP server
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password are optional, they are only sent over TLS
+	Username string
+	Password string
+	// From is the sender of the messages
+	From string
+}
+
+// SMTP sends the messages to an SMTP server.
+// STARTTLS is used when the server supports it.
+type SMTP struct {
+	cfg SMTPConfig
+}
+
+// NewSMTP returns a new SMTP mailer
+func NewSMTP(cfg SMTPConfig) *SMTP {
+	return &SMTP{
+		cfg: cfg,
+	}
+}
+
+// Send delivers the message to the server
+func (o *SMTP) Send(ctx context.Context, m goappbuild.Message) error {
+	from, err := mail.ParseAddress(o.cfg.From)
+	if err != nil {
+		return fmt.Errorf("mailer: invalid from address: %w", err)
+	}
+
+	data, err := build(from.String(), m)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(o.cfg.Host, strconv.Itoa(o.cfg.Port))
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, o.cfg.Host)
+	if err != nil {
+		conn.Close()
+
+		return err
+	}
+
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: o.cfg.Host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+
+	if o.cfg.Username != "" {
+		// PlainAuth refuses to send the credentials without TLS, except to localhost
+		if err := c.Auth(smtp.PlainAuth("", o.cfg.Username, o.cfg.Password, o.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
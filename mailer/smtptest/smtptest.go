@@ -0,0 +1,216 @@
+// Package smtptest is an in-process SMTP server for the tests. It accepts
+// every message without authentication and keeps them in memory.
+package smtptest
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message is a received message
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	// Text is the decoded body
+	Text string
+	// Raw is the message as received
+	Raw string
+}
+
+// Server is a local SMTP server
+type Server struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// New starts a new server on a random local port. Close stops it.
+func New() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	s := Server{ln: ln}
+
+	s.wg.Add(1)
+
+	go s.serve()
+
+	return &s
+}
+
+// Host returns the host of the server
+func (s *Server) Host() string {
+	return s.ln.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port of the server
+func (s *Server) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// Messages returns the received messages
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+
+		go func() {
+			defer s.wg.Done()
+
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	reply := func(code int, msg string) {
+		w.WriteString(strconv.Itoa(code) + " " + msg + "\r\n")
+		w.Flush()
+	}
+
+	reply(220, "smtptest ready")
+
+	var from string
+
+	var to []string
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch cmd {
+		case "EHLO", "HELO":
+			reply(250, "smtptest")
+		case "MAIL":
+			from = address(line)
+			to = nil
+
+			reply(250, "ok")
+		case "RCPT":
+			to = append(to, address(line))
+
+			reply(250, "ok")
+		case "DATA":
+			reply(354, "end data with <CR><LF>.<CR><LF>")
+
+			raw, err := readData(r)
+			if err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.messages = append(s.messages, parse(from, to, raw))
+			s.mu.Unlock()
+
+			reply(250, "ok")
+		case "RSET":
+			from, to = "", nil
+
+			reply(250, "ok")
+		case "NOOP":
+			reply(250, "ok")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+// address returns the address of a MAIL FROM:<...> or RCPT TO:<...> command
+func address(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+
+	return line[start+1 : end]
+}
+
+func readData(r *bufio.Reader) (string, error) {
+	var sb strings.Builder
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		if line == ".\r\n" {
+			return sb.String(), nil
+		}
+
+		// dot stuffing
+		sb.WriteString(strings.TrimPrefix(line, "."))
+	}
+}
+
+func parse(from string, to []string, raw string) Message {
+	ans := Message{
+		From: from,
+		To:   to,
+		Raw:  raw,
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return ans
+	}
+
+	ans.Subject, err = new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		ans.Subject = m.Header.Get("Subject")
+	}
+
+	var body io.Reader = m.Body
+	if strings.EqualFold(m.Header.Get("Content-Transfer-Encoding"), "quoted-printable") {
+		body = quotedprintable.NewReader(body)
+	}
+
+	text, _ := io.ReadAll(body)
+	ans.Text = strings.ReplaceAll(string(text), "\r\n", "\n")
+
+	return ans
+}
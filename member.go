@@ -0,0 +1,140 @@
+package goappbuild
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role is the role of a member of a project
+type Role string
+
+const (
+	// RoleOwner can do everything including managing the owners
+	RoleOwner Role = "owner"
+	// RoleAdmin can change the schema and manage the members and the API keys
+	RoleAdmin Role = "admin"
+	// RoleDeveloper can read and write documents
+	RoleDeveloper Role = "developer"
+	// RoleViewer can only read documents
+	RoleViewer Role = "viewer"
+)
+
+// Validate returns an error if the role is unknown
+func (r Role) Validate() error {
+	if r.level() == 0 {
+		return Errorf(EValidation, "invalid role: %q", r)
+	}
+
+	return nil
+}
+
+// AtLeast returns true if the role has all the permissions of the other role
+func (r Role) AtLeast(other Role) bool {
+	return r.level() > 0 && r.level() >= other.level()
+}
+
+func (r Role) level() int {
+	switch r {
+	case RoleOwner:
+		return 4
+	case RoleAdmin:
+		return 3
+	case RoleDeveloper:
+		return 2
+	case RoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ProjectMember is a user that has access to a project
+type ProjectMember struct {
+	ProjectID uuid.UUID
+	UserID    uuid.UUID
+	// Email is the email of the user. It is filled when listing members.
+	Email string
+	// ProjectName is the name of the project. It is filled when listing
+	// the projects of a user.
+	ProjectName string
+	Role        Role
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// InvitationStatus is the status of an invitation
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationDeclined InvitationStatus = "declined"
+)
+
+// Invitation is an invitation to join a project. Only the hash of the
+// token is stored, the token is given to the invited user.
+type Invitation struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	Email     string
+	Role      Role
+	TokenHash string
+	InvitedBy uuid.UUID
+	Status    InvitationStatus
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// InviteMemberRequest is the request to invite a user to a project
+type InviteMemberRequest struct {
+	ProjectID uuid.UUID
+	Email     string
+	Role      Role
+}
+
+// Validate validates the request
+func (o *InviteMemberRequest) Validate() error {
+	if err := ValidateEmail(o.Email); err != nil {
+		return err
+	}
+
+	return o.Role.Validate()
+}
+
+// MemberRepo is the repository of the project members
+type MemberRepo interface {
+	Create(context.Context, *ProjectMember) error
+	// Get returns the membership of a user in a project
+	Get(ctx context.Context, projectID, userID uuid.UUID) (ProjectMember, error)
+	List(ctx context.Context, projectID uuid.UUID) ([]ProjectMember, error)
+	// ListByUser returns the memberships of a user
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]ProjectMember, error)
+	UpdateRole(ctx context.Context, projectID, userID uuid.UUID, role Role) error
+	Delete(ctx context.Context, projectID, userID uuid.UUID) error
+	// CountRole returns the number of members of the project with the role
+	CountRole(ctx context.Context, projectID uuid.UUID, role Role) (int, error)
+}
+
+// InvitationRepo is the repository of the invitations
+type InvitationRepo interface {
+	Create(context.Context, *Invitation) error
+	// GetByHash returns the invitation with the given token hash
+	GetByHash(context.Context, string) (Invitation, error)
+	UpdateStatus(ctx context.Context, id uuid.UUID, status InvitationStatus) error
+}
+
+// MemberService manages the members of the projects
+type MemberService interface {
+	// Invite creates an invitation and returns the token to accept or decline it
+	Invite(context.Context, InviteMemberRequest) (Invitation, string, error)
+	// Accept adds the authenticated user to the project of the invitation
+	Accept(ctx context.Context, token string) (ProjectMember, error)
+	// Decline declines an invitation
+	Decline(ctx context.Context, token string) error
+	List(ctx context.Context, projectID uuid.UUID) ([]ProjectMember, error)
+	UpdateRole(ctx context.Context, projectID, userID uuid.UUID, role Role) error
+	Remove(ctx context.Context, projectID, userID uuid.UUID) error
+}
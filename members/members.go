@@ -0,0 +1,318 @@
+package members
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+	"github.com/gosom/goappbuild/pkg/securetoken"
+)
+
+var _ goappbuild.MemberService = (*service)(nil)
+
+// InvitationTTL is how long an invitation can be accepted
+const InvitationTTL = 7 * 24 * time.Hour
+
+var (
+	errInvalidInvitation = goappbuild.Errorf(goappbuild.ENotFound, "invitation not found or expired")
+	errLastOwner         = goappbuild.Errorf(goappbuild.EValidation, "a project must have at least one owner")
+)
+
+type service struct {
+	storage goappbuild.Storage
+}
+
+// New returns a new member service
+func New(storage goappbuild.Storage) goappbuild.MemberService {
+	return &service{
+		storage: storage,
+	}
+}
+
+func (s *service) Invite(ctx context.Context, req goappbuild.InviteMemberRequest) (goappbuild.Invitation, string, error) {
+	req.Email = goappbuild.NormalizeEmail(req.Email)
+
+	if err := req.Validate(); err != nil {
+		return goappbuild.Invitation{}, "", err
+	}
+
+	identity, err := authz.User(ctx)
+	if err != nil {
+		return goappbuild.Invitation{}, "", err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.Invitation{}, "", err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if _, err := authz.Project(ctx, uw, req.ProjectID, managerRole(req.Role)); err != nil {
+		return goappbuild.Invitation{}, "", err
+	}
+
+	if err := checkNotMember(ctx, uw, req.ProjectID, req.Email); err != nil {
+		return goappbuild.Invitation{}, "", err
+	}
+
+	token, err := securetoken.Generate("", securetoken.DefaultSize)
+	if err != nil {
+		return goappbuild.Invitation{}, "", err
+	}
+
+	inv := goappbuild.Invitation{
+		ProjectID: req.ProjectID,
+		Email:     req.Email,
+		Role:      req.Role,
+		TokenHash: securetoken.Hash(token),
+		InvitedBy: identity.UserID,
+		Status:    goappbuild.InvitationPending,
+		ExpiresAt: time.Now().UTC().Add(InvitationTTL),
+	}
+
+	if err := uw.Invitations().Create(ctx, &inv); err != nil {
+		return goappbuild.Invitation{}, "", err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.Invitation{}, "", err
+	}
+
+	return inv, token, nil
+}
+
+// Accept adds the authenticated user to the project. The invitation
+// can only be accepted by the user it was sent to.
+func (s *service) Accept(ctx context.Context, token string) (goappbuild.ProjectMember, error) {
+	identity, err := authz.User(ctx)
+	if err != nil {
+		return goappbuild.ProjectMember{}, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.ProjectMember{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	inv, err := pendingInvitation(ctx, uw, token)
+	if err != nil {
+		return goappbuild.ProjectMember{}, err
+	}
+
+	u, err := uw.Users().Get(ctx, identity.UserID)
+	if err != nil {
+		return goappbuild.ProjectMember{}, err
+	}
+
+	if u.Email != inv.Email {
+		return goappbuild.ProjectMember{}, goappbuild.Errorf(goappbuild.EForbidden, "the invitation was sent to another email")
+	}
+
+	m := goappbuild.ProjectMember{
+		ProjectID: inv.ProjectID,
+		UserID:    u.ID,
+		Email:     u.Email,
+		Role:      inv.Role,
+	}
+
+	if err := uw.Members().Create(ctx, &m); err != nil {
+		return goappbuild.ProjectMember{}, err
+	}
+
+	if err := uw.Invitations().UpdateStatus(ctx, inv.ID, goappbuild.InvitationAccepted); err != nil {
+		return goappbuild.ProjectMember{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.ProjectMember{}, err
+	}
+
+	return m, nil
+}
+
+// Decline declines an invitation. Holding the token is enough.
+func (s *service) Decline(ctx context.Context, token string) error {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	inv, err := pendingInvitation(ctx, uw, token)
+	if err != nil {
+		return err
+	}
+
+	if err := uw.Invitations().UpdateStatus(ctx, inv.ID, goappbuild.InvitationDeclined); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+func (s *service) List(ctx context.Context, projectID uuid.UUID) ([]goappbuild.ProjectMember, error) {
+	if _, err := authz.User(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := authz.Project(ctx, s.storage, projectID, goappbuild.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	return s.storage.Members().List(ctx, projectID)
+}
+
+// UpdateRole changes the role of a member. Only owners can grant or
+// revoke the owner role.
+func (s *service) UpdateRole(ctx context.Context, projectID, userID uuid.UUID, role goappbuild.Role) error {
+	if err := role.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := authz.User(ctx); err != nil {
+		return err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	m, err := uw.Members().Get(ctx, projectID, userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := authz.Project(ctx, uw, projectID, managerRole(role, m.Role)); err != nil {
+		return err
+	}
+
+	if m.Role == goappbuild.RoleOwner && role != goappbuild.RoleOwner {
+		if err := checkNotLastOwner(ctx, uw, projectID); err != nil {
+			return err
+		}
+	}
+
+	if err := uw.Members().UpdateRole(ctx, projectID, userID, role); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// Remove removes a member from the project. Members can always leave a project.
+func (s *service) Remove(ctx context.Context, projectID, userID uuid.UUID) error {
+	identity, err := authz.User(ctx)
+	if err != nil {
+		return err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	m, err := uw.Members().Get(ctx, projectID, userID)
+	if err != nil {
+		return err
+	}
+
+	required := managerRole(m.Role)
+	if userID == identity.UserID {
+		required = goappbuild.RoleViewer
+	}
+
+	if _, err := authz.Project(ctx, uw, projectID, required); err != nil {
+		return err
+	}
+
+	if m.Role == goappbuild.RoleOwner {
+		if err := checkNotLastOwner(ctx, uw, projectID); err != nil {
+			return err
+		}
+	}
+
+	if err := uw.Members().Delete(ctx, projectID, userID); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// managerRole returns the role required to manage members with the given roles
+func managerRole(roles ...goappbuild.Role) goappbuild.Role {
+	for _, r := range roles {
+		if r == goappbuild.RoleOwner {
+			return goappbuild.RoleOwner
+		}
+	}
+
+	return goappbuild.RoleAdmin
+}
+
+func checkNotLastOwner(ctx context.Context, uw goappbuild.Storage, projectID uuid.UUID) error {
+	n, err := uw.Members().CountRole(ctx, projectID, goappbuild.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	if n <= 1 {
+		return errLastOwner
+	}
+
+	return nil
+}
+
+func checkNotMember(ctx context.Context, uw goappbuild.Storage, projectID uuid.UUID, email string) error {
+	u, err := uw.Users().GetByEmail(ctx, email)
+	if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = uw.Members().Get(ctx, projectID, u.ID)
+	if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return goappbuild.Errorf(goappbuild.EConflict, "%s is already a member of the project", email)
+}
+
+func pendingInvitation(ctx context.Context, uw goappbuild.Storage, token string) (goappbuild.Invitation, error) {
+	if token == "" {
+		return goappbuild.Invitation{}, errInvalidInvitation
+	}
+
+	inv, err := uw.Invitations().GetByHash(ctx, securetoken.Hash(token))
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.Invitation{}, errInvalidInvitation
+		}
+
+		return goappbuild.Invitation{}, err
+	}
+
+	if inv.Status != goappbuild.InvitationPending || !inv.ExpiresAt.After(time.Now().UTC()) {
+		return goappbuild.Invitation{}, errInvalidInvitation
+	}
+
+	return inv, nil
+}
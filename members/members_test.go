@@ -0,0 +1,121 @@
+package members_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/members"
+)
+
+func Test_MemberService(t *testing.T) {
+	storage := memstore.New()
+	svc := members.New(storage)
+	bg := context.Background()
+
+	newUser := func(t *testing.T, email string) (goappbuild.User, context.Context) {
+		t.Helper()
+
+		u := goappbuild.User{Email: email}
+		require.NoError(t, storage.UserRepo.Create(bg, &u))
+
+		return u, goappbuild.ContextWithIdentity(bg, goappbuild.Identity{UserID: u.ID})
+	}
+
+	owner, ownerCtx := newUser(t, "owner@example.com")
+	invited, invitedCtx := newUser(t, "invited@example.com")
+	_, strangerCtx := newUser(t, "stranger@example.com")
+
+	project := goappbuild.Project{UserID: owner.ID, Name: "team"}
+	require.NoError(t, storage.ProjectRepo.Create(bg, &project))
+	require.NoError(t, storage.MemberRepo.Create(bg, &goappbuild.ProjectMember{
+		ProjectID: project.ID,
+		UserID:    owner.ID,
+		Role:      goappbuild.RoleOwner,
+	}))
+
+	t.Run("test non members cannot invite", func(t *testing.T) {
+		req := goappbuild.InviteMemberRequest{ProjectID: project.ID, Email: "x@example.com", Role: goappbuild.RoleViewer}
+
+		_, _, err := svc.Invite(strangerCtx, req)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	var token string
+
+	t.Run("test invite and accept", func(t *testing.T) {
+		req := goappbuild.InviteMemberRequest{ProjectID: project.ID, Email: "Invited@Example.com", Role: goappbuild.RoleViewer}
+
+		inv, tok, err := svc.Invite(ownerCtx, req)
+		require.NoError(t, err)
+		require.Equal(t, "invited@example.com", inv.Email)
+		require.NotEmpty(t, tok)
+
+		_, err = svc.Accept(strangerCtx, tok)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		m, err := svc.Accept(invitedCtx, tok)
+		require.NoError(t, err)
+		require.Equal(t, goappbuild.RoleViewer, m.Role)
+
+		_, err = svc.Accept(invitedCtx, tok)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		token = tok
+	})
+
+	t.Run("test declined token cannot be used", func(t *testing.T) {
+		req := goappbuild.InviteMemberRequest{ProjectID: project.ID, Email: "stranger@example.com", Role: goappbuild.RoleAdmin}
+
+		_, tok, err := svc.Invite(ownerCtx, req)
+		require.NoError(t, err)
+		require.NotEqual(t, token, tok)
+
+		require.NoError(t, svc.Decline(bg, tok))
+
+		_, err = svc.Accept(strangerCtx, tok)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test viewers cannot manage members", func(t *testing.T) {
+		err := svc.UpdateRole(invitedCtx, project.ID, invited.ID, goappbuild.RoleAdmin)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.List(invitedCtx, project.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("test only owners can grant ownership", func(t *testing.T) {
+		require.NoError(t, svc.UpdateRole(ownerCtx, project.ID, invited.ID, goappbuild.RoleAdmin))
+
+		err := svc.UpdateRole(invitedCtx, project.ID, invited.ID, goappbuild.RoleOwner)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		err = svc.Remove(invitedCtx, project.ID, owner.ID)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test the last owner cannot leave", func(t *testing.T) {
+		err := svc.Remove(ownerCtx, project.ID, owner.ID)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		err = svc.UpdateRole(ownerCtx, project.ID, owner.ID, goappbuild.RoleAdmin)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test members can leave", func(t *testing.T) {
+		require.NoError(t, svc.Remove(invitedCtx, project.ID, invited.ID))
+
+		_, err := svc.List(invitedCtx, project.ID)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test unknown project", func(t *testing.T) {
+		_, err := svc.List(ownerCtx, uuid.New())
+		require.Error(t, err)
+	})
+}
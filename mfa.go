@@ -0,0 +1,83 @@
+package goappbuild
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// RecoveryCodeCount is the number of recovery codes issued when MFA is enabled
+	RecoveryCodeCount = 10
+	// MFAChallengeTTL is how long a user has to verify the second factor after
+	// the password
+	MFAChallengeTTL = 5 * time.Minute
+)
+
+// TOTPFactor is the authenticator app (TOTP) second factor of a user
+type TOTPFactor struct {
+	UserID uuid.UUID
+	// Secret is the base32 encoded shared secret
+	Secret string
+	// LastStep is the time step of the last accepted code,
+	// codes of the same or earlier steps are rejected
+	LastStep int64
+	// ConfirmedAt is set once the user proves the enrollment with a code,
+	// unconfirmed factors are not required at login
+	ConfirmedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// IsConfirmed returns true if the factor is enabled
+func (o TOTPFactor) IsConfirmed() bool {
+	return o.ConfirmedAt != nil
+}
+
+// MFAEnrollment is the secret of a new TOTP factor
+type MFAEnrollment struct {
+	Secret string
+	// URI is the otpauth URI authenticator apps import
+	URI string
+}
+
+// LoginMFARequest is the second step of a login for users with MFA enabled
+type LoginMFARequest struct {
+	// MFAToken is the token returned by the first step
+	MFAToken string
+	// Code is a TOTP code or a recovery code
+	Code string
+}
+
+// MFARepo represents a repository for the second factors of the users.
+type MFARepo interface {
+	GetFactor(ctx context.Context, userID uuid.UUID) (TOTPFactor, error)
+	// SaveFactor creates or replaces the factor of the user
+	SaveFactor(context.Context, *TOTPFactor) error
+	// UseStep records the time step of an accepted code. It returns an
+	// ENotFound error if the step is not after the last accepted one.
+	UseStep(ctx context.Context, userID uuid.UUID, step int64) error
+	// DeleteFactor removes the factor and the recovery codes of the user
+	DeleteFactor(ctx context.Context, userID uuid.UUID) error
+	// ReplaceRecoveryCodes replaces the recovery codes of the user
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error
+	// UseRecoveryCode marks an unused recovery code as used. It returns
+	// an ENotFound error if there is no such code.
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) error
+}
+
+// MFAService manages the second factor of the users.
+type MFAService interface {
+	// Enroll creates a TOTP factor for the caller. The factor is enabled
+	// once confirmed with a code.
+	Enroll(ctx context.Context) (MFAEnrollment, error)
+	// Confirm enables the factor of the caller and returns the recovery
+	// codes. They are not stored in plain text and are shown only once.
+	Confirm(ctx context.Context, code string) ([]string, error)
+	// Disable removes the factor of the caller, a code or a recovery code is required
+	Disable(ctx context.Context, code string) error
+	// Reset removes the factor of a user that lost access to it.
+	// Only admins can reset.
+	Reset(ctx context.Context, userID uuid.UUID) error
+}
@@ -0,0 +1,272 @@
+// Package mfa manages the TOTP second factor and the recovery codes of the users.
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+	"github.com/gosom/goappbuild/pkg/securetoken"
+	"github.com/gosom/goappbuild/pkg/totp"
+)
+
+var _ goappbuild.MFAService = (*service)(nil)
+
+// DefaultIssuer is the default name the authenticator apps show for the accounts
+const DefaultIssuer = "goappbuild"
+
+var (
+	errInvalidCode = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid code")
+	errNotEnabled  = goappbuild.Errorf(goappbuild.EValidation, "mfa is not enabled")
+)
+
+// recoveryEncoding is used for the recovery codes, lowercase so they are easy to type
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// Config is the configuration of the mfa service
+type Config struct {
+	// Issuer is the name the authenticator apps show for the accounts
+	Issuer string
+}
+
+type service struct {
+	storage goappbuild.Storage
+	cfg     Config
+}
+
+// New returns a new mfa service
+func New(storage goappbuild.Storage, cfg Config) goappbuild.MFAService {
+	if cfg.Issuer == "" {
+		cfg.Issuer = DefaultIssuer
+	}
+
+	return &service{
+		storage: storage,
+		cfg:     cfg,
+	}
+}
+
+// Enroll creates a new TOTP factor for the caller, replacing an unconfirmed one
+func (s *service) Enroll(ctx context.Context) (goappbuild.MFAEnrollment, error) {
+	identity, err := authz.Self(ctx)
+	if err != nil {
+		return goappbuild.MFAEnrollment{}, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.MFAEnrollment{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	u, err := uw.Users().Get(ctx, identity.UserID)
+	if err != nil {
+		return goappbuild.MFAEnrollment{}, err
+	}
+
+	existing, err := uw.MFA().GetFactor(ctx, u.ID)
+
+	switch {
+	case err == nil && existing.IsConfirmed():
+		return goappbuild.MFAEnrollment{}, goappbuild.Errorf(goappbuild.EConflict, "mfa is already enabled")
+	case err != nil && goappbuild.ErrorCode(err) != goappbuild.ENotFound:
+		return goappbuild.MFAEnrollment{}, err
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return goappbuild.MFAEnrollment{}, err
+	}
+
+	factor := goappbuild.TOTPFactor{
+		UserID: u.ID,
+		Secret: secret,
+	}
+
+	if err := uw.MFA().SaveFactor(ctx, &factor); err != nil {
+		return goappbuild.MFAEnrollment{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.MFAEnrollment{}, err
+	}
+
+	ans := goappbuild.MFAEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.cfg.Issuer, u.Email, secret),
+	}
+
+	return ans, nil
+}
+
+// Confirm enables the factor of the caller and issues the recovery codes
+func (s *service) Confirm(ctx context.Context, code string) ([]string, error) {
+	identity, err := authz.Self(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	factor, err := uw.MFA().GetFactor(ctx, identity.UserID)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return nil, goappbuild.Errorf(goappbuild.EValidation, "mfa enrollment has not started")
+		}
+
+		return nil, err
+	}
+
+	if factor.IsConfirmed() {
+		return nil, goappbuild.Errorf(goappbuild.EConflict, "mfa is already enabled")
+	}
+
+	step, ok := totp.Validate(factor.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, errInvalidCode
+	}
+
+	now := time.Now().UTC()
+	factor.ConfirmedAt = &now
+	factor.LastStep = step
+
+	if err := uw.MFA().SaveFactor(ctx, &factor); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uw.MFA().ReplaceRecoveryCodes(ctx, identity.UserID, hashes); err != nil {
+		return nil, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable removes the factor of the caller
+func (s *service) Disable(ctx context.Context, code string) error {
+	identity, err := authz.Self(ctx)
+	if err != nil {
+		return err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if err := Verify(ctx, uw, identity.UserID, code); err != nil {
+		return err
+	}
+
+	if err := uw.MFA().DeleteFactor(ctx, identity.UserID); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// Reset removes the factor of a user. The user can log in with the password
+// only and enroll again.
+func (s *service) Reset(ctx context.Context, userID uuid.UUID) error {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if _, err := authz.Admin(ctx, uw); err != nil {
+		return err
+	}
+
+	if _, err := uw.Users().Get(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := uw.MFA().DeleteFactor(ctx, userID); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// Verify checks a TOTP code or a recovery code of a user with MFA enabled.
+// Accepted codes cannot be used again.
+func Verify(ctx context.Context, uw goappbuild.Storage, userID uuid.UUID, code string) error {
+	factor, err := uw.MFA().GetFactor(ctx, userID)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return errNotEnabled
+		}
+
+		return err
+	}
+
+	if !factor.IsConfirmed() {
+		return errNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+
+	if step, ok := totp.Validate(factor.Secret, code, time.Now()); ok {
+		err = uw.MFA().UseStep(ctx, userID, step)
+	} else {
+		err = uw.MFA().UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	}
+
+	if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+		return errInvalidCode
+	}
+
+	return err
+}
+
+// newRecoveryCodes returns the recovery codes, formatted as xxxxx-xxxxx,
+// and their hashes
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes = make([]string, goappbuild.RecoveryCodeCount)
+	hashes = make([]string, goappbuild.RecoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+
+		code := recoveryEncoding.EncodeToString(b)[:10]
+
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring the case and the separators
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	return securetoken.Hash(code)
+}
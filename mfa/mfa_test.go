@@ -0,0 +1,104 @@
+package mfa_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/mfa"
+	"github.com/gosom/goappbuild/pkg/totp"
+)
+
+func code(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+
+	c, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	require.NoError(t, err)
+
+	return c
+}
+
+func Test_MFAService(t *testing.T) {
+	storage := memstore.New()
+	svc := mfa.New(storage, mfa.Config{})
+	bg := context.Background()
+
+	u := goappbuild.User{Email: "jane@example.com"}
+	require.NoError(t, storage.UserRepo.Create(bg, &u))
+
+	ctx := goappbuild.ContextWithIdentity(bg, goappbuild.Identity{UserID: u.ID})
+
+	enrollment, err := svc.Enroll(ctx)
+	require.NoError(t, err)
+	require.Contains(t, enrollment.URI, "otpauth://totp/goappbuild:jane@example.com?")
+
+	t.Run("test not enabled before confirmation", func(t *testing.T) {
+		err := mfa.Verify(bg, storage, u.ID, code(t, enrollment.Secret, 0))
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	_, err = svc.Confirm(ctx, "000000")
+	require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+	recovery, err := svc.Confirm(ctx, code(t, enrollment.Secret, 0))
+	require.NoError(t, err)
+	require.Len(t, recovery, goappbuild.RecoveryCodeCount)
+
+	t.Run("test enroll again", func(t *testing.T) {
+		_, err := svc.Enroll(ctx)
+		require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test codes are used once", func(t *testing.T) {
+		err := mfa.Verify(bg, storage, u.ID, code(t, enrollment.Secret, 0))
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		require.NoError(t, mfa.Verify(bg, storage, u.ID, code(t, enrollment.Secret, 1)))
+	})
+
+	t.Run("test recovery codes are used once", func(t *testing.T) {
+		require.NoError(t, mfa.Verify(bg, storage, u.ID, " "+recovery[0]+" "))
+
+		err := mfa.Verify(bg, storage, u.ID, recovery[0])
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test reset is for admins", func(t *testing.T) {
+		err := svc.Reset(ctx, u.ID)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		admin := goappbuild.User{Email: "admin@example.com", IsAdmin: true}
+		require.NoError(t, storage.UserRepo.Create(bg, &admin))
+
+		adminCtx := goappbuild.ContextWithIdentity(bg, goappbuild.Identity{UserID: admin.ID})
+
+		err = svc.Reset(adminCtx, uuid.New())
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		require.NoError(t, svc.Reset(adminCtx, u.ID))
+
+		err = mfa.Verify(bg, storage, u.ID, recovery[1])
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test disable", func(t *testing.T) {
+		enrollment, err := svc.Enroll(ctx)
+		require.NoError(t, err)
+
+		recovery, err := svc.Confirm(ctx, code(t, enrollment.Secret, 0))
+		require.NoError(t, err)
+
+		err = svc.Disable(ctx, "invalid")
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		require.NoError(t, svc.Disable(ctx, recovery[0]))
+
+		_, err = storage.MFARepo.GetFactor(bg, u.ID)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+}
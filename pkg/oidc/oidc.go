@@ -0,0 +1,327 @@
+// Package oidc is a minimal OpenID Connect relying party: discovery, the
+// authorization code flow with PKCE and ID token verification. Only RS256
+// signed ID tokens are supported.
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/gosom/goappbuild/pkg/securetoken"
+)
+
+// DiscoveryPath is the path of the discovery document relative to the issuer
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+// maxResponseSize limits the responses read from the providers
+const maxResponseSize = 1 << 20
+
+// ErrInvalidToken is returned when an ID token does not verify
+var ErrInvalidToken = errors.New("invalid id token")
+
+// Metadata is the subset of the discovery document the client uses
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Config is the registration of the client at a provider
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested besides openid
+	Scopes []string
+}
+
+// Token is the response of the token endpoint
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Claims are the claims of a verified ID token
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Client talks to OpenID Connect providers
+type Client struct {
+	http *http.Client
+}
+
+// New returns a new client. A nil http client uses a default with a timeout.
+func New(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Client{
+		http: httpClient,
+	}
+}
+
+// Discover fetches the discovery document of the issuer
+func (c *Client) Discover(ctx context.Context, issuer string) (Metadata, error) {
+	var ans Metadata
+
+	u := strings.TrimSuffix(issuer, "/") + DiscoveryPath
+	if err := c.getJSON(ctx, u, &ans); err != nil {
+		return Metadata{}, fmt.Errorf("discovery: %w", err)
+	}
+
+	if ans.Issuer != issuer {
+		return Metadata{}, fmt.Errorf("discovery: issuer %q does not match %q", ans.Issuer, issuer)
+	}
+
+	if ans.AuthorizationEndpoint == "" || ans.TokenEndpoint == "" || ans.JWKSURI == "" {
+		return Metadata{}, errors.New("discovery: incomplete discovery document")
+	}
+
+	return ans, nil
+}
+
+// AuthCodeURL returns the url the user is sent to in order to authenticate
+func AuthCodeURL(meta Metadata, cfg Config, state, nonce, verifier string) string {
+	scopes := append([]string{"openid"}, cfg.Scopes...)
+
+	v := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return meta.AuthorizationEndpoint + sep + v.Encode()
+}
+
+// Exchange exchanges an authorization code for tokens
+func (c *Client) Exchange(ctx context.Context, meta Metadata, cfg Config, code, verifier string) (Token, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"client_id":     {cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Token{}, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return Token{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var oerr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+
+		_ = json.Unmarshal(body, &oerr)
+
+		return Token{}, fmt.Errorf("token endpoint: %s %s (status %d)", oerr.Error, oerr.Description, resp.StatusCode)
+	}
+
+	var ans Token
+	if err := json.Unmarshal(body, &ans); err != nil {
+		return Token{}, fmt.Errorf("token endpoint: %w", err)
+	}
+
+	if ans.IDToken == "" {
+		return Token{}, errors.New("token endpoint: missing id_token")
+	}
+
+	return ans, nil
+}
+
+// Verify verifies the signature, the issuer, the audience, the expiration
+// and the nonce of an ID token and returns its claims
+func (c *Client) Verify(ctx context.Context, meta Metadata, clientID, rawIDToken, nonce string) (Claims, error) {
+	keys, err := c.keys(ctx, meta.JWKSURI)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	keyfunc := func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+
+		// tokens without a kid are accepted only when there is a single key
+		if kid == "" && len(keys) == 1 {
+			for _, key := range keys {
+				return key, nil
+			}
+		}
+
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	claims := jwt.MapClaims{}
+
+	_, err = jwt.ParseWithClaims(
+		rawIDToken,
+		claims,
+		keyfunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if got, _ := claims["nonce"].(string); !securetoken.Equal(got, nonce) {
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	ans := Claims{}
+	ans.Subject, _ = claims["sub"].(string)
+	ans.Email, _ = claims["email"].(string)
+	ans.Name, _ = claims["name"].(string)
+
+	// some providers send email_verified as a string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		ans.EmailVerified = v
+	case string:
+		ans.EmailVerified = v == "true"
+	}
+
+	if ans.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	return ans, nil
+}
+
+// NewVerifier returns a new PKCE code verifier
+func NewVerifier() (string, error) {
+	return securetoken.Generate("", securetoken.DefaultSize)
+}
+
+// Challenge returns the S256 PKCE code challenge of the verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// keys fetches the RSA signing keys of the provider by key id
+func (c *Client) keys(ctx context.Context, jwksURI string) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+
+	if err := c.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	ans := make(map[string]*rsa.PublicKey, len(set.Keys))
+
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", k.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %w", k.Kid, err)
+		}
+
+		ans[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(ans) == 0 {
+		return nil, errors.New("jwks: no RSA signing keys")
+	}
+
+	return ans, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, u string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(dst)
+}
@@ -0,0 +1,129 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild/pkg/oidc"
+	"github.com/gosom/goappbuild/pkg/oidc/oidctest"
+)
+
+func Test_Client(t *testing.T) {
+	provider := oidctest.New("client", "secret")
+	defer provider.Close()
+
+	provider.SetUser(oidctest.User{Subject: "42", Email: "jane@example.com", EmailVerified: true, Name: "Jane"})
+
+	ctx := context.Background()
+	client := oidc.New(provider.Client())
+
+	cfg := oidc.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://app.example.com/callback",
+		Scopes:       []string{"email"},
+	}
+
+	meta, err := client.Discover(ctx, provider.URL)
+	require.NoError(t, err)
+
+	login := func(t *testing.T, nonce string) (string, string) {
+		t.Helper()
+
+		verifier, err := oidc.NewVerifier()
+		require.NoError(t, err)
+
+		code, state, err := provider.Authorize(oidc.AuthCodeURL(meta, cfg, "the-state", nonce, verifier))
+		require.NoError(t, err)
+		require.Equal(t, "the-state", state)
+
+		return code, verifier
+	}
+
+	t.Run("test issuer mismatch", func(t *testing.T) {
+		_, err := client.Discover(ctx, provider.URL+"/other")
+		require.Error(t, err)
+	})
+
+	t.Run("test code flow", func(t *testing.T) {
+		code, verifier := login(t, "n1")
+
+		token, err := client.Exchange(ctx, meta, cfg, code, verifier)
+		require.NoError(t, err)
+
+		claims, err := client.Verify(ctx, meta, cfg.ClientID, token.IDToken, "n1")
+		require.NoError(t, err)
+		require.Equal(t, oidc.Claims{Subject: "42", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}, claims)
+
+		_, err = client.Exchange(ctx, meta, cfg, code, verifier)
+		require.Error(t, err, "codes can be used once")
+	})
+
+	t.Run("test wrong verifier", func(t *testing.T) {
+		code, _ := login(t, "n1")
+
+		other, err := oidc.NewVerifier()
+		require.NoError(t, err)
+
+		_, err = client.Exchange(ctx, meta, cfg, code, other)
+		require.Error(t, err)
+	})
+
+	t.Run("test wrong nonce", func(t *testing.T) {
+		code, verifier := login(t, "n1")
+
+		token, err := client.Exchange(ctx, meta, cfg, code, verifier)
+		require.NoError(t, err)
+
+		_, err = client.Verify(ctx, meta, cfg.ClientID, token.IDToken, "n2")
+		require.True(t, errors.Is(err, oidc.ErrInvalidToken))
+	})
+
+	t.Run("test wrong audience", func(t *testing.T) {
+		code, verifier := login(t, "n1")
+
+		token, err := client.Exchange(ctx, meta, cfg, code, verifier)
+		require.NoError(t, err)
+
+		_, err = client.Verify(ctx, meta, "other-client", token.IDToken, "n1")
+		require.True(t, errors.Is(err, oidc.ErrInvalidToken))
+	})
+
+	t.Run("test expired token", func(t *testing.T) {
+		provider.OverrideClaims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})
+		defer provider.OverrideClaims(nil)
+
+		code, verifier := login(t, "n1")
+
+		token, err := client.Exchange(ctx, meta, cfg, code, verifier)
+		require.NoError(t, err)
+
+		_, err = client.Verify(ctx, meta, cfg.ClientID, token.IDToken, "n1")
+		require.True(t, errors.Is(err, oidc.ErrInvalidToken))
+	})
+
+	t.Run("test token of another provider", func(t *testing.T) {
+		other := oidctest.New("client", "secret")
+		defer other.Close()
+
+		otherMeta, err := oidc.New(other.Client()).Discover(ctx, other.URL)
+		require.NoError(t, err)
+
+		verifier, err := oidc.NewVerifier()
+		require.NoError(t, err)
+
+		code, _, err := other.Authorize(oidc.AuthCodeURL(otherMeta, cfg, "s", "n1", verifier))
+		require.NoError(t, err)
+
+		token, err := oidc.New(other.Client()).Exchange(ctx, otherMeta, cfg, code, verifier)
+		require.NoError(t, err)
+
+		_, err = client.Verify(ctx, meta, cfg.ClientID, token.IDToken, "n1")
+		require.True(t, errors.Is(err, oidc.ErrInvalidToken))
+	})
+}
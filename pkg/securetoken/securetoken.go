@@ -0,0 +1,40 @@
+package securetoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// DefaultSize is the default number of random bytes of a token.
+const DefaultSize = 32
+
+// Generate returns a random url safe token of size random bytes
+// prefixed with prefix.
+func Generate(prefix string, size int) (string, error) {
+	if size <= 0 {
+		size = DefaultSize
+	}
+
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns the hex encoded sha256 hash of the token.
+// Tokens have enough entropy so a fast hash is sufficient.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Equal compares two tokens in constant time.
+func Equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,80 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild/pkg/totp"
+)
+
+func Test_Code(t *testing.T) {
+	t.Parallel()
+
+	// the SHA1 test vectors of RFC 6238, truncated to 6 digits
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for ts, want := range vectors {
+		got, err := totp.Code(secret, totp.Step(time.Unix(ts, 0)))
+		require.NoError(t, err)
+		require.Equal(t, want, got, "time %d", ts)
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+
+	secret, err := totp.NewSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	step := totp.Step(now)
+
+	code, err := totp.Code(secret, step)
+	require.NoError(t, err)
+
+	got, ok := totp.Validate(secret, code, now)
+	require.True(t, ok)
+	require.Equal(t, step, got)
+
+	t.Run("previous step is accepted", func(t *testing.T) {
+		t.Parallel()
+
+		_, ok := totp.Validate(secret, code, now.Add(totp.Period))
+		require.True(t, ok)
+	})
+
+	t.Run("old code", func(t *testing.T) {
+		t.Parallel()
+
+		_, ok := totp.Validate(secret, code, now.Add(3*totp.Period))
+		require.False(t, ok)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		t.Parallel()
+
+		_, ok := totp.Validate(secret, "12345", now)
+		require.False(t, ok)
+	})
+
+	t.Run("uri", func(t *testing.T) {
+		t.Parallel()
+
+		uri := totp.URI("goappbuild", "jane@example.com", secret)
+		require.True(t, strings.HasPrefix(uri, "otpauth://totp/goappbuild:jane@example.com?"))
+		require.Contains(t, uri, "secret="+secret)
+	})
+}
@@ -0,0 +1,234 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.ACLRepo = (*aclRepo)(nil)
+
+const aclColumns = `id, created_at, project_id, collection, document_id,
+	principal_type, principal_id, permission, created_by`
+
+type aclRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewACLRepo returns a new instance of a postgres ACL repository
+func NewACLRepo(conn sqlext.DBTX) goappbuild.ACLRepo {
+	return &aclRepo{
+		conn: conn,
+	}
+}
+
+// Upsert stores the entry or replaces the permission of the existing one
+func (o *aclRepo) Upsert(ctx context.Context, e *goappbuild.ACLEntry) error {
+	q := `INSERT INTO document_acl
+		(created_at, project_id, collection, document_id, principal_type, principal_id, permission, created_by)
+		VALUES ((NOW() at time zone 'utc'), $1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (project_id, collection, document_id, principal_type, principal_id)
+		DO UPDATE SET permission = EXCLUDED.permission, created_by = EXCLUDED.created_by
+		RETURNING ` + aclColumns
+
+	dbe, err := sqlext.QueryRow[dbACLEntry](
+		ctx, o.conn, q,
+		e.ProjectID, e.Collection, e.DocumentID, e.PrincipalType, e.PrincipalID, e.Permission, nullUUID(e.CreatedBy),
+	)
+	if err != nil {
+		return err
+	}
+
+	*e = dbe.toModel()
+
+	return nil
+}
+
+// Delete deletes the entry of the principal on a document
+func (o *aclRepo) Delete(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collection, documentID string,
+	principalID uuid.UUID,
+) error {
+	const q = `DELETE FROM document_acl
+		WHERE project_id = $1 AND collection = $2 AND document_id = $3 AND principal_id = $4`
+
+	res, err := o.conn.ExecContext(ctx, q, projectID, collection, documentID, principalID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "acl entry not found")
+	}
+
+	return nil
+}
+
+// DeleteDocuments deletes the entries of the documents
+func (o *aclRepo) DeleteDocuments(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collection string,
+	documentIDs ...string,
+) error {
+	if len(documentIDs) == 0 {
+		return nil
+	}
+
+	const q = `DELETE FROM document_acl
+		WHERE project_id = $1 AND collection = $2 AND document_id = ANY($3)`
+
+	_, err := o.conn.ExecContext(ctx, q, projectID, collection, documentIDs)
+
+	return err
+}
+
+// DeletePrincipal deletes the entries of the principal
+func (o *aclRepo) DeletePrincipal(
+	ctx context.Context,
+	projectID uuid.UUID,
+	principalType goappbuild.PrincipalType,
+	principalID uuid.UUID,
+) error {
+	const q = `DELETE FROM document_acl
+		WHERE project_id = $1 AND principal_type = $2 AND principal_id = $3`
+
+	_, err := o.conn.ExecContext(ctx, q, projectID, principalType, principalID)
+
+	return err
+}
+
+// List returns the entries of a document, oldest first
+func (o *aclRepo) List(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collection, documentID string,
+) ([]goappbuild.ACLEntry, error) {
+	q := `SELECT ` + aclColumns + `
+		FROM document_acl
+		WHERE project_id = $1 AND collection = $2 AND document_id = $3
+		ORDER BY created_at`
+
+	items, err := sqlext.Query[dbACLEntry](ctx, o.conn, q, projectID, collection, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.ACLEntry, len(items))
+	for i := range items {
+		ans[i] = items[i].toModel()
+	}
+
+	return ans, nil
+}
+
+// Exists returns true if any document of the collection is shared with the
+// user, or a team of the user, with one of the permissions
+func (o *aclRepo) Exists(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collection string,
+	userID uuid.UUID,
+	permissions ...goappbuild.Permission,
+) (bool, error) {
+	const q = `SELECT EXISTS (
+		SELECT 1 FROM document_acl
+		WHERE project_id = $1 AND collection = $2 AND permission = ANY($4) AND ` + grantedTo + `
+	)`
+
+	return o.exists(ctx, q, projectID, collection, userID, permissionValues(permissions))
+}
+
+// Granted returns true if the document is shared with the user, or a
+// team of the user, with one of the permissions
+func (o *aclRepo) Granted(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collection, documentID string,
+	userID uuid.UUID,
+	permissions ...goappbuild.Permission,
+) (bool, error) {
+	const q = `SELECT EXISTS (
+		SELECT 1 FROM document_acl
+		WHERE project_id = $1 AND collection = $2 AND permission = ANY($4) AND ` + grantedTo + `
+			AND document_id = $5
+	)`
+
+	return o.exists(ctx, q, projectID, collection, userID, permissionValues(permissions), documentID)
+}
+
+// grantedTo matches the entries of the user ($3) and of the teams of the user
+const grantedTo = `(
+	(principal_type = 'user' AND principal_id = $3)
+	OR (principal_type = 'team' AND principal_id IN (SELECT team_id FROM team_members WHERE user_id = $3))
+)`
+
+func (o *aclRepo) exists(ctx context.Context, q string, args ...any) (bool, error) {
+	var ans bool
+
+	if err := o.conn.QueryRowContext(ctx, q, args...).Scan(&ans); err != nil {
+		return false, err
+	}
+
+	return ans, nil
+}
+
+func permissionValues(permissions []goappbuild.Permission) []string {
+	ans := make([]string, len(permissions))
+	for i := range permissions {
+		ans[i] = string(permissions[i])
+	}
+
+	return ans
+}
+
+type dbACLEntry struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	ProjectID     uuid.UUID
+	Collection    string
+	DocumentID    string
+	PrincipalType string
+	PrincipalID   uuid.UUID
+	Permission    string
+	CreatedBy     uuid.NullUUID
+}
+
+func (o *dbACLEntry) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.ProjectID,
+		&o.Collection,
+		&o.DocumentID,
+		&o.PrincipalType,
+		&o.PrincipalID,
+		&o.Permission,
+		&o.CreatedBy,
+	}
+}
+
+func (o *dbACLEntry) toModel() goappbuild.ACLEntry {
+	return goappbuild.ACLEntry{
+		ID:            o.ID,
+		CreatedAt:     o.CreatedAt,
+		ProjectID:     o.ProjectID,
+		Collection:    o.Collection,
+		DocumentID:    o.DocumentID,
+		PrincipalType: goappbuild.PrincipalType(o.PrincipalType),
+		PrincipalID:   o.PrincipalID,
+		Permission:    goappbuild.Permission(o.Permission),
+		CreatedBy:     o.CreatedBy.UUID,
+	}
+}
@@ -0,0 +1,237 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.APIKeyRepo = (*apiKeyRepo)(nil)
+
+const apiKeyColumns = `id, created_at, updated_at, project_id, name, prefix, key_hash,
+	scopes, expires_at, last_used_at, revoked_at`
+
+type apiKeyRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewAPIKeyRepo returns a new instance of a postgres API key repository
+func NewAPIKeyRepo(conn sqlext.DBTX) goappbuild.APIKeyRepo {
+	return &apiKeyRepo{
+		conn: conn,
+	}
+}
+
+// Create stores a new API key
+func (o *apiKeyRepo) Create(ctx context.Context, k *goappbuild.APIKey) error {
+	scopesJson, err := json.Marshal(k.Scopes)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO api_keys
+		(created_at, updated_at, project_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ((NOW() at time zone 'utc'), (NOW() at time zone 'utc'), $1, $2, $3, $4, $5, $6)
+		RETURNING ` + apiKeyColumns
+
+	dbk, err := sqlext.QueryRow[dbAPIKey](
+		ctx, o.conn, q,
+		k.ProjectID, k.Name, k.Prefix, k.KeyHash, scopesJson, k.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	ans, err := dbk.toModel()
+	if err != nil {
+		return err
+	}
+
+	*k = ans
+
+	return nil
+}
+
+// Get returns an API key of a project
+func (o *apiKeyRepo) Get(ctx context.Context, projectID, id uuid.UUID) (goappbuild.APIKey, error) {
+	q := `SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE project_id = $1 AND id = $2`
+
+	return o.one(ctx, q, projectID, id)
+}
+
+// GetByHash returns the API key with the given hash
+func (o *apiKeyRepo) GetByHash(ctx context.Context, hash string) (goappbuild.APIKey, error) {
+	q := `SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_hash = $1`
+
+	return o.one(ctx, q, hash)
+}
+
+// List returns the API keys of a project, newest first
+func (o *apiKeyRepo) List(ctx context.Context, projectID uuid.UUID) ([]goappbuild.APIKey, error) {
+	q := `SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE project_id = $1
+		ORDER BY created_at DESC`
+
+	items, err := sqlext.Query[dbAPIKey](ctx, o.conn, q, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.APIKey, len(items))
+
+	for i := range items {
+		ans[i], err = items[i].toModel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
+// UpdateKey replaces the prefix and the hash of an API key
+func (o *apiKeyRepo) UpdateKey(ctx context.Context, k *goappbuild.APIKey) error {
+	q := `UPDATE api_keys
+		SET prefix = $1, key_hash = $2, updated_at = (NOW() at time zone 'utc')
+		WHERE id = $3
+		RETURNING ` + apiKeyColumns
+
+	dbk, err := sqlext.QueryRow[dbAPIKey](ctx, o.conn, q, k.Prefix, k.KeyHash, k.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Errorf(goappbuild.ENotFound, "api key not found")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ans, err := dbk.toModel()
+	if err != nil {
+		return err
+	}
+
+	*k = ans
+
+	return nil
+}
+
+// Revoke revokes an API key of a project
+func (o *apiKeyRepo) Revoke(ctx context.Context, projectID, id uuid.UUID) error {
+	const q = `UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, (NOW() at time zone 'utc')),
+			updated_at = (NOW() at time zone 'utc')
+		WHERE project_id = $1 AND id = $2`
+
+	res, err := o.conn.ExecContext(ctx, q, projectID, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "api key not found")
+	}
+
+	return nil
+}
+
+// Touch updates the last used timestamp of an API key.
+// It is written at most once per minute to avoid a write on every request.
+func (o *apiKeyRepo) Touch(ctx context.Context, id uuid.UUID) error {
+	const q = `UPDATE api_keys
+		SET last_used_at = (NOW() at time zone 'utc')
+		WHERE id = $1
+			AND (last_used_at IS NULL OR last_used_at < (NOW() at time zone 'utc') - INTERVAL '1 minute')`
+
+	_, err := o.conn.ExecContext(ctx, q, id)
+
+	return err
+}
+
+func (o *apiKeyRepo) one(ctx context.Context, q string, args ...any) (goappbuild.APIKey, error) {
+	dbk, err := sqlext.QueryRow[dbAPIKey](ctx, o.conn, q, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.APIKey{}, goappbuild.Errorf(goappbuild.ENotFound, "api key not found")
+	}
+
+	if err != nil {
+		return goappbuild.APIKey{}, err
+	}
+
+	return dbk.toModel()
+}
+
+type dbAPIKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ProjectID  uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []byte
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+func (o *dbAPIKey) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.ProjectID,
+		&o.Name,
+		&o.Prefix,
+		&o.KeyHash,
+		&o.Scopes,
+		&o.ExpiresAt,
+		&o.LastUsedAt,
+		&o.RevokedAt,
+	}
+}
+
+func (o *dbAPIKey) toModel() (goappbuild.APIKey, error) {
+	ans := goappbuild.APIKey{
+		ID:         o.ID,
+		CreatedAt:  o.CreatedAt,
+		UpdatedAt:  o.UpdatedAt,
+		ProjectID:  o.ProjectID,
+		Name:       o.Name,
+		Prefix:     o.Prefix,
+		KeyHash:    o.KeyHash,
+		ExpiresAt:  nullTime(o.ExpiresAt),
+		LastUsedAt: nullTime(o.LastUsedAt),
+		RevokedAt:  nullTime(o.RevokedAt),
+	}
+
+	if err := json.Unmarshal(o.Scopes, &ans.Scopes); err != nil {
+		return goappbuild.APIKey{}, err
+	}
+
+	return ans, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
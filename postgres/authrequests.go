@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.AuthRequestRepo = (*authRequestRepo)(nil)
+
+type authRequestRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewAuthRequestRepo returns a new instance of a postgres auth request repository
+func NewAuthRequestRepo(conn sqlext.DBTX) goappbuild.AuthRequestRepo {
+	return &authRequestRepo{
+		conn: conn,
+	}
+}
+
+// Create stores a new auth request.
+// The expired requests are removed at the same time.
+func (o *authRequestRepo) Create(ctx context.Context, r *goappbuild.AuthRequest) error {
+	const cleanup = `DELETE FROM oidc_auth_requests WHERE expires_at < (NOW() at time zone 'utc')`
+
+	if _, err := o.conn.ExecContext(ctx, cleanup); err != nil {
+		return err
+	}
+
+	const q = `INSERT INTO oidc_auth_requests
+		(created_at, project_id, provider_id, state_hash, verifier, nonce, end_user_id, expires_at)
+		VALUES ((NOW() at time zone 'utc'), $1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, project_id, provider_id, state_hash, verifier, nonce, end_user_id, expires_at`
+
+	endUserID := uuid.NullUUID{UUID: r.EndUserID, Valid: r.EndUserID != uuid.Nil}
+
+	dbr, err := sqlext.QueryRow[dbAuthRequest](
+		ctx, o.conn, q,
+		r.ProjectID, r.ProviderID, r.StateHash, r.Verifier, r.Nonce, endUserID, r.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	*r = dbr.toModel()
+
+	return nil
+}
+
+// Take deletes and returns the auth request with the given state hash
+func (o *authRequestRepo) Take(ctx context.Context, stateHash string) (goappbuild.AuthRequest, error) {
+	const q = `DELETE FROM oidc_auth_requests
+		WHERE state_hash = $1
+		RETURNING id, created_at, project_id, provider_id, state_hash, verifier, nonce, end_user_id, expires_at`
+
+	dbr, err := sqlext.QueryRow[dbAuthRequest](ctx, o.conn, q, stateHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.AuthRequest{}, goappbuild.Errorf(goappbuild.ENotFound, "auth request not found")
+	}
+
+	if err != nil {
+		return goappbuild.AuthRequest{}, err
+	}
+
+	return dbr.toModel(), nil
+}
+
+type dbAuthRequest struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ProjectID  uuid.UUID
+	ProviderID uuid.UUID
+	StateHash  string
+	Verifier   string
+	Nonce      string
+	EndUserID  uuid.NullUUID
+	ExpiresAt  time.Time
+}
+
+func (o *dbAuthRequest) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.ProjectID,
+		&o.ProviderID,
+		&o.StateHash,
+		&o.Verifier,
+		&o.Nonce,
+		&o.EndUserID,
+		&o.ExpiresAt,
+	}
+}
+
+func (o *dbAuthRequest) toModel() goappbuild.AuthRequest {
+	return goappbuild.AuthRequest{
+		ID:         o.ID,
+		CreatedAt:  o.CreatedAt,
+		ProjectID:  o.ProjectID,
+		ProviderID: o.ProviderID,
+		StateHash:  o.StateHash,
+		Verifier:   o.Verifier,
+		Nonce:      o.Nonce,
+		EndUserID:  o.EndUserID.UUID,
+		ExpiresAt:  o.ExpiresAt,
+	}
+}
@@ -2,7 +2,9 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,10 +29,10 @@ func NewCollectionRepo(conn sqlext.DBTX) goappbuild.CollectionRepo {
 func (r *collectionRepo) Create(ctx context.Context, schema string, collection *goappbuild.Collection) error {
 	const (
 		q = `INSERT INTO collections
-	(created_at, updated_at, name, project_id, attributes)
+	(created_at, updated_at, name, project_id, attributes, options)
 	VALUES
-	((now() at time zone 'utc'), (now() at time zone 'utc'), $1, $2, $3)
-	RETURNING id, created_at, updated_at, name, project_id`
+	((now() at time zone 'utc'), (now() at time zone 'utc'), $1, $2, $3, $4)
+	RETURNING id, created_at, updated_at, name, project_id, attributes, options`
 	)
 
 	attributesJson, err := json.Marshal(collection.Attributes)
@@ -38,7 +40,15 @@ func (r *collectionRepo) Create(ctx context.Context, schema string, collection *
 		return err
 	}
 
-	dbCollection, err := sqlext.QueryRow[dbCollection](ctx, r.conn, q, collection.Name, collection.ProjectID, attributesJson)
+	optionsJson, err := json.Marshal(collection.Options)
+	if err != nil {
+		return err
+	}
+
+	dbCollection, err := sqlext.QueryRow[dbCollection](
+		ctx, r.conn, q,
+		collection.Name, collection.ProjectID, attributesJson, optionsJson,
+	)
 
 	if err != nil {
 		return err
@@ -51,12 +61,114 @@ func (r *collectionRepo) Create(ctx context.Context, schema string, collection *
 	return nil
 }
 
+// GetByName returns the collection of the project with the given name
+func (r *collectionRepo) GetByName(ctx context.Context, projectID uuid.UUID, name string) (goappbuild.Collection, error) {
+	const q = `SELECT
+			id, created_at, updated_at, name, project_id, attributes, options
+		FROM collections
+		WHERE project_id = $1 AND name = $2`
+
+	dbc, err := sqlext.QueryRow[dbCollection](ctx, r.conn, q, projectID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Collection{}, goappbuild.Errorf(goappbuild.ENotFound, "collection %s not found", name)
+	}
+
+	if err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	return dbc.toModel()
+}
+
+// Update stores the attributes and the options of the collection
+func (r *collectionRepo) Update(ctx context.Context, collection *goappbuild.Collection) error {
+	const q = `UPDATE collections
+		SET attributes = $1, options = $2, updated_at = (now() at time zone 'utc')
+		WHERE id = $3
+		RETURNING id, created_at, updated_at, name, project_id, attributes, options`
+
+	attributesJson, err := json.Marshal(collection.Attributes)
+	if err != nil {
+		return err
+	}
+
+	optionsJson, err := json.Marshal(collection.Options)
+	if err != nil {
+		return err
+	}
+
+	dbc, err := sqlext.QueryRow[dbCollection](ctx, r.conn, q, attributesJson, optionsJson, collection.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Errorf(goappbuild.ENotFound, "collection %s not found", collection.Name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	collection.UpdatedAt = dbc.UpdatedAt
+
+	return nil
+}
+
+// List returns the collections of the project
+func (r *collectionRepo) List(ctx context.Context, projectID uuid.UUID) ([]goappbuild.Collection, error) {
+	const q = `SELECT
+			id, created_at, updated_at, name, project_id, attributes, options
+		FROM collections
+		WHERE project_id = $1
+		ORDER BY name`
+
+	items, err := sqlext.Query[dbCollection](ctx, r.conn, q, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.Collection, len(items))
+
+	for i := range items {
+		ans[i], err = items[i].toModel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
+// ListWithRetention returns the soft delete collections that have a retention period
+func (r *collectionRepo) ListWithRetention(ctx context.Context) ([]goappbuild.Collection, error) {
+	const q = `SELECT
+			id, created_at, updated_at, name, project_id, attributes, options
+		FROM collections
+		WHERE (options->>'SoftDelete')::boolean
+		AND COALESCE((options->>'Retention')::bigint, 0) > 0`
+
+	items, err := sqlext.Query[dbCollection](ctx, r.conn, q)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.Collection, len(items))
+
+	for i := range items {
+		ans[i], err = items[i].toModel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
 type dbCollection struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	ProjectID uuid.UUID
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Name       string
+	ProjectID  uuid.UUID
+	Attributes []byte
+	Options    []byte
 }
 
 func (c *dbCollection) Bind() []any {
@@ -66,15 +178,27 @@ func (c *dbCollection) Bind() []any {
 		&c.UpdatedAt,
 		&c.Name,
 		&c.ProjectID,
+		&c.Attributes,
+		&c.Options,
 	}
 }
 
-func (c *dbCollection) toModel() goappbuild.Collection {
-	return goappbuild.Collection{
+func (c *dbCollection) toModel() (goappbuild.Collection, error) {
+	ans := goappbuild.Collection{
 		ID:        c.ID,
 		CreatedAt: c.CreatedAt,
 		UpdatedAt: c.UpdatedAt,
 		Name:      c.Name,
 		ProjectID: c.ProjectID,
 	}
+
+	if err := json.Unmarshal(c.Attributes, &ans.Attributes); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	if err := json.Unmarshal(c.Options, &ans.Options); err != nil {
+		return goappbuild.Collection{}, err
+	}
+
+	return ans, nil
 }
@@ -83,3 +83,42 @@ func (o *dbRepo) CreateColumns(ctx context.Context, schema, table string, attrib
 
 	return nil
 }
+
+// CreateHistory creates the history table and trigger of a collection table
+func (o *dbRepo) CreateHistory(ctx context.Context, schema string, collection goappbuild.Collection) error {
+	stmts := createHistoryStmts(createHistoryParams{
+		schema:  schema,
+		table:   collection.TableName(),
+		history: escape(collection.Name + goappbuild.HistorySuffix),
+	})
+
+	for i := range stmts {
+		if _, err := o.conn.ExecContext(ctx, stmts[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreatePolicies enables row level security on the table of the collection
+// and (re)creates the policies that enforce the rules of the collection
+func (o *dbRepo) CreatePolicies(ctx context.Context, schema string, collection goappbuild.Collection) error {
+	_, owner := collection.Attributes[goappbuild.OwnerColumn]
+
+	stmts := createPoliciesStmts(createPoliciesParams{
+		schema:     schema,
+		collection: collection.Name,
+		projectID:  collection.ProjectID,
+		rules:      collection.Options.Rules,
+		owner:      owner,
+	})
+
+	for i := range stmts {
+		if _, err := o.conn.ExecContext(ctx, stmts[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
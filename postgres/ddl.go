@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/gosom/goappbuild"
 )
 
@@ -30,6 +31,14 @@ func addAttributeStmt(params addAttributeParams) (string, error) {
 		return "", err
 	}
 
+	if params.attribute.AutoIncrement {
+		if params.attribute.Type != goappbuild.AttributeTypeInteger {
+			return "", fmt.Errorf("auto increment attribute %s must be an integer", params.attribute.Name)
+		}
+
+		typeQ = "BIGINT GENERATED BY DEFAULT AS IDENTITY"
+	}
+
 	var sb strings.Builder
 
 	sb.WriteString("ALTER TABLE ")
@@ -61,16 +70,8 @@ func addIndexesStmt(params addAttributeParams) string {
 		return ""
 	}
 
-	var sb strings.Builder
-
-	sb.WriteString("CREATE INDEX ")
-	sb.WriteString("idx_")
-	sb.WriteString(params.schema + "_" + params.table + "_" + params.attribute.Name)
-	sb.WriteString(" ON ")
-	sb.WriteString(`"` + params.schema + `"."` + params.table + `"`)
-	sb.WriteString(" (" + params.attribute.Name + ")")
-
-	return sb.String()
+	// the schema and the table are already quoted, so postgres names the index
+	return fmt.Sprintf(`CREATE INDEX ON %s.%s (%s)`, params.schema, params.table, escape(params.attribute.Name))
 }
 
 type attributeType struct {
@@ -103,3 +104,125 @@ func (t attributeType) postgresType() (string, error) {
 
 	return typeQ, nil
 }
+
+type createHistoryParams struct {
+	schema  string
+	table   string
+	history string
+}
+
+func createHistoryStmts(params createHistoryParams) []string {
+	schema, table, history := params.schema, params.table, params.history
+
+	return []string{
+		fmt.Sprintf(`CREATE TABLE %s.%s (
+			revision BIGSERIAL PRIMARY KEY,
+			document_id TEXT NOT NULL,
+			operation TEXT NOT NULL,
+			data JSONB NOT NULL,
+			changed_at TIMESTAMPTZ NOT NULL
+		)`, schema, history),
+		fmt.Sprintf(`CREATE INDEX ON %s.%s (document_id, changed_at)`, schema, history),
+		fmt.Sprintf(
+			`CREATE TRIGGER record_history AFTER INSERT OR UPDATE OR DELETE ON %s.%s
+			FOR EACH ROW EXECUTE FUNCTION public.goappbuild_record_history()`,
+			schema, table,
+		),
+	}
+}
+
+// policiesVersion marks the tables that have the policies of
+// createPoliciesStmts. Change it when the policies change, so that
+// SyncPolicies recreates the policies of the existing tables.
+const policiesVersion = "goappbuild policies v1"
+
+type createPoliciesParams struct {
+	schema     string
+	collection string
+	projectID  uuid.UUID
+	rules      goappbuild.CollectionRules
+	owner      bool
+}
+
+// createPoliciesStmts returns the statements that enable row level security
+// on a collection table and (re)create a policy per command. Members are
+// allowed according to their role, everybody else according to the rules.
+// Rows a caller owns, may update or may delete are also visible to it,
+// since the writes return the rows. Documents shared with a caller through
+// the ACL are readable or updatable according to the permission.
+func createPoliciesStmts(params createPoliciesParams) []string {
+	table := params.schema + "." + escape(params.collection)
+
+	granted := func(action goappbuild.Action) string {
+		permissions := make([]string, 0, len(action.Permissions()))
+		for _, p := range action.Permissions() {
+			permissions = append(permissions, "'"+string(p)+"'")
+		}
+
+		return fmt.Sprintf(
+			`public.goappbuild_is_granted('%s', '%s', "id"::text, ARRAY[%s])`,
+			params.projectID, strings.ReplaceAll(params.collection, "'", "''"), strings.Join(permissions, ", "),
+		)
+	}
+
+	member := func(role goappbuild.Role) string {
+		return fmt.Sprintf("public.goappbuild_is_member('%s', '%s')", params.projectID, role)
+	}
+
+	allowed := func(action goappbuild.Action) string {
+		return member(action.Role()) + " OR " + ruleExpr(params.rules.Rule(action), params.projectID)
+	}
+
+	read := []string{
+		allowed(goappbuild.ActionRead),
+		ruleExpr(params.rules.Rule(goappbuild.ActionUpdate), params.projectID),
+		ruleExpr(params.rules.Rule(goappbuild.ActionDelete), params.projectID),
+		granted(goappbuild.ActionRead),
+	}
+
+	update := allowed(goappbuild.ActionUpdate) + " OR " + granted(goappbuild.ActionUpdate)
+
+	if params.owner {
+		read = append(read, ruleExpr(goappbuild.RuleOwner, params.projectID))
+	}
+
+	return []string{
+		fmt.Sprintf(`ALTER TABLE %s ENABLE ROW LEVEL SECURITY`, table),
+		// the tables belong to the role of the application,
+		// without FORCE the policies would not apply to it
+		fmt.Sprintf(`ALTER TABLE %s FORCE ROW LEVEL SECURITY`, table),
+		fmt.Sprintf(`DROP POLICY IF EXISTS goappbuild_select ON %s`, table),
+		fmt.Sprintf(`DROP POLICY IF EXISTS goappbuild_insert ON %s`, table),
+		fmt.Sprintf(`DROP POLICY IF EXISTS goappbuild_update ON %s`, table),
+		fmt.Sprintf(`DROP POLICY IF EXISTS goappbuild_delete ON %s`, table),
+		fmt.Sprintf(`CREATE POLICY goappbuild_select ON %s FOR SELECT USING (%s)`, table, strings.Join(read, " OR ")),
+		fmt.Sprintf(
+			`CREATE POLICY goappbuild_insert ON %s FOR INSERT WITH CHECK (%s)`,
+			table, allowed(goappbuild.ActionCreate),
+		),
+		fmt.Sprintf(
+			`CREATE POLICY goappbuild_update ON %s FOR UPDATE USING (%s) WITH CHECK (%s)`,
+			table, update, update,
+		),
+		fmt.Sprintf(
+			`CREATE POLICY goappbuild_delete ON %s FOR DELETE USING (%s)`,
+			table, allowed(goappbuild.ActionDelete),
+		),
+		fmt.Sprintf(`COMMENT ON POLICY goappbuild_select ON %s IS '%s'`, table, policiesVersion),
+	}
+}
+
+// ruleExpr returns the condition that allows the callers of the rule.
+// End users are only authenticated in their project.
+func ruleExpr(rule goappbuild.Rule, projectID uuid.UUID) string {
+	switch rule {
+	case goappbuild.RulePublic:
+		return "true"
+	case goappbuild.RuleAuthenticated:
+		return fmt.Sprintf("public.goappbuild_is_authenticated('%s')", projectID)
+	case goappbuild.RuleOwner:
+		return escape(goappbuild.OwnerColumn) + " = public.goappbuild_user_id()"
+	default:
+		return "false"
+	}
+}
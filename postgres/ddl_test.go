@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+)
+
+func Test_createPoliciesStmts(t *testing.T) {
+	projectID := uuid.MustParse("6b1d7e3c-60b3-4c5e-9b0c-1c1e5c7c8f10")
+
+	member := func(role goappbuild.Role) string {
+		return "public.goappbuild_is_member('" + projectID.String() + "', '" + string(role) + "')"
+	}
+
+	const (
+		authenticated = `public.goappbuild_is_authenticated('6b1d7e3c-60b3-4c5e-9b0c-1c1e5c7c8f10')`
+		owner         = `"owner_id" = public.goappbuild_user_id()`
+		readGranted   = `public.goappbuild_is_granted('6b1d7e3c-60b3-4c5e-9b0c-1c1e5c7c8f10', 'it''s', "id"::text, ARRAY['read', 'write'])`
+		writeGranted  = `public.goappbuild_is_granted('6b1d7e3c-60b3-4c5e-9b0c-1c1e5c7c8f10', 'it''s', "id"::text, ARRAY['write'])`
+	)
+
+	testCases := []struct {
+		name    string
+		rules   goappbuild.CollectionRules
+		owner   bool
+		select_ string
+		insert  string
+		update  string
+		delete  string
+	}{
+		{
+			name:    "members only by default",
+			select_: member(goappbuild.RoleViewer) + " OR false OR false OR false OR " + readGranted,
+			insert:  member(goappbuild.RoleDeveloper) + " OR false",
+			update:  member(goappbuild.RoleDeveloper) + " OR false OR " + writeGranted,
+			delete:  member(goappbuild.RoleDeveloper) + " OR false",
+		},
+		{
+			name: "public",
+			rules: goappbuild.CollectionRules{
+				Read:   goappbuild.RulePublic,
+				Create: goappbuild.RulePublic,
+				Update: goappbuild.RulePublic,
+				Delete: goappbuild.RulePublic,
+			},
+			select_: member(goappbuild.RoleViewer) + " OR true OR true OR true OR " + readGranted,
+			insert:  member(goappbuild.RoleDeveloper) + " OR true",
+			update:  member(goappbuild.RoleDeveloper) + " OR true OR " + writeGranted,
+			delete:  member(goappbuild.RoleDeveloper) + " OR true",
+		},
+		{
+			name: "authenticated",
+			rules: goappbuild.CollectionRules{
+				Read:   goappbuild.RuleAuthenticated,
+				Create: goappbuild.RuleAuthenticated,
+			},
+			select_: member(goappbuild.RoleViewer) + " OR " + authenticated + " OR false OR false OR " + readGranted,
+			insert:  member(goappbuild.RoleDeveloper) + " OR " + authenticated,
+			update:  member(goappbuild.RoleDeveloper) + " OR false OR " + writeGranted,
+			delete:  member(goappbuild.RoleDeveloper) + " OR false",
+		},
+		{
+			name: "owner",
+			rules: goappbuild.CollectionRules{
+				Read:   goappbuild.RuleOwner,
+				Create: goappbuild.RuleAuthenticated,
+				Update: goappbuild.RuleOwner,
+				Delete: goappbuild.RuleOwner,
+			},
+			owner:   true,
+			select_: member(goappbuild.RoleViewer) + " OR " + owner + " OR " + owner + " OR " + owner + " OR " + readGranted + " OR " + owner,
+			insert:  member(goappbuild.RoleDeveloper) + " OR " + authenticated,
+			update:  member(goappbuild.RoleDeveloper) + " OR " + owner + " OR " + writeGranted,
+			delete:  member(goappbuild.RoleDeveloper) + " OR " + owner,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			stmts := createPoliciesStmts(createPoliciesParams{
+				schema:     `"app"`,
+				collection: "it's",
+				projectID:  projectID,
+				rules:      tc.rules,
+				owner:      tc.owner,
+			})
+
+			const table = `"app"."it's"`
+
+			expected := []string{
+				`ALTER TABLE ` + table + ` ENABLE ROW LEVEL SECURITY`,
+				`ALTER TABLE ` + table + ` FORCE ROW LEVEL SECURITY`,
+				`DROP POLICY IF EXISTS goappbuild_select ON ` + table,
+				`DROP POLICY IF EXISTS goappbuild_insert ON ` + table,
+				`DROP POLICY IF EXISTS goappbuild_update ON ` + table,
+				`DROP POLICY IF EXISTS goappbuild_delete ON ` + table,
+				`CREATE POLICY goappbuild_select ON ` + table + ` FOR SELECT USING (` + tc.select_ + `)`,
+				`CREATE POLICY goappbuild_insert ON ` + table + ` FOR INSERT WITH CHECK (` + tc.insert + `)`,
+				`CREATE POLICY goappbuild_update ON ` + table + ` FOR UPDATE USING (` + tc.update + `) WITH CHECK (` + tc.update + `)`,
+				`CREATE POLICY goappbuild_delete ON ` + table + ` FOR DELETE USING (` + tc.delete + `)`,
+				`COMMENT ON POLICY goappbuild_select ON ` + table + ` IS '` + policiesVersion + `'`,
+			}
+
+			require.Equal(t, expected, stmts)
+		})
+	}
+}
+
+func Test_ruleExpr(t *testing.T) {
+	projectID := uuid.New()
+
+	require.Equal(t, "true", ruleExpr(goappbuild.RulePublic, projectID))
+	require.Equal(t, "false", ruleExpr(goappbuild.RuleMembers, projectID))
+	require.Equal(t, `"owner_id" = public.goappbuild_user_id()`, ruleExpr(goappbuild.RuleOwner, projectID))
+	require.True(t, strings.Contains(ruleExpr(goappbuild.RuleAuthenticated, projectID), projectID.String()))
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.EndUserRepo = (*endUserRepo)(nil)
+
+type endUserRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewEndUserRepo returns a new instance of a postgres end user repository
+func NewEndUserRepo(conn sqlext.DBTX) goappbuild.EndUserRepo {
+	return &endUserRepo{
+		conn: conn,
+	}
+}
+
+// Create creates a new end user of a project
+func (o *endUserRepo) Create(ctx context.Context, u *goappbuild.EndUser) error {
+	metadata, err := marshalMetadata(u.Metadata)
+	if err != nil {
+		return err
+	}
+
+	// anonymous end users have no email
+	const q = `INSERT INTO end_users
+		(created_at, updated_at, project_id, email, password_hash, name, metadata, anonymous)
+		VALUES ((NOW() at time zone 'utc'), (NOW() at time zone 'utc'), $1, NULLIF($2, ''), $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at, project_id, COALESCE(email, ''), password_hash, name, metadata, anonymous`
+
+	dbu, err := sqlext.QueryRow[dbEndUser](
+		ctx, o.conn, q,
+		u.ProjectID, u.Email, u.PasswordHash, u.Name, metadata, u.Anonymous,
+	)
+	if isUniqueViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "email is already registered")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return dbu.toModel(u)
+}
+
+// Get returns the end user of the project with the given id
+func (o *endUserRepo) Get(ctx context.Context, projectID, id uuid.UUID) (goappbuild.EndUser, error) {
+	const q = `SELECT
+			id, created_at, updated_at, project_id, COALESCE(email, ''), password_hash, name, metadata, anonymous
+		FROM end_users
+		WHERE project_id = $1 AND id = $2`
+
+	return o.get(ctx, q, projectID, id)
+}
+
+// GetByEmail returns the end user of the project with the given email
+func (o *endUserRepo) GetByEmail(ctx context.Context, projectID uuid.UUID, email string) (goappbuild.EndUser, error) {
+	const q = `SELECT
+			id, created_at, updated_at, project_id, COALESCE(email, ''), password_hash, name, metadata, anonymous
+		FROM end_users
+		WHERE project_id = $1 AND email = $2`
+
+	return o.get(ctx, q, projectID, email)
+}
+
+// Update stores the profile and the credentials of the end user
+func (o *endUserRepo) Update(ctx context.Context, u *goappbuild.EndUser) error {
+	metadata, err := marshalMetadata(u.Metadata)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE end_users
+		SET updated_at = (NOW() at time zone 'utc'), name = $3, metadata = $4,
+			email = NULLIF($5, ''), password_hash = $6, anonymous = $7
+		WHERE project_id = $1 AND id = $2
+		RETURNING id, created_at, updated_at, project_id, COALESCE(email, ''), password_hash, name, metadata, anonymous`
+
+	dbu, err := sqlext.QueryRow[dbEndUser](
+		ctx, o.conn, q,
+		u.ProjectID, u.ID, u.Name, metadata, u.Email, u.PasswordHash, u.Anonymous,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Errorf(goappbuild.ENotFound, "end user not found")
+	}
+
+	if isUniqueViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "email is already registered")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return dbu.toModel(u)
+}
+
+// Delete deletes the end user of the project, the sessions are deleted too
+func (o *endUserRepo) Delete(ctx context.Context, projectID, id uuid.UUID) error {
+	const q = `DELETE FROM end_users WHERE project_id = $1 AND id = $2`
+
+	res, err := o.conn.ExecContext(ctx, q, projectID, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "end user not found")
+	}
+
+	return nil
+}
+
+func (o *endUserRepo) get(ctx context.Context, q string, args ...any) (goappbuild.EndUser, error) {
+	dbu, err := sqlext.QueryRow[dbEndUser](ctx, o.conn, q, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.EndUser{}, goappbuild.Errorf(goappbuild.ENotFound, "end user not found")
+	}
+
+	if err != nil {
+		return goappbuild.EndUser{}, err
+	}
+
+	var ans goappbuild.EndUser
+	if err := dbu.toModel(&ans); err != nil {
+		return goappbuild.EndUser{}, err
+	}
+
+	return ans, nil
+}
+
+func marshalMetadata(metadata map[string]any) ([]byte, error) {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+
+	return json.Marshal(metadata)
+}
+
+type dbEndUser struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ProjectID    uuid.UUID
+	Email        string
+	PasswordHash string
+	Name         string
+	Metadata     []byte
+	Anonymous    bool
+}
+
+func (o *dbEndUser) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.ProjectID,
+		&o.Email,
+		&o.PasswordHash,
+		&o.Name,
+		&o.Metadata,
+		&o.Anonymous,
+	}
+}
+
+func (o *dbEndUser) toModel(u *goappbuild.EndUser) error {
+	*u = goappbuild.EndUser{
+		ID:           o.ID,
+		ProjectID:    o.ProjectID,
+		Email:        o.Email,
+		PasswordHash: o.PasswordHash,
+		Name:         o.Name,
+		Anonymous:    o.Anonymous,
+		CreatedAt:    o.CreatedAt,
+		UpdatedAt:    o.UpdatedAt,
+	}
+
+	return json.Unmarshal(o.Metadata, &u.Metadata)
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.ExternalIdentityRepo = (*externalIdentityRepo)(nil)
+
+type externalIdentityRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewExternalIdentityRepo returns a new instance of a postgres external identity repository
+func NewExternalIdentityRepo(conn sqlext.DBTX) goappbuild.ExternalIdentityRepo {
+	return &externalIdentityRepo{
+		conn: conn,
+	}
+}
+
+// Create links an account of an identity provider to an end user
+func (o *externalIdentityRepo) Create(ctx context.Context, e *goappbuild.ExternalIdentity) error {
+	const q = `INSERT INTO external_identities
+		(created_at, project_id, provider_id, subject, end_user_id, email)
+		VALUES ((NOW() at time zone 'utc'), $1, $2, $3, $4, $5)
+		RETURNING id, created_at, project_id, provider_id, subject, end_user_id, email`
+
+	dbe, err := sqlext.QueryRow[dbExternalIdentity](
+		ctx, o.conn, q,
+		e.ProjectID, e.ProviderID, e.Subject, e.EndUserID, e.Email,
+	)
+	if isUniqueViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "the account is already linked")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*e = dbe.toModel()
+
+	return nil
+}
+
+// Get returns the external identity of the provider with the given subject
+func (o *externalIdentityRepo) Get(
+	ctx context.Context,
+	providerID uuid.UUID,
+	subject string,
+) (goappbuild.ExternalIdentity, error) {
+	const q = `SELECT
+			id, created_at, project_id, provider_id, subject, end_user_id, email
+		FROM external_identities
+		WHERE provider_id = $1 AND subject = $2`
+
+	dbe, err := sqlext.QueryRow[dbExternalIdentity](ctx, o.conn, q, providerID, subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.ExternalIdentity{}, goappbuild.Errorf(goappbuild.ENotFound, "external identity not found")
+	}
+
+	if err != nil {
+		return goappbuild.ExternalIdentity{}, err
+	}
+
+	return dbe.toModel(), nil
+}
+
+type dbExternalIdentity struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ProjectID  uuid.UUID
+	ProviderID uuid.UUID
+	Subject    string
+	EndUserID  uuid.UUID
+	Email      string
+}
+
+func (o *dbExternalIdentity) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.ProjectID,
+		&o.ProviderID,
+		&o.Subject,
+		&o.EndUserID,
+		&o.Email,
+	}
+}
+
+func (o *dbExternalIdentity) toModel() goappbuild.ExternalIdentity {
+	return goappbuild.ExternalIdentity{
+		ID:         o.ID,
+		CreatedAt:  o.CreatedAt,
+		ProjectID:  o.ProjectID,
+		ProviderID: o.ProviderID,
+		Subject:    o.Subject,
+		EndUserID:  o.EndUserID,
+		Email:      o.Email,
+	}
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.HistoryRepo = (*historyRepo)(nil)
+
+type historyRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewHistoryRepo returns a new instance of a postgres history repository
+func NewHistoryRepo(conn sqlext.DBTX) goappbuild.HistoryRepo {
+	return &historyRepo{
+		conn: conn,
+	}
+}
+
+// List returns the revisions of a document, newest first
+func (o *historyRepo) List(ctx context.Context, schema, table, id string) ([]goappbuild.Revision, error) {
+	q := `SELECT revision, document_id, operation, data, changed_at
+		FROM ` + historyTable(schema, table) + `
+		WHERE document_id = $1
+		ORDER BY revision DESC`
+
+	items, err := sqlext.Query[dbRevision](ctx, o.conn, q, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.Revision, len(items))
+
+	for i := range items {
+		ans[i], err = items[i].toModel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
+// Get returns a single revision of a document
+func (o *historyRepo) Get(ctx context.Context, schema, table, id string, revision int64) (goappbuild.Revision, error) {
+	q := `SELECT revision, document_id, operation, data, changed_at
+		FROM ` + historyTable(schema, table) + `
+		WHERE document_id = $1 AND revision = $2`
+
+	item, err := sqlext.QueryRow[dbRevision](ctx, o.conn, q, id, revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Revision{}, goappbuild.Errorf(goappbuild.ENotFound, "revision %d not found", revision)
+	}
+
+	if err != nil {
+		return goappbuild.Revision{}, err
+	}
+
+	return item.toModel()
+}
+
+// AsOf returns the latest revision of a document at the given time
+func (o *historyRepo) AsOf(ctx context.Context, schema, table, id string, at time.Time) (goappbuild.Revision, error) {
+	q := `SELECT revision, document_id, operation, data, changed_at
+		FROM ` + historyTable(schema, table) + `
+		WHERE document_id = $1 AND changed_at <= $2
+		ORDER BY revision DESC
+		LIMIT 1`
+
+	item, err := sqlext.QueryRow[dbRevision](ctx, o.conn, q, id, at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Revision{}, goappbuild.Errorf(goappbuild.ENotFound, "no revision of %s at %s", id, at)
+	}
+
+	if err != nil {
+		return goappbuild.Revision{}, err
+	}
+
+	return item.toModel()
+}
+
+func historyTable(schema, table string) string {
+	return escape(schema) + "." + escape(table+goappbuild.HistorySuffix)
+}
+
+type dbRevision struct {
+	Revision   int64
+	DocumentID string
+	Operation  string
+	Data       []byte
+	ChangedAt  time.Time
+}
+
+func (o *dbRevision) Bind() []any {
+	return []any{
+		&o.Revision,
+		&o.DocumentID,
+		&o.Operation,
+		&o.Data,
+		&o.ChangedAt,
+	}
+}
+
+func (o *dbRevision) toModel() (goappbuild.Revision, error) {
+	ans := goappbuild.Revision{
+		Revision:   o.Revision,
+		DocumentID: o.DocumentID,
+		Operation:  o.Operation,
+		ChangedAt:  o.ChangedAt,
+	}
+
+	if err := json.Unmarshal(o.Data, &ans.Data); err != nil {
+		return goappbuild.Revision{}, err
+	}
+
+	return ans, nil
+}
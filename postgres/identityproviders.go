@@ -0,0 +1,184 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.IdentityProviderRepo = (*identityProviderRepo)(nil)
+
+const identityProviderColumns = `id, created_at, updated_at, project_id, name, issuer,
+	client_id, client_secret, redirect_url, scopes`
+
+type identityProviderRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewIdentityProviderRepo returns a new instance of a postgres identity provider repository
+func NewIdentityProviderRepo(conn sqlext.DBTX) goappbuild.IdentityProviderRepo {
+	return &identityProviderRepo{
+		conn: conn,
+	}
+}
+
+// Create stores a new identity provider
+func (o *identityProviderRepo) Create(ctx context.Context, p *goappbuild.IdentityProvider) error {
+	scopes := p.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	scopesJson, err := json.Marshal(scopes)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO identity_providers
+		(created_at, updated_at, project_id, name, issuer, client_id, client_secret, redirect_url, scopes)
+		VALUES ((NOW() at time zone 'utc'), (NOW() at time zone 'utc'), $1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + identityProviderColumns
+
+	dbp, err := sqlext.QueryRow[dbIdentityProvider](
+		ctx, o.conn, q,
+		p.ProjectID, p.Name, p.Issuer, p.ClientID, p.ClientSecret, p.RedirectURL, scopesJson,
+	)
+	if isUniqueViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "identity provider %s already exists", p.Name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ans, err := dbp.toModel()
+	if err != nil {
+		return err
+	}
+
+	*p = ans
+
+	return nil
+}
+
+// GetByName returns the identity provider of the project with the given name
+func (o *identityProviderRepo) GetByName(
+	ctx context.Context,
+	projectID uuid.UUID,
+	name string,
+) (goappbuild.IdentityProvider, error) {
+	q := `SELECT ` + identityProviderColumns + `
+		FROM identity_providers
+		WHERE project_id = $1 AND name = $2`
+
+	dbp, err := sqlext.QueryRow[dbIdentityProvider](ctx, o.conn, q, projectID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.IdentityProvider{}, goappbuild.Errorf(goappbuild.ENotFound, "identity provider not found")
+	}
+
+	if err != nil {
+		return goappbuild.IdentityProvider{}, err
+	}
+
+	return dbp.toModel()
+}
+
+// List returns the identity providers of a project by name
+func (o *identityProviderRepo) List(ctx context.Context, projectID uuid.UUID) ([]goappbuild.IdentityProvider, error) {
+	q := `SELECT ` + identityProviderColumns + `
+		FROM identity_providers
+		WHERE project_id = $1
+		ORDER BY name`
+
+	items, err := sqlext.Query[dbIdentityProvider](ctx, o.conn, q, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.IdentityProvider, len(items))
+
+	for i := range items {
+		ans[i], err = items[i].toModel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
+// Delete deletes the identity provider of the project with the given name
+func (o *identityProviderRepo) Delete(ctx context.Context, projectID uuid.UUID, name string) error {
+	const q = `DELETE FROM identity_providers WHERE project_id = $1 AND name = $2`
+
+	res, err := o.conn.ExecContext(ctx, q, projectID, name)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "identity provider not found")
+	}
+
+	return nil
+}
+
+type dbIdentityProvider struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ProjectID    uuid.UUID
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []byte
+}
+
+func (o *dbIdentityProvider) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.ProjectID,
+		&o.Name,
+		&o.Issuer,
+		&o.ClientID,
+		&o.ClientSecret,
+		&o.RedirectURL,
+		&o.Scopes,
+	}
+}
+
+func (o *dbIdentityProvider) toModel() (goappbuild.IdentityProvider, error) {
+	ans := goappbuild.IdentityProvider{
+		ID:           o.ID,
+		CreatedAt:    o.CreatedAt,
+		UpdatedAt:    o.UpdatedAt,
+		ProjectID:    o.ProjectID,
+		Name:         o.Name,
+		Issuer:       o.Issuer,
+		ClientID:     o.ClientID,
+		ClientSecret: o.ClientSecret,
+		RedirectURL:  o.RedirectURL,
+	}
+
+	if err := json.Unmarshal(o.Scopes, &ans.Scopes); err != nil {
+		return goappbuild.IdentityProvider{}, err
+	}
+
+	return ans, nil
+}
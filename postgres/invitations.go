@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.InvitationRepo = (*invitationRepo)(nil)
+
+const invitationColumns = `id, created_at, updated_at, project_id, email, role,
+	token_hash, invited_by, status, expires_at`
+
+type invitationRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewInvitationRepo returns a new instance of a postgres invitation repository
+func NewInvitationRepo(conn sqlext.DBTX) goappbuild.InvitationRepo {
+	return &invitationRepo{
+		conn: conn,
+	}
+}
+
+// Create stores a new invitation
+func (o *invitationRepo) Create(ctx context.Context, inv *goappbuild.Invitation) error {
+	q := `INSERT INTO project_invitations
+		(created_at, updated_at, project_id, email, role, token_hash, invited_by, status, expires_at)
+		VALUES ((NOW() at time zone 'utc'), (NOW() at time zone 'utc'), $1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + invitationColumns
+
+	dbi, err := sqlext.QueryRow[dbInvitation](
+		ctx, o.conn, q,
+		inv.ProjectID, inv.Email, inv.Role, inv.TokenHash, inv.InvitedBy, inv.Status, inv.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	*inv = dbi.toModel()
+
+	return nil
+}
+
+// GetByHash returns the invitation with the given token hash
+func (o *invitationRepo) GetByHash(ctx context.Context, hash string) (goappbuild.Invitation, error) {
+	q := `SELECT ` + invitationColumns + `
+		FROM project_invitations
+		WHERE token_hash = $1
+		FOR UPDATE`
+
+	dbi, err := sqlext.QueryRow[dbInvitation](ctx, o.conn, q, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Invitation{}, goappbuild.Errorf(goappbuild.ENotFound, "invitation not found")
+	}
+
+	if err != nil {
+		return goappbuild.Invitation{}, err
+	}
+
+	return dbi.toModel(), nil
+}
+
+// UpdateStatus changes the status of an invitation
+func (o *invitationRepo) UpdateStatus(ctx context.Context, id uuid.UUID, status goappbuild.InvitationStatus) error {
+	const q = `UPDATE project_invitations
+		SET status = $1, updated_at = (NOW() at time zone 'utc')
+		WHERE id = $2`
+
+	_, err := o.conn.ExecContext(ctx, q, status, id)
+
+	return err
+}
+
+type dbInvitation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ProjectID uuid.UUID
+	Email     string
+	Role      string
+	TokenHash string
+	InvitedBy uuid.UUID
+	Status    string
+	ExpiresAt time.Time
+}
+
+func (o *dbInvitation) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.ProjectID,
+		&o.Email,
+		&o.Role,
+		&o.TokenHash,
+		&o.InvitedBy,
+		&o.Status,
+		&o.ExpiresAt,
+	}
+}
+
+func (o *dbInvitation) toModel() goappbuild.Invitation {
+	return goappbuild.Invitation{
+		ID:        o.ID,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+		ProjectID: o.ProjectID,
+		Email:     o.Email,
+		Role:      goappbuild.Role(o.Role),
+		TokenHash: o.TokenHash,
+		InvitedBy: o.InvitedBy,
+		Status:    goappbuild.InvitationStatus(o.Status),
+		ExpiresAt: o.ExpiresAt,
+	}
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.LoginCounterRepo = (*loginCounterRepo)(nil)
+
+type loginCounterRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewLoginCounterRepo returns a new instance of a postgres login counter repository
+func NewLoginCounterRepo(conn sqlext.DBTX) goappbuild.LoginCounterRepo {
+	return &loginCounterRepo{
+		conn: conn,
+	}
+}
+
+// Get returns the counter of the key
+func (o *loginCounterRepo) Get(ctx context.Context, key string) (goappbuild.LoginCounter, error) {
+	const q = `SELECT key, failures, last_failure_at, locked_until
+		FROM login_counters
+		WHERE key = $1`
+
+	dbc, err := sqlext.QueryRow[dbLoginCounter](ctx, o.conn, q, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.LoginCounter{}, goappbuild.Errorf(goappbuild.ENotFound, "login counter not found")
+	}
+
+	if err != nil {
+		return goappbuild.LoginCounter{}, err
+	}
+
+	return dbc.toModel(), nil
+}
+
+// Fail increments the failures of the key in a single statement, so that
+// concurrent attempts are all counted
+func (o *loginCounterRepo) Fail(ctx context.Context, key string, since time.Time) (goappbuild.LoginCounter, error) {
+	const q = `INSERT INTO login_counters AS c (key, failures, last_failure_at)
+		VALUES ($1, 1, (NOW() at time zone 'utc'))
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN c.last_failure_at < $2 THEN 1 ELSE c.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING key, failures, last_failure_at, locked_until`
+
+	dbc, err := sqlext.QueryRow[dbLoginCounter](ctx, o.conn, q, key, since)
+	if err != nil {
+		return goappbuild.LoginCounter{}, err
+	}
+
+	return dbc.toModel(), nil
+}
+
+// Lock locks out the key until the given time
+func (o *loginCounterRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	const q = `UPDATE login_counters SET locked_until = $2 WHERE key = $1`
+
+	res, err := o.conn.ExecContext(ctx, q, key, until)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "login counter not found")
+	}
+
+	return nil
+}
+
+// Reset removes the counter of the key
+func (o *loginCounterRepo) Reset(ctx context.Context, key string) error {
+	const q = `DELETE FROM login_counters WHERE key = $1`
+
+	_, err := o.conn.ExecContext(ctx, q, key)
+
+	return err
+}
+
+type dbLoginCounter struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+func (o *dbLoginCounter) Bind() []any {
+	return []any{
+		&o.Key,
+		&o.Failures,
+		&o.LastFailureAt,
+		&o.LockedUntil,
+	}
+}
+
+func (o *dbLoginCounter) toModel() goappbuild.LoginCounter {
+	return goappbuild.LoginCounter{
+		Key:           o.Key,
+		Failures:      o.Failures,
+		LastFailureAt: o.LastFailureAt,
+		LockedUntil:   nullTime(o.LockedUntil),
+	}
+}
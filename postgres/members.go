@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.MemberRepo = (*memberRepo)(nil)
+
+type memberRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewMemberRepo returns a new instance of a postgres project member repository
+func NewMemberRepo(conn sqlext.DBTX) goappbuild.MemberRepo {
+	return &memberRepo{
+		conn: conn,
+	}
+}
+
+// Create adds a member to a project
+func (o *memberRepo) Create(ctx context.Context, m *goappbuild.ProjectMember) error {
+	const q = `INSERT INTO project_members
+		(project_id, user_id, role, created_at, updated_at)
+		VALUES ($1, $2, $3, (NOW() at time zone 'utc'), (NOW() at time zone 'utc'))
+		RETURNING project_id, user_id, '', role, created_at, updated_at`
+
+	dbm, err := sqlext.QueryRow[dbMember](ctx, o.conn, q, m.ProjectID, m.UserID, m.Role)
+	if isUniqueViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "user is already a member of the project")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	m.CreatedAt = dbm.CreatedAt
+	m.UpdatedAt = dbm.UpdatedAt
+
+	return nil
+}
+
+// Get returns the membership of a user in a project
+func (o *memberRepo) Get(ctx context.Context, projectID, userID uuid.UUID) (goappbuild.ProjectMember, error) {
+	const q = `SELECT
+			m.project_id, m.user_id, COALESCE(u.email, ''), m.role, m.created_at, m.updated_at
+		FROM project_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.project_id = $1 AND m.user_id = $2`
+
+	dbm, err := sqlext.QueryRow[dbMember](ctx, o.conn, q, projectID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.ProjectMember{}, goappbuild.Errorf(goappbuild.ENotFound, "member not found")
+	}
+
+	if err != nil {
+		return goappbuild.ProjectMember{}, err
+	}
+
+	return dbm.toModel(), nil
+}
+
+// List returns the members of a project
+func (o *memberRepo) List(ctx context.Context, projectID uuid.UUID) ([]goappbuild.ProjectMember, error) {
+	const q = `SELECT
+			m.project_id, m.user_id, COALESCE(u.email, ''), m.role, m.created_at, m.updated_at
+		FROM project_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.project_id = $1
+		ORDER BY m.created_at`
+
+	items, err := sqlext.Query[dbMember](ctx, o.conn, q, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.ProjectMember, len(items))
+	for i := range items {
+		ans[i] = items[i].toModel()
+	}
+
+	return ans, nil
+}
+
+// ListByUser returns the memberships of a user with the names of the projects
+func (o *memberRepo) ListByUser(ctx context.Context, userID uuid.UUID) ([]goappbuild.ProjectMember, error) {
+	const q = `SELECT
+			m.project_id, m.user_id, COALESCE(u.email, ''), m.role, m.created_at, m.updated_at, p.name
+		FROM project_members m
+		JOIN users u ON u.id = m.user_id
+		JOIN projects p ON p.id = m.project_id
+		WHERE m.user_id = $1
+		ORDER BY p.name`
+
+	items, err := sqlext.Query[dbUserMembership](ctx, o.conn, q, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.ProjectMember, len(items))
+	for i := range items {
+		ans[i] = items[i].toModel()
+		ans[i].ProjectName = items[i].ProjectName
+	}
+
+	return ans, nil
+}
+
+// UpdateRole changes the role of a member
+func (o *memberRepo) UpdateRole(ctx context.Context, projectID, userID uuid.UUID, role goappbuild.Role) error {
+	const q = `UPDATE project_members
+		SET role = $1, updated_at = (NOW() at time zone 'utc')
+		WHERE project_id = $2 AND user_id = $3`
+
+	return o.exec(ctx, q, role, projectID, userID)
+}
+
+// Delete removes a member from a project
+func (o *memberRepo) Delete(ctx context.Context, projectID, userID uuid.UUID) error {
+	const q = `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2`
+
+	return o.exec(ctx, q, projectID, userID)
+}
+
+// CountRole returns the number of members of the project with the role
+func (o *memberRepo) CountRole(ctx context.Context, projectID uuid.UUID, role goappbuild.Role) (int, error) {
+	const q = `SELECT COUNT(*) FROM project_members WHERE project_id = $1 AND role = $2`
+
+	var ans int
+	if err := o.conn.QueryRowContext(ctx, q, projectID, role).Scan(&ans); err != nil {
+		return 0, err
+	}
+
+	return ans, nil
+}
+
+func (o *memberRepo) exec(ctx context.Context, q string, args ...any) error {
+	res, err := o.conn.ExecContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "member not found")
+	}
+
+	return nil
+}
+
+type dbMember struct {
+	ProjectID uuid.UUID
+	UserID    uuid.UUID
+	Email     string
+	Role      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (o *dbMember) Bind() []any {
+	return []any{
+		&o.ProjectID,
+		&o.UserID,
+		&o.Email,
+		&o.Role,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+	}
+}
+
+func (o *dbMember) toModel() goappbuild.ProjectMember {
+	return goappbuild.ProjectMember{
+		ProjectID: o.ProjectID,
+		UserID:    o.UserID,
+		Email:     o.Email,
+		Role:      goappbuild.Role(o.Role),
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+}
+
+// dbUserMembership is a membership with the name of the project
+type dbUserMembership struct {
+	dbMember
+	ProjectName string
+}
+
+func (o *dbUserMembership) Bind() []any {
+	return append(o.dbMember.Bind(), &o.ProjectName)
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.MFARepo = (*mfaRepo)(nil)
+
+type mfaRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewMFARepo returns a new instance of a postgres second factor repository
+func NewMFARepo(conn sqlext.DBTX) goappbuild.MFARepo {
+	return &mfaRepo{
+		conn: conn,
+	}
+}
+
+// GetFactor returns the TOTP factor of the user
+func (o *mfaRepo) GetFactor(ctx context.Context, userID uuid.UUID) (goappbuild.TOTPFactor, error) {
+	const q = `SELECT
+			user_id, created_at, updated_at, secret, last_step, confirmed_at
+		FROM totp_factors
+		WHERE user_id = $1`
+
+	dbf, err := sqlext.QueryRow[dbTOTPFactor](ctx, o.conn, q, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.TOTPFactor{}, goappbuild.Errorf(goappbuild.ENotFound, "mfa is not enabled")
+	}
+
+	if err != nil {
+		return goappbuild.TOTPFactor{}, err
+	}
+
+	return dbf.toModel(), nil
+}
+
+// SaveFactor creates or replaces the TOTP factor of the user
+func (o *mfaRepo) SaveFactor(ctx context.Context, f *goappbuild.TOTPFactor) error {
+	const q = `INSERT INTO totp_factors
+		(user_id, created_at, updated_at, secret, last_step, confirmed_at)
+		VALUES ($1, (NOW() at time zone 'utc'), (NOW() at time zone 'utc'), $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			updated_at = EXCLUDED.updated_at,
+			secret = EXCLUDED.secret,
+			last_step = EXCLUDED.last_step,
+			confirmed_at = EXCLUDED.confirmed_at
+		RETURNING user_id, created_at, updated_at, secret, last_step, confirmed_at`
+
+	dbf, err := sqlext.QueryRow[dbTOTPFactor](ctx, o.conn, q, f.UserID, f.Secret, f.LastStep, f.ConfirmedAt)
+	if err != nil {
+		return err
+	}
+
+	*f = dbf.toModel()
+
+	return nil
+}
+
+// UseStep records the time step of an accepted code.
+// The condition on the last step rejects concurrent uses of the same code.
+func (o *mfaRepo) UseStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	const q = `UPDATE totp_factors
+		SET last_step = $2, updated_at = (NOW() at time zone 'utc')
+		WHERE user_id = $1 AND last_step < $2`
+
+	return o.exec(ctx, "code already used", q, userID, step)
+}
+
+// DeleteFactor removes the factor and the recovery codes of the user
+func (o *mfaRepo) DeleteFactor(ctx context.Context, userID uuid.UUID) error {
+	const codes = `DELETE FROM recovery_codes WHERE user_id = $1`
+
+	if _, err := o.conn.ExecContext(ctx, codes, userID); err != nil {
+		return err
+	}
+
+	const q = `DELETE FROM totp_factors WHERE user_id = $1`
+
+	return o.exec(ctx, "mfa is not enabled", q, userID)
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of the user
+func (o *mfaRepo) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, hashes []string) error {
+	const del = `DELETE FROM recovery_codes WHERE user_id = $1`
+
+	if _, err := o.conn.ExecContext(ctx, del, userID); err != nil {
+		return err
+	}
+
+	const q = `INSERT INTO recovery_codes (created_at, user_id, code_hash)
+		SELECT (NOW() at time zone 'utc'), $1, h FROM jsonb_array_elements_text($2::jsonb) AS h`
+
+	hashesJson, err := json.Marshal(hashes)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.conn.ExecContext(ctx, q, userID, hashesJson)
+
+	return err
+}
+
+// UseRecoveryCode marks an unused recovery code of the user as used
+func (o *mfaRepo) UseRecoveryCode(ctx context.Context, userID uuid.UUID, hash string) error {
+	const q = `UPDATE recovery_codes
+		SET used_at = (NOW() at time zone 'utc')
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	return o.exec(ctx, "recovery code not found", q, userID, hash)
+}
+
+// exec runs a statement that has to affect a row
+func (o *mfaRepo) exec(ctx context.Context, notFound, q string, args ...any) error {
+	res, err := o.conn.ExecContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "%s", notFound)
+	}
+
+	return nil
+}
+
+type dbTOTPFactor struct {
+	UserID      uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Secret      string
+	LastStep    int64
+	ConfirmedAt sql.NullTime
+}
+
+func (o *dbTOTPFactor) Bind() []any {
+	return []any{
+		&o.UserID,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.Secret,
+		&o.LastStep,
+		&o.ConfirmedAt,
+	}
+}
+
+func (o *dbTOTPFactor) toModel() goappbuild.TOTPFactor {
+	return goappbuild.TOTPFactor{
+		UserID:      o.UserID,
+		CreatedAt:   o.CreatedAt,
+		UpdatedAt:   o.UpdatedAt,
+		Secret:      o.Secret,
+		LastStep:    o.LastStep,
+		ConfirmedAt: nullTime(o.ConfirmedAt),
+	}
+}
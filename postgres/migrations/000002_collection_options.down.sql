@@ -0,0 +1 @@
+ALTER TABLE collections DROP COLUMN options;
@@ -0,0 +1,2 @@
+ALTER TABLE collections
+    ADD COLUMN options JSONB NOT NULL DEFAULT '{}'::jsonb CHECK (jsonb_typeof(options) = 'object');
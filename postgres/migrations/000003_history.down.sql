@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS goappbuild_record_history();
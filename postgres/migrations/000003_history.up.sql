@@ -0,0 +1,22 @@
+-- goappbuild_record_history records the state of a row of a collection table
+-- in the history table of the collection (<table>__history in the same schema).
+-- For deletes the last state of the row is recorded.
+CREATE OR REPLACE FUNCTION goappbuild_record_history() RETURNS trigger AS $$
+DECLARE
+    doc JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        doc := to_jsonb(OLD);
+    ELSE
+        doc := to_jsonb(NEW);
+    END IF;
+
+    EXECUTE format(
+        'INSERT INTO %I.%I (document_id, operation, data, changed_at) VALUES ($1, $2, $3, now())',
+        TG_TABLE_SCHEMA,
+        TG_TABLE_NAME || '__history'
+    ) USING doc->>'id', TG_OP, doc;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS users_email_key;
+
+ALTER TABLE users
+    DROP COLUMN password_hash,
+    DROP COLUMN email;
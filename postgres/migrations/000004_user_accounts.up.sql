@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN email TEXT,
+    ADD COLUMN password_hash TEXT;
+
+CREATE UNIQUE INDEX users_email_key ON users (email);
@@ -0,0 +1,3 @@
+-- CASCADE drops the policies of the collection tables that use the functions
+DROP FUNCTION IF EXISTS goappbuild_is_member(UUID, TEXT) CASCADE;
+DROP FUNCTION IF EXISTS goappbuild_user_id() CASCADE;
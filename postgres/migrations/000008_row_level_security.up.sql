@@ -0,0 +1,25 @@
+-- The row level security policies of the collection tables read the caller
+-- from the settings that the unit of work sets for every transaction:
+-- goappbuild.user_id, goappbuild.project_id (API keys) and goappbuild.system.
+-- The tables of the collections created before this migration get their
+-- policies when the application starts (postgres.SyncPolicies).
+
+-- goappbuild_user_id returns the user of the transaction, NULL for anonymous callers
+CREATE OR REPLACE FUNCTION goappbuild_user_id() RETURNS UUID AS $$
+    SELECT NULLIF(current_setting('goappbuild.user_id', true), '')::UUID
+$$ LANGUAGE sql STABLE;
+
+-- goappbuild_is_member returns true if the caller of the transaction has at
+-- least the role in the project. API keys of the project and the internal
+-- jobs are allowed everything.
+CREATE OR REPLACE FUNCTION goappbuild_is_member(project UUID, min_role TEXT) RETURNS BOOLEAN AS $$
+    SELECT COALESCE(current_setting('goappbuild.system', true), '') = 'on'
+        OR NULLIF(current_setting('goappbuild.project_id', true), '')::UUID = project
+        OR EXISTS (
+            SELECT 1 FROM public.project_members m
+            WHERE m.project_id = project
+                AND m.user_id = public.goappbuild_user_id()
+                AND array_position(ARRAY['viewer', 'developer', 'admin', 'owner'], m.role)
+                    >= array_position(ARRAY['viewer', 'developer', 'admin', 'owner'], min_role)
+        )
+$$ LANGUAGE sql STABLE;
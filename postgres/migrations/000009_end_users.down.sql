@@ -0,0 +1,5 @@
+-- CASCADE drops the policies of the collection tables that use the function
+DROP FUNCTION IF EXISTS goappbuild_is_authenticated(UUID) CASCADE;
+
+DROP TABLE IF EXISTS end_user_sessions;
+DROP TABLE IF EXISTS end_users;
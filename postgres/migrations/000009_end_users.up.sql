@@ -0,0 +1,31 @@
+CREATE TABLE end_users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    password_hash TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(metadata) = 'object'),
+    UNIQUE (project_id, email)
+);
+
+CREATE TABLE end_user_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    end_user_id UUID NOT NULL REFERENCES end_users (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX end_user_sessions_end_user_id_idx ON end_user_sessions (end_user_id);
+
+-- goappbuild_is_authenticated returns true if the transaction has a user that
+-- is either a platform user or an end user of the project.
+-- goappbuild.user_project is set by the unit of work for end users.
+CREATE OR REPLACE FUNCTION goappbuild_is_authenticated(project UUID) RETURNS BOOLEAN AS $$
+    SELECT public.goappbuild_user_id() IS NOT NULL
+        AND COALESCE(NULLIF(current_setting('goappbuild.user_project', true), '')::UUID, project) = project
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1,39 @@
+CREATE TABLE identity_providers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    issuer TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    client_secret TEXT NOT NULL DEFAULT '',
+    redirect_url TEXT NOT NULL,
+    scopes JSONB NOT NULL DEFAULT '[]',
+    UNIQUE (project_id, name)
+);
+
+CREATE TABLE oidc_auth_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    provider_id UUID NOT NULL REFERENCES identity_providers (id) ON DELETE CASCADE,
+    state_hash TEXT NOT NULL UNIQUE,
+    verifier TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX oidc_auth_requests_expires_at_idx ON oidc_auth_requests (expires_at);
+
+CREATE TABLE external_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    provider_id UUID NOT NULL REFERENCES identity_providers (id) ON DELETE CASCADE,
+    subject TEXT NOT NULL,
+    end_user_id UUID NOT NULL REFERENCES end_users (id) ON DELETE CASCADE,
+    email TEXT NOT NULL DEFAULT '',
+    UNIQUE (provider_id, subject)
+);
+
+CREATE INDEX external_identities_end_user_id_idx ON external_identities (end_user_id);
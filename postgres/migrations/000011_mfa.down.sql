@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_factors;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS mfa;
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
@@ -0,0 +1,7 @@
+ALTER TABLE oidc_auth_requests DROP COLUMN IF EXISTS end_user_id;
+
+DELETE FROM end_users WHERE anonymous;
+
+ALTER TABLE end_users DROP CONSTRAINT IF EXISTS end_users_email_check;
+ALTER TABLE end_users DROP COLUMN IF EXISTS anonymous;
+ALTER TABLE end_users ALTER COLUMN email SET NOT NULL;
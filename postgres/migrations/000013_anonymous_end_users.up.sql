@@ -0,0 +1,7 @@
+-- anonymous end users have neither an email nor a password
+ALTER TABLE end_users ALTER COLUMN email DROP NOT NULL;
+ALTER TABLE end_users ADD COLUMN anonymous BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE end_users ADD CONSTRAINT end_users_email_check CHECK (anonymous OR email IS NOT NULL);
+
+-- end_user_id is the anonymous end user the account of the provider is linked to
+ALTER TABLE oidc_auth_requests ADD COLUMN end_user_id UUID REFERENCES end_users (id) ON DELETE CASCADE;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS security_events;
+
+DROP TABLE IF EXISTS login_counters;
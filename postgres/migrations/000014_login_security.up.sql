@@ -0,0 +1,23 @@
+-- login_counters count the recent failed logins of the accounts and
+-- the clients, the keys are built by the login guard
+CREATE TABLE login_counters (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
+
+-- security_events are kept for review, they outlive the users
+-- and the projects so there are no foreign keys
+CREATE TABLE security_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    kind TEXT NOT NULL,
+    user_id UUID,
+    project_id UUID,
+    email TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX security_events_created_at_idx ON security_events (created_at DESC);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS security_events_user_id_idx;
+
+ALTER TABLE security_events DROP COLUMN IF EXISTS actor_id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
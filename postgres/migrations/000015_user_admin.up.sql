@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
+
+-- actor_id is the admin that performed the action of the event
+ALTER TABLE security_events ADD COLUMN actor_id UUID;
+
+CREATE INDEX security_events_user_id_idx ON security_events (user_id);
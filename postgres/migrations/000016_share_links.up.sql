@@ -0,0 +1,26 @@
+-- share_links give read access to a document, or to the documents matching
+-- the filters, to anyone holding the signed token of the link
+CREATE TABLE share_links (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    collection TEXT NOT NULL,
+    document_id TEXT NOT NULL DEFAULT '',
+    filters JSONB NOT NULL CHECK (jsonb_typeof(filters) = 'array'),
+    created_by UUID,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX share_links_project_id_idx ON share_links (project_id, collection);
+
+-- share_link_accesses log every read of a share link
+CREATE TABLE share_link_accesses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    link_id UUID NOT NULL REFERENCES share_links (id) ON DELETE CASCADE,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX share_link_accesses_link_id_idx ON share_link_accesses (link_id, created_at DESC);
@@ -0,0 +1,4 @@
+-- CASCADE drops the policies of the collection tables that use the function
+DROP FUNCTION IF EXISTS goappbuild_is_granted(UUID, TEXT, TEXT, TEXT[]) CASCADE;
+
+DROP TABLE IF EXISTS document_acl;
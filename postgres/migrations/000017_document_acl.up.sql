@@ -0,0 +1,33 @@
+-- document_acl shares documents with specific principals. The document ids
+-- are stored as text, so that they match every id strategy.
+CREATE TABLE document_acl (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    collection TEXT NOT NULL,
+    document_id TEXT NOT NULL,
+    principal_type TEXT NOT NULL,
+    principal_id UUID NOT NULL,
+    permission TEXT NOT NULL CHECK (permission IN ('read', 'write')),
+    created_by UUID,
+    UNIQUE (project_id, collection, document_id, principal_type, principal_id)
+);
+
+CREATE INDEX document_acl_principal_idx ON document_acl (project_id, collection, principal_id, document_id);
+
+-- goappbuild_is_granted returns true if the document is shared with the
+-- caller of the transaction with one of the permissions.
+-- The tables of the collections created before this migration take the
+-- entries into account once the application starts (postgres.SyncPolicies).
+CREATE OR REPLACE FUNCTION goappbuild_is_granted(project UUID, coll TEXT, document TEXT, permissions TEXT[])
+RETURNS BOOLEAN AS $$
+    SELECT public.goappbuild_is_authenticated(project) AND EXISTS (
+        SELECT 1 FROM public.document_acl a
+        WHERE a.project_id = project
+            AND a.collection = coll
+            AND a.document_id = document
+            AND a.principal_type = 'user'
+            AND a.principal_id = public.goappbuild_user_id()
+            AND a.permission = ANY(permissions)
+    )
+$$ LANGUAGE sql STABLE;
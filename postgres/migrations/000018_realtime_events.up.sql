@@ -0,0 +1,11 @@
+-- realtime_events hold the document events relayed between the instances.
+-- The notifications only carry the id of the event, since their payload
+-- is limited to 8000 bytes. The events are deleted after a few minutes,
+-- they are only read again by the listeners that reconnect.
+CREATE TABLE realtime_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    payload JSONB NOT NULL
+);
+
+CREATE INDEX realtime_events_created_at_idx ON realtime_events (created_at);
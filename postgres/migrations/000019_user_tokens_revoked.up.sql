@@ -0,0 +1,2 @@
+-- the access tokens of the user issued before tokens_revoked_at are rejected
+ALTER TABLE users ADD COLUMN tokens_revoked_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,18 @@
+CREATE OR REPLACE FUNCTION goappbuild_is_granted(project UUID, coll TEXT, document TEXT, permissions TEXT[])
+RETURNS BOOLEAN AS $$
+    SELECT public.goappbuild_is_authenticated(project) AND EXISTS (
+        SELECT 1 FROM public.document_acl a
+        WHERE a.project_id = project
+            AND a.collection = coll
+            AND a.document_id = document
+            AND a.principal_type = 'user'
+            AND a.principal_id = public.goappbuild_user_id()
+            AND a.permission = ANY(permissions)
+    )
+$$ LANGUAGE sql STABLE;
+
+DELETE FROM document_acl WHERE principal_type = 'team';
+
+DROP TABLE IF EXISTS team_members;
+
+DROP TABLE IF EXISTS teams;
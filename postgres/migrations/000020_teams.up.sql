@@ -0,0 +1,40 @@
+-- teams group the platform users and the end users of a project, so that
+-- documents can be shared with all of them at once
+CREATE TABLE teams (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (project_id, name)
+);
+
+-- user_id is a platform user or an end user of the project of the team
+CREATE TABLE team_members (
+    team_id UUID NOT NULL REFERENCES teams (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX team_members_user_id_idx ON team_members (user_id);
+
+-- the documents shared with a team are granted to its members. The policies
+-- call the function, so they take the teams into account without a change.
+CREATE OR REPLACE FUNCTION goappbuild_is_granted(project UUID, coll TEXT, document TEXT, permissions TEXT[])
+RETURNS BOOLEAN AS $$
+    SELECT public.goappbuild_is_authenticated(project) AND EXISTS (
+        SELECT 1 FROM public.document_acl a
+        WHERE a.project_id = project
+            AND a.collection = coll
+            AND a.document_id = document
+            AND a.permission = ANY(permissions)
+            AND (
+                (a.principal_type = 'user' AND a.principal_id = public.goappbuild_user_id())
+                OR (a.principal_type = 'team' AND a.principal_id IN (
+                    SELECT m.team_id FROM public.team_members m
+                    WHERE m.user_id = public.goappbuild_user_id()
+                ))
+            )
+    )
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+// SyncPolicies creates the row level security policies of the collection
+// tables that do not have the policies of this version, like the tables
+// created before the policies were introduced. It runs when the application
+// starts and it does nothing once all the tables are up to date.
+func SyncPolicies(ctx context.Context, db *sql.DB) error {
+	const (
+		// the instances that start together wait for each other
+		lock = `SELECT pg_advisory_xact_lock(hashtext('goappbuild_sync_policies'))`
+		q    = `SELECT
+				c.id, c.created_at, c.updated_at, c.name, c.project_id, c.attributes, c.options, p.name
+			FROM collections c
+			JOIN projects p ON p.id = c.project_id
+			WHERE to_regclass(format('%I.%I', p.name, c.name)) IS NOT NULL
+			AND COALESCE((
+				SELECT obj_description(pol.oid, 'pg_policy')
+				FROM pg_policy pol
+				WHERE pol.polrelid = to_regclass(format('%I.%I', p.name, c.name))
+				AND pol.polname = 'goappbuild_select'
+			), '') <> $1`
+	)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, lock); err != nil {
+		return err
+	}
+
+	items, err := sqlext.Query[dbStaleCollection](ctx, tx, q, policiesVersion)
+	if err != nil {
+		return err
+	}
+
+	databases := NewDBRepo(tx)
+
+	for i := range items {
+		collection, err := items[i].toModel()
+		if err != nil {
+			return err
+		}
+
+		project := goappbuild.Project{ID: collection.ProjectID, Name: items[i].Schema}
+
+		if err := databases.CreatePolicies(ctx, project.SchemaName(), collection); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// dbStaleCollection is a collection with the (unquoted) schema of its project
+type dbStaleCollection struct {
+	dbCollection
+	Schema string
+}
+
+func (c *dbStaleCollection) Bind() []any {
+	return append(c.dbCollection.Bind(), &c.Schema)
+}
@@ -0,0 +1,68 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/internal/pgtest"
+	"github.com/gosom/goappbuild/postgres"
+)
+
+func Test_SyncPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	db := pgtest.New(t)
+	storage := postgres.NewUnitOfWork(db)
+
+	project, owner := pgtest.Project(t, storage)
+	collection := pgtest.Collection(t, storage, owner, project.ID, "notes", goappbuild.CollectionOptions{})
+
+	table := project.SchemaName() + "." + collection.TableName()
+
+	policies := func() (n int, enabled, forced bool) {
+		t.Helper()
+
+		const q = `SELECT
+				(SELECT COUNT(*) FROM pg_policy WHERE polrelid = c.oid), c.relrowsecurity, c.relforcerowsecurity
+			FROM pg_class c
+			WHERE c.oid = $1::regclass`
+
+		require.NoError(t, db.QueryRowContext(ctx, q, table).Scan(&n, &enabled, &forced))
+
+		return n, enabled, forced
+	}
+
+	n, enabled, forced := policies()
+	require.Equal(t, 4, n)
+	require.True(t, enabled)
+	require.True(t, forced)
+
+	// a table of a collection created before the policies
+	for _, stmt := range []string{
+		`ALTER TABLE ` + table + ` NO FORCE ROW LEVEL SECURITY`,
+		`ALTER TABLE ` + table + ` DISABLE ROW LEVEL SECURITY`,
+		`DROP POLICY goappbuild_select ON ` + table,
+		`DROP POLICY goappbuild_insert ON ` + table,
+		`DROP POLICY goappbuild_update ON ` + table,
+		`DROP POLICY goappbuild_delete ON ` + table,
+	} {
+		_, err := db.ExecContext(ctx, stmt)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, postgres.SyncPolicies(ctx, db))
+
+	n, enabled, forced = policies()
+	require.Equal(t, 4, n)
+	require.True(t, enabled)
+	require.True(t, forced)
+
+	// the tables that are up to date are left alone
+	require.NoError(t, postgres.SyncPolicies(ctx, db))
+
+	n, _, _ = policies()
+	require.Equal(t, 4, n)
+}
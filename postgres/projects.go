@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -47,6 +49,10 @@ func (o *projectRepo) Get(ctx context.Context, id uuid.UUID) (goappbuild.Project
 		WHERE id = $1`
 
 	dbp, err := sqlext.QueryRow[dbProject](ctx, o.conn, q, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Project{}, goappbuild.Errorf(goappbuild.ENotFound, "project not found")
+	}
+
 	if err != nil {
 		return goappbuild.Project{}, err
 	}
@@ -1,16 +1,21 @@
 package postgres
 
 import (
+	"errors"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/gosom/goappbuild"
+	"golang.org/x/exp/maps"
 )
 
 type postgresQ struct {
 	goappbuild.Q
-	sb   strings.Builder
-	args []any
+	sb         strings.Builder
+	args       []any
+	conditions int
 }
 
 func NewPostgresQ(params goappbuild.Q) *postgresQ {
@@ -28,6 +33,76 @@ func (q *postgresQ) Build() (string, []any, error) {
 		return "", nil, err
 	}
 
+	if err := q.orderBy(); err != nil {
+		return "", nil, err
+	}
+
+	q.limitOffset()
+
+	return q.sb.String(), q.args, nil
+}
+
+// BuildCount builds a query that counts the rows matching the filter
+func (q *postgresQ) BuildCount() (string, []any, error) {
+	q.sb.WriteString("SELECT COUNT(*)")
+
+	q.from()
+
+	if err := q.where(); err != nil {
+		return "", nil, err
+	}
+
+	return q.sb.String(), q.args, nil
+}
+
+// BuildUpdate builds an update statement for the rows matching the filter.
+// The selected columns of the rows are returned.
+func (q *postgresQ) BuildUpdate(data map[string]any) (string, []any, error) {
+	if len(data) == 0 {
+		return "", nil, errors.New("no columns to update")
+	}
+
+	q.sb.WriteString("UPDATE ")
+	q.sb.WriteString(q.tableName())
+	q.sb.WriteString(" SET ")
+
+	keys := maps.Keys(data)
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		if i > 0 {
+			q.sb.WriteString(", ")
+		}
+
+		q.args = append(q.args, data[k])
+
+		q.sb.WriteString(escape(k))
+		q.sb.WriteString(" = $")
+		q.sb.WriteString(strconv.Itoa(len(q.args)))
+	}
+
+	if err := q.where(); err != nil {
+		return "", nil, err
+	}
+
+	q.returning()
+
+	return q.sb.String(), q.args, nil
+}
+
+// BuildDelete builds a delete statement for the rows matching the filter.
+// The selected columns of the rows are returned.
+func (q *postgresQ) BuildDelete() (string, []any, error) {
+	q.sb.WriteString("DELETE")
+
+	q.from()
+
+	if err := q.where(); err != nil {
+		return "", nil, err
+	}
+
+	q.returning()
+
 	return q.sb.String(), q.args, nil
 }
 
@@ -53,30 +128,41 @@ func (q *postgresQ) selectColumns() {
 }
 
 func (q *postgresQ) from() {
-	table := escape(q.GetSchema()) + "." + escape(q.GetTable())
-
 	q.sb.WriteString(" FROM ")
-	q.sb.WriteString(table)
+	q.sb.WriteString(q.tableName())
 
 	return
 }
 
-func (q *postgresQ) where() error {
-	where := q.Where()
+func (q *postgresQ) returning() {
+	q.sb.WriteString(" RETURNING ")
 
-	if len(where) == 0 {
+	cols := q.Cols()
+	if len(cols) == 0 {
+		q.sb.WriteString("*")
 
-		return nil
+		return
 	}
 
+	escaped := make([]string, len(cols))
+	for i := range cols {
+		escaped[i] = escape(cols[i])
+	}
+
+	q.sb.WriteString(strings.Join(escaped, ", "))
+}
+
+func (q *postgresQ) tableName() string {
+	return escape(q.GetSchema()) + "." + escape(q.GetTable())
+}
+
+func (q *postgresQ) where() error {
+	where := q.Where()
+
 	for i := range where {
 		op := postgresOp{op: where[i].Op()}
 
-		if i == 0 {
-			q.sb.WriteString(" WHERE ")
-		} else {
-			q.sb.WriteString(" AND ")
-		}
+		q.condition()
 
 		q.sb.WriteString(escape(where[i].Column()))
 		q.sb.WriteString(" ")
@@ -94,9 +180,122 @@ func (q *postgresQ) where() error {
 		}
 	}
 
+	q.access()
+
+	q.softDelete()
+
+	return nil
+}
+
+// access restricts the rows to the ones the principal owns or was granted
+func (q *postgresQ) access() {
+	a := q.GetAccess()
+	if a == nil {
+		return
+	}
+
+	var conditions []string
+
+	if a.OwnerID != uuid.Nil {
+		conditions = append(conditions, escape(goappbuild.OwnerColumn)+" = "+q.arg(a.OwnerID))
+	}
+
+	// the documents shared with the grantee or with a team of the grantee
+	if a.GranteeID != uuid.Nil && len(a.Permissions) > 0 {
+		grantee := q.arg(a.GranteeID)
+
+		conditions = append(conditions, `"id"::text IN (SELECT document_id FROM public.document_acl`+
+			" WHERE project_id = "+q.arg(a.ProjectID)+
+			" AND collection = "+q.arg(q.GetTable())+
+			" AND permission = ANY("+q.arg(permissionValues(a.Permissions))+")"+
+			" AND ((principal_type = "+q.arg(string(goappbuild.PrincipalUser))+" AND principal_id = "+grantee+")"+
+			" OR (principal_type = "+q.arg(string(goappbuild.PrincipalTeam))+" AND principal_id IN"+
+			" (SELECT team_id FROM public.team_members WHERE user_id = "+grantee+"))))")
+	}
+
+	q.condition()
+
+	// a restriction without conditions matches nothing
+	if len(conditions) == 0 {
+		q.sb.WriteString("false")
+		return
+	}
+
+	q.sb.WriteString("(" + strings.Join(conditions, " OR ") + ")")
+}
+
+// arg adds an argument and returns its placeholder
+func (q *postgresQ) arg(v any) string {
+	q.args = append(q.args, v)
+
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// softDelete excludes (or selects only) the trashed rows of soft delete tables
+func (q *postgresQ) softDelete() {
+	if !q.IsSoftDelete() {
+		return
+	}
+
+	switch {
+	case q.IsOnlyDeleted():
+		q.condition()
+		q.sb.WriteString(`"deleted_at" IS NOT NULL`)
+	case q.IsIncludeDeleted():
+	default:
+		q.condition()
+		q.sb.WriteString(`"deleted_at" IS NULL`)
+	}
+}
+
+// condition starts a new condition of the where clause
+func (q *postgresQ) condition() {
+	if q.conditions == 0 {
+		q.sb.WriteString(" WHERE ")
+	} else {
+		q.sb.WriteString(" AND ")
+	}
+
+	q.conditions++
+}
+
+func (q *postgresQ) orderBy() error {
+	order := q.Order()
+
+	for i := range order {
+		if i == 0 {
+			q.sb.WriteString(" ORDER BY ")
+		} else {
+			q.sb.WriteString(", ")
+		}
+
+		q.sb.WriteString(escape(order[i].Column()))
+
+		switch order[i].Op() {
+		case goappbuild.OpOrderAsc:
+			q.sb.WriteString(" ASC")
+		case goappbuild.OpOrderDesc:
+			q.sb.WriteString(" DESC")
+		default:
+			return errors.New("invalid order operator")
+		}
+	}
+
 	return nil
 }
 
+func (q *postgresQ) limitOffset() {
+	if limit := q.GetLimit(); limit > 0 {
+		q.sb.WriteString(" LIMIT ")
+		q.sb.WriteString(strconv.Itoa(limit))
+	}
+
+	if offset := q.GetOffset(); offset > 0 {
+		q.sb.WriteString(" OFFSET ")
+		q.sb.WriteString(strconv.Itoa(offset))
+	}
+}
+
 func getValue(op goappbuild.Op) any {
 	if op.Value() == nil {
 		return nil
@@ -3,6 +3,7 @@ package postgres_test
 import (
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/gosom/goappbuild"
 	"github.com/gosom/goappbuild/postgres"
 	"github.com/stretchr/testify/require"
@@ -39,4 +40,139 @@ func Test_postgresQ(t *testing.T) {
 		require.Equal(t, "John%", args[4])
 		require.Equal(t, "%Smith", args[5])
 	})
+	t.Run("test count", func(t *testing.T) {
+		q := goappbuild.Q{}.
+			Schema("test").
+			Table("users").
+			Equal("department", "engineering")
+
+		sql, args, err := postgres.NewPostgresQ(q).BuildCount()
+		require.NoError(t, err)
+
+		require.Equal(t, `SELECT COUNT(*) FROM "test"."users" WHERE "department" = $1`, sql)
+		require.Equal(t, []any{"engineering"}, args)
+	})
+
+	t.Run("test update", func(t *testing.T) {
+		q := goappbuild.Q{}.
+			Schema("test").
+			Table("users").
+			Select("id").
+			Equal("department", "engineering").
+			LessThan("age", 30)
+
+		data := map[string]any{
+			"team":   "platform",
+			"active": true,
+		}
+
+		sql, args, err := postgres.NewPostgresQ(q).BuildUpdate(data)
+		require.NoError(t, err)
+
+		expected := `UPDATE "test"."users" SET "active" = $1, "team" = $2 WHERE "department" = $3 AND "age" < $4 RETURNING "id"`
+
+		require.Equal(t, expected, sql)
+		require.Equal(t, []any{true, "platform", "engineering", 30}, args)
+	})
+
+	t.Run("test update without data", func(t *testing.T) {
+		q := goappbuild.Q{}.Schema("test").Table("users")
+
+		_, _, err := postgres.NewPostgresQ(q).BuildUpdate(nil)
+		require.Error(t, err)
+	})
+
+	t.Run("test delete", func(t *testing.T) {
+		q := goappbuild.Q{}.
+			Schema("test").
+			Table("users").
+			Select("id").
+			Null("activated_at")
+
+		sql, args, err := postgres.NewPostgresQ(q).BuildDelete()
+		require.NoError(t, err)
+
+		require.Equal(t, `DELETE FROM "test"."users" WHERE "activated_at" IS NULL  RETURNING "id"`, sql)
+		require.Empty(t, args)
+	})
+	t.Run("test order limit offset", func(t *testing.T) {
+		q := goappbuild.Q{}.
+			Schema("test").
+			Table("users").
+			Equal("department", "engineering").
+			OrderDesc("created_at").
+			OrderAsc("name").
+			Limit(10).
+			Offset(20)
+
+		sql, args, err := postgres.NewPostgresQ(q).Build()
+		require.NoError(t, err)
+
+		expected := `SELECT * FROM "test"."users" WHERE "department" = $1 ORDER BY "created_at" DESC, "name" ASC LIMIT 10 OFFSET 20`
+
+		require.Equal(t, expected, sql)
+		require.Equal(t, []any{"engineering"}, args)
+	})
+
+	t.Run("test soft delete", func(t *testing.T) {
+		q := goappbuild.Q{}.
+			Schema("test").
+			Table("users").
+			SoftDelete()
+
+		sql, _, err := postgres.NewPostgresQ(q).Build()
+		require.NoError(t, err)
+		require.Equal(t, `SELECT * FROM "test"."users" WHERE "deleted_at" IS NULL`, sql)
+
+		sql, _, err = postgres.NewPostgresQ(q.Equal("id", 1).IncludeDeleted()).Build()
+		require.NoError(t, err)
+		require.Equal(t, `SELECT * FROM "test"."users" WHERE "id" = $1`, sql)
+
+		sql, _, err = postgres.NewPostgresQ(q.Equal("id", 1).OnlyDeleted()).BuildDelete()
+		require.NoError(t, err)
+		require.Equal(t, `DELETE FROM "test"."users" WHERE "id" = $1 AND "deleted_at" IS NOT NULL RETURNING *`, sql)
+	})
+
+	t.Run("test access", func(t *testing.T) {
+		projectID, userID := uuid.New(), uuid.New()
+
+		q := goappbuild.Q{}.
+			Schema("test").
+			Table("notes").
+			Equal("id", 1).
+			Accessible(goappbuild.Access{
+				ProjectID:   projectID,
+				OwnerID:     userID,
+				GranteeID:   userID,
+				Permissions: []goappbuild.Permission{goappbuild.PermissionWrite},
+			})
+
+		sql, args, err := postgres.NewPostgresQ(q).BuildUpdate(map[string]any{"title": "bye"})
+		require.NoError(t, err)
+
+		expected := `UPDATE "test"."notes" SET "title" = $1 WHERE "id" = $2 AND ("owner_id" = $3 OR "id"::text IN ` +
+			`(SELECT document_id FROM public.document_acl WHERE project_id = $5 AND collection = $6 ` +
+			`AND permission = ANY($7) AND ((principal_type = $8 AND principal_id = $4) ` +
+			`OR (principal_type = $9 AND principal_id IN (SELECT team_id FROM public.team_members WHERE user_id = $4))))) RETURNING *`
+
+		require.Equal(t, expected, sql)
+		require.Equal(t, []any{"bye", 1, userID, userID, projectID, "notes", []string{"write"}, "user", "team"}, args)
+
+		sql, args, err = postgres.NewPostgresQ(goappbuild.Q{}.
+			Schema("test").
+			Table("notes").
+			Accessible(goappbuild.Access{ProjectID: projectID, OwnerID: userID})).
+			BuildDelete()
+		require.NoError(t, err)
+		require.Equal(t, `DELETE FROM "test"."notes" WHERE ("owner_id" = $1) RETURNING *`, sql)
+		require.Equal(t, []any{userID}, args)
+
+		sql, _, err = postgres.NewPostgresQ(goappbuild.Q{}.
+			Schema("test").
+			Table("notes").
+			Accessible(goappbuild.Access{ProjectID: projectID})).
+			Build()
+		require.NoError(t, err)
+		require.Equal(t, `SELECT * FROM "test"."notes" WHERE false`, sql)
+	})
 }
@@ -2,13 +2,13 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"sort"
 	"strconv"
 	"strings"
 
-	"github.com/google/uuid"
 	"github.com/gosom/goappbuild"
 	"github.com/gosom/goappbuild/pkg/sqlext"
 	"golang.org/x/exp/maps"
@@ -16,6 +16,8 @@ import (
 
 var _ goappbuild.QueryRepo = (*queryRepo)(nil)
 
+var errDocumentNotFound = goappbuild.Errorf(goappbuild.ENotFound, "document not found")
+
 type queryRepo struct {
 	conn sqlext.DBTX
 }
@@ -41,7 +43,7 @@ func (o *queryRepo) Get(ctx context.Context, params goappbuild.Q) (map[string]an
 	var data []byte
 	err = o.conn.QueryRowContext(ctx, sql, args...).Scan(&data)
 	if err != nil {
-		return nil, err
+		return nil, documentErr(err)
 	}
 
 	err = json.Unmarshal(data, &ans)
@@ -52,6 +54,18 @@ func (o *queryRepo) Get(ctx context.Context, params goappbuild.Q) (map[string]an
 	return ans, nil
 }
 
+// List returns all the rows matching the query
+func (o *queryRepo) List(ctx context.Context, params goappbuild.Q) ([]map[string]any, error) {
+	builder := NewPostgresQ(params)
+
+	q, args, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return o.rows(ctx, q, args)
+}
+
 func (o *queryRepo) Create(ctx context.Context, schema, collectionName string, data map[string]any) (map[string]any, error) {
 	sb := strings.Builder{}
 
@@ -101,7 +115,7 @@ func (o *queryRepo) Create(ctx context.Context, schema, collectionName string, d
 	return ans, err
 }
 
-func (o *queryRepo) Update(ctx context.Context, schema, collectionName string, id uuid.UUID, data map[string]any) (map[string]any, error) {
+func (o *queryRepo) Update(ctx context.Context, schema, collectionName string, id any, data map[string]any) (map[string]any, error) {
 	sb := strings.Builder{}
 
 	sb.WriteString("UPDATE ")
@@ -140,7 +154,7 @@ func (o *queryRepo) Update(ctx context.Context, schema, collectionName string, i
 	err := o.conn.QueryRowContext(ctx, sql, args...).Scan(&result)
 
 	if err != nil {
-		return nil, err
+		return nil, documentErr(err)
 	}
 
 	var ans map[string]any
@@ -150,7 +164,7 @@ func (o *queryRepo) Update(ctx context.Context, schema, collectionName string, i
 	return ans, err
 }
 
-func (o *queryRepo) Delete(ctx context.Context, schema, collectionName string, id uuid.UUID) error {
+func (o *queryRepo) Delete(ctx context.Context, schema, collectionName string, id any) error {
 	sb := strings.Builder{}
 
 	sb.WriteString("DELETE FROM ")
@@ -170,12 +184,102 @@ func (o *queryRepo) Delete(ctx context.Context, schema, collectionName string, i
 	}
 
 	if affected == 0 {
-		return errors.New("no rows affected")
+		return errDocumentNotFound
 	}
 
 	return nil
 }
 
+// Count returns the number of rows matching the filter of the query
+func (o *queryRepo) Count(ctx context.Context, params goappbuild.Q) (int64, error) {
+	builder := NewPostgresQ(params)
+
+	q, args, err := builder.BuildCount()
+	if err != nil {
+		return 0, err
+	}
+
+	var ans int64
+
+	err = o.conn.QueryRowContext(ctx, q, args...).Scan(&ans)
+
+	return ans, err
+}
+
+// UpdateWhere updates the rows matching the filter of the query
+func (o *queryRepo) UpdateWhere(ctx context.Context, params goappbuild.Q, data map[string]any) ([]any, error) {
+	builder := NewPostgresQ(params.Select("id"))
+
+	q, args, err := builder.BuildUpdate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.affectedIDs(ctx, q, args)
+}
+
+// DeleteWhere deletes the rows matching the filter of the query
+func (o *queryRepo) DeleteWhere(ctx context.Context, params goappbuild.Q) ([]any, error) {
+	builder := NewPostgresQ(params.Select("id"))
+
+	q, args, err := builder.BuildDelete()
+	if err != nil {
+		return nil, err
+	}
+
+	return o.affectedIDs(ctx, q, args)
+}
+
+func (o *queryRepo) affectedIDs(ctx context.Context, q string, args []any) ([]any, error) {
+	items, err := o.rows(ctx, q, args)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]any, len(items))
+	for i := range items {
+		ids[i] = items[i]["id"]
+	}
+
+	return ids, nil
+}
+
+func (o *queryRepo) rows(ctx context.Context, q string, args []any) ([]map[string]any, error) {
+	rows, err := o.conn.QueryContext(ctx, o.wrapCte(q), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ans := []map[string]any{}
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var row map[string]any
+		if err := json.Unmarshal(data, &row); err != nil {
+			return nil, err
+		}
+
+		ans = append(ans, row)
+	}
+
+	return ans, rows.Err()
+}
+
+// documentErr reports a missing row as a missing document
+func documentErr(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return errDocumentNotFound
+	}
+
+	return err
+}
+
 func (o *queryRepo) wrapCte(q string) string {
 	var sb strings.Builder
 
@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.RefreshTokenRepo = (*refreshTokenRepo)(nil)
+
+type refreshTokenRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewRefreshTokenRepo returns a new instance of a postgres refresh token repository
+func NewRefreshTokenRepo(conn sqlext.DBTX) goappbuild.RefreshTokenRepo {
+	return &refreshTokenRepo{
+		conn: conn,
+	}
+}
+
+// Create stores a new refresh token
+func (o *refreshTokenRepo) Create(ctx context.Context, rt *goappbuild.RefreshToken) error {
+	const q = `INSERT INTO refresh_tokens
+		(created_at, user_id, family_id, token_hash, mfa, expires_at)
+		VALUES ((NOW() at time zone 'utc'), $1, $2, $3, $4, $5)
+		RETURNING id, created_at, user_id, family_id, token_hash, mfa, expires_at, revoked_at`
+
+	dbrt, err := sqlext.QueryRow[dbRefreshToken](
+		ctx, o.conn, q,
+		rt.UserID, rt.FamilyID, rt.TokenHash, rt.MFA, rt.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	*rt = dbrt.toModel()
+
+	return nil
+}
+
+// GetByHash returns the refresh token with the given hash.
+// The row is locked so that concurrent refreshes of the same token are serialized.
+func (o *refreshTokenRepo) GetByHash(ctx context.Context, hash string) (goappbuild.RefreshToken, error) {
+	const q = `SELECT
+			id, created_at, user_id, family_id, token_hash, mfa, expires_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE`
+
+	dbrt, err := sqlext.QueryRow[dbRefreshToken](ctx, o.conn, q, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.RefreshToken{}, goappbuild.Errorf(goappbuild.ENotFound, "refresh token not found")
+	}
+
+	if err != nil {
+		return goappbuild.RefreshToken{}, err
+	}
+
+	return dbrt.toModel(), nil
+}
+
+// Revoke revokes a refresh token
+func (o *refreshTokenRepo) Revoke(ctx context.Context, id uuid.UUID) error {
+	const q = `UPDATE refresh_tokens
+		SET revoked_at = (NOW() at time zone 'utc')
+		WHERE id = $1 AND revoked_at IS NULL`
+
+	_, err := o.conn.ExecContext(ctx, q, id)
+
+	return err
+}
+
+// RevokeFamily revokes all the refresh tokens of a family
+func (o *refreshTokenRepo) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	const q = `UPDATE refresh_tokens
+		SET revoked_at = (NOW() at time zone 'utc')
+		WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := o.conn.ExecContext(ctx, q, familyID)
+
+	return err
+}
+
+// RevokeUser revokes all the refresh tokens of a user
+func (o *refreshTokenRepo) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	const q = `UPDATE refresh_tokens
+		SET revoked_at = (NOW() at time zone 'utc')
+		WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := o.conn.ExecContext(ctx, q, userID)
+
+	return err
+}
+
+type dbRefreshToken struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+	TokenHash string
+	MFA       bool
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+func (o *dbRefreshToken) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.UserID,
+		&o.FamilyID,
+		&o.TokenHash,
+		&o.MFA,
+		&o.ExpiresAt,
+		&o.RevokedAt,
+	}
+}
+
+func (o *dbRefreshToken) toModel() goappbuild.RefreshToken {
+	return goappbuild.RefreshToken{
+		ID:        o.ID,
+		CreatedAt: o.CreatedAt,
+		UserID:    o.UserID,
+		FamilyID:  o.FamilyID,
+		TokenHash: o.TokenHash,
+		MFA:       o.MFA,
+		ExpiresAt: o.ExpiresAt,
+		RevokedAt: nullTime(o.RevokedAt),
+	}
+}
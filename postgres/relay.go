@@ -0,0 +1,328 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/gosom/goappbuild"
+)
+
+const (
+	// eventsChannel is the channel of the notifications of the relayed events
+	eventsChannel = "goappbuild_events"
+	// eventsRetention is how long the relayed events can be read again
+	eventsRetention = 10 * time.Minute
+	// relayQueueSize is the number of local events waiting to be relayed
+	relayQueueSize = 1024
+	// maxRelayBackoff is the longest wait before the listener reconnects
+	maxRelayBackoff = 30 * time.Second
+	// relayWindow is how long before the latest published event the listener
+	// reads the events again when it catches up. It covers the events that
+	// commit out of order, after the ones with greater ids.
+	relayWindow = time.Minute
+)
+
+// EventRelay relays the document events between the instances that share the
+// database using LISTEN/NOTIFY. The events of the local bus are stored and
+// notified, and every notified event, including the ones of this instance,
+// is published to the cluster bus.
+type EventRelay struct {
+	db      *sql.DB
+	local   goappbuild.EventBus
+	cluster goappbuild.EventBus
+	queue   chan goappbuild.Event
+	logger  goappbuild.Logger
+}
+
+// NewEventRelay returns a new relay from the local bus to the cluster bus.
+// The events that cannot be relayed and the listener failures are reported
+// to the logger.
+func NewEventRelay(db *sql.DB, local, cluster goappbuild.EventBus, logger goappbuild.Logger) *EventRelay {
+	if logger == nil {
+		logger = goappbuild.NopLogger()
+	}
+
+	return &EventRelay{
+		db:      db,
+		local:   local,
+		cluster: cluster,
+		queue:   make(chan goappbuild.Event, relayQueueSize),
+		logger:  logger,
+	}
+}
+
+// Run relays the events until the context is canceled. The listener
+// reconnects when its connection fails and catches up on the events it missed
+// while they are retained.
+func (o *EventRelay) Run(ctx context.Context) error {
+	unsubscribe := o.local.Subscribe(
+		o.enqueue,
+		goappbuild.EventDocumentCreated,
+		goappbuild.EventDocumentUpdated,
+		goappbuild.EventDocumentDeleted,
+	)
+	defer unsubscribe()
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		o.write(ctx)
+	}()
+
+	o.listen(ctx)
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// enqueue queues a local event. It runs in the goroutine of the publisher,
+// so the event is dropped when the queue is full.
+func (o *EventRelay) enqueue(_ context.Context, e goappbuild.Event) {
+	select {
+	case o.queue <- e:
+	default:
+		o.logger.Printf("event relay: queue is full, dropping event %s", e.ID)
+	}
+}
+
+// write stores and notifies the queued events and deletes the old ones
+func (o *EventRelay) write(ctx context.Context) {
+	ticker := time.NewTicker(eventsRetention)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-o.queue:
+			if err := o.notify(ctx, e); err != nil {
+				o.logger.Printf("event relay: cannot relay event %s: %v", e.ID, err)
+			}
+		case <-ticker.C:
+			if err := o.prune(ctx); err != nil {
+				o.logger.Printf("event relay: cannot delete old events: %v", err)
+			}
+		}
+	}
+}
+
+// notify stores the event and notifies its id
+func (o *EventRelay) notify(ctx context.Context, e goappbuild.Event) error {
+	const q = `WITH e AS (
+			INSERT INTO realtime_events (payload) VALUES ($1) RETURNING id
+		)
+		SELECT pg_notify('` + eventsChannel + `', id::text) FROM e`
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.db.ExecContext(ctx, q, string(payload))
+
+	return err
+}
+
+// prune deletes the events that are no longer retained
+func (o *EventRelay) prune(ctx context.Context) error {
+	const q = `DELETE FROM realtime_events WHERE created_at < $1`
+
+	_, err := o.db.ExecContext(ctx, q, time.Now().UTC().Add(-eventsRetention))
+
+	return err
+}
+
+// listen publishes the notified events, reconnecting with a backoff
+func (o *EventRelay) listen(ctx context.Context) {
+	var cursor relayCursor
+
+	backoff := time.Second
+
+	for {
+		listening, err := o.session(ctx, &cursor)
+		if ctx.Err() != nil {
+			return
+		}
+
+		o.logger.Printf("event relay: listener stopped: %v", err)
+
+		if listening {
+			backoff = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxRelayBackoff {
+			backoff = maxRelayBackoff
+		}
+	}
+}
+
+// relayCursor is the position of the listener in the stored events.
+//
+// The ids of the events are assigned when they are inserted, so the events
+// of concurrent inserts can commit, and be notified, out of order. The cursor
+// remembers the published ids instead of the last one, and the listener reads
+// again the events stored a window before the latest published one when it
+// catches up.
+type relayCursor struct {
+	// since is the time of the first event read when catching up,
+	// it is zero until the first session starts
+	since time.Time
+	// seen are the creation times of the published events created after since
+	seen map[int64]time.Time
+}
+
+// published records an event and moves the window to the latest events
+func (o *relayCursor) published(id int64, createdAt time.Time) {
+	if o.seen == nil {
+		o.seen = make(map[int64]time.Time)
+	}
+
+	o.seen[id] = createdAt
+
+	since := createdAt.Add(-relayWindow)
+	if !since.After(o.since) {
+		return
+	}
+
+	o.since = since
+
+	for k, v := range o.seen {
+		if v.Before(since) {
+			delete(o.seen, k)
+		}
+	}
+}
+
+// session listens on a dedicated connection until it fails. It returns true
+// if it started listening.
+func (o *EventRelay) session(ctx context.Context, cursor *relayCursor) (bool, error) {
+	conn, err := o.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	defer conn.Close()
+
+	var listening bool
+
+	err = conn.Raw(func(driverConn any) error {
+		sc, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("the connection is not a pgx connection")
+		}
+
+		pc := sc.Conn()
+
+		if _, err := pc.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+			return err
+		}
+
+		listening = true
+
+		if err := o.catchUp(ctx, pc, cursor); err != nil {
+			return err
+		}
+
+		for {
+			n, err := pc.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+
+			id, err := strconv.ParseInt(n.Payload, 10, 64)
+			if err != nil {
+				o.logger.Printf("event relay: invalid notification %q", n.Payload)
+				continue
+			}
+
+			// the events read when catching up are notified again
+			if _, ok := cursor.seen[id]; ok {
+				continue
+			}
+
+			if err := o.publish(ctx, pc, cursor, `WHERE id = $1`, id); err != nil {
+				return err
+			}
+		}
+	})
+
+	return listening, err
+}
+
+// catchUp publishes the events the listener missed while it was not
+// listening, or starts from now the first time
+func (o *EventRelay) catchUp(ctx context.Context, pc *pgx.Conn, cursor *relayCursor) error {
+	if cursor.since.IsZero() {
+		return pc.QueryRow(ctx, `SELECT NOW()`).Scan(&cursor.since)
+	}
+
+	return o.publish(ctx, pc, cursor, `WHERE created_at >= $1 ORDER BY id`, cursor.since)
+}
+
+// publish publishes the stored events of the condition that were not
+// published yet to the cluster bus
+func (o *EventRelay) publish(ctx context.Context, pc *pgx.Conn, cursor *relayCursor, where string, args ...any) error {
+	rows, err := pc.Query(ctx, `SELECT id, created_at, payload FROM realtime_events `+where, args...)
+	if err != nil {
+		return err
+	}
+
+	var events []goappbuild.Event
+
+	for rows.Next() {
+		var (
+			id        int64
+			createdAt time.Time
+			payload   []byte
+		)
+
+		if err := rows.Scan(&id, &createdAt, &payload); err != nil {
+			rows.Close()
+			return err
+		}
+
+		if _, ok := cursor.seen[id]; ok {
+			continue
+		}
+
+		cursor.published(id, createdAt)
+
+		var e goappbuild.Event
+
+		if err := json.Unmarshal(payload, &e); err != nil {
+			o.logger.Printf("event relay: invalid event: %v", err)
+			continue
+		}
+
+		events = append(events, e)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	o.cluster.Publish(ctx, events...)
+
+	return nil
+}
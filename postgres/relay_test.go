@@ -0,0 +1,201 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/events"
+	"github.com/gosom/goappbuild/internal/pgtest"
+	"github.com/gosom/goappbuild/postgres"
+)
+
+func Test_EventRelay(t *testing.T) {
+	ctx := context.Background()
+
+	db := pgtest.New(t)
+
+	newEvent := func() goappbuild.Event {
+		return goappbuild.Event{
+			ID:         uuid.New(),
+			Type:       goappbuild.EventDocumentCreated,
+			ProjectID:  uuid.New(),
+			Collection: "notes",
+			DocumentID: uuid.NewString(),
+			OccurredAt: time.Now().UTC(),
+		}
+	}
+
+	// store stores the event like the relay does without notifying it
+	store := func(conn interface {
+		QueryRowContext(context.Context, string, ...any) *sql.Row
+	}, e goappbuild.Event) int64 {
+		t.Helper()
+
+		payload, err := json.Marshal(e)
+		require.NoError(t, err)
+
+		var id int64
+
+		err = conn.QueryRowContext(ctx, `INSERT INTO realtime_events (payload) VALUES ($1) RETURNING id`, string(payload)).Scan(&id)
+		require.NoError(t, err)
+
+		return id
+	}
+
+	notify := func(conn interface {
+		ExecContext(context.Context, string, ...any) (sql.Result, error)
+	}, id int64) {
+		t.Helper()
+
+		_, err := conn.ExecContext(ctx, `SELECT pg_notify('goappbuild_events', $1)`, strconv.FormatInt(id, 10))
+		require.NoError(t, err)
+	}
+
+	// an event stored before the relay starts
+	old := newEvent()
+	store(db, old)
+
+	local := events.New()
+	cluster := events.New()
+
+	received := make(chan uuid.UUID, 64)
+
+	cluster.Subscribe(func(_ context.Context, e goappbuild.Event) {
+		received <- e.ID
+	}, goappbuild.EventDocumentCreated)
+
+	relay := postgres.NewEventRelay(db, local, cluster, nil)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- relay.Run(runCtx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// wait returns the ids of the events received until the event
+	wait := func(id uuid.UUID) []uuid.UUID {
+		t.Helper()
+
+		var ids []uuid.UUID
+
+		timeout := time.After(10 * time.Second)
+
+		for {
+			select {
+			case got := <-received:
+				if got == id {
+					return ids
+				}
+
+				ids = append(ids, got)
+			case <-timeout:
+				t.Fatalf("event %s was not relayed", id)
+			}
+		}
+	}
+
+	// the events published before the relay listens are not relayed,
+	// so the test publishes events until one is relayed
+	var before []uuid.UUID
+
+	deadline := time.After(10 * time.Second)
+
+	for listening := false; !listening; {
+		probe := newEvent()
+		local.Publish(ctx, probe)
+
+		select {
+		case id := <-received:
+			before = append(before, id)
+			listening = id == probe.ID
+		case <-time.After(200 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("the relay is not listening")
+		}
+	}
+
+	t.Run("test the events stored before the start are not relayed", func(t *testing.T) {
+		require.NotContains(t, before, old.ID)
+	})
+
+	t.Run("test the local events are relayed", func(t *testing.T) {
+		e := newEvent()
+		local.Publish(ctx, e)
+
+		wait(e.ID)
+	})
+
+	// a and b are inserted in this order and committed in the reverse order
+	a, b := newEvent(), newEvent()
+
+	t.Run("test the events committed out of order are relayed", func(t *testing.T) {
+		txA, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+
+		defer txA.Rollback()
+
+		idA := store(txA, a)
+
+		txB, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+
+		defer txB.Rollback()
+
+		idB := store(txB, b)
+		require.Greater(t, idB, idA)
+
+		notify(txB, idB)
+		require.NoError(t, txB.Commit())
+
+		wait(b.ID)
+
+		notify(txA, idA)
+		require.NoError(t, txA.Commit())
+
+		wait(a.ID)
+	})
+
+	t.Run("test the listener catches up after reconnecting", func(t *testing.T) {
+		// the listener is the only connection that read a single event
+		const q = `SELECT pg_terminate_backend(pid)
+			FROM pg_stat_activity
+			WHERE datname = current_database()
+				AND pid <> pg_backend_pid()
+				AND query LIKE 'SELECT id, created_at, payload FROM realtime_events WHERE id = %'`
+
+		var terminated bool
+
+		require.NoError(t, db.QueryRowContext(ctx, q).Scan(&terminated))
+		require.True(t, terminated)
+
+		// stored and notified while the listener is disconnected
+		missed := newEvent()
+		notify(db, store(db, missed))
+
+		ids := wait(missed.ID)
+
+		// the events read again in the window are not relayed twice
+		probe := newEvent()
+		local.Publish(ctx, probe)
+
+		ids = append(ids, wait(probe.ID)...)
+
+		require.NotContains(t, ids, a.ID)
+		require.NotContains(t, ids, b.ID)
+		require.NotContains(t, ids, missed.ID)
+	})
+}
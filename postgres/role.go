@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// CheckRole returns an error if the role of the connection is a superuser or
+// bypasses row level security. The policies of the collection tables do not
+// apply to such roles, so the application must connect with a dedicated role.
+func CheckRole(ctx context.Context, db *sql.DB) error {
+	const q = `SELECT current_user, rolsuper, rolbypassrls FROM pg_roles WHERE rolname = current_user`
+
+	var (
+		name             string
+		super, bypassRLS bool
+	)
+
+	if err := db.QueryRowContext(ctx, q).Scan(&name, &super, &bypassRLS); err != nil {
+		return err
+	}
+
+	switch {
+	case super:
+		return fmt.Errorf("the database role %s is a superuser: row level security does not apply to it, connect with a role without SUPERUSER", name)
+	case bypassRLS:
+		return fmt.Errorf("the database role %s bypasses row level security, connect with a role without BYPASSRLS", name)
+	}
+
+	return nil
+}
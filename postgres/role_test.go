@@ -0,0 +1,28 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild/internal/pgtest"
+	"github.com/gosom/goappbuild/postgres"
+)
+
+func Test_CheckRole(t *testing.T) {
+	ctx := context.Background()
+
+	require.NoError(t, postgres.CheckRole(ctx, pgtest.New(t)))
+
+	admin := pgtest.Admin(t)
+
+	var super bool
+
+	err := admin.QueryRowContext(ctx, `SELECT rolsuper FROM pg_roles WHERE rolname = current_user`).Scan(&super)
+	require.NoError(t, err)
+
+	if super {
+		require.Error(t, postgres.CheckRole(ctx, admin))
+	}
+}
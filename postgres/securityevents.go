@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.SecurityEventRepo = (*securityEventRepo)(nil)
+
+type securityEventRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewSecurityEventRepo returns a new instance of a postgres security event repository
+func NewSecurityEventRepo(conn sqlext.DBTX) goappbuild.SecurityEventRepo {
+	return &securityEventRepo{
+		conn: conn,
+	}
+}
+
+// Create stores a new security event
+func (o *securityEventRepo) Create(ctx context.Context, e *goappbuild.SecurityEvent) error {
+	const q = `INSERT INTO security_events
+		(created_at, kind, user_id, project_id, actor_id, email, ip, detail)
+		VALUES ((NOW() at time zone 'utc'), $1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, kind, user_id, project_id, actor_id, email, ip, detail`
+
+	dbe, err := sqlext.QueryRow[dbSecurityEvent](
+		ctx, o.conn, q,
+		e.Kind,
+		nullUUID(e.UserID),
+		nullUUID(e.ProjectID),
+		nullUUID(e.ActorID),
+		e.Email,
+		e.IP,
+		e.Detail,
+	)
+	if err != nil {
+		return err
+	}
+
+	*e = dbe.toModel()
+
+	return nil
+}
+
+// List returns the matching security events, newest first
+func (o *securityEventRepo) List(
+	ctx context.Context,
+	filter goappbuild.SecurityEventFilter,
+) ([]goappbuild.SecurityEvent, error) {
+	const q = `SELECT id, created_at, kind, user_id, project_id, actor_id, email, ip, detail
+		FROM security_events
+		WHERE ($1 = '' OR kind = $1)
+			AND ($2 = '' OR email = $2)
+			AND ($3 = '' OR ip = $3)
+			AND ($4::uuid IS NULL OR project_id = $4)
+			AND ($5::uuid IS NULL OR user_id = $5)
+		ORDER BY created_at DESC
+		LIMIT $6`
+
+	items, err := sqlext.Query[dbSecurityEvent](
+		ctx, o.conn, q,
+		filter.Kind,
+		filter.Email,
+		filter.IP,
+		nullUUID(filter.ProjectID),
+		nullUUID(filter.UserID),
+		filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.SecurityEvent, len(items))
+
+	for i := range items {
+		ans[i] = items[i].toModel()
+	}
+
+	return ans, nil
+}
+
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+type dbSecurityEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Kind      string
+	UserID    uuid.NullUUID
+	ProjectID uuid.NullUUID
+	ActorID   uuid.NullUUID
+	Email     string
+	IP        string
+	Detail    string
+}
+
+func (o *dbSecurityEvent) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.Kind,
+		&o.UserID,
+		&o.ProjectID,
+		&o.ActorID,
+		&o.Email,
+		&o.IP,
+		&o.Detail,
+	}
+}
+
+func (o *dbSecurityEvent) toModel() goappbuild.SecurityEvent {
+	return goappbuild.SecurityEvent{
+		ID:        o.ID,
+		CreatedAt: o.CreatedAt,
+		Kind:      goappbuild.SecurityEventKind(o.Kind),
+		UserID:    o.UserID.UUID,
+		ProjectID: o.ProjectID.UUID,
+		ActorID:   o.ActorID.UUID,
+		Email:     o.Email,
+		IP:        o.IP,
+		Detail:    o.Detail,
+	}
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.SessionRepo = (*sessionRepo)(nil)
+
+type sessionRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewSessionRepo returns a new instance of a postgres end user session repository
+func NewSessionRepo(conn sqlext.DBTX) goappbuild.SessionRepo {
+	return &sessionRepo{
+		conn: conn,
+	}
+}
+
+// Create stores a new session
+func (o *sessionRepo) Create(ctx context.Context, s *goappbuild.Session) error {
+	const q = `INSERT INTO end_user_sessions
+		(created_at, project_id, end_user_id, token_hash, expires_at)
+		VALUES ((NOW() at time zone 'utc'), $1, $2, $3, $4)
+		RETURNING id, created_at, project_id, end_user_id, token_hash, expires_at, revoked_at`
+
+	dbs, err := sqlext.QueryRow[dbSession](ctx, o.conn, q, s.ProjectID, s.EndUserID, s.TokenHash, s.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	*s = dbs.toModel()
+
+	return nil
+}
+
+// GetByHash returns the session with the given token hash
+func (o *sessionRepo) GetByHash(ctx context.Context, hash string) (goappbuild.Session, error) {
+	const q = `SELECT
+			id, created_at, project_id, end_user_id, token_hash, expires_at, revoked_at
+		FROM end_user_sessions
+		WHERE token_hash = $1`
+
+	dbs, err := sqlext.QueryRow[dbSession](ctx, o.conn, q, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Session{}, goappbuild.Errorf(goappbuild.ENotFound, "session not found")
+	}
+
+	if err != nil {
+		return goappbuild.Session{}, err
+	}
+
+	return dbs.toModel(), nil
+}
+
+// Revoke revokes a session
+func (o *sessionRepo) Revoke(ctx context.Context, id uuid.UUID) error {
+	const q = `UPDATE end_user_sessions
+		SET revoked_at = (NOW() at time zone 'utc')
+		WHERE id = $1 AND revoked_at IS NULL`
+
+	_, err := o.conn.ExecContext(ctx, q, id)
+
+	return err
+}
+
+// RevokeEndUser revokes all the sessions of the end user
+func (o *sessionRepo) RevokeEndUser(ctx context.Context, endUserID uuid.UUID) error {
+	const q = `UPDATE end_user_sessions
+		SET revoked_at = (NOW() at time zone 'utc')
+		WHERE end_user_id = $1 AND revoked_at IS NULL`
+
+	_, err := o.conn.ExecContext(ctx, q, endUserID)
+
+	return err
+}
+
+type dbSession struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ProjectID uuid.UUID
+	EndUserID uuid.UUID
+	TokenHash string
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+func (o *dbSession) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.ProjectID,
+		&o.EndUserID,
+		&o.TokenHash,
+		&o.ExpiresAt,
+		&o.RevokedAt,
+	}
+}
+
+func (o *dbSession) toModel() goappbuild.Session {
+	return goappbuild.Session{
+		ID:        o.ID,
+		CreatedAt: o.CreatedAt,
+		ProjectID: o.ProjectID,
+		EndUserID: o.EndUserID,
+		TokenHash: o.TokenHash,
+		ExpiresAt: o.ExpiresAt,
+		RevokedAt: nullTime(o.RevokedAt),
+	}
+}
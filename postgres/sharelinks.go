@@ -0,0 +1,250 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.ShareLinkRepo = (*shareLinkRepo)(nil)
+
+const shareLinkColumns = `id, created_at, project_id, collection, document_id, filters,
+	created_by, expires_at, revoked_at`
+
+type shareLinkRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewShareLinkRepo returns a new instance of a postgres share link repository
+func NewShareLinkRepo(conn sqlext.DBTX) goappbuild.ShareLinkRepo {
+	return &shareLinkRepo{
+		conn: conn,
+	}
+}
+
+// Create stores a new share link
+func (o *shareLinkRepo) Create(ctx context.Context, l *goappbuild.ShareLink) error {
+	filters := l.Filters
+	if filters == nil {
+		filters = []goappbuild.Filter{}
+	}
+
+	filtersJson, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO share_links
+		(created_at, project_id, collection, document_id, filters, created_by, expires_at)
+		VALUES ((NOW() at time zone 'utc'), $1, $2, $3, $4, $5, $6)
+		RETURNING ` + shareLinkColumns
+
+	dbl, err := sqlext.QueryRow[dbShareLink](
+		ctx, o.conn, q,
+		l.ProjectID, l.Collection, l.DocumentID, filtersJson, nullUUID(l.CreatedBy), l.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	ans, err := dbl.toModel()
+	if err != nil {
+		return err
+	}
+
+	*l = ans
+
+	return nil
+}
+
+// Get returns a share link
+func (o *shareLinkRepo) Get(ctx context.Context, id uuid.UUID) (goappbuild.ShareLink, error) {
+	q := `SELECT ` + shareLinkColumns + `
+		FROM share_links
+		WHERE id = $1`
+
+	dbl, err := sqlext.QueryRow[dbShareLink](ctx, o.conn, q, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.ShareLink{}, goappbuild.Errorf(goappbuild.ENotFound, "share link not found")
+	}
+
+	if err != nil {
+		return goappbuild.ShareLink{}, err
+	}
+
+	return dbl.toModel()
+}
+
+// List returns the share links of a collection, newest first
+func (o *shareLinkRepo) List(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collection string,
+) ([]goappbuild.ShareLink, error) {
+	q := `SELECT ` + shareLinkColumns + `
+		FROM share_links
+		WHERE project_id = $1 AND collection = $2
+		ORDER BY created_at DESC`
+
+	items, err := sqlext.Query[dbShareLink](ctx, o.conn, q, projectID, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.ShareLink, len(items))
+
+	for i := range items {
+		ans[i], err = items[i].toModel()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ans, nil
+}
+
+// Revoke revokes a share link of a project
+func (o *shareLinkRepo) Revoke(ctx context.Context, projectID, id uuid.UUID) error {
+	const q = `UPDATE share_links
+		SET revoked_at = COALESCE(revoked_at, (NOW() at time zone 'utc'))
+		WHERE project_id = $1 AND id = $2`
+
+	res, err := o.conn.ExecContext(ctx, q, projectID, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "share link not found")
+	}
+
+	return nil
+}
+
+// CreateAccess logs an access of a share link
+func (o *shareLinkRepo) CreateAccess(ctx context.Context, a *goappbuild.ShareAccess) error {
+	const q = `INSERT INTO share_link_accesses
+		(created_at, link_id, ip, user_agent)
+		VALUES ((NOW() at time zone 'utc'), $1, $2, $3)
+		RETURNING id, created_at, link_id, ip, user_agent`
+
+	dba, err := sqlext.QueryRow[dbShareAccess](ctx, o.conn, q, a.LinkID, a.IP, a.UserAgent)
+	if err != nil {
+		return err
+	}
+
+	*a = dba.toModel()
+
+	return nil
+}
+
+// ListAccesses returns the latest accesses of a share link, newest first
+func (o *shareLinkRepo) ListAccesses(
+	ctx context.Context,
+	linkID uuid.UUID,
+	limit int,
+) ([]goappbuild.ShareAccess, error) {
+	const q = `SELECT id, created_at, link_id, ip, user_agent
+		FROM share_link_accesses
+		WHERE link_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`
+
+	items, err := sqlext.Query[dbShareAccess](ctx, o.conn, q, linkID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.ShareAccess, len(items))
+
+	for i := range items {
+		ans[i] = items[i].toModel()
+	}
+
+	return ans, nil
+}
+
+type dbShareLink struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ProjectID  uuid.UUID
+	Collection string
+	DocumentID string
+	Filters    []byte
+	CreatedBy  uuid.NullUUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+}
+
+func (o *dbShareLink) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.ProjectID,
+		&o.Collection,
+		&o.DocumentID,
+		&o.Filters,
+		&o.CreatedBy,
+		&o.ExpiresAt,
+		&o.RevokedAt,
+	}
+}
+
+func (o *dbShareLink) toModel() (goappbuild.ShareLink, error) {
+	ans := goappbuild.ShareLink{
+		ID:         o.ID,
+		CreatedAt:  o.CreatedAt,
+		ProjectID:  o.ProjectID,
+		Collection: o.Collection,
+		DocumentID: o.DocumentID,
+		CreatedBy:  o.CreatedBy.UUID,
+		ExpiresAt:  o.ExpiresAt,
+		RevokedAt:  nullTime(o.RevokedAt),
+	}
+
+	if err := json.Unmarshal(o.Filters, &ans.Filters); err != nil {
+		return goappbuild.ShareLink{}, err
+	}
+
+	return ans, nil
+}
+
+type dbShareAccess struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	LinkID    uuid.UUID
+	IP        string
+	UserAgent string
+}
+
+func (o *dbShareAccess) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.LinkID,
+		&o.IP,
+		&o.UserAgent,
+	}
+}
+
+func (o *dbShareAccess) toModel() goappbuild.ShareAccess {
+	return goappbuild.ShareAccess{
+		ID:        o.ID,
+		CreatedAt: o.CreatedAt,
+		LinkID:    o.LinkID,
+		IP:        o.IP,
+		UserAgent: o.UserAgent,
+	}
+}
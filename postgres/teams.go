@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.TeamRepo = (*teamRepo)(nil)
+
+var errTeamNotFound = goappbuild.Errorf(goappbuild.ENotFound, "team not found")
+
+type teamRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewTeamRepo returns a new instance of a postgres team repository
+func NewTeamRepo(conn sqlext.DBTX) goappbuild.TeamRepo {
+	return &teamRepo{
+		conn: conn,
+	}
+}
+
+// Create creates a team
+func (o *teamRepo) Create(ctx context.Context, t *goappbuild.Team) error {
+	const q = `INSERT INTO teams
+		(created_at, updated_at, project_id, name)
+		VALUES ((NOW() at time zone 'utc'), (NOW() at time zone 'utc'), $1, $2)
+		RETURNING id, created_at, updated_at, project_id, name`
+
+	dbt, err := sqlext.QueryRow[dbTeam](ctx, o.conn, q, t.ProjectID, t.Name)
+	if isUniqueViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "team %s already exists", t.Name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*t = dbt.toModel()
+
+	return nil
+}
+
+// Get returns the team of the project
+func (o *teamRepo) Get(ctx context.Context, projectID, id uuid.UUID) (goappbuild.Team, error) {
+	const q = `SELECT id, created_at, updated_at, project_id, name
+		FROM teams
+		WHERE project_id = $1 AND id = $2`
+
+	dbt, err := sqlext.QueryRow[dbTeam](ctx, o.conn, q, projectID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Team{}, errTeamNotFound
+	}
+
+	if err != nil {
+		return goappbuild.Team{}, err
+	}
+
+	return dbt.toModel(), nil
+}
+
+// List returns the teams of the project ordered by name
+func (o *teamRepo) List(ctx context.Context, projectID uuid.UUID) ([]goappbuild.Team, error) {
+	const q = `SELECT id, created_at, updated_at, project_id, name
+		FROM teams
+		WHERE project_id = $1
+		ORDER BY name`
+
+	items, err := sqlext.Query[dbTeam](ctx, o.conn, q, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.Team, len(items))
+	for i := range items {
+		ans[i] = items[i].toModel()
+	}
+
+	return ans, nil
+}
+
+// Delete deletes the team, the members are removed with it
+func (o *teamRepo) Delete(ctx context.Context, projectID, id uuid.UUID) error {
+	const q = `DELETE FROM teams WHERE project_id = $1 AND id = $2`
+
+	res, err := o.conn.ExecContext(ctx, q, projectID, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return errTeamNotFound
+	}
+
+	return nil
+}
+
+// AddMember adds the user to the team, adding a member again is a no-op
+func (o *teamRepo) AddMember(ctx context.Context, teamID, userID uuid.UUID) error {
+	const q = `INSERT INTO team_members
+		(team_id, user_id, created_at)
+		VALUES ($1, $2, (NOW() at time zone 'utc'))
+		ON CONFLICT (team_id, user_id) DO NOTHING`
+
+	_, err := o.conn.ExecContext(ctx, q, teamID, userID)
+	if isForeignKeyViolation(err) {
+		return errTeamNotFound
+	}
+
+	return err
+}
+
+// RemoveMember removes the user from the team
+func (o *teamRepo) RemoveMember(ctx context.Context, teamID, userID uuid.UUID) error {
+	const q = `DELETE FROM team_members WHERE team_id = $1 AND user_id = $2`
+
+	res, err := o.conn.ExecContext(ctx, q, teamID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "team member not found")
+	}
+
+	return nil
+}
+
+// ListMembers returns the members of the team, oldest first
+func (o *teamRepo) ListMembers(ctx context.Context, teamID uuid.UUID) ([]goappbuild.TeamMember, error) {
+	const q = `SELECT team_id, user_id, created_at
+		FROM team_members
+		WHERE team_id = $1
+		ORDER BY created_at, user_id`
+
+	items, err := sqlext.Query[dbTeamMember](ctx, o.conn, q, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.TeamMember, len(items))
+	for i := range items {
+		ans[i] = items[i].toModel()
+	}
+
+	return ans, nil
+}
+
+type dbTeam struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ProjectID uuid.UUID
+	Name      string
+}
+
+func (o *dbTeam) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.UpdatedAt,
+		&o.ProjectID,
+		&o.Name,
+	}
+}
+
+func (o *dbTeam) toModel() goappbuild.Team {
+	return goappbuild.Team{
+		ID:        o.ID,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+		ProjectID: o.ProjectID,
+		Name:      o.Name,
+	}
+}
+
+type dbTeamMember struct {
+	TeamID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (o *dbTeamMember) Bind() []any {
+	return []any{
+		&o.TeamID,
+		&o.UserID,
+		&o.CreatedAt,
+	}
+}
+
+func (o *dbTeamMember) toModel() goappbuild.TeamMember {
+	return goappbuild.TeamMember{
+		TeamID:    o.TeamID,
+		UserID:    o.UserID,
+		CreatedAt: o.CreatedAt,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 
+	"github.com/google/uuid"
 	"github.com/gosom/goappbuild"
 )
 
@@ -18,6 +19,23 @@ type storage struct {
 	collections goappbuild.CollectionRepo
 	databases   goappbuild.DatabaseRepo
 	queries     goappbuild.QueryRepo
+	history     goappbuild.HistoryRepo
+	tokens      goappbuild.RefreshTokenRepo
+	apiKeys     goappbuild.APIKeyRepo
+	members     goappbuild.MemberRepo
+	invitations goappbuild.InvitationRepo
+	endUsers    goappbuild.EndUserRepo
+	sessions    goappbuild.SessionRepo
+	providers   goappbuild.IdentityProviderRepo
+	authReqs    goappbuild.AuthRequestRepo
+	identities  goappbuild.ExternalIdentityRepo
+	mfa         goappbuild.MFARepo
+	userTokens  goappbuild.UserTokenRepo
+	counters    goappbuild.LoginCounterRepo
+	events      goappbuild.SecurityEventRepo
+	shareLinks  goappbuild.ShareLinkRepo
+	acl         goappbuild.ACLRepo
+	teams       goappbuild.TeamRepo
 }
 
 func NewUnitOfWork(db *sql.DB) goappbuild.Storage {
@@ -28,15 +46,40 @@ func NewUnitOfWork(db *sql.DB) goappbuild.Storage {
 		collections: NewCollectionRepo(db),
 		databases:   NewDBRepo(db),
 		queries:     NewQueryRepo(db),
+		history:     NewHistoryRepo(db),
+		tokens:      NewRefreshTokenRepo(db),
+		apiKeys:     NewAPIKeyRepo(db),
+		members:     NewMemberRepo(db),
+		invitations: NewInvitationRepo(db),
+		endUsers:    NewEndUserRepo(db),
+		sessions:    NewSessionRepo(db),
+		providers:   NewIdentityProviderRepo(db),
+		authReqs:    NewAuthRequestRepo(db),
+		identities:  NewExternalIdentityRepo(db),
+		mfa:         NewMFARepo(db),
+		userTokens:  NewUserTokenRepo(db),
+		counters:    NewLoginCounterRepo(db),
+		events:      NewSecurityEventRepo(db),
+		shareLinks:  NewShareLinkRepo(db),
+		acl:         NewACLRepo(db),
+		teams:       NewTeamRepo(db),
 	}
 }
 
+// New begins a transaction and sets the caller of the context for the row
+// level security policies of the collection tables
 func (uw *storage) New(ctx context.Context) (goappbuild.Storage, error) {
 	tx, err := uw.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := setCaller(ctx, tx); err != nil {
+		_ = tx.Rollback()
+
+		return nil, err
+	}
+
 	ans := storage{
 		db:          uw.db,
 		tx:          tx,
@@ -44,6 +87,24 @@ func (uw *storage) New(ctx context.Context) (goappbuild.Storage, error) {
 		projects:    NewProjectRepo(tx),
 		collections: NewCollectionRepo(tx),
 		databases:   NewDBRepo(tx),
+		queries:     NewQueryRepo(tx),
+		history:     NewHistoryRepo(tx),
+		tokens:      NewRefreshTokenRepo(tx),
+		apiKeys:     NewAPIKeyRepo(tx),
+		members:     NewMemberRepo(tx),
+		invitations: NewInvitationRepo(tx),
+		endUsers:    NewEndUserRepo(tx),
+		sessions:    NewSessionRepo(tx),
+		providers:   NewIdentityProviderRepo(tx),
+		authReqs:    NewAuthRequestRepo(tx),
+		identities:  NewExternalIdentityRepo(tx),
+		mfa:         NewMFARepo(tx),
+		userTokens:  NewUserTokenRepo(tx),
+		counters:    NewLoginCounterRepo(tx),
+		events:      NewSecurityEventRepo(tx),
+		shareLinks:  NewShareLinkRepo(tx),
+		acl:         NewACLRepo(tx),
+		teams:       NewTeamRepo(tx),
 	}
 
 	return &ans, nil
@@ -76,3 +137,106 @@ func (uw *storage) Databases() goappbuild.DatabaseRepo {
 func (uw *storage) Queries() goappbuild.QueryRepo {
 	return uw.queries
 }
+
+func (uw *storage) History() goappbuild.HistoryRepo {
+	return uw.history
+}
+
+func (uw *storage) RefreshTokens() goappbuild.RefreshTokenRepo {
+	return uw.tokens
+}
+
+func (uw *storage) APIKeys() goappbuild.APIKeyRepo {
+	return uw.apiKeys
+}
+
+func (uw *storage) Members() goappbuild.MemberRepo {
+	return uw.members
+}
+
+func (uw *storage) Invitations() goappbuild.InvitationRepo {
+	return uw.invitations
+}
+
+func (uw *storage) EndUsers() goappbuild.EndUserRepo {
+	return uw.endUsers
+}
+
+func (uw *storage) Sessions() goappbuild.SessionRepo {
+	return uw.sessions
+}
+
+func (uw *storage) IdentityProviders() goappbuild.IdentityProviderRepo {
+	return uw.providers
+}
+
+func (uw *storage) AuthRequests() goappbuild.AuthRequestRepo {
+	return uw.authReqs
+}
+
+func (uw *storage) ExternalIdentities() goappbuild.ExternalIdentityRepo {
+	return uw.identities
+}
+
+func (uw *storage) MFA() goappbuild.MFARepo {
+	return uw.mfa
+}
+
+func (uw *storage) UserTokens() goappbuild.UserTokenRepo {
+	return uw.userTokens
+}
+
+func (uw *storage) LoginCounters() goappbuild.LoginCounterRepo {
+	return uw.counters
+}
+
+func (uw *storage) SecurityEvents() goappbuild.SecurityEventRepo {
+	return uw.events
+}
+
+func (uw *storage) ShareLinks() goappbuild.ShareLinkRepo {
+	return uw.shareLinks
+}
+
+func (uw *storage) ACL() goappbuild.ACLRepo {
+	return uw.acl
+}
+
+func (uw *storage) Teams() goappbuild.TeamRepo {
+	return uw.teams
+}
+
+// setCaller sets the identity of the context as transaction local settings
+// (the equivalent of SET LOCAL), so they are reset on commit or rollback.
+// Without an identity the caller is anonymous.
+func setCaller(ctx context.Context, tx *sql.Tx) error {
+	var userID, userProject, projectID, system string
+
+	if identity, ok := goappbuild.IdentityFromContext(ctx); ok {
+		if identity.UserID != uuid.Nil {
+			userID = identity.UserID.String()
+		}
+
+		if identity.IsEndUser() {
+			userProject = identity.ProjectID.String()
+		}
+
+		if identity.IsAPIKey() {
+			projectID = identity.ProjectID.String()
+		}
+	}
+
+	if goappbuild.IsSystem(ctx) {
+		system = "on"
+	}
+
+	const q = `SELECT
+		set_config('goappbuild.user_id', $1, true),
+		set_config('goappbuild.user_project', $2, true),
+		set_config('goappbuild.project_id', $3, true),
+		set_config('goappbuild.system', $4, true)`
+
+	_, err := tx.ExecContext(ctx, q, userID, userProject, projectID, system)
+
+	return err
+}
@@ -0,0 +1,143 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/postgres"
+)
+
+// recorder is a database driver that records the statements it executes
+type recorder struct {
+	mu    sync.Mutex
+	execs [][]any
+}
+
+func (o *recorder) Open(string) (driver.Conn, error) {
+	return &recorderConn{recorder: o}, nil
+}
+
+func (o *recorder) last() []any {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.execs) == 0 {
+		return nil
+	}
+
+	return o.execs[len(o.execs)-1]
+}
+
+type recorderConn struct {
+	recorder *recorder
+}
+
+func (o *recorderConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (o *recorderConn) Close() error {
+	return nil
+}
+
+func (o *recorderConn) Begin() (driver.Tx, error) {
+	return o, nil
+}
+
+func (o *recorderConn) Commit() error {
+	return nil
+}
+
+func (o *recorderConn) Rollback() error {
+	return nil
+}
+
+func (o *recorderConn) ExecContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]any, len(args))
+	for i := range args {
+		values[i] = args[i].Value
+	}
+
+	o.recorder.mu.Lock()
+	o.recorder.execs = append(o.recorder.execs, values)
+	o.recorder.mu.Unlock()
+
+	return driver.RowsAffected(0), nil
+}
+
+var recorderDriver = &recorder{}
+
+func init() {
+	sql.Register("goappbuild_recorder", recorderDriver)
+}
+
+func Test_UnitOfWork_SetsCaller(t *testing.T) {
+	db, err := sql.Open("goappbuild_recorder", "")
+	require.NoError(t, err)
+
+	defer db.Close()
+
+	storage := postgres.NewUnitOfWork(db)
+
+	userID, projectID := uuid.New(), uuid.New()
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		expected []any
+	}{
+		{
+			name:     "anonymous",
+			ctx:      context.Background(),
+			expected: []any{"", "", "", ""},
+		},
+		{
+			name:     "platform user",
+			ctx:      goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: userID}),
+			expected: []any{userID.String(), "", "", ""},
+		},
+		{
+			name: "end user",
+			ctx: goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{
+				UserID:    userID,
+				SessionID: uuid.New(),
+				ProjectID: projectID,
+			}),
+			expected: []any{userID.String(), projectID.String(), "", ""},
+		},
+		{
+			name: "api key",
+			ctx: goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{
+				APIKeyID:  uuid.New(),
+				ProjectID: projectID,
+			}),
+			expected: []any{"", "", projectID.String(), ""},
+		},
+		{
+			name:     "system",
+			ctx:      goappbuild.ContextWithSystem(context.Background()),
+			expected: []any{"", "", "", "on"},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			uw, err := storage.New(tc.ctx)
+			require.NoError(t, err)
+
+			defer uw.Rollback(tc.ctx)
+
+			require.Equal(t, tc.expected, recorderDriver.last())
+		})
+	}
+}
@@ -2,6 +2,9 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,11 +29,15 @@ func NewUserRepo(conn sqlext.DBTX) goappbuild.UserRepo {
 // Create creates a new user in the database
 func (o *userRepo) Create(ctx context.Context, u *goappbuild.User) error {
 	const q = `INSERT INTO users
-		(created_at, updated_at)
-		VALUES ((NOW() at time zone 'utc'), (NOW() at time zone 'utc'))
-		RETURNING id, created_at, updated_at`
+		(created_at, updated_at, email, password_hash)
+		VALUES ((NOW() at time zone 'utc'), (NOW() at time zone 'utc'), $1, $2)
+		RETURNING id, created_at, updated_at, email, password_hash, email_verified_at, is_admin, disabled_at, tokens_revoked_at`
+
+	dbu, err := sqlext.QueryRow[dbUser](ctx, o.conn, q, u.Email, u.PasswordHash)
+	if isUniqueViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "email is already registered")
+	}
 
-	dbu, err := sqlext.QueryRow[dbUser](ctx, o.conn, q)
 	if err != nil {
 		return err
 	}
@@ -42,10 +49,135 @@ func (o *userRepo) Create(ctx context.Context, u *goappbuild.User) error {
 	return nil
 }
 
+// Get returns the user with the given id
+func (o *userRepo) Get(ctx context.Context, id uuid.UUID) (goappbuild.User, error) {
+	const q = `SELECT
+			id, created_at, updated_at, email, password_hash, email_verified_at, is_admin, disabled_at, tokens_revoked_at
+		FROM users
+		WHERE id = $1`
+
+	dbu, err := sqlext.QueryRow[dbUser](ctx, o.conn, q, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.User{}, goappbuild.Errorf(goappbuild.ENotFound, "user not found")
+	}
+
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	return dbu.toModel(), nil
+}
+
+// GetByEmail returns the user with the given email
+func (o *userRepo) GetByEmail(ctx context.Context, email string) (goappbuild.User, error) {
+	const q = `SELECT
+			id, created_at, updated_at, email, password_hash, email_verified_at, is_admin, disabled_at, tokens_revoked_at
+		FROM users
+		WHERE email = $1`
+
+	dbu, err := sqlext.QueryRow[dbUser](ctx, o.conn, q, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.User{}, goappbuild.Errorf(goappbuild.ENotFound, "user not found")
+	}
+
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	return dbu.toModel(), nil
+}
+
+// Update stores the email, the password hash, the email verification,
+// the disabled time and the token revocation time of the user
+func (o *userRepo) Update(ctx context.Context, u *goappbuild.User) error {
+	const q = `UPDATE users
+		SET updated_at = (NOW() at time zone 'utc'), email = $2, password_hash = $3,
+			email_verified_at = $4, disabled_at = $5, tokens_revoked_at = $6
+		WHERE id = $1
+		RETURNING id, created_at, updated_at, email, password_hash, email_verified_at, is_admin, disabled_at, tokens_revoked_at`
+
+	dbu, err := sqlext.QueryRow[dbUser](
+		ctx, o.conn, q,
+		u.ID, u.Email, u.PasswordHash, u.EmailVerifiedAt, u.DisabledAt, u.TokensRevokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.Errorf(goappbuild.ENotFound, "user not found")
+	}
+
+	if isUniqueViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "email is already registered")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*u = dbu.toModel()
+
+	return nil
+}
+
+// List returns the matching users ordered by email
+func (o *userRepo) List(ctx context.Context, filter goappbuild.UserFilter) ([]goappbuild.User, error) {
+	const q = `SELECT
+			id, created_at, updated_at, email, password_hash, email_verified_at, is_admin, disabled_at, tokens_revoked_at
+		FROM users
+		WHERE ($1 = '' OR email ILIKE '%' || $1 || '%')
+			AND ($2::boolean IS NULL OR (disabled_at IS NOT NULL) = $2)
+		ORDER BY email, id
+		LIMIT $3 OFFSET $4`
+
+	search := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Search)
+
+	items, err := sqlext.Query[dbUser](ctx, o.conn, q, search, filter.Disabled, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.User, len(items))
+	for i := range items {
+		ans[i] = items[i].toModel()
+	}
+
+	return ans, nil
+}
+
+// Delete deletes a user, the tokens and the memberships of the user
+// are removed with it
+func (o *userRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	const q = `DELETE FROM users WHERE id = $1`
+
+	res, err := o.conn.ExecContext(ctx, q, id)
+	if isForeignKeyViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "the user owns projects")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "user not found")
+	}
+
+	return nil
+}
+
 type dbUser struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           sql.NullString
+	PasswordHash    sql.NullString
+	EmailVerifiedAt sql.NullTime
+	IsAdmin         bool
+	DisabledAt      sql.NullTime
+	TokensRevokedAt sql.NullTime
 }
 
 func (o *dbUser) Bind() []any {
@@ -53,13 +185,25 @@ func (o *dbUser) Bind() []any {
 		&o.ID,
 		&o.CreatedAt,
 		&o.UpdatedAt,
+		&o.Email,
+		&o.PasswordHash,
+		&o.EmailVerifiedAt,
+		&o.IsAdmin,
+		&o.DisabledAt,
+		&o.TokensRevokedAt,
 	}
 }
 
 func (o *dbUser) toModel() goappbuild.User {
 	return goappbuild.User{
-		ID:        o.ID,
-		CreatedAt: o.CreatedAt,
-		UpdatedAt: o.UpdatedAt,
+		ID:              o.ID,
+		CreatedAt:       o.CreatedAt,
+		UpdatedAt:       o.UpdatedAt,
+		Email:           o.Email.String,
+		PasswordHash:    o.PasswordHash.String,
+		EmailVerifiedAt: nullTime(o.EmailVerifiedAt),
+		IsAdmin:         o.IsAdmin,
+		DisabledAt:      nullTime(o.DisabledAt),
+		TokensRevokedAt: nullTime(o.TokensRevokedAt),
 	}
 }
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/pkg/sqlext"
+)
+
+var _ goappbuild.UserTokenRepo = (*userTokenRepo)(nil)
+
+type userTokenRepo struct {
+	conn sqlext.DBTX
+}
+
+// NewUserTokenRepo returns a new instance of a postgres user token repository
+func NewUserTokenRepo(conn sqlext.DBTX) goappbuild.UserTokenRepo {
+	return &userTokenRepo{
+		conn: conn,
+	}
+}
+
+// Create stores a new user token.
+// The tokens of the user that expired before the retention are removed at the same time.
+func (o *userTokenRepo) Create(ctx context.Context, t *goappbuild.UserToken) error {
+	const cleanup = `DELETE FROM user_tokens
+		WHERE user_id = $1 AND expires_at < $2`
+
+	retention := time.Now().UTC().Add(-goappbuild.UserTokenRetention)
+
+	if _, err := o.conn.ExecContext(ctx, cleanup, t.UserID, retention); err != nil {
+		return err
+	}
+
+	const q = `INSERT INTO user_tokens
+		(created_at, user_id, purpose, token_hash, expires_at)
+		VALUES ((NOW() at time zone 'utc'), $1, $2, $3, $4)
+		RETURNING id, created_at, user_id, purpose, token_hash, expires_at, used_at`
+
+	dbt, err := sqlext.QueryRow[dbUserToken](ctx, o.conn, q, t.UserID, t.Purpose, t.TokenHash, t.ExpiresAt)
+	if isUniqueViolation(err) {
+		return goappbuild.Errorf(goappbuild.EConflict, "token already exists")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*t = dbt.toModel()
+
+	return nil
+}
+
+// Count returns the number of tokens of the user with one of the purposes created after since
+func (o *userTokenRepo) Count(
+	ctx context.Context,
+	userID uuid.UUID,
+	since time.Time,
+	purposes ...goappbuild.TokenPurpose,
+) (int, error) {
+	const q = `SELECT COUNT(*) FROM user_tokens
+		WHERE user_id = $1 AND created_at > $2 AND purpose = ANY($3)`
+
+	items := make([]string, len(purposes))
+	for i := range purposes {
+		items[i] = string(purposes[i])
+	}
+
+	var ans int
+	if err := o.conn.QueryRowContext(ctx, q, userID, since, items).Scan(&ans); err != nil {
+		return 0, err
+	}
+
+	return ans, nil
+}
+
+// Use marks the unused and unexpired token as used and returns it
+func (o *userTokenRepo) Use(
+	ctx context.Context,
+	purpose goappbuild.TokenPurpose,
+	hash string,
+) (goappbuild.UserToken, error) {
+	const q = `UPDATE user_tokens
+		SET used_at = (NOW() at time zone 'utc')
+		WHERE purpose = $1 AND token_hash = $2
+			AND used_at IS NULL AND expires_at > (NOW() at time zone 'utc')
+		RETURNING id, created_at, user_id, purpose, token_hash, expires_at, used_at`
+
+	dbt, err := sqlext.QueryRow[dbUserToken](ctx, o.conn, q, purpose, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return goappbuild.UserToken{}, goappbuild.Errorf(goappbuild.ENotFound, "token not found")
+	}
+
+	if err != nil {
+		return goappbuild.UserToken{}, err
+	}
+
+	return dbt.toModel(), nil
+}
+
+type dbUserToken struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+func (o *dbUserToken) Bind() []any {
+	return []any{
+		&o.ID,
+		&o.CreatedAt,
+		&o.UserID,
+		&o.Purpose,
+		&o.TokenHash,
+		&o.ExpiresAt,
+		&o.UsedAt,
+	}
+}
+
+func (o *dbUserToken) toModel() goappbuild.UserToken {
+	return goappbuild.UserToken{
+		ID:        o.ID,
+		CreatedAt: o.CreatedAt,
+		UserID:    o.UserID,
+		Purpose:   goappbuild.TokenPurpose(o.Purpose),
+		TokenHash: o.TokenHash,
+		ExpiresAt: o.ExpiresAt,
+		UsedAt:    nullTime(o.UsedAt),
+	}
+}
@@ -5,21 +5,48 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
 )
 
 var _ goappbuild.ProjectService = (*projectService)(nil)
 
 type projectService struct {
 	storage goappbuild.Storage
+	events  goappbuild.EventBus
 }
 
-func New(storage goappbuild.Storage) goappbuild.ProjectService {
-	return &projectService{
+// Option configures the project service
+type Option func(*projectService)
+
+// WithEvents publishes the creation of the projects to the bus
+func WithEvents(bus goappbuild.EventBus) Option {
+	return func(s *projectService) {
+		s.events = bus
+	}
+}
+
+func New(storage goappbuild.Storage, opts ...Option) goappbuild.ProjectService {
+	ans := projectService{
 		storage: storage,
 	}
+
+	for _, opt := range opts {
+		opt(&ans)
+	}
+
+	return &ans
 }
 
 func (s *projectService) Create(ctx context.Context, req goappbuild.CreateProjectRequest) (goappbuild.Project, error) {
+	identity, err := authz.User(ctx)
+	if err != nil {
+		return goappbuild.Project{}, err
+	}
+
+	if req.UserID != identity.UserID {
+		return goappbuild.Project{}, goappbuild.Errorf(goappbuild.EForbidden, "projects can only be created for the authenticated user")
+	}
+
 	p := goappbuild.Project{
 		UserID: req.UserID,
 		Name:   req.Name,
@@ -36,6 +63,16 @@ func (s *projectService) Create(ctx context.Context, req goappbuild.CreateProjec
 		return goappbuild.Project{}, err
 	}
 
+	owner := goappbuild.ProjectMember{
+		ProjectID: p.ID,
+		UserID:    p.UserID,
+		Role:      goappbuild.RoleOwner,
+	}
+
+	if err := uw.Members().Create(ctx, &owner); err != nil {
+		return goappbuild.Project{}, err
+	}
+
 	if err := uw.Databases().CreateSchema(ctx, p.SchemaName()); err != nil {
 		return goappbuild.Project{}, err
 	}
@@ -44,9 +81,16 @@ func (s *projectService) Create(ctx context.Context, req goappbuild.CreateProjec
 		return goappbuild.Project{}, err
 	}
 
+	if s.events != nil {
+		e := goappbuild.NewEvent(ctx, goappbuild.EventProjectCreated, p.ID)
+		e.After = p
+
+		s.events.Publish(ctx, e)
+	}
+
 	return p, nil
 }
 
 func (s *projectService) Get(ctx context.Context, id uuid.UUID) (goappbuild.Project, error) {
-	return s.storage.Projects().Get(ctx, id)
+	return authz.Project(ctx, s.storage, id, goappbuild.RoleViewer)
 }
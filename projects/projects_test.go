@@ -0,0 +1,42 @@
+package projects_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/projects"
+)
+
+func Test_ProjectService(t *testing.T) {
+	storage := memstore.New()
+	svc := projects.New(storage)
+
+	ownerID := uuid.New()
+	owner := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: ownerID})
+	other := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: uuid.New()})
+
+	t.Run("test create for another user is forbidden", func(t *testing.T) {
+		_, err := svc.Create(other, goappbuild.CreateProjectRequest{UserID: ownerID, Name: "p"})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test only the owner can get the project", func(t *testing.T) {
+		p, err := svc.Create(owner, goappbuild.CreateProjectRequest{UserID: ownerID, Name: "p"})
+		require.NoError(t, err)
+
+		got, err := svc.Get(owner, p.ID)
+		require.NoError(t, err)
+		require.Equal(t, p.ID, got.ID)
+
+		_, err = svc.Get(other, p.ID)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.Get(context.Background(), p.ID)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+}
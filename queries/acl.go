@@ -0,0 +1,239 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+)
+
+var _ goappbuild.ACLService = (*aclService)(nil)
+
+type aclService struct {
+	storage goappbuild.Storage
+}
+
+// NewACLService returns a new service that shares documents with specific
+// users and teams. The entries are enforced by the queries of the query service.
+func NewACLService(storage goappbuild.Storage) goappbuild.ACLService {
+	return &aclService{
+		storage: storage,
+	}
+}
+
+// Share grants the permission on a document to a platform user, an end user
+// or a team of the project. The caller must be allowed to write the collection
+// as a member or an API key.
+func (s *aclService) Share(ctx context.Context, req goappbuild.ShareDocumentRequest) (goappbuild.ACLEntry, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.ACLEntry{}, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.ACLEntry{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := s.authorize(ctx, uw, req.ProjectID, req.Collection)
+	if err != nil {
+		return goappbuild.ACLEntry{}, err
+	}
+
+	documentID, err := s.document(ctx, uw, t, req.DocumentID)
+	if err != nil {
+		return goappbuild.ACLEntry{}, err
+	}
+
+	if req.PrincipalType == "" {
+		req.PrincipalType = goappbuild.PrincipalUser
+	}
+
+	if err := principal(ctx, uw, req.ProjectID, req.PrincipalType, req.PrincipalID); err != nil {
+		return goappbuild.ACLEntry{}, err
+	}
+
+	identity, _ := goappbuild.IdentityFromContext(ctx)
+
+	e := goappbuild.ACLEntry{
+		ProjectID:     req.ProjectID,
+		Collection:    req.Collection,
+		DocumentID:    documentID,
+		PrincipalType: req.PrincipalType,
+		PrincipalID:   req.PrincipalID,
+		Permission:    req.Permission,
+	}
+
+	if !identity.IsAPIKey() {
+		e.CreatedBy = identity.UserID
+	}
+
+	if err := uw.ACL().Upsert(ctx, &e); err != nil {
+		return goappbuild.ACLEntry{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.ACLEntry{}, err
+	}
+
+	return e, nil
+}
+
+// Unshare revokes the access of a principal to a document
+func (s *aclService) Unshare(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collection, documentID string,
+	principalID uuid.UUID,
+) error {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := s.authorize(ctx, uw, projectID, collection)
+	if err != nil {
+		return err
+	}
+
+	id, err := key(t, documentID)
+	if err != nil {
+		return err
+	}
+
+	if err := uw.ACL().Delete(ctx, projectID, collection, id, principalID); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// List returns the entries of a document, oldest first
+func (s *aclService) List(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collection, documentID string,
+) ([]goappbuild.ACLEntry, error) {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := s.authorize(ctx, uw, projectID, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := key(t, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return uw.ACL().List(ctx, projectID, collection, id)
+}
+
+// authorize returns the collection if the caller can manage its entries
+func (s *aclService) authorize(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	projectID uuid.UUID,
+	collection string,
+) (target, error) {
+	project, c, grant, err := authz.Documents(ctx, uw, projectID, collection, goappbuild.ActionUpdate)
+	if err != nil {
+		return target{}, err
+	}
+
+	if !grant.Member {
+		return target{}, goappbuild.Errorf(goappbuild.EForbidden, "only members can share documents")
+	}
+
+	ans := target{
+		project:    project,
+		collection: c,
+		grant:      grant,
+	}
+
+	return ans, nil
+}
+
+// document returns the key of the document of the collection, it must exist
+func (s *aclService) document(ctx context.Context, uw goappbuild.Storage, t target, sid string) (string, error) {
+	id, err := t.collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := uw.Queries().Get(ctx, t.query().Equal("id", id)); err != nil {
+		return "", err
+	}
+
+	return formatID(id), nil
+}
+
+// key returns the id of a document as it is stored in the entries,
+// which is how postgres casts it to text
+func key(t target, sid string) (string, error) {
+	id, err := t.collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return "", err
+	}
+
+	return formatID(id), nil
+}
+
+// unshareDeleted deletes the entries of the deleted documents, so that
+// the documents created later with the same ids are not shared
+func unshareDeleted(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	projectID uuid.UUID,
+	collection string,
+	ids []any,
+) error {
+	keys := make([]string, len(ids))
+
+	for i, id := range ids {
+		keys[i] = formatID(id)
+	}
+
+	return uw.ACL().DeleteDocuments(ctx, projectID, collection, keys...)
+}
+
+// principal returns an error unless the id is a team of the project for
+// teams, and an end user of the project or a platform user for users
+func principal(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	projectID uuid.UUID,
+	principalType goappbuild.PrincipalType,
+	id uuid.UUID,
+) error {
+	if principalType == goappbuild.PrincipalTeam {
+		_, err := uw.Teams().Get(ctx, projectID, id)
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.Errorf(goappbuild.ENotFound, "team %s not found", id)
+		}
+
+		return err
+	}
+
+	_, err := uw.EndUsers().Get(ctx, projectID, id)
+	if goappbuild.ErrorCode(err) != goappbuild.ENotFound {
+		return err
+	}
+
+	_, err = uw.Users().Get(ctx, id)
+	if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+		return goappbuild.Errorf(goappbuild.ENotFound, "principal %s not found", id)
+	}
+
+	return err
+}
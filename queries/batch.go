@@ -0,0 +1,168 @@
+package queries
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gosom/goappbuild"
+)
+
+// Batch executes the operations in order in a single unit of work
+func (q *queryService) Batch(
+	ctx context.Context,
+	projectID uuid.UUID,
+	ops []goappbuild.BatchOperation,
+) ([]goappbuild.BatchResult, error) {
+	uw, err := q.storage.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	results := make([]goappbuild.BatchResult, 0, len(ops))
+	c := q.changes(ctx)
+
+	for i := range ops {
+		res, err := q.batchOp(ctx, uw, projectID, ops[i], results, c)
+		if err != nil {
+			return nil, &goappbuild.BatchError{Step: i, Err: err}
+		}
+
+		results = append(results, res)
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	q.publish(ctx, c)
+
+	return results, nil
+}
+
+func (q *queryService) batchOp(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	projectID uuid.UUID,
+	op goappbuild.BatchOperation,
+	results []goappbuild.BatchResult,
+	c *changes,
+) (goappbuild.BatchResult, error) {
+	ans := goappbuild.BatchResult{
+		Type:       op.Type,
+		Collection: op.Collection,
+	}
+
+	action, err := batchAction(op.Type)
+	if err != nil {
+		return ans, err
+	}
+
+	t, err := q.authorize(ctx, uw, projectID, op.Collection, action)
+	if err != nil {
+		return ans, err
+	}
+
+	data := make(map[string]any, len(op.Data))
+
+	for k, v := range op.Data {
+		resolved, err := resolveRef(v, results)
+		if err != nil {
+			return ans, err
+		}
+
+		data[k] = resolved
+	}
+
+	if op.Type == goappbuild.BatchOpCreate {
+		doc, err := q.create(ctx, uw, t, data, c)
+		if err != nil {
+			return ans, err
+		}
+
+		ans.Document = doc
+
+		return ans, nil
+	}
+
+	id, err := resolveID(op.ID, results)
+	if err != nil {
+		return ans, err
+	}
+
+	switch op.Type {
+	case goappbuild.BatchOpUpdate:
+		ans.Document, err = q.update(ctx, uw, t, id, data, c)
+	case goappbuild.BatchOpDelete:
+		err = q.delete(ctx, uw, t, id, c)
+	case goappbuild.BatchOpGet:
+		ans.Document, err = q.get(ctx, uw, t, id)
+	}
+
+	return ans, err
+}
+
+// batchAction returns the action performed by an operation type
+func batchAction(typ goappbuild.BatchOpType) (goappbuild.Action, error) {
+	switch typ {
+	case goappbuild.BatchOpGet:
+		return goappbuild.ActionRead, nil
+	case goappbuild.BatchOpCreate:
+		return goappbuild.ActionCreate, nil
+	case goappbuild.BatchOpUpdate:
+		return goappbuild.ActionUpdate, nil
+	case goappbuild.BatchOpDelete:
+		return goappbuild.ActionDelete, nil
+	default:
+		return "", goappbuild.Errorf(goappbuild.EValidation, "invalid operation type: %q", typ)
+	}
+}
+
+// resolveRef replaces a reference with the value of the referenced field
+func resolveRef(v any, results []goappbuild.BatchResult) (any, error) {
+	ref, ok := v.(goappbuild.BatchRef)
+	if !ok {
+		return v, nil
+	}
+
+	if ref.Step >= len(results) {
+		return nil, goappbuild.Errorf(
+			goappbuild.EValidation,
+			"reference to operation %d: only earlier operations can be referenced",
+			ref.Step,
+		)
+	}
+
+	val, ok := results[ref.Step].Document.Values[ref.Field]
+	if !ok {
+		return nil, goappbuild.Errorf(
+			goappbuild.EValidation,
+			"reference to operation %d: field %q not found",
+			ref.Step, ref.Field,
+		)
+	}
+
+	return val, nil
+}
+
+// resolveID returns the string representation of the id of an operation
+// (ids of serial collections are decoded from JSON as numbers)
+func resolveID(v any, results []goappbuild.BatchResult) (string, error) {
+	resolved, err := resolveRef(v, results)
+	if err != nil {
+		return "", err
+	}
+
+	switch id := resolved.(type) {
+	case string:
+		return id, nil
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64), nil
+	case uuid.UUID:
+		return id.String(), nil
+	default:
+		return "", goappbuild.Errorf(goappbuild.EValidation, "invalid id: %v", resolved)
+	}
+}
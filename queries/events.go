@@ -0,0 +1,118 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gosom/goappbuild"
+)
+
+// WithEvents publishes the changes of the documents to the bus
+// after their unit of work commits
+func WithEvents(bus goappbuild.EventBus) Option {
+	return func(q *queryService) {
+		q.events = bus
+	}
+}
+
+// changes collects the events of a unit of work until it commits.
+// A nil changes records nothing, the service has no event bus then
+// and the snapshots are not read.
+type changes struct {
+	ctx    context.Context
+	events []goappbuild.Event
+}
+
+// changes returns the collector of a new unit of work
+func (q *queryService) changes(ctx context.Context) *changes {
+	if q.events == nil {
+		return nil
+	}
+
+	return &changes{ctx: ctx}
+}
+
+// publish publishes the events of a committed unit of work
+func (q *queryService) publish(ctx context.Context, c *changes) {
+	if c == nil || len(c.events) == 0 {
+		return
+	}
+
+	q.events.Publish(ctx, c.events...)
+}
+
+// enabled returns true if the events are recorded
+func (c *changes) enabled() bool {
+	return c != nil
+}
+
+// document records a change of a document of the target.
+// The snapshot before is nil for creations, after is nil for deletions.
+// The snapshots are copied, since the callers remove the attributes they
+// cannot read from the documents they return and the events carry them all.
+func (c *changes) document(typ goappbuild.EventType, t target, before, after map[string]any) {
+	if c == nil {
+		return
+	}
+
+	if before != nil {
+		before = merge(before, nil)
+	}
+
+	if after != nil {
+		after = merge(after, nil)
+	}
+
+	e := goappbuild.NewEvent(c.ctx, typ, t.project.ID)
+	e.Collection = t.collection.Name
+
+	if before != nil {
+		e.Before = before
+		e.DocumentID = documentID(before)
+	}
+
+	if after != nil {
+		e.After = after
+		e.DocumentID = documentID(after)
+	}
+
+	c.events = append(c.events, e)
+}
+
+// documentID returns the id of the values as the events carry it
+func documentID(values map[string]any) string {
+	id, ok := values["id"]
+	if !ok {
+		return ""
+	}
+
+	return formatID(id)
+}
+
+// formatID returns the id as the events and the ACL entries carry it
+func formatID(id any) string {
+	switch v := id.(type) {
+	// the ids of serial collections are decoded from JSON as numbers,
+	// which fmt formats with an exponent from a million
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// merge returns the values with the data applied
+func merge(values, data map[string]any) map[string]any {
+	ans := make(map[string]any, len(values)+len(data))
+
+	for k, v := range values {
+		ans[k] = v
+	}
+
+	for k, v := range data {
+		ans[k] = v
+	}
+
+	return ans
+}
@@ -0,0 +1,34 @@
+package queries
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_documentID(t *testing.T) {
+	id := uuid.MustParse("0190d5b4-7b7c-7c3e-9f0a-2f1d3c4b5a69")
+
+	testCases := []struct {
+		name   string
+		values map[string]any
+		want   string
+	}{
+		{name: "no id", values: map[string]any{}, want: ""},
+		{name: "uuid", values: map[string]any{"id": id}, want: id.String()},
+		{name: "string", values: map[string]any{"id": "slug"}, want: "slug"},
+		{name: "serial", values: map[string]any{"id": int64(42)}, want: "42"},
+		{name: "small serial from json", values: map[string]any{"id": float64(42)}, want: "42"},
+		{name: "large serial from json", values: map[string]any{"id": float64(1234567)}, want: "1234567"},
+		{name: "largest exact serial from json", values: map[string]any{"id": float64(1 << 53)}, want: "9007199254740992"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, documentID(tc.values))
+		})
+	}
+}
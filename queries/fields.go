@@ -0,0 +1,97 @@
+package queries
+
+import (
+	"context"
+
+	"github.com/gosom/goappbuild"
+)
+
+// readable returns true if the caller can read the attribute
+func (t target) readable(name string) bool {
+	attr, ok := t.collection.Attributes[name]
+	if !ok {
+		return true
+	}
+
+	switch {
+	case attr.Access.Private:
+		return false
+	case attr.Access.ReadRole == "":
+		return true
+	case !t.grant.Member:
+		return false
+	default:
+		// API keys have no role and can read every attribute that is not private
+		return t.grant.Role == "" || t.grant.Role.AtLeast(attr.Access.ReadRole)
+	}
+}
+
+// document returns the document without the attributes the caller cannot read
+func (t target) document(values map[string]any) goappbuild.Document {
+	for k := range values {
+		if !t.readable(k) {
+			delete(values, k)
+		}
+	}
+
+	return goappbuild.Document{
+		Values: values,
+	}
+}
+
+// checkRead returns an error if the query selects, filters or sorts by
+// attributes the caller cannot read, since that would reveal their values,
+// or if it reads the trash and the caller is not a member
+func (t target) checkRead(param goappbuild.Q) error {
+	if (param.IsIncludeDeleted() || param.IsOnlyDeleted()) && !t.grant.Member {
+		return goappbuild.Errorf(goappbuild.EForbidden, "only the members of the project can read the trash")
+	}
+
+	cols := append([]string{}, param.Cols()...)
+
+	for _, op := range param.Where() {
+		cols = append(cols, op.Column())
+	}
+
+	for _, op := range param.Order() {
+		cols = append(cols, op.Column())
+	}
+
+	for _, col := range cols {
+		if !t.readable(col) {
+			return goappbuild.Errorf(goappbuild.EForbidden, "attribute %s cannot be read", col)
+		}
+	}
+
+	return nil
+}
+
+// checkWrite returns an error if the data set attributes the caller cannot
+// write. Write once attributes can only be set when the document is created
+// and the documents are only moved to the trash by Delete and out of it by
+// Restore.
+func (t target) checkWrite(ctx context.Context, data map[string]any, create bool) error {
+	identity, _ := goappbuild.IdentityFromContext(ctx)
+	server := identity.IsAPIKey() || goappbuild.IsSystem(ctx)
+
+	for k := range data {
+		if k == "deleted_at" {
+			return goappbuild.Errorf(goappbuild.EForbidden, "attribute deleted_at is set by delete and restore only")
+		}
+
+		attr, ok := t.collection.Attributes[k]
+		if !ok {
+			continue
+		}
+
+		if attr.Access.ReadOnly && !server {
+			return goappbuild.Errorf(goappbuild.EForbidden, "attribute %s is read only", k)
+		}
+
+		if attr.Access.WriteOnce && !create {
+			return goappbuild.Errorf(goappbuild.EForbidden, "attribute %s can only be set when the document is created", k)
+		}
+	}
+
+	return nil
+}
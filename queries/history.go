@@ -0,0 +1,266 @@
+package queries
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+)
+
+// Revisions returns the revisions of a document, newest first
+func (q *queryService) Revisions(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collectionName string,
+	sid string,
+) ([]goappbuild.Revision, error) {
+	if err := goappbuild.CheckScope(ctx, projectID, goappbuild.DocumentsReadScope(collectionName)); err != nil {
+		return nil, err
+	}
+
+	uw, err := q.storage.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := q.historyCollection(ctx, uw, projectID, collectionName, goappbuild.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := t.collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return nil, err
+	}
+
+	revs, err := uw.History().List(ctx, t.project.Name, t.collection.Name, formatID(id))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range revs {
+		revs[i].Data = t.document(revs[i].Data).Values
+	}
+
+	return revs, nil
+}
+
+// GetAsOf returns a document as it was at the given time
+func (q *queryService) GetAsOf(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collectionName string,
+	sid string,
+	at time.Time,
+) (goappbuild.Document, error) {
+	if err := goappbuild.CheckScope(ctx, projectID, goappbuild.DocumentsReadScope(collectionName)); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	uw, err := q.storage.New(ctx)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := q.historyCollection(ctx, uw, projectID, collectionName, goappbuild.RoleViewer)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	id, err := t.collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	rev, err := uw.History().AsOf(ctx, t.project.Name, t.collection.Name, formatID(id), at)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	if rev.Operation == goappbuild.RevisionOpDelete {
+		return goappbuild.Document{}, goappbuild.Errorf(goappbuild.ENotFound, "document %s was deleted at %s", id, at)
+	}
+
+	if t.collection.Options.SoftDelete && rev.Data["deleted_at"] != nil {
+		return goappbuild.Document{}, goappbuild.Errorf(goappbuild.ENotFound, "document %s was in the trash at %s", id, at)
+	}
+
+	return t.document(rev.Data), nil
+}
+
+// RestoreRevision restores a document to the state of a revision.
+// A deleted document is recreated, a trashed one has to be restored from
+// the trash first. The values are written like the ones of an update or
+// a create, so the attributes the caller cannot write are not restored.
+func (q *queryService) RestoreRevision(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collectionName string,
+	sid string,
+	revision int64,
+) (goappbuild.Document, error) {
+	if err := goappbuild.CheckScope(ctx, projectID, goappbuild.DocumentsWriteScope(collectionName)); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	uw, err := q.storage.New(ctx)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := q.historyCollection(ctx, uw, projectID, collectionName, goappbuild.RoleDeveloper)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	project, collection := t.project, t.collection
+
+	id, err := collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	rev, err := uw.History().Get(ctx, project.Name, collection.Name, formatID(id), revision)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	current, err := uw.Queries().List(ctx, query(project, collection).IncludeDeleted().Equal("id", id))
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	c := q.changes(ctx)
+
+	var doc goappbuild.Document
+
+	switch {
+	case len(current) == 0:
+		doc, err = q.recreate(ctx, uw, t, id, rev.Data, c)
+	case current[0]["deleted_at"] != nil:
+		return goappbuild.Document{}, goappbuild.Errorf(
+			goappbuild.EConflict,
+			"document %s is in the trash, restore it first",
+			id,
+		)
+	default:
+		// only the changed values are written, so that the attributes
+		// that cannot be updated are restored when they did not change
+		doc, err = q.update(ctx, uw, t, sid, changed(current[0], rev.Data), c)
+	}
+
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	q.publish(ctx, c)
+
+	return doc, nil
+}
+
+// recreate creates a deleted document again with the values of a revision
+func (q *queryService) recreate(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	t target,
+	id any,
+	values map[string]any,
+	c *changes,
+) (goappbuild.Document, error) {
+	data := restorable(values)
+
+	if err := t.checkWrite(ctx, data, true); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	data["id"] = id
+	data["created_at"] = values["created_at"]
+	data["updated_at"] = time.Now().UTC()
+
+	result, err := uw.Queries().Create(ctx, t.project.Name, t.collection.Name, data)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	c.document(goappbuild.EventDocumentCreated, t, nil, result)
+
+	return t.document(result), nil
+}
+
+// restorable returns the values of a revision without the ones the server manages
+func restorable(values map[string]any) map[string]any {
+	ans := make(map[string]any, len(values))
+
+	for k, v := range values {
+		switch k {
+		case "id", "created_at", "updated_at", "deleted_at":
+			continue
+		}
+
+		ans[k] = v
+	}
+
+	return ans
+}
+
+// changed returns the values of a revision that differ from the current ones
+func changed(current, values map[string]any) map[string]any {
+	ans := restorable(values)
+
+	for k, v := range ans {
+		if cur, ok := current[k]; ok && reflect.DeepEqual(cur, v) {
+			delete(ans, k)
+		}
+	}
+
+	return ans
+}
+
+// historyCollection returns the collection that keeps history as a target
+// of the operations only project members can perform
+func (q *queryService) historyCollection(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	projectID uuid.UUID,
+	collectionName string,
+	role goappbuild.Role,
+) (target, error) {
+	project, grant, err := authz.Member(ctx, uw, projectID, role)
+	if err != nil {
+		return target{}, err
+	}
+
+	collection, err := uw.Collections().GetByName(ctx, project.ID, collectionName)
+	if err != nil {
+		return target{}, err
+	}
+
+	if !collection.Options.History {
+		return target{}, goappbuild.Errorf(
+			goappbuild.EValidation,
+			"collection %s does not keep history",
+			collection.Name,
+		)
+	}
+
+	ans := target{
+		project:    project,
+		collection: collection,
+		grant:      grant,
+	}
+
+	return ans, nil
+}
@@ -0,0 +1,697 @@
+package queries_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/collections"
+	"github.com/gosom/goappbuild/internal/pgtest"
+	"github.com/gosom/goappbuild/postgres"
+	"github.com/gosom/goappbuild/queries"
+	"github.com/gosom/goappbuild/teams"
+)
+
+// pgSetup returns a postgres storage with a project of a new platform user
+// and the context of the user. It skips the test without a test database.
+func pgSetup(t *testing.T) (goappbuild.Storage, goappbuild.Project, context.Context) {
+	t.Helper()
+
+	storage := postgres.NewUnitOfWork(pgtest.New(t))
+	project, owner := pgtest.Project(t, storage)
+
+	return storage, project, owner
+}
+
+func titles(docs []goappbuild.Document) []string {
+	ans := make([]string, 0, len(docs))
+	for _, d := range docs {
+		ans = append(ans, fmt.Sprint(d.Values["title"]))
+	}
+
+	return ans
+}
+
+func Test_QueryService_Postgres_Rules(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "notes", goappbuild.CollectionOptions{
+		Rules: goappbuild.CollectionRules{
+			Read:   goappbuild.RuleOwner,
+			Create: goappbuild.RuleAuthenticated,
+			Update: goappbuild.RuleOwner,
+			Delete: goappbuild.RuleOwner,
+		},
+	})
+
+	alice := pgtest.EndUser(t, storage, project.ID)
+	bob := pgtest.EndUser(t, storage, project.ID)
+
+	s := queries.New(storage)
+	all := goappbuild.Q{}.Table("notes")
+
+	note, err := s.Create(alice, project.ID, "notes", map[string]any{"title": "alice"})
+	require.NoError(t, err)
+
+	_, err = s.Create(bob, project.ID, "notes", map[string]any{"title": "bob"})
+	require.NoError(t, err)
+
+	id := fmt.Sprint(note.Values["id"])
+
+	t.Run("anonymous callers cannot create", func(t *testing.T) {
+		_, err := s.Create(context.Background(), project.ID, "notes", map[string]any{"title": "anonymous"})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("owners only see their documents", func(t *testing.T) {
+		docs, err := s.List(alice, project.ID, all)
+		require.NoError(t, err)
+		require.Equal(t, []string{"alice"}, titles(docs))
+
+		docs, err = s.List(bob, project.ID, all)
+		require.NoError(t, err)
+		require.Equal(t, []string{"bob"}, titles(docs))
+	})
+
+	t.Run("members see all the documents", func(t *testing.T) {
+		docs, err := s.List(owner, project.ID, all.OrderAsc("title"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"alice", "bob"}, titles(docs))
+	})
+
+	t.Run("others cannot read, update or delete the document", func(t *testing.T) {
+		_, err := s.Get(bob, project.ID, all.Equal("id", id))
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		_, err = s.Update(bob, project.ID, "notes", id, map[string]any{"title": "stolen"})
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		err = s.Delete(bob, project.ID, "notes", id)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		doc, err := s.Get(alice, project.ID, all.Equal("id", id))
+		require.NoError(t, err)
+		require.Equal(t, "alice", doc.Values["title"])
+	})
+
+	t.Run("the owner updates and deletes the document", func(t *testing.T) {
+		doc, err := s.Update(alice, project.ID, "notes", id, map[string]any{"title": "alice 2"})
+		require.NoError(t, err)
+		require.Equal(t, "alice 2", doc.Values["title"])
+
+		require.NoError(t, s.Delete(alice, project.ID, "notes", id))
+
+		docs, err := s.List(owner, project.ID, all)
+		require.NoError(t, err)
+		require.Equal(t, []string{"bob"}, titles(docs))
+	})
+}
+
+func Test_QueryService_Postgres_ACL(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "docs", goappbuild.CollectionOptions{})
+
+	alice := pgtest.EndUser(t, storage, project.ID)
+	identity, _ := goappbuild.IdentityFromContext(alice)
+
+	s := queries.New(storage)
+	acl := queries.NewACLService(storage)
+	all := goappbuild.Q{}.Table("docs")
+
+	doc, err := s.Create(owner, project.ID, "docs", map[string]any{"title": "shared"})
+	require.NoError(t, err)
+
+	_, err = s.Create(owner, project.ID, "docs", map[string]any{"title": "private"})
+	require.NoError(t, err)
+
+	id := fmt.Sprint(doc.Values["id"])
+
+	_, err = s.List(alice, project.ID, all)
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+	share := func(permission goappbuild.Permission) {
+		_, err := acl.Share(owner, goappbuild.ShareDocumentRequest{
+			ProjectID:   project.ID,
+			Collection:  "docs",
+			DocumentID:  id,
+			PrincipalID: identity.UserID,
+			Permission:  permission,
+		})
+		require.NoError(t, err)
+	}
+
+	share(goappbuild.PermissionRead)
+
+	docs, err := s.List(alice, project.ID, all)
+	require.NoError(t, err)
+	require.Equal(t, []string{"shared"}, titles(docs))
+
+	_, err = s.Update(alice, project.ID, "docs", id, map[string]any{"title": "changed"})
+	require.Error(t, err)
+
+	share(goappbuild.PermissionWrite)
+
+	updated, err := s.Update(alice, project.ID, "docs", id, map[string]any{"title": "changed"})
+	require.NoError(t, err)
+	require.Equal(t, "changed", updated.Values["title"])
+
+	require.NoError(t, acl.Unshare(owner, project.ID, "docs", id, identity.UserID))
+
+	_, err = s.Get(alice, project.ID, all.Equal("id", id))
+	require.Error(t, err)
+}
+
+func Test_QueryService_Postgres_ACLDeletedDocuments(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "docs", goappbuild.CollectionOptions{
+		IDStrategy: goappbuild.IDStrategySerial,
+	})
+	pgtest.Collection(t, storage, owner, project.ID, "notes", goappbuild.CollectionOptions{
+		SoftDelete: true,
+	})
+
+	alice := pgtest.EndUser(t, storage, project.ID)
+	identity, _ := goappbuild.IdentityFromContext(alice)
+
+	s := queries.New(storage)
+	acl := queries.NewACLService(storage)
+
+	shared := func(collection string) string {
+		doc, err := s.Create(owner, project.ID, collection, map[string]any{"title": "shared"})
+		require.NoError(t, err)
+
+		id := fmt.Sprint(doc.Values["id"])
+
+		_, err = acl.Share(owner, goappbuild.ShareDocumentRequest{
+			ProjectID:   project.ID,
+			Collection:  collection,
+			DocumentID:  id,
+			PrincipalID: identity.UserID,
+			Permission:  goappbuild.PermissionRead,
+		})
+		require.NoError(t, err)
+
+		return id
+	}
+
+	requireUnshared := func(collection, id string) {
+		entries, err := acl.List(owner, project.ID, collection, id)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	}
+
+	id := shared("docs")
+	require.NoError(t, s.Delete(owner, project.ID, "docs", id))
+	requireUnshared("docs", id)
+
+	id = shared("docs")
+	_, err := s.DeleteWhere(owner, project.ID, goappbuild.Q{}.Table("docs").Equal("title", "shared"), goappbuild.BulkOptions{})
+	require.NoError(t, err)
+	requireUnshared("docs", id)
+
+	// trashed documents keep the entries until they are purged
+	id = shared("notes")
+	require.NoError(t, s.Delete(owner, project.ID, "notes", id))
+
+	entries, err := acl.List(owner, project.ID, "notes", id)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, s.Purge(owner, project.ID, "notes", id))
+	requireUnshared("notes", id)
+}
+
+func Test_QueryService_Postgres_Trash(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "notes", goappbuild.CollectionOptions{
+		SoftDelete: true,
+		Rules:      goappbuild.CollectionRules{Read: goappbuild.RulePublic},
+	})
+
+	alice := pgtest.EndUser(t, storage, project.ID)
+	identity, _ := goappbuild.IdentityFromContext(alice)
+
+	s := queries.New(storage)
+	acl := queries.NewACLService(storage)
+	all := goappbuild.Q{}.Table("notes")
+
+	doc, err := s.Create(owner, project.ID, "notes", map[string]any{"title": "kept"})
+	require.NoError(t, err)
+
+	id := fmt.Sprint(doc.Values["id"])
+
+	_, err = acl.Share(owner, goappbuild.ShareDocumentRequest{
+		ProjectID:   project.ID,
+		Collection:  "notes",
+		DocumentID:  id,
+		PrincipalID: identity.UserID,
+		Permission:  goappbuild.PermissionWrite,
+	})
+	require.NoError(t, err)
+
+	t.Run("test deleted_at cannot be written", func(t *testing.T) {
+		trashed := map[string]any{"deleted_at": "2000-01-01T00:00:00Z"}
+
+		for _, ctx := range []context.Context{owner, alice} {
+			_, err := s.Update(ctx, project.ID, "notes", id, trashed)
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+			_, err = s.UpdateWhere(ctx, project.ID, all.Equal("id", id), trashed, goappbuild.BulkOptions{})
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+		}
+
+		docs, err := s.List(owner, project.ID, all)
+		require.NoError(t, err)
+		require.Equal(t, []string{"kept"}, titles(docs))
+	})
+
+	t.Run("test only members read the trash", func(t *testing.T) {
+		require.NoError(t, s.Delete(owner, project.ID, "notes", id))
+
+		anonymous := context.Background()
+
+		for _, ctx := range []context.Context{anonymous, alice} {
+			_, err := s.List(ctx, project.ID, all.OnlyDeleted())
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+			_, err = s.Get(ctx, project.ID, all.IncludeDeleted().Equal("id", id))
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+		}
+
+		docs, err := s.List(owner, project.ID, all.OnlyDeleted())
+		require.NoError(t, err)
+		require.Equal(t, []string{"kept"}, titles(docs))
+	})
+}
+
+func Test_QueryService_Postgres_InvalidID(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "docs", goappbuild.CollectionOptions{})
+	pgtest.Collection(t, storage, owner, project.ID, "events", goappbuild.CollectionOptions{
+		IDStrategy: goappbuild.IDStrategyUUIDv7,
+	})
+	pgtest.Collection(t, storage, owner, project.ID, "counters", goappbuild.CollectionOptions{
+		IDStrategy: goappbuild.IDStrategySerial,
+	})
+
+	s := queries.New(storage)
+
+	for _, name := range []string{"docs", "events", "counters"} {
+		_, err := s.Get(owner, project.ID, goappbuild.Q{}.Table(name).Equal("id", "not-an-id"))
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err), name)
+	}
+
+	_, err := s.Get(owner, project.ID, goappbuild.Q{}.Table("docs").Equal("id", uuid.NewString()))
+	require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+}
+
+func Test_QueryService_Postgres_TeamACL(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "docs", goappbuild.CollectionOptions{})
+
+	alice := pgtest.EndUser(t, storage, project.ID)
+	identity, _ := goappbuild.IdentityFromContext(alice)
+
+	s := queries.New(storage)
+	acl := queries.NewACLService(storage)
+	svc := teams.New(storage)
+
+	team, err := svc.Create(owner, goappbuild.CreateTeamRequest{ProjectID: project.ID, Name: "readers"})
+	require.NoError(t, err)
+
+	doc, err := s.Create(owner, project.ID, "docs", map[string]any{"title": "shared"})
+	require.NoError(t, err)
+
+	_, err = s.Create(owner, project.ID, "docs", map[string]any{"title": "private"})
+	require.NoError(t, err)
+
+	id := fmt.Sprint(doc.Values["id"])
+
+	_, err = acl.Share(owner, goappbuild.ShareDocumentRequest{
+		ProjectID:     project.ID,
+		Collection:    "docs",
+		DocumentID:    id,
+		PrincipalType: goappbuild.PrincipalTeam,
+		PrincipalID:   team.ID,
+		Permission:    goappbuild.PermissionRead,
+	})
+	require.NoError(t, err)
+
+	all := goappbuild.Q{}.Table("docs")
+
+	// the members of the team only
+	_, err = s.List(alice, project.ID, all)
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+	require.NoError(t, svc.AddMember(owner, project.ID, team.ID, identity.UserID))
+
+	docs, err := s.List(alice, project.ID, all)
+	require.NoError(t, err)
+	require.Equal(t, []string{"shared"}, titles(docs))
+
+	// the policies grant the team too, without the filter of the service
+	uw, err := storage.New(alice)
+	require.NoError(t, err)
+
+	n, err := uw.Queries().Count(alice, goappbuild.Q{}.Schema(project.Name).Table("docs"))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+	require.NoError(t, uw.Rollback(alice))
+
+	_, err = s.Update(alice, project.ID, "docs", id, map[string]any{"title": "changed"})
+	require.Error(t, err)
+
+	require.NoError(t, svc.Delete(owner, project.ID, team.ID))
+
+	_, err = s.List(alice, project.ID, all)
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+}
+
+// Test_QueryService_Postgres_RLS checks that the policies restrict the queries
+// that skip the authorization of the service
+func Test_QueryService_Postgres_RLS(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "notes", goappbuild.CollectionOptions{
+		Rules: goappbuild.CollectionRules{
+			Read:   goappbuild.RuleOwner,
+			Create: goappbuild.RuleAuthenticated,
+			Update: goappbuild.RuleOwner,
+			Delete: goappbuild.RuleOwner,
+		},
+	})
+
+	alice := pgtest.EndUser(t, storage, project.ID)
+	bob := pgtest.EndUser(t, storage, project.ID)
+
+	s := queries.New(storage)
+
+	for _, ctx := range []context.Context{alice, bob} {
+		_, err := s.Create(ctx, project.ID, "notes", map[string]any{"title": "note"})
+		require.NoError(t, err)
+	}
+
+	all := goappbuild.Q{}.Schema(project.Name).Table("notes")
+
+	raw := func(ctx context.Context, fn func(goappbuild.QueryRepo) error) {
+		t.Helper()
+
+		uw, err := storage.New(ctx)
+		require.NoError(t, err)
+
+		defer uw.Rollback(ctx)
+
+		require.NoError(t, fn(uw.Queries()))
+		require.NoError(t, uw.Commit(ctx))
+	}
+
+	count := func(ctx context.Context) int64 {
+		t.Helper()
+
+		var n int64
+
+		raw(ctx, func(r goappbuild.QueryRepo) (err error) {
+			n, err = r.Count(ctx, all)
+			return err
+		})
+
+		return n
+	}
+
+	require.EqualValues(t, 2, count(owner))
+	require.EqualValues(t, 1, count(alice))
+	require.EqualValues(t, 1, count(bob))
+	require.EqualValues(t, 0, count(context.Background()))
+
+	raw(alice, func(r goappbuild.QueryRepo) error {
+		ids, err := r.UpdateWhere(alice, all, map[string]any{"title": "alice"})
+		require.Len(t, ids, 1)
+
+		return err
+	})
+
+	raw(bob, func(r goappbuild.QueryRepo) error {
+		ids, err := r.DeleteWhere(bob, all)
+		require.Len(t, ids, 1)
+
+		return err
+	})
+
+	docs, err := s.List(owner, project.ID, goappbuild.Q{}.Table("notes"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice"}, titles(docs))
+}
+
+func Test_QueryService_Postgres_Bulk(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "posts", goappbuild.CollectionOptions{})
+
+	s := queries.New(storage, queries.WithMaxAffectedRows(3))
+
+	for _, title := range []string{"a", "b", "c", "d"} {
+		_, err := s.Create(owner, project.ID, "posts", map[string]any{"title": title})
+		require.NoError(t, err)
+	}
+
+	all := goappbuild.Q{}.Table("posts")
+	list := func() []string {
+		t.Helper()
+
+		docs, err := s.List(owner, project.ID, all.OrderAsc("title"))
+		require.NoError(t, err)
+
+		return titles(docs)
+	}
+
+	t.Run("a filter is required without force", func(t *testing.T) {
+		_, err := s.DeleteWhere(owner, project.ID, all, goappbuild.BulkOptions{})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		_, err = s.UpdateWhere(owner, project.ID, all, map[string]any{"title": "x"}, goappbuild.BulkOptions{})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		require.Equal(t, []string{"a", "b", "c", "d"}, list())
+	})
+
+	t.Run("dry runs count the matching documents", func(t *testing.T) {
+		res, err := s.DeleteWhere(owner, project.ID, all, goappbuild.BulkOptions{Force: true, DryRun: true})
+		require.NoError(t, err)
+		require.True(t, res.DryRun)
+		require.EqualValues(t, 4, res.Affected)
+
+		require.Equal(t, []string{"a", "b", "c", "d"}, list())
+	})
+
+	t.Run("operations over the limit are refused", func(t *testing.T) {
+		_, err := s.UpdateWhere(owner, project.ID, all, map[string]any{"title": "x"}, goappbuild.BulkOptions{Force: true})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		_, err = s.DeleteWhere(owner, project.ID, all.NotEqual("title", "a"), goappbuild.BulkOptions{MaxAffected: 2})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		require.Equal(t, []string{"a", "b", "c", "d"}, list())
+	})
+
+	t.Run("operations within the limit run", func(t *testing.T) {
+		res, err := s.UpdateWhere(
+			owner, project.ID, all.NotEqual("title", "a"),
+			map[string]any{"title": "x"}, goappbuild.BulkOptions{ReturnIDs: true},
+		)
+		require.NoError(t, err)
+		require.EqualValues(t, 3, res.Affected)
+		require.Len(t, res.IDs, 3)
+
+		require.Equal(t, []string{"a", "x", "x", "x"}, list())
+
+		res, err = s.DeleteWhere(owner, project.ID, all.Equal("title", "x"), goappbuild.BulkOptions{})
+		require.NoError(t, err)
+		require.EqualValues(t, 3, res.Affected)
+
+		require.Equal(t, []string{"a"}, list())
+	})
+}
+
+func Test_QueryService_Postgres_Batch(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "posts", goappbuild.CollectionOptions{})
+
+	s := queries.New(storage)
+	all := goappbuild.Q{}.Table("posts")
+
+	t.Run("references resolve to earlier results", func(t *testing.T) {
+		ref := goappbuild.BatchRef{Step: 0, Field: "id"}
+
+		results, err := s.Batch(owner, project.ID, []goappbuild.BatchOperation{
+			{Type: goappbuild.BatchOpCreate, Collection: "posts", Data: map[string]any{"title": "draft"}},
+			{
+				Type:       goappbuild.BatchOpCreate,
+				Collection: "posts",
+				Data:       map[string]any{"title": goappbuild.BatchRef{Step: 0, Field: "title"}},
+			},
+			{Type: goappbuild.BatchOpUpdate, Collection: "posts", ID: ref, Data: map[string]any{"title": "published"}},
+			{Type: goappbuild.BatchOpGet, Collection: "posts", ID: ref},
+		})
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+
+		require.Equal(t, "draft", results[1].Document.Values["title"])
+		require.Equal(t, results[0].Document.Values["id"], results[3].Document.Values["id"])
+		require.Equal(t, "published", results[3].Document.Values["title"])
+
+		docs, err := s.List(owner, project.ID, all.OrderAsc("title"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"draft", "published"}, titles(docs))
+	})
+
+	t.Run("a failed operation rolls back the batch", func(t *testing.T) {
+		_, err := s.Batch(owner, project.ID, []goappbuild.BatchOperation{
+			{Type: goappbuild.BatchOpCreate, Collection: "posts", Data: map[string]any{"title": "lost"}},
+			{Type: goappbuild.BatchOpDelete, Collection: "posts", ID: goappbuild.BatchRef{Step: 0, Field: "id"}},
+			{Type: goappbuild.BatchOpGet, Collection: "posts", ID: goappbuild.BatchRef{Step: 0, Field: "id"}},
+		})
+
+		var batchErr *goappbuild.BatchError
+
+		require.ErrorAs(t, err, &batchErr)
+		require.Equal(t, 2, batchErr.Step)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(batchErr.Err))
+
+		docs, err := s.List(owner, project.ID, all.OrderAsc("title"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"draft", "published"}, titles(docs))
+	})
+
+	t.Run("only earlier operations can be referenced", func(t *testing.T) {
+		_, err := s.Batch(owner, project.ID, []goappbuild.BatchOperation{
+			{Type: goappbuild.BatchOpCreate, Collection: "posts", Data: map[string]any{"title": "lost"}},
+			{Type: goappbuild.BatchOpGet, Collection: "posts", ID: goappbuild.BatchRef{Step: 1, Field: "id"}},
+		})
+
+		var batchErr *goappbuild.BatchError
+
+		require.ErrorAs(t, err, &batchErr)
+		require.Equal(t, 1, batchErr.Step)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(batchErr.Err))
+
+		n, err := s.List(owner, project.ID, all.Equal("title", "lost"))
+		require.NoError(t, err)
+		require.Empty(t, n)
+	})
+}
+
+func Test_QueryService_Postgres_History(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	_, err := collections.New(storage).Create(owner, goappbuild.CollectionCreateRequest{
+		Name:      "notes",
+		ProjectID: project.ID,
+		Options:   goappbuild.CollectionOptions{History: true, SoftDelete: true},
+		Attributes: []goappbuild.Attribute{
+			{Name: "title", Type: goappbuild.AttributeTypeString},
+			{Name: "status", Type: goappbuild.AttributeTypeString, Access: goappbuild.FieldAccess{ReadOnly: true}},
+		},
+	})
+	require.NoError(t, err)
+
+	s := queries.New(storage)
+
+	note, err := s.Create(owner, project.ID, "notes", map[string]any{"title": "v1"})
+	require.NoError(t, err)
+
+	id := fmt.Sprint(note.Values["id"])
+
+	time.Sleep(10 * time.Millisecond)
+	before := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = s.Update(owner, project.ID, "notes", id, map[string]any{"title": "v2"})
+	require.NoError(t, err)
+
+	revs, err := s.Revisions(owner, project.ID, "notes", id)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	require.Equal(t, "v2", revs[0].Data["title"])
+	require.Equal(t, "v1", revs[1].Data["title"])
+
+	old, err := s.GetAsOf(owner, project.ID, "notes", id, before)
+	require.NoError(t, err)
+	require.Equal(t, "v1", old.Values["title"])
+
+	current, err := s.GetAsOf(owner, project.ID, "notes", id, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, "v2", current.Values["title"])
+
+	restored, err := s.RestoreRevision(owner, project.ID, "notes", id, revs[1].Revision)
+	require.NoError(t, err)
+	require.Equal(t, "v1", restored.Values["title"])
+
+	// the server sets the read only status, the members cannot restore it
+	ctx := goappbuild.ContextWithSystem(context.Background())
+
+	uw, err := storage.New(ctx)
+	require.NoError(t, err)
+
+	defer uw.Rollback(ctx)
+
+	_, err = uw.Queries().UpdateWhere(ctx,
+		goappbuild.Q{}.Schema(project.Name).Table("notes").Equal("id", note.Values["id"]),
+		map[string]any{"status": "approved"},
+	)
+	require.NoError(t, err)
+	require.NoError(t, uw.Commit(ctx))
+
+	_, err = s.RestoreRevision(owner, project.ID, "notes", id, revs[1].Revision)
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+	// trashed documents are not found as of now and are restored from the trash
+	require.NoError(t, s.Delete(owner, project.ID, "notes", id))
+
+	_, err = s.GetAsOf(owner, project.ID, "notes", id, time.Now())
+	require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+	_, err = s.RestoreRevision(owner, project.ID, "notes", id, revs[0].Revision)
+	require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+}
+
+func Test_QueryService_Postgres_HistoryRecreate(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "notes", goappbuild.CollectionOptions{History: true})
+
+	s := queries.New(storage)
+
+	note, err := s.Create(owner, project.ID, "notes", map[string]any{"title": "v1"})
+	require.NoError(t, err)
+
+	id := fmt.Sprint(note.Values["id"])
+
+	require.NoError(t, s.Delete(owner, project.ID, "notes", id))
+
+	_, err = s.GetAsOf(owner, project.ID, "notes", id, time.Now())
+	require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+	revs, err := s.Revisions(owner, project.ID, "notes", id)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	require.Equal(t, goappbuild.RevisionOpDelete, revs[0].Operation)
+
+	restored, err := s.RestoreRevision(owner, project.ID, "notes", id, revs[1].Revision)
+	require.NoError(t, err)
+	require.Equal(t, "v1", restored.Values["title"])
+
+	doc, err := s.Get(owner, project.ID, goappbuild.Q{}.Table("notes").Equal("id", note.Values["id"]))
+	require.NoError(t, err)
+	require.Equal(t, "v1", doc.Values["title"])
+}
@@ -2,30 +2,108 @@ package queries
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
 )
 
+// DefaultMaxAffectedRows is the default maximum number of rows
+// a set based operation is allowed to affect
+const DefaultMaxAffectedRows = 1000
+
 type queryService struct {
-	storage goappbuild.Storage
+	storage         goappbuild.Storage
+	maxAffectedRows int
+	events          goappbuild.EventBus
+}
+
+// Option configures the query service
+type Option func(*queryService)
+
+// WithMaxAffectedRows sets the maximum number of rows a set based
+// operation is allowed to affect
+func WithMaxAffectedRows(n int) Option {
+	return func(q *queryService) {
+		q.maxAffectedRows = n
+	}
 }
 
 // NewQueryService returns a new instance of a query service
-func New(storage goappbuild.Storage) goappbuild.QueryService {
-	return &queryService{
-		storage: storage,
+func New(storage goappbuild.Storage, opts ...Option) goappbuild.QueryService {
+	ans := queryService{
+		storage:         storage,
+		maxAffectedRows: DefaultMaxAffectedRows,
 	}
+
+	for _, opt := range opts {
+		opt(&ans)
+	}
+
+	return &ans
 }
 
-func (q *queryService) Get(ctx context.Context, param goappbuild.Q) (goappbuild.Document, error) {
-	m, err := q.storage.Queries().Get(ctx, param)
+func (q *queryService) Get(ctx context.Context, projectID uuid.UUID, param goappbuild.Q) (goappbuild.Document, error) {
+	// the reads run in a unit of work too, the caller is set per transaction
+	uw, err := q.storage.New(ctx)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := q.authorize(ctx, uw, projectID, param.GetTable(), goappbuild.ActionRead)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	if err := t.checkRead(param); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	if err := t.checkIDs(param); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	m, err := uw.Queries().Get(ctx, t.prepare(param))
 	if err != nil {
 		return goappbuild.Document{}, err
 	}
 
-	ans := goappbuild.Document{
-		Values: m,
+	return t.document(m), nil
+}
+
+// List returns the documents matching the query
+func (q *queryService) List(ctx context.Context, projectID uuid.UUID, param goappbuild.Q) ([]goappbuild.Document, error) {
+	uw, err := q.storage.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := q.authorize(ctx, uw, projectID, param.GetTable(), goappbuild.ActionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.checkRead(param); err != nil {
+		return nil, err
+	}
+
+	if err := t.checkIDs(param); err != nil {
+		return nil, err
+	}
+
+	items, err := uw.Queries().List(ctx, t.prepare(param))
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.Document, len(items))
+	for i := range items {
+		ans[i] = t.document(items[i])
 	}
 
 	return ans, nil
@@ -37,7 +115,6 @@ func (q *queryService) Create(
 	collectionName string,
 	data map[string]any,
 ) (goappbuild.Document, error) {
-
 	uw, err := q.storage.New(ctx)
 	if err != nil {
 		return goappbuild.Document{}, err
@@ -45,12 +122,14 @@ func (q *queryService) Create(
 
 	defer uw.Rollback(ctx)
 
-	project, err := q.storage.Projects().Get(ctx, projectID)
+	t, err := q.authorize(ctx, uw, projectID, collectionName, goappbuild.ActionCreate)
 	if err != nil {
 		return goappbuild.Document{}, err
 	}
 
-	result, err := q.storage.Queries().Create(ctx, project.Name, collectionName, data)
+	c := q.changes(ctx)
+
+	ans, err := q.create(ctx, uw, t, data, c)
 	if err != nil {
 		return goappbuild.Document{}, err
 	}
@@ -59,9 +138,7 @@ func (q *queryService) Create(
 		return goappbuild.Document{}, err
 	}
 
-	ans := goappbuild.Document{
-		Values: result,
-	}
+	q.publish(ctx, c)
 
 	return ans, nil
 }
@@ -70,7 +147,7 @@ func (q *queryService) Update(
 	ctx context.Context,
 	projectID uuid.UUID,
 	collectionName string,
-	id uuid.UUID,
+	id string,
 	data map[string]any,
 ) (goappbuild.Document, error) {
 	uw, err := q.storage.New(ctx)
@@ -80,12 +157,14 @@ func (q *queryService) Update(
 
 	defer uw.Rollback(ctx)
 
-	project, err := q.storage.Projects().Get(ctx, projectID)
+	t, err := q.authorize(ctx, uw, projectID, collectionName, goappbuild.ActionUpdate)
 	if err != nil {
 		return goappbuild.Document{}, err
 	}
 
-	result, err := q.storage.Queries().Update(ctx, project.Name, collectionName, id, data)
+	c := q.changes(ctx)
+
+	ans, err := q.update(ctx, uw, t, id, data, c)
 	if err != nil {
 		return goappbuild.Document{}, err
 	}
@@ -94,9 +173,7 @@ func (q *queryService) Update(
 		return goappbuild.Document{}, err
 	}
 
-	ans := goappbuild.Document{
-		Values: result,
-	}
+	q.publish(ctx, c)
 
 	return ans, nil
 }
@@ -105,7 +182,7 @@ func (q *queryService) Delete(
 	ctx context.Context,
 	projectID uuid.UUID,
 	collectionName string,
-	id uuid.UUID,
+	id string,
 ) error {
 	uw, err := q.storage.New(ctx)
 	if err != nil {
@@ -114,12 +191,14 @@ func (q *queryService) Delete(
 
 	defer uw.Rollback(ctx)
 
-	project, err := q.storage.Projects().Get(ctx, projectID)
+	t, err := q.authorize(ctx, uw, projectID, collectionName, goappbuild.ActionDelete)
 	if err != nil {
 		return err
 	}
 
-	if err := q.storage.Queries().Delete(ctx, project.Name, collectionName, id); err != nil {
+	c := q.changes(ctx)
+
+	if err := q.delete(ctx, uw, t, id, c); err != nil {
 		return err
 	}
 
@@ -127,5 +206,542 @@ func (q *queryService) Delete(
 		return err
 	}
 
+	q.publish(ctx, c)
+
+	return nil
+}
+
+func (q *queryService) get(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	t target,
+	sid string,
+) (goappbuild.Document, error) {
+	id, err := t.collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	m, err := uw.Queries().Get(ctx, t.query().Equal("id", id))
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	return t.document(m), nil
+}
+
+func (q *queryService) create(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	t target,
+	data map[string]any,
+	c *changes,
+) (goappbuild.Document, error) {
+	if err := t.checkWrite(ctx, data, true); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	// the managed attributes are set on a copy, the map belongs to the caller
+	data = merge(data, nil)
+
+	if err := setID(t.collection.Options.IDStrategy, data); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	setOwner(ctx, t, data)
+
+	now := time.Now().UTC()
+
+	data["created_at"] = now
+	data["updated_at"] = now
+
+	result, err := uw.Queries().Create(ctx, t.project.Name, t.collection.Name, data)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	c.document(goappbuild.EventDocumentCreated, t, nil, result)
+
+	return t.document(result), nil
+}
+
+func (q *queryService) update(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	t target,
+	sid string,
+	data map[string]any,
+	c *changes,
+) (goappbuild.Document, error) {
+	if err := checkUpdate(ctx, t, data); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	id, err := t.collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	var before map[string]any
+
+	// trashed documents cannot be updated, they have to be restored first
+	// and restricted callers can only update the documents they own or
+	// that are shared with them. The events need the document before too.
+	if t.collection.Options.SoftDelete || t.grant.Restricted() || c.enabled() {
+		before, err = uw.Queries().Get(ctx, t.query().Equal("id", id))
+		if err != nil {
+			return goappbuild.Document{}, err
+		}
+	}
+
+	data = merge(data, map[string]any{"updated_at": time.Now().UTC()})
+
+	result, err := uw.Queries().Update(ctx, t.project.Name, t.collection.Name, id, data)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	c.document(goappbuild.EventDocumentUpdated, t, before, result)
+
+	return t.document(result), nil
+}
+
+func (q *queryService) delete(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	t target,
+	sid string,
+	c *changes,
+) error {
+	id, err := t.collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return err
+	}
+
+	param := t.query().Equal("id", id)
+
+	var before map[string]any
+
+	if c.enabled() {
+		before, err = uw.Queries().Get(ctx, param)
+		if err != nil {
+			return err
+		}
+	}
+
+	var ids []any
+
+	switch {
+	case t.collection.Options.SoftDelete:
+		ids, err = uw.Queries().UpdateWhere(ctx, param, trash())
+	case t.grant.Restricted():
+		ids, err = uw.Queries().DeleteWhere(ctx, param)
+	default:
+		err = uw.Queries().Delete(ctx, t.project.Name, t.collection.Name, id)
+		ids = []any{id}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "document %s not found", id)
+	}
+
+	if !t.collection.Options.SoftDelete {
+		if err := unshareDeleted(ctx, uw, t.project.ID, t.collection.Name, ids); err != nil {
+			return err
+		}
+	}
+
+	c.document(goappbuild.EventDocumentDeleted, t, before, nil)
+
+	return nil
+}
+
+// UpdateWhere updates all the documents matching the filter of the query
+func (q *queryService) UpdateWhere(
+	ctx context.Context,
+	projectID uuid.UUID,
+	param goappbuild.Q,
+	data map[string]any,
+	opts goappbuild.BulkOptions,
+) (goappbuild.BulkResult, error) {
+	if err := q.checkFilter(param, opts); err != nil {
+		return goappbuild.BulkResult{}, err
+	}
+
+	values := merge(data, map[string]any{"updated_at": time.Now().UTC()})
+
+	op := bulkOp{
+		action: goappbuild.ActionUpdate,
+		run: func(uw goappbuild.Storage, t target, param goappbuild.Q) ([]any, error) {
+			if err := checkUpdate(ctx, t, data); err != nil {
+				return nil, err
+			}
+
+			return uw.Queries().UpdateWhere(ctx, param, values)
+		},
+		after: func(before map[string]any) map[string]any {
+			return merge(before, values)
+		},
+	}
+
+	return q.bulk(ctx, projectID, param, opts, op)
+}
+
+// DeleteWhere deletes all the documents matching the filter of the query
+func (q *queryService) DeleteWhere(
+	ctx context.Context,
+	projectID uuid.UUID,
+	param goappbuild.Q,
+	opts goappbuild.BulkOptions,
+) (goappbuild.BulkResult, error) {
+	if err := q.checkFilter(param, opts); err != nil {
+		return goappbuild.BulkResult{}, err
+	}
+
+	op := bulkOp{
+		action: goappbuild.ActionDelete,
+		run: func(uw goappbuild.Storage, t target, param goappbuild.Q) ([]any, error) {
+			if t.collection.Options.SoftDelete {
+				return uw.Queries().UpdateWhere(ctx, param, trash())
+			}
+
+			ids, err := uw.Queries().DeleteWhere(ctx, param)
+			if err != nil {
+				return nil, err
+			}
+
+			return ids, unshareDeleted(ctx, uw, t.project.ID, t.collection.Name, ids)
+		},
+	}
+
+	return q.bulk(ctx, projectID, param, opts, op)
+}
+
+// bulkOp is a set based operation
+type bulkOp struct {
+	action goappbuild.Action
+	// run runs the operation and returns the ids of the affected rows
+	run func(goappbuild.Storage, target, goappbuild.Q) ([]any, error)
+	// after returns the snapshot of an affected row after the operation,
+	// it is nil for deletions
+	after func(before map[string]any) map[string]any
+}
+
+func (q *queryService) bulk(
+	ctx context.Context,
+	projectID uuid.UUID,
+	param goappbuild.Q,
+	opts goappbuild.BulkOptions,
+	op bulkOp,
+) (goappbuild.BulkResult, error) {
+	uw, err := q.storage.New(ctx)
+	if err != nil {
+		return goappbuild.BulkResult{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := q.authorize(ctx, uw, projectID, param.GetTable(), op.action)
+	if err != nil {
+		return goappbuild.BulkResult{}, err
+	}
+
+	if err := t.checkRead(param); err != nil {
+		return goappbuild.BulkResult{}, err
+	}
+
+	param = t.prepare(param)
+
+	n, err := uw.Queries().Count(ctx, param)
+	if err != nil {
+		return goappbuild.BulkResult{}, err
+	}
+
+	if opts.DryRun {
+		ans := goappbuild.BulkResult{
+			Affected: n,
+			DryRun:   true,
+		}
+
+		return ans, nil
+	}
+
+	limit := q.maxAffected(opts)
+
+	if limit > 0 && n > int64(limit) {
+		return goappbuild.BulkResult{}, tooManyRows(n, limit)
+	}
+
+	c := q.changes(ctx)
+
+	var before []map[string]any
+
+	if c.enabled() {
+		before, err = uw.Queries().List(ctx, param)
+		if err != nil {
+			return goappbuild.BulkResult{}, err
+		}
+	}
+
+	ids, err := op.run(uw, t, param)
+	if err != nil {
+		return goappbuild.BulkResult{}, err
+	}
+
+	// rows can start matching between the count and the statement,
+	// exceeding the limit then rolls everything back
+	if limit > 0 && len(ids) > limit {
+		return goappbuild.BulkResult{}, tooManyRows(int64(len(ids)), limit)
+	}
+
+	if c.enabled() {
+		op.record(c, t, before, ids)
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.BulkResult{}, err
+	}
+
+	q.publish(ctx, c)
+
+	ans := goappbuild.BulkResult{
+		Affected: int64(len(ids)),
+	}
+
+	if opts.ReturnIDs {
+		ans.IDs = ids
+	}
+
+	return ans, nil
+}
+
+// record records the changes of the affected rows
+func (o bulkOp) record(c *changes, t target, before []map[string]any, ids []any) {
+	affected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		affected[formatID(id)] = true
+	}
+
+	for _, row := range before {
+		if !affected[documentID(row)] {
+			continue
+		}
+
+		if o.after == nil {
+			c.document(goappbuild.EventDocumentDeleted, t, row, nil)
+		} else {
+			c.document(goappbuild.EventDocumentUpdated, t, row, o.after(row))
+		}
+	}
+}
+
+// tooManyRows returns the error of a set based operation that would affect
+// more rows than the limit
+func tooManyRows(n int64, limit int) error {
+	return goappbuild.Errorf(
+		goappbuild.EValidation,
+		"operation affects %d rows which is more than the maximum allowed (%d)",
+		n, limit,
+	)
+}
+
+func (q *queryService) checkFilter(param goappbuild.Q, opts goappbuild.BulkOptions) error {
+	if len(param.Where()) == 0 && !opts.Force {
+		return goappbuild.Errorf(
+			goappbuild.EValidation,
+			"refusing to run without a filter, use force to affect all the documents",
+		)
+	}
+
+	return nil
+}
+
+// maxAffected returns the effective limit of affected rows.
+// A request can lower the configured limit but never raise it.
+func (q *queryService) maxAffected(opts goappbuild.BulkOptions) int {
+	switch {
+	case opts.MaxAffected <= 0:
+		return q.maxAffectedRows
+	case q.maxAffectedRows <= 0:
+		return opts.MaxAffected
+	case opts.MaxAffected < q.maxAffectedRows:
+		return opts.MaxAffected
+	default:
+		return q.maxAffectedRows
+	}
+}
+
+var (
+	errIDChange    = goappbuild.Errorf(goappbuild.EValidation, "the id of a document cannot be changed")
+	errOwnerChange = goappbuild.Errorf(goappbuild.EForbidden, "the owner of a document cannot be changed")
+)
+
+// checkUpdate returns an error if the data change the id of the documents,
+// the caller is not a member and the data change their owner or the caller
+// cannot write the attributes of the data
+func checkUpdate(ctx context.Context, t target, data map[string]any) error {
+	if _, ok := data["id"]; ok {
+		return errIDChange
+	}
+
+	if _, ok := data[goappbuild.OwnerColumn]; ok && !t.grant.Member {
+		return errOwnerChange
+	}
+
+	return t.checkWrite(ctx, data, false)
+}
+
+// checkIDs returns an error if the query compares the id of the documents
+// with a value that is not an id of the collection, which the database
+// would fail to convert
+func (t target) checkIDs(param goappbuild.Q) error {
+	for _, op := range param.Where() {
+		if op.Column() != "id" || (op.Op() != goappbuild.OpEq && op.Op() != goappbuild.OpNeq) {
+			continue
+		}
+
+		sid, ok := op.Value().(string)
+		if !ok {
+			continue
+		}
+
+		if _, err := t.collection.Options.IDStrategy.ParseID(sid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setOwner sets the owner of a new document when the collection has one.
+// Callers that are allowed by the collection rules always own the documents
+// they create, members own them unless they set the owner explicitly.
+func setOwner(ctx context.Context, t target, data map[string]any) {
+	if _, ok := t.collection.Attributes[goappbuild.OwnerColumn]; !ok {
+		return
+	}
+
+	identity, _ := goappbuild.IdentityFromContext(ctx)
+
+	if !t.grant.Member {
+		delete(data, goappbuild.OwnerColumn)
+	} else if _, ok := data[goappbuild.OwnerColumn]; ok {
+		return
+	}
+
+	if identity.UserID != uuid.Nil {
+		data[goappbuild.OwnerColumn] = identity.UserID
+	}
+}
+
+// setID sets the id of a new document according to the id strategy
+func setID(ids goappbuild.IDStrategy, data map[string]any) error {
+	v, ok := data["id"]
+
+	if ids.ServerGenerated() {
+		if ok {
+			return goappbuild.Errorf(goappbuild.EValidation, "the id is generated by the server")
+		}
+
+		id, err := ids.NewID()
+		if err != nil {
+			return err
+		}
+
+		if id != nil {
+			data["id"] = id
+		}
+
+		return nil
+	}
+
+	sid, isString := v.(string)
+	if !ok || !isString {
+		return goappbuild.Errorf(goappbuild.EValidation, "a string id is required")
+	}
+
+	id, err := ids.ParseID(sid)
+	if err != nil {
+		return err
+	}
+
+	data["id"] = id
+
 	return nil
 }
+
+// target is a collection the caller is authorized to access
+type target struct {
+	project    goappbuild.Project
+	collection goappbuild.Collection
+	grant      authz.Grant
+}
+
+// authorize authorizes the action on the documents of the collection
+func (q *queryService) authorize(
+	ctx context.Context,
+	storage goappbuild.Storage,
+	projectID uuid.UUID,
+	collectionName string,
+	action goappbuild.Action,
+) (target, error) {
+	project, collection, grant, err := authz.Documents(ctx, storage, projectID, collectionName, action)
+	if err != nil {
+		return target{}, err
+	}
+
+	ans := target{
+		project:    project,
+		collection: collection,
+		grant:      grant,
+	}
+
+	return ans, nil
+}
+
+// query returns the base query of the target
+func (t target) query() goappbuild.Q {
+	return t.prepare(goappbuild.Q{}.Table(t.collection.Name))
+}
+
+// prepare prepares the query for the collection and restricts it
+// to the documents the caller owns or that are shared with it when needed
+func (t target) prepare(param goappbuild.Q) goappbuild.Q {
+	param = prepare(param, t.project, t.collection)
+
+	if t.grant.Restricted() {
+		param = param.Accessible(t.grant.Access(t.project.ID))
+	}
+
+	return param
+}
+
+// query returns the base query for the collection of the project
+func query(project goappbuild.Project, collection goappbuild.Collection) goappbuild.Q {
+	return prepare(goappbuild.Q{}.Table(collection.Name), project, collection)
+}
+
+// prepare sets the schema of the query and applies the collection options
+func prepare(param goappbuild.Q, project goappbuild.Project, collection goappbuild.Collection) goappbuild.Q {
+	param = param.Schema(project.Name)
+
+	if collection.Options.SoftDelete {
+		param = param.SoftDelete()
+	}
+
+	return param
+}
+
+// trash returns the values that move a document to the trash
+func trash() map[string]any {
+	now := time.Now().UTC()
+
+	return map[string]any{
+		"deleted_at": now,
+		"updated_at": now,
+	}
+}
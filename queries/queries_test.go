@@ -0,0 +1,1044 @@
+package queries_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/events"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/queries"
+)
+
+func setup(t *testing.T) (*memstore.Storage, goappbuild.Project) {
+	t.Helper()
+
+	storage := memstore.New()
+	ctx := context.Background()
+
+	project := goappbuild.Project{UserID: uuid.New(), Name: "owned"}
+	require.NoError(t, storage.ProjectRepo.Create(ctx, &project))
+
+	owner := goappbuild.ProjectMember{ProjectID: project.ID, UserID: project.UserID, Role: goappbuild.RoleOwner}
+	require.NoError(t, storage.MemberRepo.Create(ctx, &owner))
+
+	collection := goappbuild.Collection{ProjectID: project.ID, Name: "posts"}
+	require.NoError(t, storage.CollectionRepo.Create(ctx, project.Name, &collection))
+
+	return storage, project
+}
+
+func Test_QueryService_CrossProjectAccess(t *testing.T) {
+	id := uuid.NewString()
+	data := func() map[string]any {
+		return map[string]any{"title": "hello"}
+	}
+
+	calls := map[string]func(goappbuild.QueryService, context.Context, uuid.UUID) error{
+		"get": func(s goappbuild.QueryService, ctx context.Context, projectID uuid.UUID) error {
+			_, err := s.Get(ctx, projectID, goappbuild.Q{}.Table("posts").Equal("id", id))
+			return err
+		},
+		"list": func(s goappbuild.QueryService, ctx context.Context, projectID uuid.UUID) error {
+			_, err := s.List(ctx, projectID, goappbuild.Q{}.Table("posts"))
+			return err
+		},
+		"create": func(s goappbuild.QueryService, ctx context.Context, projectID uuid.UUID) error {
+			_, err := s.Create(ctx, projectID, "posts", data())
+			return err
+		},
+		"update": func(s goappbuild.QueryService, ctx context.Context, projectID uuid.UUID) error {
+			_, err := s.Update(ctx, projectID, "posts", id, data())
+			return err
+		},
+		"delete": func(s goappbuild.QueryService, ctx context.Context, projectID uuid.UUID) error {
+			return s.Delete(ctx, projectID, "posts", id)
+		},
+		"update where": func(s goappbuild.QueryService, ctx context.Context, projectID uuid.UUID) error {
+			q := goappbuild.Q{}.Table("posts").Equal("title", "hello")
+			_, err := s.UpdateWhere(ctx, projectID, q, data(), goappbuild.BulkOptions{})
+			return err
+		},
+		"delete where": func(s goappbuild.QueryService, ctx context.Context, projectID uuid.UUID) error {
+			q := goappbuild.Q{}.Table("posts").Equal("title", "hello")
+			_, err := s.DeleteWhere(ctx, projectID, q, goappbuild.BulkOptions{})
+			return err
+		},
+		"batch": func(s goappbuild.QueryService, ctx context.Context, projectID uuid.UUID) error {
+			ops := []goappbuild.BatchOperation{{Type: goappbuild.BatchOpCreate, Collection: "posts", Data: data()}}
+			_, err := s.Batch(ctx, projectID, ops)
+			return err
+		},
+	}
+
+	for name, call := range calls {
+		call := call
+
+		t.Run(name, func(t *testing.T) {
+			storage, project := setup(t)
+			svc := queries.New(storage)
+
+			other := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: uuid.New()})
+			err := call(svc, other, project.ID)
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+			otherKey := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{
+				APIKeyID:  uuid.New(),
+				ProjectID: uuid.New(),
+				Scopes:    []string{goappbuild.ScopeAll},
+			})
+			err = call(svc, otherKey, project.ID)
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+			err = call(svc, context.Background(), project.ID)
+			require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+			require.Zero(t, storage.QueryRepo.Calls())
+
+			owner := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+			require.NoError(t, call(svc, owner, project.ID))
+			require.NotZero(t, storage.QueryRepo.Calls())
+		})
+	}
+}
+
+func Test_QueryService_Roles(t *testing.T) {
+	storage, project := setup(t)
+	svc := queries.New(storage)
+
+	viewer := goappbuild.ProjectMember{ProjectID: project.ID, UserID: uuid.New(), Role: goappbuild.RoleViewer}
+	require.NoError(t, storage.MemberRepo.Create(context.Background(), &viewer))
+
+	ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: viewer.UserID})
+
+	_, err := svc.List(ctx, project.ID, goappbuild.Q{}.Table("posts"))
+	require.NoError(t, err)
+
+	_, err = svc.Create(ctx, project.ID, "posts", map[string]any{"title": "hello"})
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+	ops := []goappbuild.BatchOperation{{Type: goappbuild.BatchOpGet, Collection: "posts", ID: uuid.NewString()}}
+	_, err = svc.Batch(ctx, project.ID, ops)
+	require.NoError(t, err)
+}
+
+func Test_QueryService_InvalidID(t *testing.T) {
+	storage, project := setup(t)
+	svc := queries.New(storage)
+	ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+
+	q := goappbuild.Q{}.Table("posts")
+
+	_, err := svc.Get(ctx, project.ID, q.Equal("id", "not-a-uuid"))
+	require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+	_, err = svc.List(ctx, project.ID, q.NotEqual("id", "not-a-uuid"))
+	require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+}
+
+func Test_QueryService_KeepsCallerData(t *testing.T) {
+	storage, project := setup(t)
+	svc := queries.New(storage)
+
+	ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+
+	data := map[string]any{"title": "hello"}
+	expected := map[string]any{"title": "hello"}
+
+	doc, err := svc.Create(ctx, project.ID, "posts", data)
+	require.NoError(t, err)
+	require.Contains(t, doc.Values, "id")
+	require.Equal(t, expected, data)
+
+	doc, err = svc.Update(ctx, project.ID, "posts", uuid.NewString(), data)
+	require.NoError(t, err)
+	require.Contains(t, doc.Values, "updated_at")
+	require.Equal(t, expected, data)
+
+	q := goappbuild.Q{}.Table("posts").Equal("title", "hello")
+	_, err = svc.UpdateWhere(ctx, project.ID, q, data, goappbuild.BulkOptions{})
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+
+	ops := []goappbuild.BatchOperation{{Type: goappbuild.BatchOpCreate, Collection: "posts", Data: data}}
+	_, err = svc.Batch(ctx, project.ID, ops)
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+}
+
+func Test_QueryService_Rules(t *testing.T) {
+	storage, project := setup(t)
+	svc := queries.New(storage)
+	ctx := context.Background()
+
+	collection := goappbuild.Collection{
+		ProjectID: project.ID,
+		Name:      "notes",
+		Attributes: map[string]goappbuild.Attribute{
+			goappbuild.OwnerColumn: goappbuild.OwnerAttribute(),
+		},
+		Options: goappbuild.CollectionOptions{
+			Rules: goappbuild.CollectionRules{
+				Read:   goappbuild.RulePublic,
+				Create: goappbuild.RuleAuthenticated,
+				Update: goappbuild.RuleOwner,
+			},
+		},
+	}
+	require.NoError(t, storage.CollectionRepo.Create(ctx, project.Name, &collection))
+
+	user := uuid.New()
+	userCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: user})
+
+	t.Run("test public read allows anonymous callers", func(t *testing.T) {
+		_, err := svc.List(ctx, project.ID, goappbuild.Q{}.Table("notes"))
+		require.NoError(t, err)
+	})
+
+	t.Run("test members rule is the default", func(t *testing.T) {
+		_, err := svc.List(userCtx, project.ID, goappbuild.Q{}.Table("posts"))
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		err = svc.Delete(userCtx, project.ID, "notes", uuid.NewString())
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test authenticated create requires an identity", func(t *testing.T) {
+		_, err := svc.Create(ctx, project.ID, "notes", map[string]any{"title": "hello"})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		doc, err := svc.Create(userCtx, project.ID, "notes", map[string]any{
+			"title":                "hello",
+			goappbuild.OwnerColumn: uuid.New(),
+		})
+		require.NoError(t, err)
+		require.Equal(t, user, doc.Values[goappbuild.OwnerColumn])
+	})
+
+	t.Run("test owner update is restricted to the documents of the caller", func(t *testing.T) {
+		_, err := svc.Update(userCtx, project.ID, "notes", uuid.NewString(), map[string]any{"title": "bye"})
+		require.NoError(t, err)
+
+		access := storage.QueryRepo.Last().GetAccess()
+		require.NotNil(t, access)
+		require.Equal(t, user, access.OwnerID)
+		require.Equal(t, user, access.GranteeID)
+		require.Equal(t, []goappbuild.Permission{goappbuild.PermissionWrite}, access.Permissions)
+
+		_, err = svc.Update(userCtx, project.ID, "notes", uuid.NewString(), map[string]any{goappbuild.OwnerColumn: uuid.New()})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+}
+
+func Test_QueryService_EndUsers(t *testing.T) {
+	storage, project := setup(t)
+	svc := queries.New(storage)
+
+	collection := goappbuild.Collection{
+		ProjectID: project.ID,
+		Name:      "notes",
+		Options: goappbuild.CollectionOptions{
+			Rules: goappbuild.CollectionRules{Read: goappbuild.RuleAuthenticated},
+		},
+	}
+	require.NoError(t, storage.CollectionRepo.Create(context.Background(), project.Name, &collection))
+
+	endUser := func(projectID uuid.UUID) context.Context {
+		return goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{
+			UserID:    uuid.New(),
+			SessionID: uuid.New(),
+			ProjectID: projectID,
+		})
+	}
+
+	_, err := svc.List(endUser(project.ID), project.ID, goappbuild.Q{}.Table("notes"))
+	require.NoError(t, err)
+
+	_, err = svc.List(endUser(uuid.New()), project.ID, goappbuild.Q{}.Table("notes"))
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+	// end users are never members, even with the id of a member
+	ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{
+		UserID:    project.UserID,
+		SessionID: uuid.New(),
+		ProjectID: project.ID,
+	})
+
+	_, err = svc.List(ctx, project.ID, goappbuild.Q{}.Table("posts"))
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+}
+
+func Test_QueryService_FieldAccess(t *testing.T) {
+	storage, project := setup(t)
+	svc := queries.New(storage)
+	ctx := context.Background()
+
+	collection := goappbuild.Collection{
+		ProjectID: project.ID,
+		Name:      "tickets",
+		Attributes: map[string]goappbuild.Attribute{
+			"title":  {Name: "title", Type: goappbuild.AttributeTypeString},
+			"notes":  {Name: "notes", Type: goappbuild.AttributeTypeString, Access: goappbuild.FieldAccess{Private: true}},
+			"status": {Name: "status", Type: goappbuild.AttributeTypeString, Access: goappbuild.FieldAccess{ReadOnly: true}},
+			"ref":    {Name: "ref", Type: goappbuild.AttributeTypeString, Access: goappbuild.FieldAccess{WriteOnce: true}},
+			"cost": {
+				Name:   "cost",
+				Type:   goappbuild.AttributeTypeNumeric,
+				Access: goappbuild.FieldAccess{ReadRole: goappbuild.RoleDeveloper},
+			},
+		},
+		Options: goappbuild.CollectionOptions{
+			Rules: goappbuild.CollectionRules{Read: goappbuild.RulePublic, Create: goappbuild.RulePublic},
+		},
+	}
+	require.NoError(t, storage.CollectionRepo.Create(ctx, project.Name, &collection))
+
+	viewer := goappbuild.ProjectMember{ProjectID: project.ID, UserID: uuid.New(), Role: goappbuild.RoleViewer}
+	require.NoError(t, storage.MemberRepo.Create(ctx, &viewer))
+
+	ownerCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: project.UserID})
+	viewerCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: viewer.UserID})
+	keyCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{
+		APIKeyID:  uuid.New(),
+		ProjectID: project.ID,
+		Scopes:    []string{goappbuild.ScopeAll},
+	})
+
+	data := func() map[string]any {
+		return map[string]any{"title": "hello", "notes": "secret", "ref": "T-1", "cost": 10}
+	}
+
+	t.Run("test private attributes are never returned", func(t *testing.T) {
+		for _, ctx := range []context.Context{ownerCtx, keyCtx, ctx} {
+			doc, err := svc.Create(ctx, project.ID, "tickets", data())
+			require.NoError(t, err)
+			require.NotContains(t, doc.Values, "notes")
+			require.Equal(t, "hello", doc.Values["title"])
+		}
+	})
+
+	t.Run("test read role hides attributes", func(t *testing.T) {
+		doc, err := svc.Create(ownerCtx, project.ID, "tickets", data())
+		require.NoError(t, err)
+		require.Contains(t, doc.Values, "cost")
+
+		doc, err = svc.Create(keyCtx, project.ID, "tickets", data())
+		require.NoError(t, err)
+		require.Contains(t, doc.Values, "cost")
+
+		doc, err = svc.Create(ctx, project.ID, "tickets", data())
+		require.NoError(t, err)
+		require.NotContains(t, doc.Values, "cost")
+
+		_, err = svc.List(viewerCtx, project.ID, goappbuild.Q{}.Table("tickets").OrderAsc("cost"))
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.List(ownerCtx, project.ID, goappbuild.Q{}.Table("tickets").OrderAsc("cost"))
+		require.NoError(t, err)
+	})
+
+	t.Run("test private attributes cannot be filtered", func(t *testing.T) {
+		q := goappbuild.Q{}.Table("tickets").Equal("notes", "secret")
+
+		_, err := svc.List(ownerCtx, project.ID, q)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.Get(ctx, project.ID, goappbuild.Q{}.Table("tickets").Select("notes"))
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.DeleteWhere(ownerCtx, project.ID, q, goappbuild.BulkOptions{DryRun: true})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test read only attributes are set by api keys only", func(t *testing.T) {
+		withStatus := data()
+		withStatus["status"] = "closed"
+
+		_, err := svc.Create(ownerCtx, project.ID, "tickets", withStatus)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.Update(ownerCtx, project.ID, "tickets", uuid.NewString(), map[string]any{"status": "open"})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		doc, err := svc.Create(keyCtx, project.ID, "tickets", withStatus)
+		require.NoError(t, err)
+		require.Equal(t, "closed", doc.Values["status"])
+	})
+
+	t.Run("test write once attributes are set at create only", func(t *testing.T) {
+		_, err := svc.Update(keyCtx, project.ID, "tickets", uuid.NewString(), map[string]any{"ref": "T-2"})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		q := goappbuild.Q{}.Table("tickets").Equal("title", "hello")
+
+		_, err = svc.UpdateWhere(ownerCtx, project.ID, q, map[string]any{"ref": "T-2"}, goappbuild.BulkOptions{})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		doc, err := svc.Update(ownerCtx, project.ID, "tickets", uuid.NewString(), map[string]any{"title": "bye"})
+		require.NoError(t, err)
+		require.Equal(t, "bye", doc.Values["title"])
+	})
+
+	t.Run("test only members read the trash", func(t *testing.T) {
+		for _, q := range []goappbuild.Q{
+			goappbuild.Q{}.Table("tickets").IncludeDeleted(),
+			goappbuild.Q{}.Table("tickets").OnlyDeleted(),
+		} {
+			_, err := svc.List(ctx, project.ID, q)
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+			_, err = svc.Get(ctx, project.ID, q.Equal("id", uuid.NewString()))
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+			_, err = svc.List(viewerCtx, project.ID, q)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("test deleted_at is set by delete and restore only", func(t *testing.T) {
+		trashed := map[string]any{"deleted_at": "2000-01-01T00:00:00Z"}
+
+		for _, ctx := range []context.Context{ownerCtx, keyCtx} {
+			withDeleted := data()
+			withDeleted["deleted_at"] = trashed["deleted_at"]
+
+			_, err := svc.Create(ctx, project.ID, "tickets", withDeleted)
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+			_, err = svc.Update(ctx, project.ID, "tickets", uuid.NewString(), trashed)
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+			q := goappbuild.Q{}.Table("tickets").Equal("title", "hello")
+
+			_, err = svc.UpdateWhere(ctx, project.ID, q, trashed, goappbuild.BulkOptions{})
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+		}
+	})
+}
+
+func Test_ShareService(t *testing.T) {
+	storage, project := setup(t)
+	secret := []byte("test-secret")
+	svc := queries.NewShareService(storage, queries.ShareConfig{Secret: secret})
+	ctx := context.Background()
+
+	collection := goappbuild.Collection{
+		ProjectID: project.ID,
+		Name:      "invoices",
+		Attributes: map[string]goappbuild.Attribute{
+			"number": {Name: "number", Type: goappbuild.AttributeTypeString},
+			"notes":  {Name: "notes", Type: goappbuild.AttributeTypeString, Access: goappbuild.FieldAccess{Private: true}},
+			"margin": {
+				Name:   "margin",
+				Type:   goappbuild.AttributeTypeNumeric,
+				Access: goappbuild.FieldAccess{ReadRole: goappbuild.RoleDeveloper},
+			},
+		},
+	}
+	require.NoError(t, storage.CollectionRepo.Create(ctx, project.Name, &collection))
+
+	viewer := goappbuild.ProjectMember{ProjectID: project.ID, UserID: uuid.New(), Role: goappbuild.RoleViewer}
+	require.NoError(t, storage.MemberRepo.Create(ctx, &viewer))
+
+	ownerCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: project.UserID})
+	viewerCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: viewer.UserID})
+	publicCtx := goappbuild.ContextWithClientIP(ctx, "203.0.113.7")
+
+	docReq := goappbuild.CreateShareLinkRequest{
+		ProjectID:  project.ID,
+		Collection: "invoices",
+		DocumentID: uuid.NewString(),
+	}
+
+	t.Run("test only writers can share", func(t *testing.T) {
+		_, _, err := svc.Create(viewerCtx, docReq)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, _, err = svc.Create(ctx, docReq)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test invalid requests", func(t *testing.T) {
+		req := docReq
+		req.Filters = []goappbuild.Filter{{Column: "number", Op: "eq", Value: "1"}}
+
+		_, _, err := svc.Create(ownerCtx, req)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		req.DocumentID = ""
+		req.TTL = goappbuild.MaxShareLinkTTL + time.Hour
+
+		_, _, err = svc.Create(ownerCtx, req)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test views cannot filter hidden attributes", func(t *testing.T) {
+		for _, column := range []string{"notes", "margin"} {
+			req := goappbuild.CreateShareLinkRequest{
+				ProjectID:  project.ID,
+				Collection: "invoices",
+				Filters:    []goappbuild.Filter{{Column: column, Op: "gt", Value: 1}},
+			}
+
+			_, _, err := svc.Create(ownerCtx, req)
+			require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+		}
+	})
+
+	t.Run("test open a shared document", func(t *testing.T) {
+		l, token, err := svc.Create(ownerCtx, docReq)
+		require.NoError(t, err)
+		require.NotEmpty(t, token)
+		require.Equal(t, project.UserID, l.CreatedBy)
+
+		content, err := svc.Open(publicCtx, goappbuild.OpenShareRequest{Token: token, UserAgent: "test"})
+		require.NoError(t, err)
+		require.Len(t, content.Documents, 1)
+		require.Equal(t, l.ID, content.Link.ID)
+
+		accesses, err := svc.Accesses(ownerCtx, project.ID, l.ID, 0)
+		require.NoError(t, err)
+		require.Len(t, accesses, 1)
+		require.Equal(t, "203.0.113.7", accesses[0].IP)
+		require.Equal(t, "test", accesses[0].UserAgent)
+
+		_, err = svc.Accesses(viewerCtx, project.ID, l.ID, 0)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		require.NoError(t, svc.Revoke(ownerCtx, project.ID, l.ID))
+
+		_, err = svc.Open(publicCtx, goappbuild.OpenShareRequest{Token: token})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test open a shared view", func(t *testing.T) {
+		req := goappbuild.CreateShareLinkRequest{
+			ProjectID:  project.ID,
+			Collection: "invoices",
+			Filters:    []goappbuild.Filter{{Column: "number", Op: "starts_with", Value: "2024-"}},
+		}
+
+		_, token, err := svc.Create(ownerCtx, req)
+		require.NoError(t, err)
+
+		_, err = svc.Open(publicCtx, goappbuild.OpenShareRequest{Token: token, Limit: 10})
+		require.NoError(t, err)
+
+		q := storage.QueryRepo.Last()
+		require.Equal(t, project.Name, q.GetSchema())
+		require.Equal(t, "invoices", q.GetTable())
+		require.Len(t, q.Where(), 1)
+		require.Equal(t, 10, q.GetLimit())
+
+		links, err := svc.List(ownerCtx, project.ID, "invoices")
+		require.NoError(t, err)
+		require.Len(t, links, 2)
+	})
+
+	t.Run("test invalid tokens", func(t *testing.T) {
+		req := docReq
+		req.TTL = time.Nanosecond
+
+		_, expired, err := svc.Create(ownerCtx, req)
+		require.NoError(t, err)
+
+		_, token, err := svc.Create(ownerCtx, docReq)
+		require.NoError(t, err)
+
+		other := queries.NewShareService(storage, queries.ShareConfig{Secret: []byte("other-secret")})
+
+		_, err = other.Open(publicCtx, goappbuild.OpenShareRequest{Token: token})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		for _, tok := range []string{expired, token + "x", "invalid"} {
+			_, err := svc.Open(publicCtx, goappbuild.OpenShareRequest{Token: tok})
+			require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+		}
+	})
+}
+
+func Test_ACLService(t *testing.T) {
+	storage, project := setup(t)
+	svc := queries.NewACLService(storage)
+	querySvc := queries.New(storage)
+	ctx := context.Background()
+
+	viewer := goappbuild.ProjectMember{ProjectID: project.ID, UserID: uuid.New(), Role: goappbuild.RoleViewer}
+	require.NoError(t, storage.MemberRepo.Create(ctx, &viewer))
+
+	endUser := goappbuild.EndUser{ProjectID: project.ID, Email: "reader@example.com"}
+	require.NoError(t, storage.EndUserRepo.Create(ctx, &endUser))
+
+	ownerCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: project.UserID})
+	viewerCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: viewer.UserID})
+	endUserCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{
+		UserID:    endUser.ID,
+		ProjectID: project.ID,
+		SessionID: uuid.New(),
+	})
+
+	documentID := uuid.New()
+
+	req := goappbuild.ShareDocumentRequest{
+		ProjectID:   project.ID,
+		Collection:  "posts",
+		DocumentID:  documentID.String(),
+		PrincipalID: endUser.ID,
+		Permission:  goappbuild.PermissionRead,
+	}
+
+	t.Run("test documents are not shared by default", func(t *testing.T) {
+		_, err := querySvc.List(endUserCtx, project.ID, goappbuild.Q{}.Table("posts"))
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test only writers can share", func(t *testing.T) {
+		_, err := svc.Share(viewerCtx, req)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.Share(endUserCtx, req)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test invalid requests", func(t *testing.T) {
+		invalid := req
+		invalid.Permission = "admin"
+
+		_, err := svc.Share(ownerCtx, invalid)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		invalid = req
+		invalid.PrincipalID = uuid.New()
+
+		_, err = svc.Share(ownerCtx, invalid)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test shared documents are readable", func(t *testing.T) {
+		e, err := svc.Share(ownerCtx, req)
+		require.NoError(t, err)
+		require.Equal(t, goappbuild.PrincipalUser, e.PrincipalType)
+		require.Equal(t, project.UserID, e.CreatedBy)
+
+		_, err = querySvc.List(endUserCtx, project.ID, goappbuild.Q{}.Table("posts"))
+		require.NoError(t, err)
+
+		access := storage.QueryRepo.Last().GetAccess()
+		require.NotNil(t, access)
+		require.Equal(t, project.ID, access.ProjectID)
+		require.Equal(t, uuid.Nil, access.OwnerID)
+		require.Equal(t, endUser.ID, access.GranteeID)
+		require.Equal(t, []goappbuild.Permission{goappbuild.PermissionRead, goappbuild.PermissionWrite}, access.Permissions)
+
+		// read access does not allow updates
+		_, err = querySvc.Update(endUserCtx, project.ID, "posts", documentID.String(), map[string]any{"title": "bye"})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test sharing again replaces the permission", func(t *testing.T) {
+		write := req
+		write.Permission = goappbuild.PermissionWrite
+
+		_, err := svc.Share(ownerCtx, write)
+		require.NoError(t, err)
+
+		entries, err := svc.List(ownerCtx, project.ID, "posts", documentID.String())
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, goappbuild.PermissionWrite, entries[0].Permission)
+
+		_, err = querySvc.Update(endUserCtx, project.ID, "posts", documentID.String(), map[string]any{"title": "bye"})
+		require.NoError(t, err)
+
+		err = querySvc.Delete(endUserCtx, project.ID, "posts", documentID.String())
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test unshare", func(t *testing.T) {
+		require.NoError(t, svc.Unshare(ownerCtx, project.ID, "posts", documentID.String(), endUser.ID))
+
+		err := svc.Unshare(ownerCtx, project.ID, "posts", documentID.String(), endUser.ID)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		_, err = querySvc.Get(endUserCtx, project.ID, goappbuild.Q{}.Table("posts").Equal("id", documentID))
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test documents shared with a team are readable by its members", func(t *testing.T) {
+		team := goappbuild.Team{ProjectID: project.ID, Name: "readers"}
+		require.NoError(t, storage.TeamRepo.Create(ctx, &team))
+
+		shareTeam := req
+		shareTeam.PrincipalType = goappbuild.PrincipalTeam
+		shareTeam.PrincipalID = team.ID
+
+		e, err := svc.Share(ownerCtx, shareTeam)
+		require.NoError(t, err)
+		require.Equal(t, goappbuild.PrincipalTeam, e.PrincipalType)
+
+		_, err = querySvc.List(endUserCtx, project.ID, goappbuild.Q{}.Table("posts"))
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		require.NoError(t, storage.TeamRepo.AddMember(ctx, team.ID, endUser.ID))
+
+		_, err = querySvc.List(endUserCtx, project.ID, goappbuild.Q{}.Table("posts"))
+		require.NoError(t, err)
+
+		require.NoError(t, svc.Unshare(ownerCtx, project.ID, "posts", documentID.String(), team.ID))
+
+		unknown := shareTeam
+		unknown.PrincipalID = uuid.New()
+
+		_, err = svc.Share(ownerCtx, unknown)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		invalid := shareTeam
+		invalid.PrincipalType = "group"
+
+		_, err = svc.Share(ownerCtx, invalid)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test deleting the document deletes the entries", func(t *testing.T) {
+		_, err := svc.Share(ownerCtx, req)
+		require.NoError(t, err)
+
+		require.NoError(t, querySvc.Delete(ownerCtx, project.ID, "posts", documentID.String()))
+
+		entries, err := svc.List(ownerCtx, project.ID, "posts", documentID.String())
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+}
+
+func Test_QueryService_Events(t *testing.T) {
+	storage, project := setup(t)
+	bus := events.New()
+	svc := queries.New(storage, queries.WithEvents(bus))
+
+	var published []goappbuild.Event
+
+	bus.Subscribe(func(_ context.Context, e goappbuild.Event) {
+		published = append(published, e)
+	})
+
+	ownerCtx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+
+	t.Run("test create", func(t *testing.T) {
+		published = nil
+
+		doc, err := svc.Create(ownerCtx, project.ID, "posts", map[string]any{"title": "hello"})
+		require.NoError(t, err)
+
+		require.Len(t, published, 1)
+		e := published[0]
+		require.Equal(t, goappbuild.EventDocumentCreated, e.Type)
+		require.Equal(t, project.ID, e.ProjectID)
+		require.Equal(t, "posts", e.Collection)
+		require.Equal(t, fmt.Sprint(doc.Values["id"]), e.DocumentID)
+		require.Equal(t, project.UserID, e.ActorID)
+		require.Nil(t, e.Before)
+		require.Equal(t, "hello", e.After.(map[string]any)["title"])
+	})
+
+	t.Run("test update", func(t *testing.T) {
+		published = nil
+
+		id := uuid.NewString()
+
+		_, err := svc.Update(ownerCtx, project.ID, "posts", id, map[string]any{"title": "bye"})
+		require.NoError(t, err)
+
+		require.Len(t, published, 1)
+		require.Equal(t, goappbuild.EventDocumentUpdated, published[0].Type)
+		require.NotNil(t, published[0].Before)
+		require.Equal(t, "bye", published[0].After.(map[string]any)["title"])
+	})
+
+	t.Run("test events keep the attributes the caller cannot read", func(t *testing.T) {
+		collection := goappbuild.Collection{
+			ProjectID: project.ID,
+			Name:      "secrets",
+			Attributes: map[string]goappbuild.Attribute{
+				"notes": {Name: "notes", Type: goappbuild.AttributeTypeString, Access: goappbuild.FieldAccess{Private: true}},
+			},
+		}
+		require.NoError(t, storage.CollectionRepo.Create(context.Background(), project.Name, &collection))
+
+		published = nil
+
+		doc, err := svc.Create(ownerCtx, project.ID, "secrets", map[string]any{"title": "hello", "notes": "created"})
+		require.NoError(t, err)
+		require.NotContains(t, doc.Values, "notes")
+
+		doc, err = svc.Update(ownerCtx, project.ID, "secrets", fmt.Sprint(doc.Values["id"]), map[string]any{"notes": "updated"})
+		require.NoError(t, err)
+		require.NotContains(t, doc.Values, "notes")
+
+		require.Len(t, published, 2)
+		require.Equal(t, "created", published[0].After.(map[string]any)["notes"])
+		require.Equal(t, "updated", published[1].After.(map[string]any)["notes"])
+	})
+
+	t.Run("test delete", func(t *testing.T) {
+		published = nil
+
+		require.NoError(t, svc.Delete(ownerCtx, project.ID, "posts", uuid.NewString()))
+
+		require.Len(t, published, 1)
+		require.Equal(t, goappbuild.EventDocumentDeleted, published[0].Type)
+		require.NotNil(t, published[0].Before)
+		require.Nil(t, published[0].After)
+	})
+
+	t.Run("test batch publishes after the whole batch", func(t *testing.T) {
+		published = nil
+
+		ops := []goappbuild.BatchOperation{
+			{Type: goappbuild.BatchOpCreate, Collection: "posts", Data: map[string]any{"title": "a"}},
+			{Type: goappbuild.BatchOpCreate, Collection: "posts", Data: map[string]any{"title": "b"}},
+		}
+
+		_, err := svc.Batch(ownerCtx, project.ID, ops)
+		require.NoError(t, err)
+		require.Len(t, published, 2)
+
+		published = nil
+
+		ops = append(ops, goappbuild.BatchOperation{Type: "invalid", Collection: "posts"})
+
+		_, err = svc.Batch(ownerCtx, project.ID, ops)
+		require.Error(t, err)
+		require.Empty(t, published)
+	})
+
+	t.Run("test failed writes publish nothing", func(t *testing.T) {
+		published = nil
+
+		other := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: uuid.New()})
+
+		_, err := svc.Create(other, project.ID, "posts", map[string]any{"title": "hello"})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+		require.Empty(t, published)
+	})
+}
+
+func Test_RealtimeService(t *testing.T) {
+	storage, project := setup(t)
+	bus := events.New()
+	svc := queries.NewRealtimeService(storage, bus)
+	ctx := context.Background()
+
+	collection := goappbuild.Collection{
+		ProjectID: project.ID,
+		Name:      "notes",
+		Attributes: map[string]goappbuild.Attribute{
+			goappbuild.OwnerColumn: goappbuild.OwnerAttribute(),
+			"secret":               {Name: "secret", Type: goappbuild.AttributeTypeString, Access: goappbuild.FieldAccess{Private: true}},
+		},
+		Options: goappbuild.CollectionOptions{
+			Rules: goappbuild.CollectionRules{Read: goappbuild.RuleOwner},
+		},
+	}
+	require.NoError(t, storage.CollectionRepo.Create(ctx, project.Name, &collection))
+
+	viewer := goappbuild.ProjectMember{ProjectID: project.ID, UserID: uuid.New(), Role: goappbuild.RoleViewer}
+	require.NoError(t, storage.MemberRepo.Create(ctx, &viewer))
+
+	user := uuid.New()
+
+	ownerCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: project.UserID})
+	viewerCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: viewer.UserID})
+	userCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: user})
+
+	publish := func(typ goappbuild.EventType, values map[string]any) {
+		e := goappbuild.NewEvent(ctx, typ, project.ID)
+		e.Collection = "notes"
+		e.DocumentID = fmt.Sprint(values["id"])
+		e.After = values
+
+		bus.Publish(ctx, e)
+	}
+
+	next := func(t *testing.T, sub goappbuild.Subscription) goappbuild.Change {
+		t.Helper()
+
+		select {
+		case c, ok := <-sub.Changes():
+			require.True(t, ok, "subscription ended: %v", sub.Err())
+			return c
+		case <-time.After(time.Second):
+			require.FailNow(t, "no change delivered")
+			return goappbuild.Change{}
+		}
+	}
+
+	subscribe := func(ctx context.Context, req goappbuild.SubscribeRequest) goappbuild.Subscription {
+		t.Helper()
+
+		req.ProjectID, req.Collection = project.ID, "notes"
+
+		sub, err := svc.Subscribe(ctx, req)
+		require.NoError(t, err)
+		t.Cleanup(sub.Close)
+
+		return sub
+	}
+
+	t.Run("test invalid subscriptions", func(t *testing.T) {
+		req := goappbuild.SubscribeRequest{ProjectID: project.ID, Collection: "notes"}
+
+		_, err := svc.Subscribe(ctx, req)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		invalid := req
+		invalid.DocumentID = uuid.NewString()
+		invalid.Filters = []goappbuild.Filter{{Column: "title", Op: "eq", Value: "a"}}
+
+		_, err = svc.Subscribe(ownerCtx, invalid)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		invalid = req
+		invalid.Filters = []goappbuild.Filter{{Column: "secret", Op: "eq", Value: "a"}}
+
+		_, err = svc.Subscribe(ownerCtx, invalid)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test changes hide private attributes", func(t *testing.T) {
+		sub := subscribe(ownerCtx, goappbuild.SubscribeRequest{})
+
+		id := uuid.New()
+		publish(goappbuild.EventDocumentCreated, map[string]any{"id": id, "title": "hello", "secret": "s"})
+
+		c := next(t, sub)
+		require.Equal(t, goappbuild.EventDocumentCreated, c.Type)
+		require.Equal(t, id.String(), c.DocumentID)
+		require.Nil(t, c.Before)
+		require.Equal(t, "hello", c.After.Values["title"])
+		require.NotContains(t, c.After.Values, "secret")
+	})
+
+	t.Run("test restricted callers only see their documents", func(t *testing.T) {
+		sub := subscribe(userCtx, goappbuild.SubscribeRequest{})
+
+		publish(goappbuild.EventDocumentCreated, map[string]any{"id": uuid.New(), goappbuild.OwnerColumn: uuid.New()})
+
+		id := uuid.New()
+		publish(goappbuild.EventDocumentCreated, map[string]any{"id": id, goappbuild.OwnerColumn: user.String()})
+
+		require.Equal(t, id.String(), next(t, sub).DocumentID)
+	})
+
+	t.Run("test document and filter subscriptions", func(t *testing.T) {
+		id := uuid.New()
+
+		doc := subscribe(ownerCtx, goappbuild.SubscribeRequest{DocumentID: id.String()})
+		open := subscribe(ownerCtx, goappbuild.SubscribeRequest{
+			Filters: []goappbuild.Filter{{Column: "status", Op: "eq", Value: "open"}},
+		})
+
+		publish(goappbuild.EventDocumentCreated, map[string]any{"id": uuid.New(), "status": "closed"})
+		publish(goappbuild.EventDocumentCreated, map[string]any{"id": uuid.New(), "status": "open"})
+		publish(goappbuild.EventDocumentUpdated, map[string]any{"id": id, "status": "open"})
+
+		require.Equal(t, id.String(), next(t, doc).DocumentID)
+		require.Equal(t, "open", next(t, open).After.Values["status"])
+		require.Equal(t, id.String(), next(t, open).DocumentID)
+	})
+
+	t.Run("test subscriptions end when the access is revoked", func(t *testing.T) {
+		svc := queries.NewRealtimeService(storage, bus, queries.WithRealtimeAuthorizationTTL(0))
+
+		sub, err := svc.Subscribe(viewerCtx, goappbuild.SubscribeRequest{ProjectID: project.ID, Collection: "notes"})
+		require.NoError(t, err)
+		t.Cleanup(sub.Close)
+
+		// former members are restricted to their documents by the rules
+		require.NoError(t, storage.MemberRepo.Delete(ctx, project.ID, viewer.UserID))
+
+		id := uuid.New()
+		publish(goappbuild.EventDocumentCreated, map[string]any{"id": uuid.New()})
+		publish(goappbuild.EventDocumentCreated, map[string]any{"id": id, goappbuild.OwnerColumn: viewer.UserID})
+
+		require.Equal(t, id.String(), next(t, sub).DocumentID)
+
+		collection.Options.Rules.Read = goappbuild.RuleMembers
+		require.NoError(t, storage.CollectionRepo.Update(ctx, &collection))
+
+		publish(goappbuild.EventDocumentCreated, map[string]any{"id": uuid.New()})
+
+		select {
+		case _, ok := <-sub.Changes():
+			require.False(t, ok)
+		case <-time.After(time.Second):
+			require.FailNow(t, "subscription not ended")
+		}
+
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(sub.Err()))
+	})
+
+	t.Run("test the authorization is cached", func(t *testing.T) {
+		member := goappbuild.ProjectMember{ProjectID: project.ID, UserID: uuid.New(), Role: goappbuild.RoleViewer}
+		require.NoError(t, storage.MemberRepo.Create(ctx, &member))
+
+		memberCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: member.UserID})
+
+		sub := subscribe(memberCtx, goappbuild.SubscribeRequest{})
+
+		require.NoError(t, storage.MemberRepo.Delete(ctx, project.ID, member.UserID))
+
+		// the subscription is authorized again after the ttl
+		id := uuid.New()
+		publish(goappbuild.EventDocumentCreated, map[string]any{"id": id})
+
+		require.Equal(t, id.String(), next(t, sub).DocumentID)
+	})
+
+	t.Run("test subscriptions end when the credentials expire", func(t *testing.T) {
+		expired := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{
+			UserID:    project.UserID,
+			ExpiresAt: time.Now().Add(-time.Second),
+		})
+
+		_, err := svc.Subscribe(expired, goappbuild.SubscribeRequest{ProjectID: project.ID, Collection: "notes"})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		expiring := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{
+			UserID:    project.UserID,
+			ExpiresAt: time.Now().Add(100 * time.Millisecond),
+		})
+
+		sub := subscribe(expiring, goappbuild.SubscribeRequest{})
+
+		select {
+		case _, ok := <-sub.Changes():
+			require.False(t, ok)
+		case <-time.After(time.Second):
+			require.FailNow(t, "subscription not ended")
+		}
+
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(sub.Err()))
+	})
+
+	t.Run("test slow subscribers fall behind", func(t *testing.T) {
+		svc := queries.NewRealtimeService(storage, bus, queries.WithRealtimeBuffer(1))
+
+		sub, err := svc.Subscribe(ownerCtx, goappbuild.SubscribeRequest{ProjectID: project.ID, Collection: "notes"})
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			publish(goappbuild.EventDocumentCreated, map[string]any{"id": uuid.New()})
+		}
+
+		require.Eventually(t, func() bool {
+			return sub.Err() != nil
+		}, time.Second, 10*time.Millisecond)
+		require.Equal(t, goappbuild.ETooManyRequests, goappbuild.ErrorCode(sub.Err()))
+	})
+}
@@ -0,0 +1,452 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+)
+
+var _ goappbuild.RealtimeService = (*realtimeService)(nil)
+
+// DefaultRealtimeBuffer is the number of events a subscription holds
+// before it falls behind
+const DefaultRealtimeBuffer = 256
+
+// DefaultRealtimeAuthorizationTTL is how long the authorization of a
+// subscriber is reused for its changes
+const DefaultRealtimeAuthorizationTTL = 10 * time.Second
+
+// maxRealtimeGrants is the number of documents shared with a subscriber that
+// a subscription remembers
+const maxRealtimeGrants = 1024
+
+var (
+	errFellBehind         = goappbuild.Errorf(goappbuild.ETooManyRequests, "the subscription fell behind the changes")
+	errCredentialsExpired = goappbuild.Errorf(goappbuild.EUnauthorized, "the credentials expired")
+)
+
+type realtimeService struct {
+	storage goappbuild.Storage
+	bus     goappbuild.EventBus
+	buffer  int
+	ttl     time.Duration
+}
+
+// RealtimeOption configures the realtime service
+type RealtimeOption func(*realtimeService)
+
+// WithRealtimeBuffer sets the number of events a subscription holds while
+// they are authorized and delivered. A subscription that falls further
+// behind is ended, so that a slow subscriber cannot hold the publishers.
+func WithRealtimeBuffer(n int) RealtimeOption {
+	return func(s *realtimeService) {
+		s.buffer = n
+	}
+}
+
+// WithRealtimeAuthorizationTTL sets how long the authorization of a subscriber,
+// and the documents shared with it, are reused for its changes. A subscriber
+// that loses access keeps receiving the changes for at most this long,
+// zero authorizes every change.
+func WithRealtimeAuthorizationTTL(d time.Duration) RealtimeOption {
+	return func(s *realtimeService) {
+		s.ttl = d
+	}
+}
+
+// NewRealtimeService returns a new service that delivers the document events
+// of the bus to the subscribers allowed to read them. In a cluster the bus
+// must carry the events of all the instances.
+func NewRealtimeService(
+	storage goappbuild.Storage,
+	bus goappbuild.EventBus,
+	opts ...RealtimeOption,
+) goappbuild.RealtimeService {
+	ans := realtimeService{
+		storage: storage,
+		bus:     bus,
+		buffer:  DefaultRealtimeBuffer,
+		ttl:     DefaultRealtimeAuthorizationTTL,
+	}
+
+	for _, o := range opts {
+		o(&ans)
+	}
+
+	return &ans
+}
+
+// Subscribe starts a subscription if the caller can read the collection and
+// filter by the attributes of the filters. The subscription ends when the
+// credentials of the caller expire.
+func (s *realtimeService) Subscribe(
+	ctx context.Context,
+	req goappbuild.SubscribeRequest,
+) (goappbuild.Subscription, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	identity, _ := goappbuild.IdentityFromContext(ctx)
+	if identity.Expired(time.Now()) {
+		return nil, errCredentialsExpired
+	}
+
+	sub := subscription{
+		service:  s,
+		req:      req,
+		identity: identity,
+		inbox:    make(chan goappbuild.Event, s.buffer),
+		changes:  make(chan goappbuild.Change),
+		done:     make(chan struct{}),
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := sub.authorize(ctx, uw)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.cache(t, time.Now())
+
+	if req.DocumentID != "" {
+		sub.documentID, err = key(t, req.DocumentID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sub.unsubscribe = s.bus.Subscribe(
+		sub.receive,
+		goappbuild.EventDocumentCreated,
+		goappbuild.EventDocumentUpdated,
+		goappbuild.EventDocumentDeleted,
+	)
+
+	go sub.run(ctx)
+
+	return &sub, nil
+}
+
+var _ goappbuild.Subscription = (*subscription)(nil)
+
+type subscription struct {
+	service *realtimeService
+	req     goappbuild.SubscribeRequest
+	// documentID is the id of the document of the request as the events carry it
+	documentID  string
+	unsubscribe func()
+	// identity is the subscriber, the subscription ends when it expires
+	identity goappbuild.Identity
+
+	// target is the cached authorization of the subscriber and granted the
+	// documents shared with it, they are only used by the run goroutine
+	target       target
+	authorizedAt time.Time
+	granted      map[string]bool
+
+	inbox   chan goappbuild.Event
+	changes chan goappbuild.Change
+	done    chan struct{}
+	once    sync.Once
+
+	mu  sync.Mutex
+	err error
+}
+
+// Changes returns the channel of the changes
+func (o *subscription) Changes() <-chan goappbuild.Change {
+	return o.changes
+}
+
+// Err returns why the subscription ended
+func (o *subscription) Err() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.err
+}
+
+// Close ends the subscription
+func (o *subscription) Close() {
+	o.stop(nil)
+}
+
+// stop ends the subscription with the error, only the first call has effect
+func (o *subscription) stop(err error) {
+	o.once.Do(func() {
+		o.mu.Lock()
+		o.err = err
+		o.mu.Unlock()
+
+		close(o.done)
+	})
+}
+
+// receive queues the events of the subscription. It runs in the goroutine of
+// the publisher, so it ends the subscription instead of waiting when the queue
+// is full.
+func (o *subscription) receive(_ context.Context, e goappbuild.Event) {
+	if !o.matches(e) {
+		return
+	}
+
+	select {
+	case o.inbox <- e:
+	case <-o.done:
+	default:
+		o.stop(errFellBehind)
+	}
+}
+
+// matches returns true if the event is a change of the documents of the request
+func (o *subscription) matches(e goappbuild.Event) bool {
+	switch {
+	case e.ProjectID != o.req.ProjectID || e.Collection != o.req.Collection:
+		return false
+	case o.documentID != "":
+		return e.DocumentID == o.documentID
+	case len(o.req.Filters) > 0:
+		return match(o.req.Filters, snapshot(e.Before)) || match(o.req.Filters, snapshot(e.After))
+	default:
+		return true
+	}
+}
+
+// run authorizes and delivers the queued events until the subscription ends
+func (o *subscription) run(ctx context.Context) {
+	defer close(o.changes)
+	defer o.unsubscribe()
+
+	var expired <-chan time.Time
+
+	if !o.identity.ExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(o.identity.ExpiresAt))
+		defer timer.Stop()
+
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			o.stop(nil)
+			return
+		case <-o.done:
+			return
+		case <-expired:
+			o.stop(errCredentialsExpired)
+			return
+		case e := <-o.inbox:
+			change, ok, err := o.change(ctx, e)
+			if err != nil {
+				o.stop(err)
+				return
+			}
+
+			if !ok {
+				continue
+			}
+
+			select {
+			case o.changes <- change:
+			case <-o.done:
+				return
+			case <-ctx.Done():
+				o.stop(nil)
+				return
+			}
+		}
+	}
+}
+
+// change authorizes the event, since the access of the subscriber may have
+// changed since it subscribed. It returns false when the subscriber cannot
+// see the document and an error when it cannot subscribe anymore.
+func (o *subscription) change(ctx context.Context, e goappbuild.Event) (goappbuild.Change, bool, error) {
+	if o.identity.Expired(time.Now()) {
+		return goappbuild.Change{}, false, errCredentialsExpired
+	}
+
+	t, err := o.authorization(ctx)
+	if err != nil {
+		return goappbuild.Change{}, false, err
+	}
+
+	before, after := snapshot(e.Before), snapshot(e.After)
+
+	visible, err := o.visible(ctx, t, e.DocumentID, after, before)
+	if err != nil || !visible {
+		return goappbuild.Change{}, false, err
+	}
+
+	ans := goappbuild.Change{
+		Type:       e.Type,
+		Collection: e.Collection,
+		DocumentID: e.DocumentID,
+		OccurredAt: e.OccurredAt,
+	}
+
+	if before != nil {
+		doc := t.document(merge(before, nil))
+		ans.Before = &doc
+	}
+
+	if after != nil {
+		doc := t.document(merge(after, nil))
+		ans.After = &doc
+	}
+
+	return ans, true, nil
+}
+
+// authorization returns the cached authorization of the subscriber, or
+// authorizes it again when it is older than the ttl of the service
+func (o *subscription) authorization(ctx context.Context) (target, error) {
+	now := time.Now()
+
+	if now.Sub(o.authorizedAt) < o.service.ttl {
+		return o.target, nil
+	}
+
+	uw, err := o.service.storage.New(ctx)
+	if err != nil {
+		return target{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := o.authorize(ctx, uw)
+	if err != nil {
+		return target{}, err
+	}
+
+	o.cache(t, now)
+
+	return t, nil
+}
+
+// cache caches the authorization and forgets the shared documents
+func (o *subscription) cache(t target, now time.Time) {
+	o.target = t
+	o.authorizedAt = now
+	o.granted = make(map[string]bool)
+}
+
+// authorize authorizes the subscriber to read the collection and to filter
+// by the attributes of the filters
+func (o *subscription) authorize(ctx context.Context, uw goappbuild.Storage) (target, error) {
+	project, collection, grant, err := authz.Documents(
+		ctx, uw, o.req.ProjectID, o.req.Collection, goappbuild.ActionRead,
+	)
+	if err != nil {
+		return target{}, err
+	}
+
+	t := target{
+		project:    project,
+		collection: collection,
+		grant:      grant,
+	}
+
+	q, err := applyFilters(goappbuild.Q{}.Table(collection.Name), o.req.Filters)
+	if err != nil {
+		return target{}, err
+	}
+
+	if err := t.checkRead(q); err != nil {
+		return target{}, err
+	}
+
+	return t, nil
+}
+
+// visible returns true if the subscriber can read the document: restricted
+// subscribers only see the documents they own or that are shared with them.
+// The latest snapshot decides, so a subscriber sees the change that takes
+// a document away from it only if it can still see the document after it.
+func (o *subscription) visible(
+	ctx context.Context,
+	t target,
+	documentID string,
+	snapshots ...map[string]any,
+) (bool, error) {
+	if !t.grant.Restricted() {
+		return true, nil
+	}
+
+	var values map[string]any
+
+	for _, v := range snapshots {
+		if v != nil {
+			values = v
+			break
+		}
+	}
+
+	if t.grant.OwnerID != uuid.Nil && fmt.Sprint(values[goappbuild.OwnerColumn]) == t.grant.OwnerID.String() {
+		return true, nil
+	}
+
+	if t.grant.SharedWith == uuid.Nil {
+		return false, nil
+	}
+
+	if granted, ok := o.granted[documentID]; ok {
+		return granted, nil
+	}
+
+	uw, err := o.service.storage.New(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	granted, err := uw.ACL().Granted(ctx, t.project.ID, t.collection.Name, documentID, t.grant.SharedWith, t.grant.Permissions...)
+	if err != nil {
+		return false, err
+	}
+
+	if len(o.granted) >= maxRealtimeGrants {
+		o.granted = make(map[string]bool)
+	}
+
+	o.granted[documentID] = granted
+
+	return granted, nil
+}
+
+// snapshot returns the values of a document snapshot of an event
+func snapshot(v any) map[string]any {
+	values, _ := v.(map[string]any)
+
+	return values
+}
+
+// match returns true if the values satisfy all the filters
+func match(filters []goappbuild.Filter, values map[string]any) bool {
+	if values == nil {
+		return false
+	}
+
+	for _, f := range filters {
+		if !f.Match(values) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,463 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+)
+
+const (
+	// DefaultSharedDocuments is the number of documents of a shared view
+	// returned when the request has no limit
+	DefaultSharedDocuments = 100
+	// DefaultShareAccesses is the number of accesses listed when the request has no limit
+	DefaultShareAccesses = 100
+
+	// shareAudience is the audience of the share tokens, it keeps them apart
+	// from the other tokens signed with the same secret
+	shareAudience = "share"
+)
+
+var errInvalidShareToken = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid or expired share link")
+
+// ShareConfig is the configuration of the share service
+type ShareConfig struct {
+	// Secret is the key used to sign the share tokens
+	Secret []byte
+}
+
+var _ goappbuild.ShareService = (*shareService)(nil)
+
+type shareService struct {
+	storage goappbuild.Storage
+	cfg     ShareConfig
+}
+
+// NewShareService returns a new share service. The tokens are HS256 signed
+// JWTs that carry the id of the link and expire with it.
+func NewShareService(storage goappbuild.Storage, cfg ShareConfig) goappbuild.ShareService {
+	return &shareService{
+		storage: storage,
+		cfg:     cfg,
+	}
+}
+
+// Create shares a document or a filtered view of a collection. The caller must
+// be allowed to write the collection as a member or an API key. The filters
+// can only use the attributes that anonymous callers can read.
+func (s *shareService) Create(
+	ctx context.Context,
+	req goappbuild.CreateShareLinkRequest,
+) (goappbuild.ShareLink, string, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.ShareLink{}, "", err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.ShareLink{}, "", err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := s.authorize(ctx, uw, req.ProjectID, req.Collection)
+	if err != nil {
+		return goappbuild.ShareLink{}, "", err
+	}
+
+	if req.DocumentID != "" {
+		id, err := t.collection.Options.IDStrategy.ParseID(req.DocumentID)
+		if err != nil {
+			return goappbuild.ShareLink{}, "", err
+		}
+
+		if _, err := uw.Queries().Get(ctx, t.query().Equal("id", id)); err != nil {
+			return goappbuild.ShareLink{}, "", err
+		}
+	} else {
+		q, err := applyFilters(goappbuild.Q{}, req.Filters)
+		if err != nil {
+			return goappbuild.ShareLink{}, "", err
+		}
+
+		if err := shared(t).checkRead(q); err != nil {
+			return goappbuild.ShareLink{}, "", err
+		}
+	}
+
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = goappbuild.DefaultShareLinkTTL
+	}
+
+	identity, _ := goappbuild.IdentityFromContext(ctx)
+
+	l := goappbuild.ShareLink{
+		ProjectID:  req.ProjectID,
+		Collection: req.Collection,
+		DocumentID: req.DocumentID,
+		Filters:    req.Filters,
+		ExpiresAt:  time.Now().UTC().Add(ttl),
+	}
+
+	if !identity.IsAPIKey() {
+		l.CreatedBy = identity.UserID
+	}
+
+	if err := uw.ShareLinks().Create(ctx, &l); err != nil {
+		return goappbuild.ShareLink{}, "", err
+	}
+
+	token, err := s.sign(l)
+	if err != nil {
+		return goappbuild.ShareLink{}, "", err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.ShareLink{}, "", err
+	}
+
+	return l, token, nil
+}
+
+// List returns the share links of a collection, newest first
+func (s *shareService) List(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collection string,
+) ([]goappbuild.ShareLink, error) {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if _, err := s.authorize(ctx, uw, projectID, collection); err != nil {
+		return nil, err
+	}
+
+	return uw.ShareLinks().List(ctx, projectID, collection)
+}
+
+// Revoke revokes a share link of the project
+func (s *shareService) Revoke(ctx context.Context, projectID, id uuid.UUID) error {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if _, err := s.link(ctx, uw, projectID, id); err != nil {
+		return err
+	}
+
+	if err := uw.ShareLinks().Revoke(ctx, projectID, id); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// Accesses returns the latest accesses of a share link of the project
+func (s *shareService) Accesses(
+	ctx context.Context,
+	projectID, id uuid.UUID,
+	limit int,
+) ([]goappbuild.ShareAccess, error) {
+	if limit < 0 || limit > goappbuild.MaxShareAccesses {
+		return nil, goappbuild.Errorf(goappbuild.EValidation, "limit must be between 0 and %d", goappbuild.MaxShareAccesses)
+	}
+
+	if limit == 0 {
+		limit = DefaultShareAccesses
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if _, err := s.link(ctx, uw, projectID, id); err != nil {
+		return nil, err
+	}
+
+	return uw.ShareLinks().ListAccesses(ctx, id, limit)
+}
+
+// Open returns the content of the share link of the token. The documents are
+// read as an anonymous caller would, the attributes that are private or need
+// a role are removed. Every access is logged.
+func (s *shareService) Open(ctx context.Context, req goappbuild.OpenShareRequest) (goappbuild.SharedContent, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.SharedContent{}, err
+	}
+
+	linkID, err := s.parse(req.Token)
+	if err != nil {
+		return goappbuild.SharedContent{}, err
+	}
+
+	ip := goappbuild.ClientIPFromContext(ctx)
+
+	// the token is the authorization, the row level security is bypassed
+	ctx = goappbuild.ContextWithSystem(ctx)
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.SharedContent{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	l, err := uw.ShareLinks().Get(ctx, linkID)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.SharedContent{}, errInvalidShareToken
+		}
+
+		return goappbuild.SharedContent{}, err
+	}
+
+	if !l.IsActive(time.Now().UTC()) {
+		return goappbuild.SharedContent{}, errInvalidShareToken
+	}
+
+	t, err := s.target(ctx, uw, l)
+	if err != nil {
+		return goappbuild.SharedContent{}, err
+	}
+
+	ans := goappbuild.SharedContent{
+		Link: l,
+	}
+
+	if l.IsView() {
+		ans.Documents, err = s.view(ctx, uw, t, l, req)
+	} else {
+		var doc goappbuild.Document
+
+		doc, err = s.document(ctx, uw, t, l)
+		ans.Documents = []goappbuild.Document{doc}
+	}
+
+	if err != nil {
+		return goappbuild.SharedContent{}, err
+	}
+
+	access := goappbuild.ShareAccess{
+		LinkID:    l.ID,
+		IP:        ip,
+		UserAgent: req.UserAgent,
+	}
+
+	if err := uw.ShareLinks().CreateAccess(ctx, &access); err != nil {
+		return goappbuild.SharedContent{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.SharedContent{}, err
+	}
+
+	return ans, nil
+}
+
+// document returns the shared document
+func (s *shareService) document(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	t target,
+	l goappbuild.ShareLink,
+) (goappbuild.Document, error) {
+	id, err := t.collection.Options.IDStrategy.ParseID(l.DocumentID)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	m, err := uw.Queries().Get(ctx, t.query().Equal("id", id))
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	return t.document(m), nil
+}
+
+// view returns a page of the documents matching the filters of the link, newest first
+func (s *shareService) view(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	t target,
+	l goappbuild.ShareLink,
+	req goappbuild.OpenShareRequest,
+) ([]goappbuild.Document, error) {
+	q, err := applyFilters(t.query(), l.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	// the attributes could have been restricted after the link was created
+	if err := t.checkRead(q); err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = DefaultSharedDocuments
+	}
+
+	q = q.OrderDesc("created_at").Limit(limit).Offset(req.Offset)
+
+	items, err := uw.Queries().List(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]goappbuild.Document, len(items))
+	for i := range items {
+		ans[i] = t.document(items[i])
+	}
+
+	return ans, nil
+}
+
+// authorize returns the collection if the caller can manage its share links
+func (s *shareService) authorize(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	projectID uuid.UUID,
+	collection string,
+) (target, error) {
+	project, c, grant, err := authz.Documents(ctx, uw, projectID, collection, goappbuild.ActionUpdate)
+	if err != nil {
+		return target{}, err
+	}
+
+	if !grant.Member {
+		return target{}, goappbuild.Errorf(goappbuild.EForbidden, "only members can share documents")
+	}
+
+	ans := target{
+		project:    project,
+		collection: c,
+		grant:      grant,
+	}
+
+	return ans, nil
+}
+
+// link returns a share link of the project if the caller can manage it
+func (s *shareService) link(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	projectID, id uuid.UUID,
+) (goappbuild.ShareLink, error) {
+	l, err := uw.ShareLinks().Get(ctx, id)
+	if err != nil {
+		return goappbuild.ShareLink{}, err
+	}
+
+	if l.ProjectID != projectID {
+		return goappbuild.ShareLink{}, goappbuild.Errorf(goappbuild.ENotFound, "share link not found")
+	}
+
+	if _, err := s.authorize(ctx, uw, projectID, l.Collection); err != nil {
+		return goappbuild.ShareLink{}, err
+	}
+
+	return l, nil
+}
+
+// target returns the collection of the link for an anonymous reader
+func (s *shareService) target(ctx context.Context, uw goappbuild.Storage, l goappbuild.ShareLink) (target, error) {
+	project, err := uw.Projects().Get(ctx, l.ProjectID)
+	if err != nil {
+		return target{}, err
+	}
+
+	collection, err := uw.Collections().GetByName(ctx, l.ProjectID, l.Collection)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return target{}, errInvalidShareToken
+		}
+
+		return target{}, err
+	}
+
+	return shared(target{project: project, collection: collection}), nil
+}
+
+// sign returns the token of the link
+func (s *shareService) sign(l goappbuild.ShareLink) (string, error) {
+	if len(s.cfg.Secret) == 0 {
+		return "", errors.New("share secret is not configured")
+	}
+
+	claims := jwt.RegisteredClaims{
+		Subject:   l.ID.String(),
+		Audience:  jwt.ClaimStrings{shareAudience},
+		IssuedAt:  jwt.NewNumericDate(l.CreatedAt),
+		ExpiresAt: jwt.NewNumericDate(l.ExpiresAt),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.cfg.Secret)
+}
+
+// parse verifies the token and returns the id of its link
+func (s *shareService) parse(token string) (uuid.UUID, error) {
+	if len(s.cfg.Secret) == 0 {
+		return uuid.Nil, errInvalidShareToken
+	}
+
+	claims := jwt.RegisteredClaims{}
+
+	_, err := jwt.ParseWithClaims(
+		token,
+		&claims,
+		func(*jwt.Token) (any, error) {
+			return s.cfg.Secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(shareAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return uuid.Nil, errInvalidShareToken
+	}
+
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, errInvalidShareToken
+	}
+
+	return id, nil
+}
+
+// shared returns the target as an anonymous caller sees it
+func shared(t target) target {
+	t.grant = authz.Grant{}
+
+	return t
+}
+
+// applyFilters adds the filters to the query
+func applyFilters(q goappbuild.Q, filters []goappbuild.Filter) (goappbuild.Q, error) {
+	var err error
+
+	for _, f := range filters {
+		q, err = f.Apply(q)
+		if err != nil {
+			return q, err
+		}
+	}
+
+	return q, nil
+}
@@ -0,0 +1,114 @@
+package queries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gosom/goappbuild"
+)
+
+// Sweeper periodically purges the trashed documents that are older
+// than the retention period of their collection
+type Sweeper struct {
+	storage  goappbuild.Storage
+	interval time.Duration
+	logger   goappbuild.Logger
+}
+
+// NewSweeper returns a new sweeper that runs every interval and reports
+// the errors of the sweeps to the logger
+func NewSweeper(storage goappbuild.Storage, interval time.Duration, logger goappbuild.Logger) *Sweeper {
+	if logger == nil {
+		logger = goappbuild.NopLogger()
+	}
+
+	return &Sweeper{
+		storage:  storage,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run sweeps the trash until the context is canceled
+func (s *Sweeper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Sweep(ctx); err != nil {
+			s.logger.Printf("trash sweeper: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep purges the expired trashed documents of all the collections
+// and returns the number of purged documents
+func (s *Sweeper) Sweep(ctx context.Context) (int64, error) {
+	collections, err := s.storage.Collections().ListWithRetention(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		total int64
+		errs  []error
+	)
+
+	for i := range collections {
+		n, err := s.sweep(ctx, collections[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("collection %s: %w", collections[i].ID, err))
+
+			continue
+		}
+
+		total += n
+	}
+
+	return total, errors.Join(errs...)
+}
+
+func (s *Sweeper) sweep(ctx context.Context, collection goappbuild.Collection) (int64, error) {
+	ctx = goappbuild.ContextWithSystem(ctx)
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	project, err := uw.Projects().Get(ctx, collection.ProjectID)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().UTC().Add(-collection.Options.Retention)
+
+	param := query(project, collection).
+		OnlyDeleted().
+		LessThan("deleted_at", cutoff)
+
+	ids, err := uw.Queries().DeleteWhere(ctx, param)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := unshareDeleted(ctx, uw, project.ID, collection.Name, ids); err != nil {
+		return 0, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return int64(len(ids)), nil
+}
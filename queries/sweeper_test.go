@@ -0,0 +1,128 @@
+package queries_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/internal/pgtest"
+	"github.com/gosom/goappbuild/queries"
+)
+
+// recordingLogger records the logged messages
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+	logged   chan struct{}
+}
+
+func (o *recordingLogger) Printf(format string, v ...any) {
+	o.mu.Lock()
+	o.messages = append(o.messages, fmt.Sprintf(format, v...))
+	o.mu.Unlock()
+
+	select {
+	case o.logged <- struct{}{}:
+	default:
+	}
+}
+
+func Test_Sweeper_ReportsErrors(t *testing.T) {
+	storage := memstore.New()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	defer cancel()
+
+	// the project of the collection does not exist
+	collection := goappbuild.Collection{
+		ProjectID: uuid.New(),
+		Name:      "posts",
+		Options:   goappbuild.CollectionOptions{SoftDelete: true, Retention: time.Hour},
+	}
+	require.NoError(t, storage.CollectionRepo.Create(ctx, "missing", &collection))
+
+	logger := recordingLogger{logged: make(chan struct{}, 1)}
+	sweeper := queries.NewSweeper(storage, time.Hour, &logger)
+
+	_, err := sweeper.Sweep(ctx)
+	require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+	done := make(chan error)
+
+	go func() {
+		done <- sweeper.Run(ctx)
+	}()
+
+	select {
+	case <-logger.logged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the error of the sweep was not logged")
+	}
+
+	cancel()
+
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	require.Len(t, logger.messages, 1)
+	require.Contains(t, logger.messages[0], collection.ID.String())
+}
+
+func Test_Sweeper_Postgres(t *testing.T) {
+	storage, project, owner := pgSetup(t)
+
+	pgtest.Collection(t, storage, owner, project.ID, "notes", goappbuild.CollectionOptions{
+		SoftDelete: true,
+		Retention:  time.Hour,
+	})
+
+	s := queries.New(storage)
+
+	ids := make(map[string]string)
+
+	for _, title := range []string{"live", "trashed", "expired"} {
+		doc, err := s.Create(owner, project.ID, "notes", map[string]any{"title": title})
+		require.NoError(t, err)
+
+		ids[title] = fmt.Sprint(doc.Values["id"])
+	}
+
+	require.NoError(t, s.Delete(owner, project.ID, "notes", ids["trashed"]))
+	require.NoError(t, s.Delete(owner, project.ID, "notes", ids["expired"]))
+
+	// the document was trashed longer than the retention ago
+	ctx := goappbuild.ContextWithSystem(context.Background())
+
+	uw, err := storage.New(ctx)
+	require.NoError(t, err)
+
+	defer uw.Rollback(ctx)
+
+	q := goappbuild.Q{}.Schema(project.Name).Table("notes").Equal("id", ids["expired"])
+	_, err = uw.Queries().UpdateWhere(ctx, q, map[string]any{"deleted_at": time.Now().UTC().Add(-2 * time.Hour)})
+	require.NoError(t, err)
+	require.NoError(t, uw.Commit(ctx))
+
+	n, err := queries.NewSweeper(storage, time.Hour, nil).Sweep(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+
+	_, err = s.Restore(owner, project.ID, "notes", ids["expired"])
+	require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+	_, err = s.Restore(owner, project.ID, "notes", ids["trashed"])
+	require.NoError(t, err)
+
+	docs, err := s.List(owner, project.ID, goappbuild.Q{}.Table("notes").OrderAsc("title"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"live", "trashed"}, titles(docs))
+}
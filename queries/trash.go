@@ -0,0 +1,150 @@
+package queries
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+)
+
+// Restore moves a document out of the trash
+func (q *queryService) Restore(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collectionName string,
+	sid string,
+) (goappbuild.Document, error) {
+	if err := goappbuild.CheckScope(ctx, projectID, goappbuild.DocumentsWriteScope(collectionName)); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	uw, err := q.storage.New(ctx)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := q.softDeleteCollection(ctx, uw, projectID, collectionName)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	id, err := t.collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	data := map[string]any{
+		"deleted_at": nil,
+		"updated_at": time.Now().UTC(),
+	}
+
+	ids, err := uw.Queries().UpdateWhere(ctx, query(t.project, t.collection).OnlyDeleted().Equal("id", id), data)
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	if len(ids) == 0 {
+		return goappbuild.Document{}, goappbuild.Errorf(goappbuild.ENotFound, "document %s not found in trash", id)
+	}
+
+	m, err := uw.Queries().Get(ctx, t.query().Equal("id", id))
+	if err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	// the document was deleted for everyone else, it comes back
+	c := q.changes(ctx)
+	c.document(goappbuild.EventDocumentCreated, t, nil, m)
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.Document{}, err
+	}
+
+	q.publish(ctx, c)
+
+	return t.document(m), nil
+}
+
+// Purge permanently deletes a trashed document. It publishes no event,
+// the document was deleted when it was moved to the trash.
+func (q *queryService) Purge(
+	ctx context.Context,
+	projectID uuid.UUID,
+	collectionName string,
+	sid string,
+) error {
+	if err := goappbuild.CheckScope(ctx, projectID, goappbuild.DocumentsWriteScope(collectionName)); err != nil {
+		return err
+	}
+
+	uw, err := q.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t, err := q.softDeleteCollection(ctx, uw, projectID, collectionName)
+	if err != nil {
+		return err
+	}
+
+	id, err := t.collection.Options.IDStrategy.ParseID(sid)
+	if err != nil {
+		return err
+	}
+
+	ids, err := uw.Queries().DeleteWhere(ctx, query(t.project, t.collection).OnlyDeleted().Equal("id", id))
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return goappbuild.Errorf(goappbuild.ENotFound, "document %s not found in trash", id)
+	}
+
+	if err := unshareDeleted(ctx, uw, t.project.ID, t.collection.Name, ids); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// softDeleteCollection returns the soft delete collection as a target
+// of the operations only project members can perform
+func (q *queryService) softDeleteCollection(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	projectID uuid.UUID,
+	collectionName string,
+) (target, error) {
+	project, grant, err := authz.Member(ctx, uw, projectID, goappbuild.RoleDeveloper)
+	if err != nil {
+		return target{}, err
+	}
+
+	collection, err := uw.Collections().GetByName(ctx, project.ID, collectionName)
+	if err != nil {
+		return target{}, err
+	}
+
+	if !collection.Options.SoftDelete {
+		return target{}, goappbuild.Errorf(
+			goappbuild.EValidation,
+			"collection %s does not use soft delete",
+			collection.Name,
+		)
+	}
+
+	ans := target{
+		project:    project,
+		collection: collection,
+		grant:      grant,
+	}
+
+	return ans, nil
+}
@@ -2,23 +2,78 @@ package goappbuild
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type QueryRepo interface {
 	Get(context.Context, Q) (map[string]any, error)
+	// List returns all the rows matching the query
+	List(context.Context, Q) ([]map[string]any, error)
 	Create(ctx context.Context, schema, table string, data map[string]any) (map[string]any, error)
-	Update(ctx context.Context, schema, table string, id uuid.UUID, data map[string]any) (map[string]any, error)
-	Delete(ctx context.Context, schema, table string, id uuid.UUID) error
+	Update(ctx context.Context, schema, table string, id any, data map[string]any) (map[string]any, error)
+	Delete(ctx context.Context, schema, table string, id any) error
+	// Count returns the number of rows matching the filter of the query
+	Count(context.Context, Q) (int64, error)
+	// UpdateWhere updates all the rows matching the filter of the query
+	// and returns the ids of the affected rows
+	UpdateWhere(ctx context.Context, q Q, data map[string]any) ([]any, error)
+	// DeleteWhere deletes all the rows matching the filter of the query
+	// and returns the ids of the affected rows
+	DeleteWhere(context.Context, Q) ([]any, error)
 }
 
 // QueryService is the interface that provides the Query
 type QueryService interface {
-	Get(context.Context, Q) (Document, error)
+	Get(context.Context, uuid.UUID, Q) (Document, error)
+	List(context.Context, uuid.UUID, Q) ([]Document, error)
 	Create(context.Context, uuid.UUID, string, map[string]any) (Document, error)
-	Update(context.Context, uuid.UUID, string, uuid.UUID, map[string]any) (Document, error)
-	Delete(context.Context, uuid.UUID, string, uuid.UUID) error
+	Update(context.Context, uuid.UUID, string, string, map[string]any) (Document, error)
+	Delete(context.Context, uuid.UUID, string, string) error
+	UpdateWhere(context.Context, uuid.UUID, Q, map[string]any, BulkOptions) (BulkResult, error)
+	DeleteWhere(context.Context, uuid.UUID, Q, BulkOptions) (BulkResult, error)
+	// Batch executes the operations in order in a single unit of work.
+	// If any of the operations fails nothing is persisted
+	// and a *BatchError is returned.
+	Batch(context.Context, uuid.UUID, []BatchOperation) ([]BatchResult, error)
+	// Restore moves a document out of the trash of a soft delete collection
+	Restore(context.Context, uuid.UUID, string, string) (Document, error)
+	// Purge permanently deletes a trashed document of a soft delete collection
+	Purge(context.Context, uuid.UUID, string, string) error
+	// Revisions returns the revisions of a document, newest first
+	Revisions(context.Context, uuid.UUID, string, string) ([]Revision, error)
+	// GetAsOf returns a document as it was at the given time
+	GetAsOf(context.Context, uuid.UUID, string, string, time.Time) (Document, error)
+	// RestoreRevision restores a document to the state of a revision
+	RestoreRevision(context.Context, uuid.UUID, string, string, int64) (Document, error)
+}
+
+// BulkOptions holds the options of the set based operations
+type BulkOptions struct {
+	// Force allows the operation to run without a filter
+	// (it affects all the rows of the collection)
+	Force bool
+	// DryRun only counts the rows that match the filter
+	DryRun bool
+	// MaxAffected is the maximum number of rows the operation is allowed
+	// to affect. Zero means that the service default is used.
+	MaxAffected int
+	// ReturnIDs indicates if the ids of the affected rows should be returned
+	ReturnIDs bool
+}
+
+// BulkResult is the result of a set based operation
+type BulkResult struct {
+	// Affected is the number of rows affected (or matched for dry runs)
+	Affected int64
+	// DryRun indicates that nothing was modified
+	DryRun bool
+	// IDs holds the ids of the affected rows when requested
+	IDs []any
 }
 
 type Q struct {
@@ -26,6 +81,15 @@ type Q struct {
 	table  string
 	cols   []string
 	where  []Op
+	order  []Op
+	limit  int
+	offset int
+
+	softDelete     bool
+	includeDeleted bool
+	onlyDeleted    bool
+
+	access *Access
 }
 
 func (q Q) GetSchema() string {
@@ -56,6 +120,96 @@ func (q Q) Where() []Op {
 	return q.where
 }
 
+func (q Q) Order() []Op {
+	return q.order
+}
+
+// OrderAsc sorts the results by the column in ascending order
+func (q Q) OrderAsc(column string) Q {
+	q.order = append(q.order, Op{column: column, op: OpOrderAsc})
+
+	return q
+}
+
+// OrderDesc sorts the results by the column in descending order
+func (q Q) OrderDesc(column string) Q {
+	q.order = append(q.order, Op{column: column, op: OpOrderDesc})
+
+	return q
+}
+
+func (q Q) GetLimit() int {
+	return q.limit
+}
+
+// Limit limits the number of results (0 means no limit)
+func (q Q) Limit(limit int) Q {
+	q.limit = limit
+
+	return q
+}
+
+func (q Q) GetOffset() int {
+	return q.offset
+}
+
+// Offset skips the first offset results
+func (q Q) Offset(offset int) Q {
+	q.offset = offset
+
+	return q
+}
+
+// IsSoftDelete returns true if the table uses soft deletion
+func (q Q) IsSoftDelete() bool {
+	return q.softDelete
+}
+
+// SoftDelete marks the table as soft delete, so rows that have
+// the deleted_at column set are excluded unless requested
+func (q Q) SoftDelete() Q {
+	q.softDelete = true
+
+	return q
+}
+
+// IsIncludeDeleted returns true if trashed rows are included
+func (q Q) IsIncludeDeleted() bool {
+	return q.includeDeleted
+}
+
+// IncludeDeleted includes the trashed rows of a soft delete table
+func (q Q) IncludeDeleted() Q {
+	q.includeDeleted = true
+
+	return q
+}
+
+// IsOnlyDeleted returns true if only trashed rows are selected
+func (q Q) IsOnlyDeleted() bool {
+	return q.onlyDeleted
+}
+
+// OnlyDeleted selects only the trashed rows of a soft delete table
+func (q Q) OnlyDeleted() Q {
+	q.onlyDeleted = true
+
+	return q
+}
+
+// GetAccess returns the access restriction of the query, nil when unrestricted
+func (q Q) GetAccess() *Access {
+	return q.access
+}
+
+// Accessible restricts the rows to the ones the principal of the access
+// owns or was granted
+func (q Q) Accessible(a Access) Q {
+	q.access = &a
+
+	return q
+}
+
 func (q Q) Select(cols ...string) Q {
 	existings := make(map[string]bool)
 	for _, col := range q.cols {
@@ -223,3 +377,166 @@ func (op Op) Op() int {
 func (op Op) Value() any {
 	return op.value
 }
+
+// Filter is a condition on a column that can be stored, like the filters
+// of the shared views. Op is one of eq, neq, lt, lte, gt, gte, null,
+// not_null, starts_with, ends_with.
+type Filter struct {
+	Column string `json:"column"`
+	Op     string `json:"op"`
+	Value  any    `json:"value,omitempty"`
+}
+
+// Apply adds the condition to the query
+func (f Filter) Apply(q Q) (Q, error) {
+	if f.Column == "" {
+		return q, Errorf(EValidation, "filter column is required")
+	}
+
+	switch f.Op {
+	case "eq":
+		return q.Equal(f.Column, f.Value), nil
+	case "neq":
+		return q.NotEqual(f.Column, f.Value), nil
+	case "lt":
+		return q.LessThan(f.Column, f.Value), nil
+	case "lte":
+		return q.LessThanOrEqual(f.Column, f.Value), nil
+	case "gt":
+		return q.GreaterThan(f.Column, f.Value), nil
+	case "gte":
+		return q.GreaterThanOrEqual(f.Column, f.Value), nil
+	case "null":
+		return q.Null(f.Column), nil
+	case "not_null":
+		return q.NotNull(f.Column), nil
+	case "starts_with", "ends_with":
+		s, ok := f.Value.(string)
+		if !ok {
+			return q, Errorf(EValidation, "filter %s on %s requires a string value", f.Op, f.Column)
+		}
+
+		if f.Op == "starts_with" {
+			return q.StartsWith(f.Column, s), nil
+		}
+
+		return q.EndsWith(f.Column, s), nil
+	default:
+		return q, Errorf(EValidation, "invalid filter operator: %q", f.Op)
+	}
+}
+
+// Match returns true if the values of a document satisfy the condition.
+// The values are compared the way they are decoded from JSON: numbers as
+// numbers and everything else, including the times, as strings.
+func (f Filter) Match(values map[string]any) bool {
+	v := values[f.Column]
+
+	switch f.Op {
+	case "null":
+		return v == nil
+	case "not_null":
+		return v != nil
+	case "starts_with", "ends_with":
+		s, ok := v.(string)
+		part, _ := f.Value.(string)
+
+		if !ok {
+			return false
+		}
+
+		if f.Op == "starts_with" {
+			return strings.HasPrefix(s, part)
+		}
+
+		return strings.HasSuffix(s, part)
+	}
+
+	if v == nil || f.Value == nil {
+		return false
+	}
+
+	c, ok := compare(v, f.Value)
+	if !ok {
+		return f.Op == "neq"
+	}
+
+	switch f.Op {
+	case "eq":
+		return c == 0
+	case "neq":
+		return c != 0
+	case "lt":
+		return c < 0
+	case "lte":
+		return c <= 0
+	case "gt":
+		return c > 0
+	case "gte":
+		return c >= 0
+	default:
+		return false
+	}
+}
+
+// compare compares two values, the second result is false when they
+// cannot be compared
+func compare(a, b any) (int, bool) {
+	fa, aok := number(a)
+	fb, bok := number(b)
+
+	switch {
+	case aok && bok:
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		default:
+			return 0, true
+		}
+	case aok || bok:
+		return 0, false
+	}
+
+	if ba, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		if !ok || ba != bb {
+			return 1, ok
+		}
+
+		return 0, true
+	}
+
+	return strings.Compare(text(a), text(b)), true
+}
+
+// text returns the value as it is encoded in JSON
+func text(v any) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// number returns the value as a float
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,52 @@
+package goappbuild_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+)
+
+func Test_FilterMatch(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	values := map[string]any{
+		"title":      "hello world",
+		"count":      float64(3),
+		"done":       false,
+		"deleted_at": nil,
+		"created_at": created,
+	}
+
+	tests := []struct {
+		name   string
+		filter goappbuild.Filter
+		match  bool
+	}{
+		{"eq string", goappbuild.Filter{Column: "title", Op: "eq", Value: "hello world"}, true},
+		{"neq string", goappbuild.Filter{Column: "title", Op: "neq", Value: "hello world"}, false},
+		{"eq number of another type", goappbuild.Filter{Column: "count", Op: "eq", Value: 3}, true},
+		{"gt number", goappbuild.Filter{Column: "count", Op: "gt", Value: 2.5}, true},
+		{"lte number", goappbuild.Filter{Column: "count", Op: "lte", Value: 2}, false},
+		{"number and string differ", goappbuild.Filter{Column: "count", Op: "eq", Value: "3"}, false},
+		{"eq bool", goappbuild.Filter{Column: "done", Op: "eq", Value: false}, true},
+		{"time as json", goappbuild.Filter{Column: "created_at", Op: "gte", Value: "2024-01-01T00:00:00Z"}, true},
+		{"null", goappbuild.Filter{Column: "deleted_at", Op: "null"}, true},
+		{"missing is null", goappbuild.Filter{Column: "missing", Op: "null"}, true},
+		{"not null", goappbuild.Filter{Column: "title", Op: "not_null"}, true},
+		{"null never equals", goappbuild.Filter{Column: "deleted_at", Op: "neq", Value: "x"}, false},
+		{"starts with", goappbuild.Filter{Column: "title", Op: "starts_with", Value: "hello"}, true},
+		{"ends with", goappbuild.Filter{Column: "title", Op: "ends_with", Value: "hello"}, false},
+		{"invalid operator", goappbuild.Filter{Column: "title", Op: "like", Value: "hello"}, false},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.match, tc.filter.Match(values))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package goappbuild
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxSubscriptionFilters is the maximum number of filters of a subscription
+const MaxSubscriptionFilters = 20
+
+// SubscribeRequest is the request to receive the changes of the documents of
+// a collection, of a single document or of the documents matching the filters
+type SubscribeRequest struct {
+	ProjectID  uuid.UUID
+	Collection string
+	// DocumentID limits the subscription to a single document
+	DocumentID string
+	// Filters limit the subscription to the documents that match all of them
+	// before or after the change
+	Filters []Filter
+}
+
+// Validate validates the request
+func (o *SubscribeRequest) Validate() error {
+	if strings.TrimSpace(o.Collection) == "" {
+		return Errorf(EValidation, "collection is required")
+	}
+
+	switch {
+	case o.DocumentID != "" && len(o.Filters) > 0:
+		return Errorf(EValidation, "a document id and filters cannot be combined")
+	case len(o.Filters) > MaxSubscriptionFilters:
+		return Errorf(EValidation, "a subscription can have at most %d filters", MaxSubscriptionFilters)
+	}
+
+	for _, f := range o.Filters {
+		if _, err := f.Apply(Q{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Change is a change of a document delivered to a subscription. The documents
+// only contain the attributes the subscriber can read.
+type Change struct {
+	Type       EventType
+	Collection string
+	DocumentID string
+	// Before is nil for the creations and After for the deletions
+	Before     *Document
+	After      *Document
+	OccurredAt time.Time
+}
+
+// Subscription delivers the changes of the documents it matches
+type Subscription interface {
+	// Changes returns the channel of the changes, it is closed when the
+	// subscription ends
+	Changes() <-chan Change
+	// Err returns why the subscription ended, it is nil while the
+	// subscription is active and when it was closed by the subscriber
+	Err() error
+	// Close ends the subscription
+	Close()
+}
+
+// RealtimeService delivers the changes of the documents as they happen
+type RealtimeService interface {
+	// Subscribe authorizes the caller of the context to read the documents and
+	// starts a subscription that lasts until it is closed, the context ends or
+	// the credentials of the caller expire. The changes are authorized again
+	// before they are delivered, with an authorization cached for a short time.
+	Subscribe(context.Context, SubscribeRequest) (Subscription, error)
+}
@@ -0,0 +1,119 @@
+package goappbuild
+
+// OwnerColumn is the attribute that holds the owner of a document
+const OwnerColumn = "owner_id"
+
+// Action is an operation on the documents of a collection
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Role returns the minimum project role that is allowed the action
+func (a Action) Role() Role {
+	if a == ActionRead {
+		return RoleViewer
+	}
+
+	return RoleDeveloper
+}
+
+// Scope returns the API key scope required for the action on the collection
+func (a Action) Scope(collection string) string {
+	if a == ActionRead {
+		return DocumentsReadScope(collection)
+	}
+
+	return DocumentsWriteScope(collection)
+}
+
+// Rule declares who, besides the project members, is allowed an action
+type Rule string
+
+const (
+	// RuleMembers allows only the project members and API keys. It is the default.
+	RuleMembers Rule = "members"
+	// RulePublic allows everyone, including anonymous callers
+	RulePublic Rule = "public"
+	// RuleAuthenticated allows every authenticated caller
+	RuleAuthenticated Rule = "authenticated"
+	// RuleOwner allows authenticated callers on the documents they own.
+	// For create it makes the caller the owner of the new document.
+	RuleOwner Rule = "owner"
+)
+
+// Validate returns an error if the rule is unknown
+func (r Rule) Validate() error {
+	switch r {
+	case "", RuleMembers, RulePublic, RuleAuthenticated, RuleOwner:
+		return nil
+	default:
+		return Errorf(EValidation, "invalid rule: %q", r)
+	}
+}
+
+// CollectionRules holds the rule of every action of a collection.
+// Project members are always allowed according to their role.
+type CollectionRules struct {
+	Read   Rule
+	Create Rule
+	Update Rule
+	Delete Rule
+}
+
+// Rule returns the rule of the action
+func (o CollectionRules) Rule(action Action) Rule {
+	var ans Rule
+
+	switch action {
+	case ActionRead:
+		ans = o.Read
+	case ActionCreate:
+		ans = o.Create
+	case ActionUpdate:
+		ans = o.Update
+	case ActionDelete:
+		ans = o.Delete
+	}
+
+	if ans == "" {
+		return RuleMembers
+	}
+
+	return ans
+}
+
+// Validate returns an error if a rule is invalid
+func (o CollectionRules) Validate() error {
+	for _, r := range []Rule{o.Read, o.Create, o.Update, o.Delete} {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NeedsOwner returns true if the rules need the owner of the documents.
+// Documents created by callers that are not members keep their owner, so
+// that the callers can read them back.
+func (o CollectionRules) NeedsOwner() bool {
+	if o.Rule(ActionCreate) != RuleMembers {
+		return true
+	}
+
+	return o.Read == RuleOwner || o.Update == RuleOwner || o.Delete == RuleOwner
+}
+
+// OwnerAttribute returns the attribute that holds the owner of the documents
+func OwnerAttribute() Attribute {
+	return Attribute{
+		Name:  OwnerColumn,
+		Type:  AttributeTypeUUID,
+		Index: true,
+	}
+}
@@ -0,0 +1,160 @@
+package goappbuild
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxSecurityEvents is the maximum number of security events returned at once
+const MaxSecurityEvents = 500
+
+// SecurityEventKind is the kind of a security event
+type SecurityEventKind string
+
+const (
+	// EventLoginFailed is recorded when a login has wrong credentials
+	EventLoginFailed SecurityEventKind = "login_failed"
+	// EventLockout is recorded when an account or a client is locked out
+	EventLockout SecurityEventKind = "lockout"
+	// EventBreachedPassword is recorded when a password is rejected because
+	// it appears in the breached password list
+	EventBreachedPassword SecurityEventKind = "breached_password"
+	// EventUserDisabled is recorded when an admin disables a user
+	EventUserDisabled SecurityEventKind = "user_disabled"
+	// EventUserEnabled is recorded when an admin enables a user
+	EventUserEnabled SecurityEventKind = "user_enabled"
+	// EventUserDeleted is recorded when an admin deletes a user
+	EventUserDeleted SecurityEventKind = "user_deleted"
+	// EventForcedLogout is recorded when an admin logs out a user
+	EventForcedLogout SecurityEventKind = "forced_logout"
+	// EventImpersonation is recorded when an admin impersonates a user
+	EventImpersonation SecurityEventKind = "impersonation"
+)
+
+// Validate returns an error if the kind is unknown
+func (k SecurityEventKind) Validate() error {
+	switch k {
+	case EventLoginFailed, EventLockout, EventBreachedPassword, EventUserDisabled,
+		EventUserEnabled, EventUserDeleted, EventForcedLogout, EventImpersonation:
+		return nil
+	default:
+		return Errorf(EValidation, "invalid event kind %q", k)
+	}
+}
+
+// SecurityEvent is a security relevant event recorded for review by the admins
+type SecurityEvent struct {
+	ID   uuid.UUID
+	Kind SecurityEventKind
+	// UserID is the platform user or the end user of the event, if known
+	UserID uuid.UUID
+	// ProjectID is set for the events of the end users of a project
+	ProjectID uuid.UUID
+	// ActorID is the admin that performed the action, if any
+	ActorID uuid.UUID
+	Email   string
+	// IP is the address of the client
+	IP string
+	// Detail describes the event
+	Detail    string
+	CreatedAt time.Time
+}
+
+// SecurityEventFilter selects the security events to list.
+// The zero values do not filter.
+type SecurityEventFilter struct {
+	Kind      SecurityEventKind
+	Email     string
+	IP        string
+	ProjectID uuid.UUID
+	// UserID selects the events of a user
+	UserID uuid.UUID
+	// Limit is the maximum number of events, newest first
+	Limit int
+}
+
+// Validate returns an error if the filter is invalid.
+func (o *SecurityEventFilter) Validate() error {
+	if o.Kind != "" {
+		if err := o.Kind.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.Limit < 0 || o.Limit > MaxSecurityEvents {
+		return Errorf(EValidation, "limit must be between 0 and %d", MaxSecurityEvents)
+	}
+
+	return nil
+}
+
+// LoginCounter counts the recent failed logins of an account or a client
+type LoginCounter struct {
+	// Key identifies the account or the client
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	// LockedUntil is set while the key is locked out
+	LockedUntil *time.Time
+}
+
+// IsLocked returns true if the key is locked out at the given time
+func (o LoginCounter) IsLocked(now time.Time) bool {
+	return o.LockedUntil != nil && o.LockedUntil.After(now)
+}
+
+// LoginAttempt is a login of a platform user or an end user of a project.
+// The address of the client is taken from the context.
+type LoginAttempt struct {
+	// ProjectID is set for the end users of a project
+	ProjectID uuid.UUID
+	// UserID is set when the email belongs to a user
+	UserID uuid.UUID
+	Email  string
+	// MFA is true for the second factor of a login. Its failures are counted
+	// by user apart from the ones of the password, a login with the password
+	// does not clear them.
+	MFA bool
+}
+
+// LoginGuard protects the logins against brute force attacks
+type LoginGuard interface {
+	// Check returns an ETooManyRequests error if the account or the client
+	// is locked out or has to wait after the recent failures
+	Check(context.Context, LoginAttempt) error
+	// Fail records a failed login and locks out the account or the client
+	// when they exceed the limits
+	Fail(context.Context, LoginAttempt) error
+	// Succeed clears the failures of the account
+	Succeed(context.Context, LoginAttempt) error
+	// CheckPassword returns an error if a new password does not satisfy the
+	// password policy
+	CheckPassword(ctx context.Context, email, password string) error
+}
+
+// SecurityService lets the admins review the security events.
+type SecurityService interface {
+	// Events returns the matching security events, newest first
+	Events(context.Context, SecurityEventFilter) ([]SecurityEvent, error)
+}
+
+// LoginCounterRepo represents a repository for the failed login counters.
+type LoginCounterRepo interface {
+	// Get returns the counter of the key or an ENotFound error
+	Get(ctx context.Context, key string) (LoginCounter, error)
+	// Fail increments the failures of the key and returns the counter.
+	// The count starts over when the last failure happened before since.
+	Fail(ctx context.Context, key string, since time.Time) (LoginCounter, error)
+	// Lock locks out the key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset removes the counter of the key
+	Reset(ctx context.Context, key string) error
+}
+
+// SecurityEventRepo represents a repository for the security events.
+type SecurityEventRepo interface {
+	Create(context.Context, *SecurityEvent) error
+	List(context.Context, SecurityEventFilter) ([]SecurityEvent, error)
+}
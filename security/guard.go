@@ -0,0 +1,326 @@
+package security
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+)
+
+var _ goappbuild.LoginGuard = (*guard)(nil)
+
+const (
+	// DefaultDelayAfter is the default number of failures before the attempts are delayed
+	DefaultDelayAfter = 3
+	// DefaultBaseDelay is the default delay after DelayAfter failures
+	DefaultBaseDelay = time.Second
+	// DefaultMaxDelay is the default maximum delay between attempts
+	DefaultMaxDelay = time.Minute
+	// DefaultLockoutThreshold is the default number of failures that locks out an account
+	DefaultLockoutThreshold = 10
+	// DefaultIPLockoutThreshold is the default number of failures that locks out a client
+	DefaultIPLockoutThreshold = 100
+	// DefaultLockoutDuration is the default duration of the first lockout
+	DefaultLockoutDuration = 15 * time.Minute
+	// DefaultMaxLockoutDuration is the default maximum duration of a lockout
+	DefaultMaxLockoutDuration = 24 * time.Hour
+	// DefaultFailureWindow is the default time after which the failures are forgotten
+	DefaultFailureWindow = 24 * time.Hour
+)
+
+// Config is the configuration of the login guard.
+// The zero values are replaced with the defaults.
+type Config struct {
+	// DelayAfter is the number of failures of an account after which
+	// the next attempt has to wait. The delay doubles with every failure.
+	DelayAfter int
+	// BaseDelay is the first delay
+	BaseDelay time.Duration
+	// MaxDelay caps the delays
+	MaxDelay time.Duration
+	// LockoutThreshold is the number of failures that lock out an account.
+	// Every further LockoutThreshold failures lock it out again for twice as long.
+	LockoutThreshold int
+	// IPLockoutThreshold is the number of failures that lock out a client
+	// address. It is higher since clients may share an address.
+	IPLockoutThreshold int
+	// LockoutDuration is the duration of the first lockout
+	LockoutDuration time.Duration
+	// MaxLockoutDuration caps the lockouts
+	MaxLockoutDuration time.Duration
+	// FailureWindow is how long the failures are remembered,
+	// the count starts over after that long without failures
+	FailureWindow time.Duration
+	// MinPasswordLength is the minimum number of characters of a new password.
+	// It cannot be lower than goappbuild.MinPasswordLength.
+	MinPasswordLength int
+	// BreachedPasswords are rejected as new passwords.
+	// Defaults to the embedded list of common passwords.
+	BreachedPasswords *PasswordList
+	// Logger reports the security events that cannot be recorded.
+	// Defaults to a logger that discards everything.
+	Logger goappbuild.Logger
+}
+
+type guard struct {
+	storage goappbuild.Storage
+	cfg     Config
+}
+
+// NewGuard returns a login guard that keeps the failed attempts in the storage.
+// The accounts are delayed and locked out by email, whether they exist or not,
+// and the clients by address.
+func NewGuard(storage goappbuild.Storage, cfg Config) goappbuild.LoginGuard {
+	if cfg.DelayAfter <= 0 {
+		cfg.DelayAfter = DefaultDelayAfter
+	}
+
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = DefaultBaseDelay
+	}
+
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = DefaultMaxDelay
+	}
+
+	if cfg.LockoutThreshold <= 0 {
+		cfg.LockoutThreshold = DefaultLockoutThreshold
+	}
+
+	if cfg.IPLockoutThreshold <= 0 {
+		cfg.IPLockoutThreshold = DefaultIPLockoutThreshold
+	}
+
+	if cfg.LockoutDuration <= 0 {
+		cfg.LockoutDuration = DefaultLockoutDuration
+	}
+
+	if cfg.MaxLockoutDuration <= 0 {
+		cfg.MaxLockoutDuration = DefaultMaxLockoutDuration
+	}
+
+	if cfg.FailureWindow <= 0 {
+		cfg.FailureWindow = DefaultFailureWindow
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = goappbuild.NopLogger()
+	}
+
+	if cfg.MinPasswordLength < goappbuild.MinPasswordLength {
+		cfg.MinPasswordLength = goappbuild.MinPasswordLength
+	}
+
+	if cfg.BreachedPasswords == nil {
+		cfg.BreachedPasswords = DefaultPasswordList()
+	}
+
+	return &guard{
+		storage: storage,
+		cfg:     cfg,
+	}
+}
+
+// Check returns an error if the account or the client has to wait
+func (g *guard) Check(ctx context.Context, attempt goappbuild.LoginAttempt) error {
+	now := time.Now().UTC()
+
+	account, err := g.counter(ctx, accountKey(attempt))
+	if err != nil {
+		return err
+	}
+
+	wait := g.delay(account, now)
+
+	if ip := goappbuild.ClientIPFromContext(ctx); ip != "" {
+		client, err := g.counter(ctx, ipKey(ip))
+		if err != nil {
+			return err
+		}
+
+		// the clients are only locked out, delaying them would
+		// delay all the users behind the same address
+		if client.IsLocked(now) && client.LockedUntil.Sub(now) > wait {
+			wait = client.LockedUntil.Sub(now)
+		}
+	}
+
+	if wait > 0 {
+		return goappbuild.Errorf(
+			goappbuild.ETooManyRequests,
+			"too many failed logins, try again in %s",
+			(wait + time.Second - 1).Truncate(time.Second),
+		)
+	}
+
+	return nil
+}
+
+// Fail counts the failure for the account and the client
+func (g *guard) Fail(ctx context.Context, attempt goappbuild.LoginAttempt) error {
+	since := time.Now().UTC().Add(-g.cfg.FailureWindow)
+	ip := goappbuild.ClientIPFromContext(ctx)
+
+	g.record(ctx, goappbuild.EventLoginFailed, attempt, "")
+
+	account, err := g.storage.LoginCounters().Fail(ctx, accountKey(attempt), since)
+	if err != nil {
+		return err
+	}
+
+	if err := g.lockout(ctx, account, g.cfg.LockoutThreshold, attempt, "account"); err != nil {
+		return err
+	}
+
+	if ip == "" {
+		return nil
+	}
+
+	client, err := g.storage.LoginCounters().Fail(ctx, ipKey(ip), since)
+	if err != nil {
+		return err
+	}
+
+	return g.lockout(ctx, client, g.cfg.IPLockoutThreshold, attempt, "client")
+}
+
+// Succeed clears the failures of the account. The failures of the client
+// are kept, otherwise an attacker could clear them with an account of their own.
+func (g *guard) Succeed(ctx context.Context, attempt goappbuild.LoginAttempt) error {
+	return g.storage.LoginCounters().Reset(ctx, accountKey(attempt))
+}
+
+// CheckPassword rejects the short passwords, the email and the breached passwords
+func (g *guard) CheckPassword(ctx context.Context, email, password string) error {
+	if utf8.RuneCountInString(password) < g.cfg.MinPasswordLength {
+		return goappbuild.Errorf(
+			goappbuild.EValidation,
+			"password must have at least %d characters",
+			g.cfg.MinPasswordLength,
+		)
+	}
+
+	if email != "" {
+		local, _, _ := strings.Cut(email, "@")
+
+		if strings.EqualFold(password, email) || strings.EqualFold(password, local) {
+			return goappbuild.Errorf(goappbuild.EValidation, "password cannot be the email")
+		}
+	}
+
+	if g.cfg.BreachedPasswords.Contains(password) {
+		g.record(ctx, goappbuild.EventBreachedPassword, goappbuild.LoginAttempt{Email: email}, "")
+
+		return goappbuild.Errorf(
+			goappbuild.EValidation,
+			"password appears in a list of breached passwords, choose another one",
+		)
+	}
+
+	return nil
+}
+
+// counter returns the counter of the key, an empty one if there is none
+func (g *guard) counter(ctx context.Context, key string) (goappbuild.LoginCounter, error) {
+	c, err := g.storage.LoginCounters().Get(ctx, key)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.LoginCounter{Key: key}, nil
+		}
+
+		return goappbuild.LoginCounter{}, err
+	}
+
+	return c, nil
+}
+
+// delay returns how long the account has to wait before the next attempt
+func (g *guard) delay(c goappbuild.LoginCounter, now time.Time) time.Duration {
+	if c.IsLocked(now) {
+		return c.LockedUntil.Sub(now)
+	}
+
+	if c.Failures < g.cfg.DelayAfter {
+		return 0
+	}
+
+	delay := g.cfg.MaxDelay
+
+	if n := c.Failures - g.cfg.DelayAfter; n < 32 && g.cfg.BaseDelay<<n < delay {
+		delay = g.cfg.BaseDelay << n
+	}
+
+	return c.LastFailureAt.Add(delay).Sub(now)
+}
+
+// lockout locks out the key every threshold failures,
+// each lockout lasts twice as long as the previous one
+func (g *guard) lockout(
+	ctx context.Context,
+	c goappbuild.LoginCounter,
+	threshold int,
+	attempt goappbuild.LoginAttempt,
+	what string,
+) error {
+	if c.Failures%threshold != 0 {
+		return nil
+	}
+
+	duration := g.cfg.MaxLockoutDuration
+
+	if n := c.Failures/threshold - 1; n < 32 && g.cfg.LockoutDuration<<n < duration {
+		duration = g.cfg.LockoutDuration << n
+	}
+
+	if err := g.storage.LoginCounters().Lock(ctx, c.Key, c.LastFailureAt.Add(duration)); err != nil {
+		return err
+	}
+
+	g.record(ctx, goappbuild.EventLockout, attempt, what+" locked out for "+duration.String())
+
+	return nil
+}
+
+// record stores a security event. The events are not worth failing
+// the request for, the errors are only logged.
+func (g *guard) record(
+	ctx context.Context,
+	kind goappbuild.SecurityEventKind,
+	attempt goappbuild.LoginAttempt,
+	detail string,
+) {
+	e := goappbuild.SecurityEvent{
+		Kind:      kind,
+		UserID:    attempt.UserID,
+		ProjectID: attempt.ProjectID,
+		Email:     attempt.Email,
+		IP:        goappbuild.ClientIPFromContext(ctx),
+		Detail:    detail,
+	}
+
+	if err := g.storage.SecurityEvents().Create(ctx, &e); err != nil {
+		g.cfg.Logger.Printf("security: recording a %s event: %v", kind, err)
+	}
+}
+
+// accountKey is the counter key of the email, the end users
+// are counted per project and the second factors per user
+func accountKey(attempt goappbuild.LoginAttempt) string {
+	if attempt.MFA {
+		return "mfa:" + attempt.UserID.String()
+	}
+
+	if attempt.ProjectID != uuid.Nil {
+		return "enduser:" + attempt.ProjectID.String() + ":" + attempt.Email
+	}
+
+	return "user:" + attempt.Email
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
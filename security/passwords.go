@@ -0,0 +1,92 @@
+package security
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // the lists of breached passwords are sha1 hashes
+	_ "embed"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+//go:embed passwords.txt
+var commonPasswords string
+
+// PasswordList is a set of breached passwords checked offline.
+// Only the SHA-1 hashes of the passwords are kept in memory.
+type PasswordList struct {
+	hashes map[[sha1.Size]byte]struct{}
+}
+
+// NewPasswordList returns an empty password list
+func NewPasswordList() *PasswordList {
+	return &PasswordList{
+		hashes: map[[sha1.Size]byte]struct{}{},
+	}
+}
+
+// DefaultPasswordList returns a list of the most common passwords
+func DefaultPasswordList() *PasswordList {
+	ans := NewPasswordList()
+
+	// the embedded list is valid, reading from a string does not fail
+	_ = ans.Load(strings.NewReader(commonPasswords))
+
+	return ans
+}
+
+// Load adds the passwords of the reader, one per line. A line is either a
+// plain text password or the hex SHA-1 hash of one, optionally followed by
+// a colon and a count like in the Pwned Passwords downloads.
+// Empty lines and lines starting with # are skipped.
+func (o *PasswordList) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if hash, ok := parseHash(line); ok {
+			o.hashes[hash] = struct{}{}
+
+			continue
+		}
+
+		o.hashes[sha1.Sum([]byte(line))] = struct{}{}
+	}
+
+	return scanner.Err()
+}
+
+// Contains returns true if the password or its lowercase form is in the list
+func (o *PasswordList) Contains(password string) bool {
+	if _, ok := o.hashes[sha1.Sum([]byte(password))]; ok {
+		return true
+	}
+
+	_, ok := o.hashes[sha1.Sum([]byte(strings.ToLower(password)))]
+
+	return ok
+}
+
+// Len returns the number of passwords in the list
+func (o *PasswordList) Len() int {
+	return len(o.hashes)
+}
+
+func parseHash(line string) ([sha1.Size]byte, bool) {
+	var ans [sha1.Size]byte
+
+	line, _, _ = strings.Cut(line, ":")
+	if len(line) != hex.EncodedLen(sha1.Size) {
+		return ans, false
+	}
+
+	if _, err := hex.Decode(ans[:], []byte(line)); err != nil {
+		return ans, false
+	}
+
+	return ans, true
+}
@@ -0,0 +1,69 @@
+// Package security protects the logins against brute force attacks and
+// keeps the security events for review.
+package security
+
+import (
+	"context"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+)
+
+var _ goappbuild.SecurityService = (*service)(nil)
+
+// DefaultEventLimit is the number of events listed when the filter has no limit
+const DefaultEventLimit = 100
+
+type service struct {
+	storage goappbuild.Storage
+}
+
+// New returns a new security service
+func New(storage goappbuild.Storage) goappbuild.SecurityService {
+	return &service{
+		storage: storage,
+	}
+}
+
+// Events returns the matching security events. Only the admins can review them.
+func (s *service) Events(
+	ctx context.Context,
+	filter goappbuild.SecurityEventFilter,
+) ([]goappbuild.SecurityEvent, error) {
+	if _, err := authz.Admin(ctx, s.storage); err != nil {
+		return nil, err
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = DefaultEventLimit
+	}
+
+	filter.Email = goappbuild.NormalizeEmail(filter.Email)
+
+	return s.storage.SecurityEvents().List(ctx, filter)
+}
+
+// Audit records an action of an admin on a user in the storage of the
+// action, so that the event is committed with it
+func Audit(
+	ctx context.Context,
+	storage goappbuild.Storage,
+	kind goappbuild.SecurityEventKind,
+	admin, user goappbuild.User,
+	detail string,
+) error {
+	e := goappbuild.SecurityEvent{
+		Kind:    kind,
+		UserID:  user.ID,
+		ActorID: admin.ID,
+		Email:   user.Email,
+		IP:      goappbuild.ClientIPFromContext(ctx),
+		Detail:  detail,
+	}
+
+	return storage.SecurityEvents().Create(ctx, &e)
+}
@@ -0,0 +1,225 @@
+package security_test
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // the lists of breached passwords are sha1 hashes
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/security"
+)
+
+func Test_Guard(t *testing.T) {
+	storage := memstore.New()
+	guard := security.NewGuard(storage, security.Config{
+		DelayAfter:         2,
+		BaseDelay:          time.Minute,
+		MaxDelay:           time.Hour,
+		LockoutThreshold:   4,
+		IPLockoutThreshold: 6,
+		LockoutDuration:    time.Hour,
+	})
+	bg := context.Background()
+
+	tooMany := func(t *testing.T, err error) {
+		t.Helper()
+		require.Equal(t, goappbuild.ETooManyRequests, goappbuild.ErrorCode(err))
+	}
+
+	t.Run("test progressive delay", func(t *testing.T) {
+		attempt := goappbuild.LoginAttempt{Email: "delay@example.com"}
+
+		require.NoError(t, guard.Fail(bg, attempt))
+		require.NoError(t, guard.Check(bg, attempt))
+
+		require.NoError(t, guard.Fail(bg, attempt))
+		tooMany(t, guard.Check(bg, attempt))
+
+		// the end users of the projects are counted separately
+		require.NoError(t, guard.Check(bg, goappbuild.LoginAttempt{
+			ProjectID: uuid.New(),
+			Email:     attempt.Email,
+		}))
+
+		require.NoError(t, guard.Succeed(bg, attempt))
+		require.NoError(t, guard.Check(bg, attempt))
+	})
+
+	t.Run("test account lockout", func(t *testing.T) {
+		attempt := goappbuild.LoginAttempt{UserID: uuid.New(), Email: "lockout@example.com"}
+
+		for i := 0; i < 4; i++ {
+			require.NoError(t, guard.Fail(bg, attempt))
+		}
+
+		c, err := storage.LoginCounterRepo.Get(bg, "user:lockout@example.com")
+		require.NoError(t, err)
+		require.True(t, c.IsLocked(time.Now()))
+		require.WithinDuration(t, c.LastFailureAt.Add(time.Hour), *c.LockedUntil, time.Second)
+
+		err = guard.Check(bg, attempt)
+		tooMany(t, err)
+		require.Contains(t, goappbuild.ErrorMessage(err), "try again in 1h0m0s")
+
+		events, err := storage.SecurityEventRepo.List(bg, goappbuild.SecurityEventFilter{
+			Kind:  goappbuild.EventLockout,
+			Email: attempt.Email,
+			Limit: 10,
+		})
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, attempt.UserID, events[0].UserID)
+	})
+
+	t.Run("test password logins do not clear the mfa failures", func(t *testing.T) {
+		userID := uuid.New()
+		password := goappbuild.LoginAttempt{UserID: userID, Email: "mfa@example.com"}
+		code := goappbuild.LoginAttempt{UserID: userID, MFA: true}
+
+		for i := 0; i < 2; i++ {
+			require.NoError(t, guard.Fail(bg, code))
+		}
+
+		tooMany(t, guard.Check(bg, code))
+
+		require.NoError(t, guard.Succeed(bg, password))
+		tooMany(t, guard.Check(bg, code))
+		require.NoError(t, guard.Check(bg, password))
+	})
+
+	t.Run("test client lockout", func(t *testing.T) {
+		ctx := goappbuild.ContextWithClientIP(bg, "203.0.113.7")
+
+		for i := 0; i < 6; i++ {
+			require.NoError(t, guard.Fail(ctx, goappbuild.LoginAttempt{Email: uuid.NewString() + "@example.com"}))
+		}
+
+		attempt := goappbuild.LoginAttempt{Email: "innocent@example.com"}
+
+		tooMany(t, guard.Check(ctx, attempt))
+		require.NoError(t, guard.Check(bg, attempt))
+
+		// a successful login does not clear the failures of the client
+		require.NoError(t, guard.Succeed(ctx, attempt))
+		tooMany(t, guard.Check(ctx, attempt))
+
+		events, err := storage.SecurityEventRepo.List(bg, goappbuild.SecurityEventFilter{
+			Kind:  goappbuild.EventLoginFailed,
+			IP:    "203.0.113.7",
+			Limit: 10,
+		})
+		require.NoError(t, err)
+		require.Len(t, events, 6)
+	})
+
+	t.Run("test password policy", func(t *testing.T) {
+		tcs := []struct {
+			name     string
+			email    string
+			password string
+			valid    bool
+		}{
+			{"short", "jane@example.com", "short", false},
+			{"email", "jane@example.com", "Jane@Example.com", false},
+			{"local part of the email", "janedoe123@example.com", "janedoe123", false},
+			{"breached", "jane@example.com", "password123", false},
+			{"breached uppercase", "jane@example.com", "PASSWORD123", false},
+			{"valid", "jane@example.com", "correct horse", true},
+		}
+
+		for _, tc := range tcs {
+			err := guard.CheckPassword(bg, tc.email, tc.password)
+			if tc.valid {
+				require.NoError(t, err, tc.name)
+				continue
+			}
+
+			require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err), tc.name)
+		}
+
+		events, err := storage.SecurityEventRepo.List(bg, goappbuild.SecurityEventFilter{
+			Kind:  goappbuild.EventBreachedPassword,
+			Limit: 10,
+		})
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+	})
+
+	t.Run("test min password length", func(t *testing.T) {
+		strict := security.NewGuard(storage, security.Config{MinPasswordLength: 12})
+
+		err := strict.CheckPassword(bg, "", "correct hors")
+		require.NoError(t, err)
+
+		err = strict.CheckPassword(bg, "", "correct hor")
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+}
+
+func Test_PasswordList(t *testing.T) {
+	hash := sha1.Sum([]byte("hunter2hunter2"))
+
+	list := security.NewPasswordList()
+	err := list.Load(strings.NewReader(strings.Join([]string{
+		"# comment",
+		"",
+		strings.ToUpper(hex.EncodeToString(hash[:])) + ":42",
+		"letmeinplease",
+	}, "\n")))
+	require.NoError(t, err)
+	require.Equal(t, 2, list.Len())
+
+	require.True(t, list.Contains("hunter2hunter2"))
+	require.True(t, list.Contains("LetMeInPlease"))
+	require.False(t, list.Contains("correct horse"))
+
+	require.True(t, security.DefaultPasswordList().Contains("qwerty123"))
+}
+
+func Test_SecurityService_Events(t *testing.T) {
+	storage := memstore.New()
+	svc := security.New(storage)
+	bg := context.Background()
+
+	admin := goappbuild.User{Email: "admin@example.com", IsAdmin: true}
+	require.NoError(t, storage.UserRepo.Create(bg, &admin))
+
+	user := goappbuild.User{Email: "jane@example.com"}
+	require.NoError(t, storage.UserRepo.Create(bg, &user))
+
+	guard := security.NewGuard(storage, security.Config{})
+	require.NoError(t, guard.Fail(bg, goappbuild.LoginAttempt{Email: "jane@example.com"}))
+	require.NoError(t, guard.Fail(bg, goappbuild.LoginAttempt{Email: "john@example.com"}))
+
+	_, err := svc.Events(bg, goappbuild.SecurityEventFilter{})
+	require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+	userCtx := goappbuild.ContextWithIdentity(bg, goappbuild.Identity{UserID: user.ID})
+	_, err = svc.Events(userCtx, goappbuild.SecurityEventFilter{})
+	require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+	adminCtx := goappbuild.ContextWithIdentity(bg, goappbuild.Identity{UserID: admin.ID})
+
+	events, err := svc.Events(adminCtx, goappbuild.SecurityEventFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "john@example.com", events[0].Email)
+
+	events, err = svc.Events(adminCtx, goappbuild.SecurityEventFilter{Email: " Jane@Example.com"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, goappbuild.EventLoginFailed, events[0].Kind)
+
+	_, err = svc.Events(adminCtx, goappbuild.SecurityEventFilter{Kind: "unknown"})
+	require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+	_, err = svc.Events(adminCtx, goappbuild.SecurityEventFilter{Limit: goappbuild.MaxSecurityEvents + 1})
+	require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+}
@@ -0,0 +1,163 @@
+package goappbuild
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultShareLinkTTL is the lifetime of the share links without an explicit one
+	DefaultShareLinkTTL = 7 * 24 * time.Hour
+	// MaxShareLinkTTL is the maximum lifetime of a share link
+	MaxShareLinkTTL = 90 * 24 * time.Hour
+	// MaxShareFilters is the maximum number of filters of a shared view
+	MaxShareFilters = 20
+	// MaxSharedDocuments is the maximum number of documents of a shared view returned at once
+	MaxSharedDocuments = 1000
+	// MaxShareAccesses is the maximum number of accesses of a share link listed at once
+	MaxShareAccesses = 500
+)
+
+// ShareLink gives read access to a single document, or to the documents of a
+// collection that match the filters, to anyone holding its signed token.
+// The token expires with the link and stops working when the link is revoked.
+type ShareLink struct {
+	ID         uuid.UUID
+	ProjectID  uuid.UUID
+	Collection string
+	// DocumentID is the shared document, it is empty for the shared views
+	DocumentID string
+	// Filters select the documents of a shared view
+	Filters []Filter
+	// CreatedBy is the user that created the link, it is empty for API keys
+	CreatedBy uuid.UUID
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// IsActive returns true if the link is not revoked or expired
+func (o *ShareLink) IsActive(now time.Time) bool {
+	return o.RevokedAt == nil && o.ExpiresAt.After(now)
+}
+
+// IsView returns true if the link shares the documents matching the filters
+func (o *ShareLink) IsView() bool {
+	return o.DocumentID == ""
+}
+
+// CreateShareLinkRequest is the request to share a document or a filtered view
+// of a collection. Exactly one of DocumentID and Filters is set.
+type CreateShareLinkRequest struct {
+	ProjectID  uuid.UUID
+	Collection string
+	DocumentID string
+	Filters    []Filter
+	// TTL is the lifetime of the link, DefaultShareLinkTTL when zero
+	TTL time.Duration
+}
+
+// Validate validates the request
+func (o *CreateShareLinkRequest) Validate() error {
+	if strings.TrimSpace(o.Collection) == "" {
+		return Errorf(EValidation, "collection is required")
+	}
+
+	switch {
+	case o.DocumentID == "" && len(o.Filters) == 0:
+		return Errorf(EValidation, "a document id or at least one filter is required")
+	case o.DocumentID != "" && len(o.Filters) > 0:
+		return Errorf(EValidation, "a document id and filters cannot be combined")
+	case len(o.Filters) > MaxShareFilters:
+		return Errorf(EValidation, "a shared view can have at most %d filters", MaxShareFilters)
+	}
+
+	for _, f := range o.Filters {
+		if _, err := f.Apply(Q{}); err != nil {
+			return err
+		}
+	}
+
+	if o.TTL < 0 || o.TTL > MaxShareLinkTTL {
+		return Errorf(EValidation, "ttl must be between 0 and %s", MaxShareLinkTTL)
+	}
+
+	return nil
+}
+
+// ShareAccess is a read of a share link
+type ShareAccess struct {
+	ID        uuid.UUID
+	LinkID    uuid.UUID
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+// OpenShareRequest is the request to read the content of a share link.
+// The address of the client is taken from the context.
+type OpenShareRequest struct {
+	Token     string
+	UserAgent string
+	// Limit and Offset page the documents of a shared view
+	Limit  int
+	Offset int
+}
+
+// Validate validates the request
+func (o *OpenShareRequest) Validate() error {
+	if o.Token == "" {
+		return Errorf(EValidation, "token is required")
+	}
+
+	if o.Limit < 0 || o.Limit > MaxSharedDocuments {
+		return Errorf(EValidation, "limit must be between 0 and %d", MaxSharedDocuments)
+	}
+
+	if o.Offset < 0 {
+		return Errorf(EValidation, "offset must not be negative")
+	}
+
+	return nil
+}
+
+// SharedContent is the content of a share link. The documents only contain
+// the attributes that anonymous callers can read.
+type SharedContent struct {
+	Link ShareLink
+	// Documents holds the shared document or the page of the shared view
+	Documents []Document
+}
+
+// ShareService manages the share links of the documents
+type ShareService interface {
+	// Create creates a share link and returns it with its token.
+	// The token is returned only once.
+	Create(context.Context, CreateShareLinkRequest) (ShareLink, string, error)
+	// List returns the share links of a collection, newest first
+	List(ctx context.Context, projectID uuid.UUID, collection string) ([]ShareLink, error)
+	// Revoke revokes a share link, its token stops working
+	Revoke(ctx context.Context, projectID, id uuid.UUID) error
+	// Accesses returns the latest accesses of a share link, newest first
+	Accesses(ctx context.Context, projectID, id uuid.UUID, limit int) ([]ShareAccess, error)
+	// Open returns the content of the share link of the token without
+	// authenticating the caller and logs the access
+	Open(context.Context, OpenShareRequest) (SharedContent, error)
+}
+
+// ShareLinkRepo is the repository of the share links and their accesses
+type ShareLinkRepo interface {
+	Create(context.Context, *ShareLink) error
+	// Get returns a share link of any project
+	Get(ctx context.Context, id uuid.UUID) (ShareLink, error)
+	// List returns the share links of a collection, newest first
+	List(ctx context.Context, projectID uuid.UUID, collection string) ([]ShareLink, error)
+	Revoke(ctx context.Context, projectID, id uuid.UUID) error
+	// CreateAccess logs an access of a share link
+	CreateAccess(context.Context, *ShareAccess) error
+	// ListAccesses returns the latest accesses of a share link, newest first
+	ListAccesses(ctx context.Context, linkID uuid.UUID, limit int) ([]ShareAccess, error)
+}
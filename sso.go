@@ -0,0 +1,147 @@
+package goappbuild
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthRequestTTL is how long a sign in with an identity provider can take
+const AuthRequestTTL = 10 * time.Minute
+
+var providerNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// IdentityProvider is an OpenID Connect provider the end users
+// of a project can sign in with
+type IdentityProvider struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	// Name identifies the provider in the project (e.g. google)
+	Name string
+	// Issuer is the issuer url, the discovery document is fetched from it
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the end users back to the app
+	RedirectURL string
+	// Scopes are requested besides openid
+	Scopes    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CreateIdentityProviderRequest is the request to add an identity provider to a project
+type CreateIdentityProviderRequest struct {
+	ProjectID    uuid.UUID
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Validate returns an error if the request is invalid
+func (o *CreateIdentityProviderRequest) Validate() error {
+	if !providerNameRe.MatchString(o.Name) {
+		return Errorf(EValidation, "name must be lowercase letters, digits, - or _")
+	}
+
+	if err := validateURL("issuer", o.Issuer); err != nil {
+		return err
+	}
+
+	if err := validateURL("redirect url", o.RedirectURL); err != nil {
+		return err
+	}
+
+	if o.ClientID == "" {
+		return Errorf(EValidation, "client id is required")
+	}
+
+	return nil
+}
+
+func validateURL(name, v string) error {
+	u, err := url.Parse(v)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return Errorf(EValidation, "%s must be an absolute url", name)
+	}
+
+	return nil
+}
+
+// AuthRequest is a pending sign in with an identity provider. It holds the
+// PKCE verifier and the nonce until the end user comes back with a code.
+type AuthRequest struct {
+	ID         uuid.UUID
+	ProjectID  uuid.UUID
+	ProviderID uuid.UUID
+	// StateHash is the hash of the state parameter
+	StateHash string
+	Verifier  string
+	Nonce     string
+	// EndUserID is the anonymous end user the account is linked to, if any
+	EndUserID uuid.UUID
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// ExternalIdentity links the account of an identity provider to an end user
+type ExternalIdentity struct {
+	ID         uuid.UUID
+	ProjectID  uuid.UUID
+	ProviderID uuid.UUID
+	// Subject is the id of the account at the provider
+	Subject   string
+	EndUserID uuid.UUID
+	Email     string
+	CreatedAt time.Time
+}
+
+// SSOCallbackRequest is the request to finish a sign in with an identity provider
+type SSOCallbackRequest struct {
+	ProjectID uuid.UUID
+	Provider  string
+	Code      string
+	State     string
+}
+
+// IdentityProviderRepo represents a repository for managing the identity providers.
+type IdentityProviderRepo interface {
+	Create(context.Context, *IdentityProvider) error
+	GetByName(ctx context.Context, projectID uuid.UUID, name string) (IdentityProvider, error)
+	List(ctx context.Context, projectID uuid.UUID) ([]IdentityProvider, error)
+	Delete(ctx context.Context, projectID uuid.UUID, name string) error
+}
+
+// AuthRequestRepo represents a repository for the pending sign ins.
+type AuthRequestRepo interface {
+	Create(context.Context, *AuthRequest) error
+	// Take deletes and returns the request with the state hash,
+	// so that every state is used once
+	Take(ctx context.Context, stateHash string) (AuthRequest, error)
+}
+
+// ExternalIdentityRepo represents a repository for the external identities of the end users.
+type ExternalIdentityRepo interface {
+	Create(context.Context, *ExternalIdentity) error
+	Get(ctx context.Context, providerID uuid.UUID, subject string) (ExternalIdentity, error)
+}
+
+// SSOService signs in end users with OpenID Connect providers.
+type SSOService interface {
+	CreateProvider(context.Context, CreateIdentityProviderRequest) (IdentityProvider, error)
+	ListProviders(ctx context.Context, projectID uuid.UUID) ([]IdentityProvider, error)
+	DeleteProvider(ctx context.Context, projectID uuid.UUID, name string) error
+	// Start begins a sign in and returns the url of the provider
+	// the end user is sent to. When the caller is an anonymous end user
+	// the account of the provider is linked to it.
+	Start(ctx context.Context, projectID uuid.UUID, provider string) (string, error)
+	// Callback finishes a sign in, links the account of the provider to an
+	// end user (created when needed) and starts a session
+	Callback(context.Context, SSOCallbackRequest) (EndUserSession, error)
+}
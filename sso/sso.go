@@ -0,0 +1,371 @@
+// Package sso signs in the end users of the projects with OpenID Connect providers.
+package sso
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+	"github.com/gosom/goappbuild/endusers"
+	"github.com/gosom/goappbuild/pkg/oidc"
+	"github.com/gosom/goappbuild/pkg/securetoken"
+)
+
+var _ goappbuild.SSOService = (*service)(nil)
+
+var (
+	errInvalidState = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid or expired sign in")
+	errSignInFailed = goappbuild.Errorf(goappbuild.EUnauthorized, "sign in with the provider failed")
+)
+
+// Config is the configuration of the sso service
+type Config struct {
+	// SessionTTL is the lifetime of the sessions of the end users
+	SessionTTL time.Duration
+	// HTTPClient is used to talk to the providers
+	HTTPClient *http.Client
+}
+
+type service struct {
+	storage goappbuild.Storage
+	cfg     Config
+	client  *oidc.Client
+}
+
+// New returns a new sso service
+func New(storage goappbuild.Storage, cfg Config) goappbuild.SSOService {
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = endusers.DefaultSessionTTL
+	}
+
+	return &service{
+		storage: storage,
+		cfg:     cfg,
+		client:  oidc.New(cfg.HTTPClient),
+	}
+}
+
+// CreateProvider adds an identity provider to the project.
+// The discovery document of the issuer has to be reachable.
+func (s *service) CreateProvider(
+	ctx context.Context,
+	req goappbuild.CreateIdentityProviderRequest,
+) (goappbuild.IdentityProvider, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.IdentityProvider{}, err
+	}
+
+	if err := checkAdmin(ctx, s.storage, req.ProjectID); err != nil {
+		return goappbuild.IdentityProvider{}, err
+	}
+
+	if _, err := s.client.Discover(ctx, req.Issuer); err != nil {
+		return goappbuild.IdentityProvider{}, goappbuild.Errorf(goappbuild.EValidation, "invalid issuer: %v", err)
+	}
+
+	p := goappbuild.IdentityProvider{
+		ProjectID:    req.ProjectID,
+		Name:         req.Name,
+		Issuer:       req.Issuer,
+		ClientID:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		RedirectURL:  req.RedirectURL,
+		Scopes:       req.Scopes,
+	}
+
+	if err := s.storage.IdentityProviders().Create(ctx, &p); err != nil {
+		return goappbuild.IdentityProvider{}, err
+	}
+
+	return p, nil
+}
+
+// ListProviders returns the identity providers of the project
+func (s *service) ListProviders(ctx context.Context, projectID uuid.UUID) ([]goappbuild.IdentityProvider, error) {
+	if err := checkAdmin(ctx, s.storage, projectID); err != nil {
+		return nil, err
+	}
+
+	return s.storage.IdentityProviders().List(ctx, projectID)
+}
+
+// DeleteProvider removes an identity provider from the project.
+// The end users it linked keep their accounts.
+func (s *service) DeleteProvider(ctx context.Context, projectID uuid.UUID, name string) error {
+	if err := checkAdmin(ctx, s.storage, projectID); err != nil {
+		return err
+	}
+
+	return s.storage.IdentityProviders().Delete(ctx, projectID, name)
+}
+
+// Start begins a sign in with the provider and returns the url of the
+// provider. The state, the nonce and the PKCE verifier are kept until
+// the callback. Anonymous end users that start a sign in are linked to the
+// account of the provider.
+func (s *service) Start(ctx context.Context, projectID uuid.UUID, name string) (string, error) {
+	p, err := s.storage.IdentityProviders().GetByName(ctx, projectID, name)
+	if err != nil {
+		return "", err
+	}
+
+	anonID, err := s.anonymous(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+
+	meta, err := s.client.Discover(ctx, p.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := securetoken.Generate("", securetoken.DefaultSize)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := securetoken.Generate("", securetoken.DefaultSize)
+	if err != nil {
+		return "", err
+	}
+
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	ar := goappbuild.AuthRequest{
+		ProjectID:  projectID,
+		ProviderID: p.ID,
+		StateHash:  securetoken.Hash(state),
+		Verifier:   verifier,
+		Nonce:      nonce,
+		EndUserID:  anonID,
+		ExpiresAt:  time.Now().UTC().Add(goappbuild.AuthRequestTTL),
+	}
+
+	if err := s.storage.AuthRequests().Create(ctx, &ar); err != nil {
+		return "", err
+	}
+
+	return oidc.AuthCodeURL(meta, oidcConfig(p), state, nonce, verifier), nil
+}
+
+// Callback exchanges the code, verifies the ID token and signs in the end
+// user linked to the account of the provider. Accounts are linked to the end
+// user with the same email only when the provider verified the email.
+func (s *service) Callback(ctx context.Context, req goappbuild.SSOCallbackRequest) (goappbuild.EndUserSession, error) {
+	p, err := s.storage.IdentityProviders().GetByName(ctx, req.ProjectID, req.Provider)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	// the state is consumed even if the sign in fails
+	ar, err := s.storage.AuthRequests().Take(ctx, securetoken.Hash(req.State))
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.EndUserSession{}, errInvalidState
+		}
+
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if ar.ProviderID != p.ID || !time.Now().UTC().Before(ar.ExpiresAt) {
+		return goappbuild.EndUserSession{}, errInvalidState
+	}
+
+	meta, err := s.client.Discover(ctx, p.Issuer)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	token, err := s.client.Exchange(ctx, meta, oidcConfig(p), req.Code, ar.Verifier)
+	if err != nil {
+		return goappbuild.EndUserSession{}, errSignInFailed
+	}
+
+	claims, err := s.client.Verify(ctx, meta, p.ClientID, token.IDToken, ar.Nonce)
+	if err != nil {
+		return goappbuild.EndUserSession{}, errSignInFailed
+	}
+
+	if ar.EndUserID != uuid.Nil {
+		// the documents of the anonymous end user are reassigned
+		// regardless of the row level security policies
+		ctx = goappbuild.ContextWithSystem(ctx)
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	var anon *goappbuild.EndUser
+
+	if ar.EndUserID != uuid.Nil {
+		u, err := uw.EndUsers().Get(ctx, p.ProjectID, ar.EndUserID)
+		if err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+
+		// the end user was linked to an account since the sign in started
+		if !u.Anonymous {
+			return goappbuild.EndUserSession{}, errInvalidState
+		}
+
+		anon = &u
+	}
+
+	u, err := link(ctx, uw, p, claims, anon)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if anon != nil {
+		if err := linkAnonymous(ctx, uw, *anon, u); err != nil {
+			return goappbuild.EndUserSession{}, err
+		}
+	}
+
+	ans, err := endusers.StartSession(ctx, uw, u, s.cfg.SessionTTL)
+	if err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.EndUserSession{}, err
+	}
+
+	return ans, nil
+}
+
+// link returns the end user of the account of the provider.
+// Unknown accounts are linked to a new or an existing end user,
+// or to the anonymous end user when there is one.
+func link(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	p goappbuild.IdentityProvider,
+	claims oidc.Claims,
+	anon *goappbuild.EndUser,
+) (goappbuild.EndUser, error) {
+	ext, err := uw.ExternalIdentities().Get(ctx, p.ID, claims.Subject)
+	if err == nil {
+		return uw.EndUsers().Get(ctx, p.ProjectID, ext.EndUserID)
+	}
+
+	if goappbuild.ErrorCode(err) != goappbuild.ENotFound {
+		return goappbuild.EndUser{}, err
+	}
+
+	email := goappbuild.NormalizeEmail(claims.Email)
+	if err := goappbuild.ValidateEmail(email); err != nil {
+		return goappbuild.EndUser{}, goappbuild.Errorf(goappbuild.EValidation, "the provider did not return a valid email")
+	}
+
+	u, err := uw.EndUsers().GetByEmail(ctx, p.ProjectID, email)
+
+	switch {
+	case err == nil && !claims.EmailVerified:
+		return goappbuild.EndUser{}, goappbuild.Errorf(
+			goappbuild.EConflict,
+			"an account with the email exists and the provider did not verify the email",
+		)
+	case goappbuild.ErrorCode(err) == goappbuild.ENotFound && anon != nil:
+		u = *anon
+		u.Email = email
+		u.Anonymous = false
+
+		if u.Name == "" {
+			u.Name = claims.Name
+		}
+
+		if err := uw.EndUsers().Update(ctx, &u); err != nil {
+			return goappbuild.EndUser{}, err
+		}
+	case goappbuild.ErrorCode(err) == goappbuild.ENotFound:
+		u = goappbuild.EndUser{
+			ProjectID: p.ProjectID,
+			Email:     email,
+			Name:      claims.Name,
+		}
+
+		if err := uw.EndUsers().Create(ctx, &u); err != nil {
+			return goappbuild.EndUser{}, err
+		}
+	case err != nil:
+		return goappbuild.EndUser{}, err
+	}
+
+	ext = goappbuild.ExternalIdentity{
+		ProjectID:  p.ProjectID,
+		ProviderID: p.ID,
+		Subject:    claims.Subject,
+		EndUserID:  u.ID,
+		Email:      email,
+	}
+
+	if err := uw.ExternalIdentities().Create(ctx, &ext); err != nil {
+		return goappbuild.EndUser{}, err
+	}
+
+	return u, nil
+}
+
+// linkAnonymous finishes the linking of the anonymous end user to the
+// account. An existing account gets the documents of the anonymous end user,
+// otherwise the anonymous end user became the account and its anonymous
+// sessions are revoked.
+func linkAnonymous(ctx context.Context, uw goappbuild.Storage, anon, account goappbuild.EndUser) error {
+	if anon.ID == account.ID {
+		return uw.Sessions().RevokeEndUser(ctx, anon.ID)
+	}
+
+	return endusers.Merge(ctx, uw, anon, account)
+}
+
+// anonymous returns the id of the caller if it is an anonymous end user of the project
+func (s *service) anonymous(ctx context.Context, projectID uuid.UUID) (uuid.UUID, error) {
+	identity, ok := goappbuild.IdentityFromContext(ctx)
+	if !ok || !identity.IsEndUser() || identity.ProjectID != projectID {
+		return uuid.Nil, nil
+	}
+
+	u, err := s.storage.EndUsers().Get(ctx, projectID, identity.UserID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if !u.Anonymous {
+		return uuid.Nil, nil
+	}
+
+	return u.ID, nil
+}
+
+func oidcConfig(p goappbuild.IdentityProvider) oidc.Config {
+	return oidc.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Scopes:       p.Scopes,
+	}
+}
+
+// checkAdmin returns an error if the caller is not an admin of the project.
+// API keys cannot manage the identity providers.
+func checkAdmin(ctx context.Context, storage goappbuild.Storage, projectID uuid.UUID) error {
+	if _, err := authz.User(ctx); err != nil {
+		return err
+	}
+
+	_, err := authz.Project(ctx, storage, projectID, goappbuild.RoleAdmin)
+
+	return err
+}
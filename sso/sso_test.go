@@ -0,0 +1,210 @@
+package sso_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/pkg/oidc/oidctest"
+	"github.com/gosom/goappbuild/sso"
+)
+
+func setup(t *testing.T) (*memstore.Storage, *oidctest.Provider, goappbuild.SSOService, goappbuild.IdentityProvider) {
+	t.Helper()
+
+	storage := memstore.New()
+
+	project := goappbuild.Project{UserID: uuid.New(), Name: "app"}
+	require.NoError(t, storage.ProjectRepo.Create(context.Background(), &project))
+	require.NoError(t, storage.MemberRepo.Create(context.Background(), &goappbuild.ProjectMember{
+		ProjectID: project.ID,
+		UserID:    project.UserID,
+		Role:      goappbuild.RoleOwner,
+	}))
+
+	idp := oidctest.New("client", "secret")
+	t.Cleanup(idp.Close)
+
+	svc := sso.New(storage, sso.Config{HTTPClient: idp.Client()})
+
+	owner := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+
+	p, err := svc.CreateProvider(owner, goappbuild.CreateIdentityProviderRequest{
+		ProjectID:    project.ID,
+		Name:         "mock",
+		Issuer:       idp.URL,
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  "https://app.example.com/callback",
+		Scopes:       []string{"email", "profile"},
+	})
+	require.NoError(t, err)
+
+	return storage, idp, svc, p
+}
+
+// signIn runs the flow like a browser and the app would
+func signIn(t *testing.T, idp *oidctest.Provider, svc goappbuild.SSOService, p goappbuild.IdentityProvider) (goappbuild.EndUserSession, error) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	u, err := svc.Start(ctx, p.ProjectID, p.Name)
+	require.NoError(t, err)
+
+	code, state, err := idp.Authorize(u)
+	require.NoError(t, err)
+
+	return svc.Callback(ctx, goappbuild.SSOCallbackRequest{
+		ProjectID: p.ProjectID,
+		Provider:  p.Name,
+		Code:      code,
+		State:     state,
+	})
+}
+
+func Test_SSOService_Callback(t *testing.T) {
+	storage, idp, svc, p := setup(t)
+
+	idp.SetUser(oidctest.User{Subject: "123", Email: "Jane@example.com", EmailVerified: true, Name: "Jane"})
+
+	session, err := signIn(t, idp, svc, p)
+	require.NoError(t, err)
+	require.True(t, goappbuild.IsSessionToken(session.Token))
+	require.Equal(t, "jane@example.com", session.User.Email)
+	require.Equal(t, "Jane", session.User.Name)
+	require.Empty(t, session.User.PasswordHash)
+
+	t.Run("test the account is linked", func(t *testing.T) {
+		idp.SetUser(oidctest.User{Subject: "123", Email: "changed@example.com", EmailVerified: true})
+
+		again, err := signIn(t, idp, svc, p)
+		require.NoError(t, err)
+		require.Equal(t, session.User.ID, again.User.ID)
+	})
+
+	t.Run("test verified email links the existing end user", func(t *testing.T) {
+		existing := goappbuild.EndUser{ProjectID: p.ProjectID, Email: "john@example.com", PasswordHash: "hash"}
+		require.NoError(t, storage.EndUserRepo.Create(context.Background(), &existing))
+
+		idp.SetUser(oidctest.User{Subject: "456", Email: "john@example.com", EmailVerified: true})
+
+		s, err := signIn(t, idp, svc, p)
+		require.NoError(t, err)
+		require.Equal(t, existing.ID, s.User.ID)
+	})
+
+	t.Run("test unverified email does not take over an end user", func(t *testing.T) {
+		existing := goappbuild.EndUser{ProjectID: p.ProjectID, Email: "mary@example.com", PasswordHash: "hash"}
+		require.NoError(t, storage.EndUserRepo.Create(context.Background(), &existing))
+
+		idp.SetUser(oidctest.User{Subject: "789", Email: "mary@example.com"})
+
+		_, err := signIn(t, idp, svc, p)
+		require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test missing email", func(t *testing.T) {
+		idp.SetUser(oidctest.User{Subject: "000"})
+
+		_, err := signIn(t, idp, svc, p)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test invalid id token", func(t *testing.T) {
+		idp.SetUser(oidctest.User{Subject: "123", Email: "jane@example.com", EmailVerified: true})
+		idp.OverrideClaims(jwt.MapClaims{"aud": "another-client"})
+		defer idp.OverrideClaims(nil)
+
+		_, err := signIn(t, idp, svc, p)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+}
+
+func Test_SSOService_State(t *testing.T) {
+	_, idp, svc, p := setup(t)
+	ctx := context.Background()
+
+	idp.SetUser(oidctest.User{Subject: "123", Email: "jane@example.com", EmailVerified: true})
+
+	u, err := svc.Start(ctx, p.ProjectID, p.Name)
+	require.NoError(t, err)
+
+	code, state, err := idp.Authorize(u)
+	require.NoError(t, err)
+
+	req := goappbuild.SSOCallbackRequest{ProjectID: p.ProjectID, Provider: p.Name, Code: code, State: state}
+
+	t.Run("test unknown state", func(t *testing.T) {
+		invalid := req
+		invalid.State = "invalid"
+
+		_, err := svc.Callback(ctx, invalid)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test state is used once", func(t *testing.T) {
+		_, err := svc.Callback(ctx, req)
+		require.NoError(t, err)
+
+		_, err = svc.Callback(ctx, req)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test unknown provider", func(t *testing.T) {
+		_, err := svc.Start(ctx, p.ProjectID, "unknown")
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+}
+
+func Test_SSOService_CreateProvider(t *testing.T) {
+	storage, idp, svc, p := setup(t)
+
+	project, err := storage.ProjectRepo.Get(context.Background(), p.ProjectID)
+	require.NoError(t, err)
+
+	owner := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: project.UserID})
+
+	req := goappbuild.CreateIdentityProviderRequest{
+		ProjectID:   p.ProjectID,
+		Name:        "other",
+		Issuer:      idp.URL,
+		ClientID:    "client",
+		RedirectURL: "https://app.example.com/callback",
+	}
+
+	t.Run("test only admins", func(t *testing.T) {
+		ctx := goappbuild.ContextWithIdentity(context.Background(), goappbuild.Identity{UserID: uuid.New()})
+
+		_, err := svc.CreateProvider(ctx, req)
+		require.Error(t, err)
+
+		_, err = svc.ListProviders(ctx, p.ProjectID)
+		require.Error(t, err)
+
+		providers, err := svc.ListProviders(owner, p.ProjectID)
+		require.NoError(t, err)
+		require.Len(t, providers, 1)
+	})
+
+	t.Run("test invalid issuer", func(t *testing.T) {
+		invalid := req
+		invalid.Issuer = idp.URL + "/unknown"
+
+		_, err := svc.CreateProvider(owner, invalid)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test duplicate name", func(t *testing.T) {
+		dup := req
+		dup.Name = p.Name
+
+		_, err := svc.CreateProvider(owner, dup)
+		require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+	})
+}
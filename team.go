@@ -0,0 +1,80 @@
+package goappbuild
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// MaxTeamNameLength is the maximum number of characters of a team name
+const MaxTeamNameLength = 100
+
+// Team is a group of platform users and end users of a project.
+// Documents shared with a team are shared with all its members.
+type Team struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TeamMember is a platform user or an end user of the project in a team
+type TeamMember struct {
+	TeamID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+// CreateTeamRequest is the request to create a team
+type CreateTeamRequest struct {
+	ProjectID uuid.UUID
+	Name      string
+}
+
+// Validate validates the request
+func (o *CreateTeamRequest) Validate() error {
+	name := strings.TrimSpace(o.Name)
+
+	if name == "" {
+		return Errorf(EValidation, "name is required")
+	}
+
+	if utf8.RuneCountInString(name) > MaxTeamNameLength {
+		return Errorf(EValidation, "name must be at most %d characters", MaxTeamNameLength)
+	}
+
+	return nil
+}
+
+// TeamRepo is the repository of the teams
+type TeamRepo interface {
+	Create(context.Context, *Team) error
+	// Get returns the team of the project
+	Get(ctx context.Context, projectID, id uuid.UUID) (Team, error)
+	// List returns the teams of the project ordered by name
+	List(ctx context.Context, projectID uuid.UUID) ([]Team, error)
+	// Delete deletes the team, the members are removed with it
+	Delete(ctx context.Context, projectID, id uuid.UUID) error
+	// AddMember adds the user to the team, adding a member again is a no-op
+	AddMember(ctx context.Context, teamID, userID uuid.UUID) error
+	RemoveMember(ctx context.Context, teamID, userID uuid.UUID) error
+	// ListMembers returns the members of the team, oldest first
+	ListMembers(ctx context.Context, teamID uuid.UUID) ([]TeamMember, error)
+}
+
+// TeamService manages the teams of the projects. The admins of the project
+// manage the teams and every member can list them.
+type TeamService interface {
+	Create(context.Context, CreateTeamRequest) (Team, error)
+	List(ctx context.Context, projectID uuid.UUID) ([]Team, error)
+	// Delete deletes the team and the sharing of the documents with it
+	Delete(ctx context.Context, projectID, id uuid.UUID) error
+	// AddMember adds a platform user or an end user of the project to the team
+	AddMember(ctx context.Context, projectID, teamID, userID uuid.UUID) error
+	RemoveMember(ctx context.Context, projectID, teamID, userID uuid.UUID) error
+	ListMembers(ctx context.Context, projectID, teamID uuid.UUID) ([]TeamMember, error)
+}
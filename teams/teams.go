@@ -0,0 +1,185 @@
+package teams
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+)
+
+var _ goappbuild.TeamService = (*service)(nil)
+
+type service struct {
+	storage goappbuild.Storage
+}
+
+// New returns a new team service
+func New(storage goappbuild.Storage) goappbuild.TeamService {
+	return &service{
+		storage: storage,
+	}
+}
+
+// Create creates a team of the project
+func (s *service) Create(ctx context.Context, req goappbuild.CreateTeamRequest) (goappbuild.Team, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.Team{}, err
+	}
+
+	uw, err := s.manage(ctx, req.ProjectID)
+	if err != nil {
+		return goappbuild.Team{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	t := goappbuild.Team{
+		ProjectID: req.ProjectID,
+		Name:      strings.TrimSpace(req.Name),
+	}
+
+	if err := uw.Teams().Create(ctx, &t); err != nil {
+		return goappbuild.Team{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.Team{}, err
+	}
+
+	return t, nil
+}
+
+// List returns the teams of the project ordered by name
+func (s *service) List(ctx context.Context, projectID uuid.UUID) ([]goappbuild.Team, error) {
+	if _, err := authz.User(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := authz.Project(ctx, s.storage, projectID, goappbuild.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	return s.storage.Teams().List(ctx, projectID)
+}
+
+// Delete deletes the team. The documents shared with the team are not
+// shared with its members anymore.
+func (s *service) Delete(ctx context.Context, projectID, id uuid.UUID) error {
+	uw, err := s.manage(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if err := uw.Teams().Delete(ctx, projectID, id); err != nil {
+		return err
+	}
+
+	if err := uw.ACL().DeletePrincipal(ctx, projectID, goappbuild.PrincipalTeam, id); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// AddMember adds a platform user or an end user of the project to the team
+func (s *service) AddMember(ctx context.Context, projectID, teamID, userID uuid.UUID) error {
+	uw, err := s.manage(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if _, err := uw.Teams().Get(ctx, projectID, teamID); err != nil {
+		return err
+	}
+
+	if err := checkUser(ctx, uw, projectID, userID); err != nil {
+		return err
+	}
+
+	if err := uw.Teams().AddMember(ctx, teamID, userID); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// RemoveMember removes a user from the team
+func (s *service) RemoveMember(ctx context.Context, projectID, teamID, userID uuid.UUID) error {
+	uw, err := s.manage(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	if _, err := uw.Teams().Get(ctx, projectID, teamID); err != nil {
+		return err
+	}
+
+	if err := uw.Teams().RemoveMember(ctx, teamID, userID); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+// ListMembers returns the members of the team, oldest first
+func (s *service) ListMembers(ctx context.Context, projectID, teamID uuid.UUID) ([]goappbuild.TeamMember, error) {
+	if _, err := authz.User(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := authz.Project(ctx, s.storage, projectID, goappbuild.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.storage.Teams().Get(ctx, projectID, teamID); err != nil {
+		return nil, err
+	}
+
+	return s.storage.Teams().ListMembers(ctx, teamID)
+}
+
+// manage returns a unit of work if the caller can manage the teams of the
+// project. The caller must roll it back.
+func (s *service) manage(ctx context.Context, projectID uuid.UUID) (goappbuild.Storage, error) {
+	if _, err := authz.User(ctx); err != nil {
+		return nil, err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := authz.Project(ctx, uw, projectID, goappbuild.RoleAdmin); err != nil {
+		_ = uw.Rollback(ctx)
+
+		return nil, err
+	}
+
+	return uw, nil
+}
+
+// checkUser returns an error unless the id is an end user of the project
+// or a platform user
+func checkUser(ctx context.Context, uw goappbuild.Storage, projectID, id uuid.UUID) error {
+	_, err := uw.EndUsers().Get(ctx, projectID, id)
+	if goappbuild.ErrorCode(err) != goappbuild.ENotFound {
+		return err
+	}
+
+	_, err = uw.Users().Get(ctx, id)
+	if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+		return goappbuild.Errorf(goappbuild.ENotFound, "user %s not found", id)
+	}
+
+	return err
+}
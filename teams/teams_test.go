@@ -0,0 +1,127 @@
+package teams_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/teams"
+)
+
+func Test_TeamService(t *testing.T) {
+	storage := memstore.New()
+	svc := teams.New(storage)
+	bg := context.Background()
+
+	newUser := func(t *testing.T, email string) (goappbuild.User, context.Context) {
+		t.Helper()
+
+		u := goappbuild.User{Email: email}
+		require.NoError(t, storage.UserRepo.Create(bg, &u))
+
+		return u, goappbuild.ContextWithIdentity(bg, goappbuild.Identity{UserID: u.ID})
+	}
+
+	owner, ownerCtx := newUser(t, "owner@example.com")
+	viewer, viewerCtx := newUser(t, "viewer@example.com")
+	_, strangerCtx := newUser(t, "stranger@example.com")
+
+	project := goappbuild.Project{UserID: owner.ID, Name: "teams"}
+	require.NoError(t, storage.ProjectRepo.Create(bg, &project))
+
+	for _, m := range []goappbuild.ProjectMember{
+		{ProjectID: project.ID, UserID: owner.ID, Role: goappbuild.RoleOwner},
+		{ProjectID: project.ID, UserID: viewer.ID, Role: goappbuild.RoleViewer},
+	} {
+		m := m
+		require.NoError(t, storage.MemberRepo.Create(bg, &m))
+	}
+
+	endUser := goappbuild.EndUser{ProjectID: project.ID, Email: "reader@example.com"}
+	require.NoError(t, storage.EndUserRepo.Create(bg, &endUser))
+
+	req := goappbuild.CreateTeamRequest{ProjectID: project.ID, Name: " editors "}
+
+	var team goappbuild.Team
+
+	t.Run("test only admins can create teams", func(t *testing.T) {
+		_, err := svc.Create(viewerCtx, req)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.Create(strangerCtx, req)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test create", func(t *testing.T) {
+		_, err := svc.Create(ownerCtx, goappbuild.CreateTeamRequest{ProjectID: project.ID, Name: " "})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		team, err = svc.Create(ownerCtx, req)
+		require.NoError(t, err)
+		require.Equal(t, "editors", team.Name)
+
+		_, err = svc.Create(ownerCtx, req)
+		require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+
+		items, err := svc.List(viewerCtx, project.ID)
+		require.NoError(t, err)
+		require.Equal(t, []goappbuild.Team{team}, items)
+
+		_, err = svc.List(strangerCtx, project.ID)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test members", func(t *testing.T) {
+		err := svc.AddMember(ownerCtx, project.ID, team.ID, uuid.New())
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		err = svc.AddMember(ownerCtx, project.ID, uuid.New(), endUser.ID)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+
+		err = svc.AddMember(viewerCtx, project.ID, team.ID, endUser.ID)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		require.NoError(t, svc.AddMember(ownerCtx, project.ID, team.ID, endUser.ID))
+		require.NoError(t, svc.AddMember(ownerCtx, project.ID, team.ID, endUser.ID))
+		require.NoError(t, svc.AddMember(ownerCtx, project.ID, team.ID, viewer.ID))
+
+		members, err := svc.ListMembers(viewerCtx, project.ID, team.ID)
+		require.NoError(t, err)
+		require.Len(t, members, 2)
+		require.Equal(t, endUser.ID, members[0].UserID)
+
+		require.NoError(t, svc.RemoveMember(ownerCtx, project.ID, team.ID, viewer.ID))
+
+		err = svc.RemoveMember(ownerCtx, project.ID, team.ID, viewer.ID)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test delete removes the sharing with the team", func(t *testing.T) {
+		e := goappbuild.ACLEntry{
+			ProjectID:     project.ID,
+			Collection:    "posts",
+			DocumentID:    uuid.NewString(),
+			PrincipalType: goappbuild.PrincipalTeam,
+			PrincipalID:   team.ID,
+			Permission:    goappbuild.PermissionRead,
+		}
+		require.NoError(t, storage.ACLRepo.Upsert(bg, &e))
+
+		granted, err := storage.ACLRepo.Granted(bg, project.ID, "posts", e.DocumentID, endUser.ID, goappbuild.PermissionRead)
+		require.NoError(t, err)
+		require.True(t, granted)
+
+		require.NoError(t, svc.Delete(ownerCtx, project.ID, team.ID))
+
+		entries, err := storage.ACLRepo.List(bg, project.ID, "posts", e.DocumentID)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+
+		err = svc.Delete(ownerCtx, project.ID, team.ID)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+}
@@ -2,33 +2,338 @@ package goappbuild
 
 import (
 	"context"
+	"net/mail"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
+const (
+	// MinPasswordLength is the minimum number of characters of a password
+	MinPasswordLength = 8
+	// MaxPasswordLength is the maximum length of a password in bytes
+	MaxPasswordLength = 72
+
+	// EmailVerificationTTL is the lifetime of the email verification links
+	EmailVerificationTTL = 24 * time.Hour
+	// PasswordResetTTL is the lifetime of the password reset links
+	PasswordResetTTL = time.Hour
+	// LoginLinkTTL is the lifetime of the magic login links
+	LoginLinkTTL = 15 * time.Minute
+	// LoginCodeTTL is the lifetime of the one-time login codes
+	LoginCodeTTL = 10 * time.Minute
+	// LoginCodeLength is the number of digits of the one-time login codes
+	LoginCodeLength = 6
+	// UserTokenRetention is how long the expired user tokens are kept.
+	// The recent tokens of a user limit the passwordless login requests.
+	UserTokenRetention = 24 * time.Hour
+
+	// MaxUsers is the maximum number of users listed at once
+	MaxUsers = 500
+	// MaxImpersonationReasonLength is the maximum length of the reason of an impersonation
+	MaxImpersonationReasonLength = 500
+)
+
 // User represents a user.
 type User struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID    uuid.UUID
+	Email string
+	// Password is the plain text password. It is only set when
+	// registering or changing the password and it is never stored.
+	Password string
+	// PasswordHash is the hash of the password
+	PasswordHash string
+	// EmailVerifiedAt is set once the user proves the ownership of the email
+	EmailVerifiedAt *time.Time
+	// IsAdmin is true for the operators of the platform.
+	// Admins are promoted directly in the database.
+	IsAdmin bool
+	// DisabledAt is set while the user is disabled by an admin.
+	// Disabled users cannot log in.
+	DisabledAt *time.Time
+	// TokensRevokedAt is set when the sessions of the user are ended.
+	// The access tokens issued before it are rejected.
+	TokensRevokedAt *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// IsDisabled returns true if the user is disabled
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// TokenRevoked returns true if an access token of the user issued at the
+// given time was revoked. The issue times of the tokens have a precision of
+// a second, so the tokens issued in the second of the revocation are revoked.
+func (u *User) TokenRevoked(issuedAt time.Time) bool {
+	return u.TokensRevokedAt != nil && issuedAt.Before(*u.TokensRevokedAt)
 }
 
 // Validate returns an error if the user is invalid.
 func (u *User) Validate() error {
+	if err := ValidateEmail(u.Email); err != nil {
+		return err
+	}
+
+	if u.Password == "" && u.PasswordHash == "" {
+		return Errorf(EValidation, "password is required")
+	}
+
+	if u.Password != "" {
+		return ValidatePassword(u.Password)
+	}
+
+	return nil
+}
+
+// NormalizeEmail returns the normalized form of an email address
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateEmail returns an error if the email is not a valid address
+func ValidateEmail(email string) error {
+	if email == "" {
+		return Errorf(EValidation, "email is required")
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return Errorf(EValidation, "invalid email address")
+	}
+
+	return nil
+}
+
+// ValidatePassword returns an error if the password does not
+// satisfy the password policy
+func ValidatePassword(password string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return Errorf(EValidation, "password must have at least %d characters", MinPasswordLength)
+	}
+
+	if len(password) > MaxPasswordLength {
+		return Errorf(EValidation, "password must have at most %d bytes", MaxPasswordLength)
+	}
+
+	if strings.TrimSpace(password) == "" {
+		return Errorf(EValidation, "password cannot be blank")
+	}
+
 	return nil
 }
 
 // RegisterUserRequest represents a request to register a user.
 type RegisterUserRequest struct {
+	Email    string
+	Password string
+}
+
+// LoginRequest represents a request to authenticate a user
+// with email and password.
+type LoginRequest struct {
+	Email    string
+	Password string
+}
+
+// ResetPasswordRequest represents a request to set a new password
+// with a password reset token.
+type ResetPasswordRequest struct {
+	Token    string
+	Password string
+}
+
+// PasswordlessMethod is how the passwordless login is delivered to the email
+type PasswordlessMethod string
+
+const (
+	// PasswordlessLink emails a magic link
+	PasswordlessLink PasswordlessMethod = "link"
+	// PasswordlessCode emails a one-time code
+	PasswordlessCode PasswordlessMethod = "code"
+)
+
+// PasswordlessRequest represents a request to log in without a password.
+type PasswordlessRequest struct {
+	Email  string
+	Method PasswordlessMethod
+	// BindDevice binds the link or the code to the device of the request.
+	// They are only accepted together with the device token of the challenge.
+	BindDevice bool
+}
+
+// Validate returns an error if the request is invalid.
+func (o *PasswordlessRequest) Validate() error {
+	if strings.TrimSpace(o.Email) == "" {
+		return Errorf(EValidation, "email is required")
+	}
+
+	switch o.Method {
+	case PasswordlessLink, PasswordlessCode:
+		return nil
+	default:
+		return Errorf(EValidation, "method must be %q or %q", PasswordlessLink, PasswordlessCode)
+	}
+}
+
+// PasswordlessChallenge is returned to the device that requested a
+// passwordless login. It is the same whether the email is registered or not.
+type PasswordlessChallenge struct {
+	// DeviceToken is set when the login is bound to the device
+	DeviceToken string
+	ExpiresAt   time.Time
+}
+
+// LoginLinkRequest represents a login with the token of a magic link.
+type LoginLinkRequest struct {
+	Token string
+	// DeviceToken is required when the link is bound to a device
+	DeviceToken string
+}
+
+// LoginCodeRequest represents a login with a one-time code.
+type LoginCodeRequest struct {
+	Email string
+	Code  string
+	// DeviceToken is required when the code is bound to a device
+	DeviceToken string
+}
+
+// UserFilter selects the users listed by the admins.
+// The zero values do not filter.
+type UserFilter struct {
+	// Search matches a part of the email
+	Search string
+	// Disabled selects the disabled or the enabled users
+	Disabled *bool
+	Limit    int
+	Offset   int
+}
+
+// Validate returns an error if the filter is invalid.
+func (o *UserFilter) Validate() error {
+	if o.Limit < 0 || o.Limit > MaxUsers {
+		return Errorf(EValidation, "limit must be between 0 and %d", MaxUsers)
+	}
+
+	if o.Offset < 0 {
+		return Errorf(EValidation, "offset must not be negative")
+	}
+
+	return nil
+}
+
+// ImpersonateRequest represents a request of an admin to act as a user.
+type ImpersonateRequest struct {
+	UserID uuid.UUID
+	// Reason is recorded in the security events, like the ticket being debugged
+	Reason string
+}
+
+// Validate returns an error if the request is invalid.
+func (o *ImpersonateRequest) Validate() error {
+	if o.UserID == uuid.Nil {
+		return Errorf(EValidation, "user id is required")
+	}
+
+	if strings.TrimSpace(o.Reason) == "" {
+		return Errorf(EValidation, "reason is required")
+	}
+
+	if len(o.Reason) > MaxImpersonationReasonLength {
+		return Errorf(EValidation, "reason must have at most %d bytes", MaxImpersonationReasonLength)
+	}
+
+	return nil
+}
+
+// TokenPurpose is what a user token can be used for
+type TokenPurpose string
+
+const (
+	PurposeVerifyEmail   TokenPurpose = "verify_email"
+	PurposeResetPassword TokenPurpose = "reset_password"
+	PurposeLoginLink     TokenPurpose = "login_link"
+	PurposeLoginCode     TokenPurpose = "login_code"
+)
+
+// UserToken is a single use token sent to the email of a user
+type UserToken struct {
+	ID      uuid.UUID
+	UserID  uuid.UUID
+	Purpose TokenPurpose
+	// TokenHash is the hash of the token, the token is only sent to the user
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
 }
 
 // UserService represents a service for managing users.
 type UserService interface {
+	// Register creates a user and sends the email verification link
 	Register(context.Context, RegisterUserRequest) (User, error)
+	// Login returns the user with the given credentials
+	Login(context.Context, LoginRequest) (User, error)
+	// RequestEmailVerification sends a new verification link to the caller
+	RequestEmailVerification(context.Context) error
+	// VerifyEmail marks the email of the user of the token as verified
+	VerifyEmail(ctx context.Context, token string) error
+	// RequestPasswordReset sends a password reset link if the email is
+	// registered. It does not reveal whether it is.
+	RequestPasswordReset(ctx context.Context, email string) error
+	// ResetPassword sets a new password and logs the user out everywhere
+	ResetPassword(context.Context, ResetPasswordRequest) error
+	// RequestLogin emails a magic link or a one-time code if the email is
+	// registered. It does not reveal whether it is.
+	RequestLogin(context.Context, PasswordlessRequest) (PasswordlessChallenge, error)
+	// LoginWithLink returns the user of the magic link token
+	LoginWithLink(context.Context, LoginLinkRequest) (User, error)
+	// LoginWithCode returns the user with the given email and one-time code
+	LoginWithCode(context.Context, LoginCodeRequest) (User, error)
+
+	// List returns the matching users. Admins only.
+	List(context.Context, UserFilter) ([]User, error)
+	// Get returns a user. Admins only.
+	Get(ctx context.Context, id uuid.UUID) (User, error)
+	// Disable disables a user and logs them out everywhere. Admins only.
+	Disable(ctx context.Context, id uuid.UUID) (User, error)
+	// Enable enables a disabled user. Admins only.
+	Enable(ctx context.Context, id uuid.UUID) (User, error)
+	// Delete deletes a user that does not own projects. Admins only.
+	Delete(ctx context.Context, id uuid.UUID) error
+	// ForceLogout revokes the refresh tokens of a user. Admins only.
+	ForceLogout(ctx context.Context, id uuid.UUID) error
+	// Projects returns the memberships of a user. Admins only.
+	Projects(ctx context.Context, id uuid.UUID) ([]ProjectMember, error)
 }
 
 // UserRepo represents a repository for managing users.
 type UserRepo interface {
 	Create(context.Context, *User) error
+	Get(context.Context, uuid.UUID) (User, error)
+	// GetByEmail returns the user with the given (normalized) email
+	GetByEmail(context.Context, string) (User, error)
+	// Update stores the email, the password hash, the email verification
+	// and the disabled time of the user
+	Update(context.Context, *User) error
+	// List returns the matching users ordered by email
+	List(context.Context, UserFilter) ([]User, error)
+	// Delete deletes a user. It returns an EConflict error if the user owns projects.
+	Delete(context.Context, uuid.UUID) error
+}
+
+// UserTokenRepo represents a repository for the single use tokens of the users.
+type UserTokenRepo interface {
+	// Create stores a new token. It returns an EConflict error if the hash exists.
+	Create(context.Context, *UserToken) error
+	// Count returns the number of tokens of the user with one of the purposes
+	// created after since, used or not
+	Count(ctx context.Context, userID uuid.UUID, since time.Time, purposes ...TokenPurpose) (int, error)
+	// Use marks the unused and unexpired token with the purpose and the hash
+	// as used and returns it. It returns an ENotFound error if there is none.
+	Use(ctx context.Context, purpose TokenPurpose, hash string) (UserToken, error)
 }
@@ -0,0 +1,47 @@
+package goappbuild_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+)
+
+func Test_User_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    goappbuild.User
+		wantErr bool
+	}{
+		{"valid", goappbuild.User{Email: "john@example.com", Password: "correct horse"}, false},
+		{"hashed password", goappbuild.User{Email: "john@example.com", PasswordHash: "$2a$10$hash"}, false},
+		{"missing email", goappbuild.User{Password: "correct horse"}, true},
+		{"invalid email", goappbuild.User{Email: "john@", Password: "correct horse"}, true},
+		{"display name", goappbuild.User{Email: "John <john@example.com>", Password: "correct horse"}, true},
+		{"missing password", goappbuild.User{Email: "john@example.com"}, true},
+		{"short password", goappbuild.User{Email: "john@example.com", Password: "short"}, true},
+		{"long password", goappbuild.User{Email: "john@example.com", Password: strings.Repeat("a", 73)}, true},
+		{"blank password", goappbuild.User{Email: "john@example.com", Password: "          "}, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.user.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func Test_NormalizeEmail(t *testing.T) {
+	require.Equal(t, "john@example.com", goappbuild.NormalizeEmail("  John@Example.COM "))
+}
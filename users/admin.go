@@ -0,0 +1,158 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+	"github.com/gosom/goappbuild/security"
+)
+
+// DefaultListLimit is the number of users listed when the filter has no limit
+const DefaultListLimit = 50
+
+var errSelf = goappbuild.Errorf(goappbuild.EForbidden, "admins cannot perform this operation on themselves")
+
+// List returns the matching users
+func (s *service) List(ctx context.Context, filter goappbuild.UserFilter) ([]goappbuild.User, error) {
+	if _, err := authz.Admin(ctx, s.storage); err != nil {
+		return nil, err
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = DefaultListLimit
+	}
+
+	return s.storage.Users().List(ctx, filter)
+}
+
+// Get returns a user
+func (s *service) Get(ctx context.Context, id uuid.UUID) (goappbuild.User, error) {
+	if _, err := authz.Admin(ctx, s.storage); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	return s.storage.Users().Get(ctx, id)
+}
+
+// Disable disables the user and revokes the tokens of the user
+func (s *service) Disable(ctx context.Context, id uuid.UUID) (goappbuild.User, error) {
+	return s.manage(ctx, id, goappbuild.EventUserDisabled, func(uw goappbuild.Storage, u *goappbuild.User) error {
+		if u.IsDisabled() {
+			return goappbuild.Errorf(goappbuild.EConflict, "user is already disabled")
+		}
+
+		now := time.Now().UTC()
+		u.DisabledAt = &now
+
+		return revokeTokens(ctx, uw, u, now)
+	})
+}
+
+// Enable enables a disabled user
+func (s *service) Enable(ctx context.Context, id uuid.UUID) (goappbuild.User, error) {
+	return s.manage(ctx, id, goappbuild.EventUserEnabled, func(uw goappbuild.Storage, u *goappbuild.User) error {
+		if !u.IsDisabled() {
+			return goappbuild.Errorf(goappbuild.EConflict, "user is not disabled")
+		}
+
+		u.DisabledAt = nil
+
+		return uw.Users().Update(ctx, u)
+	})
+}
+
+// Delete deletes the user. The users that own projects are not deleted,
+// the projects have to be deleted or transferred first.
+func (s *service) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := s.manage(ctx, id, goappbuild.EventUserDeleted, func(uw goappbuild.Storage, u *goappbuild.User) error {
+		return uw.Users().Delete(ctx, u.ID)
+	})
+
+	return err
+}
+
+// ForceLogout revokes the tokens of the user
+func (s *service) ForceLogout(ctx context.Context, id uuid.UUID) error {
+	_, err := s.manage(ctx, id, goappbuild.EventForcedLogout, func(uw goappbuild.Storage, u *goappbuild.User) error {
+		return revokeTokens(ctx, uw, u, time.Now().UTC())
+	})
+
+	return err
+}
+
+// Projects returns the memberships of the user
+func (s *service) Projects(ctx context.Context, id uuid.UUID) ([]goappbuild.ProjectMember, error) {
+	if _, err := authz.Admin(ctx, s.storage); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.storage.Users().Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.storage.Members().ListByUser(ctx, id)
+}
+
+// revokeTokens stores the user with the access tokens issued before now
+// revoked and revokes the refresh tokens of the user
+func revokeTokens(ctx context.Context, uw goappbuild.Storage, u *goappbuild.User, now time.Time) error {
+	u.TokensRevokedAt = &now
+
+	if err := uw.Users().Update(ctx, u); err != nil {
+		return err
+	}
+
+	return uw.RefreshTokens().RevokeUser(ctx, u.ID)
+}
+
+// manage runs an action of the admin of the context on another user
+// and records it in the security events
+func (s *service) manage(
+	ctx context.Context,
+	id uuid.UUID,
+	kind goappbuild.SecurityEventKind,
+	action func(goappbuild.Storage, *goappbuild.User) error,
+) (goappbuild.User, error) {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	admin, err := authz.Admin(ctx, uw)
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	if admin.ID == id {
+		return goappbuild.User{}, errSelf
+	}
+
+	u, err := uw.Users().Get(ctx, id)
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	if err := action(uw, &u); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	if err := security.Audit(ctx, uw, kind, admin, u, ""); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	if err := uw.Commit(ctx); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	return u, nil
+}
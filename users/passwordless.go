@@ -0,0 +1,283 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/mailer"
+	"github.com/gosom/goappbuild/pkg/securetoken"
+)
+
+const (
+	// DefaultPasswordlessLimit is the default number of passwordless logins
+	// that can be requested for an email in the window
+	DefaultPasswordlessLimit = 5
+	// DefaultPasswordlessWindow is the default window of the passwordless limit
+	DefaultPasswordlessWindow = time.Hour
+
+	// codeAttempts is the number of tries to store a code that is unique
+	codeAttempts = 3
+)
+
+var errInvalidCode = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid or expired code")
+
+// RequestLogin emails a magic link or a one-time code to the email.
+// Unknown and disabled users and the emails that exceeded the limit are ignored,
+// so that the response does not reveal them.
+func (s *service) RequestLogin(
+	ctx context.Context,
+	req goappbuild.PasswordlessRequest,
+) (goappbuild.PasswordlessChallenge, error) {
+	if err := req.Validate(); err != nil {
+		return goappbuild.PasswordlessChallenge{}, err
+	}
+
+	ttl := goappbuild.LoginLinkTTL
+	if req.Method == goappbuild.PasswordlessCode {
+		ttl = goappbuild.LoginCodeTTL
+	}
+
+	ans := goappbuild.PasswordlessChallenge{
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+
+	if req.BindDevice {
+		device, err := securetoken.Generate("", securetoken.DefaultSize)
+		if err != nil {
+			return goappbuild.PasswordlessChallenge{}, err
+		}
+
+		ans.DeviceToken = device
+	}
+
+	u, err := s.storage.Users().GetByEmail(ctx, goappbuild.NormalizeEmail(req.Email))
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return ans, nil
+		}
+
+		return goappbuild.PasswordlessChallenge{}, err
+	}
+
+	if u.IsDisabled() {
+		return ans, nil
+	}
+
+	since := time.Now().UTC().Add(-s.cfg.PasswordlessWindow)
+
+	count, err := s.storage.UserTokens().Count(ctx, u.ID, since, goappbuild.PurposeLoginLink, goappbuild.PurposeLoginCode)
+	if err != nil {
+		return goappbuild.PasswordlessChallenge{}, err
+	}
+
+	if count >= s.cfg.PasswordlessLimit {
+		s.cfg.Logger.Printf("users: passwordless login limit reached for %s", u.ID)
+
+		return ans, nil
+	}
+
+	if req.Method == goappbuild.PasswordlessCode {
+		err = s.sendCode(ctx, u, ans.DeviceToken)
+	} else {
+		err = s.sendLink(ctx, u, ans.DeviceToken)
+	}
+
+	if err != nil {
+		return goappbuild.PasswordlessChallenge{}, err
+	}
+
+	return ans, nil
+}
+
+// LoginWithLink returns the user of the magic link token.
+// The email of the user is verified since the link was delivered to it.
+func (s *service) LoginWithLink(ctx context.Context, req goappbuild.LoginLinkRequest) (goappbuild.User, error) {
+	if req.Token == "" {
+		return goappbuild.User{}, errInvalidToken
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	u, err := useToken(ctx, uw, goappbuild.PurposeLoginLink, bindDevice(req.Token, req.DeviceToken))
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	if err := s.completeLogin(ctx, uw, &u); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	return u, nil
+}
+
+// LoginWithCode returns the user with the email and the one-time code.
+// The wrong codes count as failed logins of the email and the client.
+func (s *service) LoginWithCode(ctx context.Context, req goappbuild.LoginCodeRequest) (goappbuild.User, error) {
+	attempt := goappbuild.LoginAttempt{Email: goappbuild.NormalizeEmail(req.Email)}
+
+	if err := s.cfg.Guard.Check(ctx, attempt); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	u, err := s.storage.Users().GetByEmail(ctx, attempt.Email)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.User{}, s.failCode(ctx, attempt)
+		}
+
+		return goappbuild.User{}, err
+	}
+
+	attempt.UserID = u.ID
+
+	code := strings.ReplaceAll(req.Code, " ", "")
+	if len(code) != goappbuild.LoginCodeLength {
+		return goappbuild.User{}, s.failCode(ctx, attempt)
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	defer uw.Rollback(ctx)
+
+	hash := securetoken.Hash(bindDevice(codeToken(u.ID, code), req.DeviceToken))
+
+	if _, err := uw.UserTokens().Use(ctx, goappbuild.PurposeLoginCode, hash); err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.User{}, s.failCode(ctx, attempt)
+		}
+
+		return goappbuild.User{}, err
+	}
+
+	if err := s.completeLogin(ctx, uw, &u); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	if err := s.cfg.Guard.Succeed(ctx, attempt); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	return u, nil
+}
+
+// completeLogin rejects the disabled users, verifies the email of the user
+// and commits the unit of work
+func (s *service) completeLogin(ctx context.Context, uw goappbuild.Storage, u *goappbuild.User) error {
+	if u.IsDisabled() {
+		return errDisabled
+	}
+
+	if u.EmailVerifiedAt == nil {
+		now := time.Now().UTC()
+		u.EmailVerifiedAt = &now
+
+		if err := uw.Users().Update(ctx, u); err != nil {
+			return err
+		}
+	}
+
+	return uw.Commit(ctx)
+}
+
+// failCode records the failed login and returns the error for the caller
+func (s *service) failCode(ctx context.Context, attempt goappbuild.LoginAttempt) error {
+	if err := s.cfg.Guard.Fail(ctx, attempt); err != nil {
+		return err
+	}
+
+	return errInvalidCode
+}
+
+// sendLink emails a magic link to the user
+func (s *service) sendLink(ctx context.Context, u goappbuild.User, device string) error {
+	token, err := securetoken.Generate("", securetoken.DefaultSize)
+	if err != nil {
+		return err
+	}
+
+	hash := securetoken.Hash(bindDevice(token, device))
+
+	if err := createToken(ctx, s.storage, u.ID, goappbuild.PurposeLoginLink, hash, goappbuild.LoginLinkTTL); err != nil {
+		return err
+	}
+
+	return s.send(ctx, mailer.TemplateLoginLink, u, s.cfg.LoginLinkURL, token, goappbuild.LoginLinkTTL)
+}
+
+// sendCode emails a one-time code to the user. The codes are short, so the
+// hash includes the user and a new code is drawn when it collides.
+func (s *service) sendCode(ctx context.Context, u goappbuild.User, device string) error {
+	var code string
+
+	for i := 0; ; i++ {
+		var err error
+
+		code, err = newCode()
+		if err != nil {
+			return err
+		}
+
+		hash := securetoken.Hash(bindDevice(codeToken(u.ID, code), device))
+
+		err = createToken(ctx, s.storage, u.ID, goappbuild.PurposeLoginCode, hash, goappbuild.LoginCodeTTL)
+		if err == nil {
+			break
+		}
+
+		if goappbuild.ErrorCode(err) != goappbuild.EConflict || i+1 == codeAttempts {
+			return err
+		}
+	}
+
+	data := map[string]any{
+		"Code":      code,
+		"ExpiresIn": goappbuild.LoginCodeTTL.String(),
+	}
+
+	return s.mail(ctx, mailer.TemplateLoginCode, u, data)
+}
+
+// newCode returns a random numeric code
+func newCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < goappbuild.LoginCodeLength; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", goappbuild.LoginCodeLength, n), nil
+}
+
+// codeToken is the token of a code, the codes are unique per user
+func codeToken(userID uuid.UUID, code string) string {
+	return userID.String() + ":" + code
+}
+
+// bindDevice binds the token to the device token. The bound tokens are stored
+// with the hash of both, so they cannot be used without the device token.
+func bindDevice(token, device string) string {
+	if device == "" {
+		return token
+	}
+
+	return token + "." + device
+}
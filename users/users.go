@@ -2,32 +2,413 @@ package users
 
 import (
 	"context"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/authz"
+	"github.com/gosom/goappbuild/mailer"
+	"github.com/gosom/goappbuild/pkg/securetoken"
+	"github.com/gosom/goappbuild/security"
 )
 
 var _ goappbuild.UserService = (*service)(nil)
 
+var (
+	errInvalidCredentials = goappbuild.Errorf(goappbuild.EUnauthorized, "invalid email or password")
+	errDisabled           = goappbuild.Errorf(goappbuild.EForbidden, "user is disabled")
+	errInvalidToken       = goappbuild.Errorf(goappbuild.EValidation, "invalid or expired token")
+)
+
+// Config is the configuration of the user service
+type Config struct {
+	// Mailer sends the verification and the password reset emails.
+	// Without it the emails are dropped.
+	Mailer goappbuild.Mailer
+	// VerifyEmailURL is the page of the app that verifies the email.
+	// The token is added as the token query parameter.
+	VerifyEmailURL string
+	// ResetPasswordURL is the page of the app that sets a new password.
+	// The token is added as the token query parameter.
+	ResetPasswordURL string
+	// LoginLinkURL is the page of the app that completes the magic link login.
+	// The token is added as the token query parameter.
+	LoginLinkURL string
+	// Guard protects the logins and checks the new passwords.
+	// Defaults to a guard with the default limits.
+	Guard goappbuild.LoginGuard
+	// PasswordlessLimit is the number of passwordless logins that can be
+	// requested for an email in the PasswordlessWindow. The window cannot
+	// exceed goappbuild.UserTokenRetention.
+	PasswordlessLimit  int
+	PasswordlessWindow time.Duration
+	// Logger reports the emails that cannot be sent and the exceeded
+	// passwordless limits. Defaults to a logger that discards everything.
+	Logger goappbuild.Logger
+}
+
 type service struct {
 	storage goappbuild.Storage
+	cfg     Config
+	// dummyHash is compared when the user does not exist so that
+	// the response time does not reveal registered emails
+	dummyHash []byte
 }
 
-func New(storage goappbuild.Storage) goappbuild.UserService {
+func New(storage goappbuild.Storage, cfg Config) goappbuild.UserService {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("goappbuild-dummy-password"), bcrypt.DefaultCost)
+
+	if cfg.Mailer == nil {
+		cfg.Mailer = mailer.NewFile(io.Discard, "")
+	}
+
+	if cfg.Guard == nil {
+		cfg.Guard = security.NewGuard(storage, security.Config{})
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = goappbuild.NopLogger()
+	}
+
+	if cfg.PasswordlessLimit <= 0 {
+		cfg.PasswordlessLimit = DefaultPasswordlessLimit
+	}
+
+	if cfg.PasswordlessWindow <= 0 || cfg.PasswordlessWindow > goappbuild.UserTokenRetention {
+		cfg.PasswordlessWindow = DefaultPasswordlessWindow
+	}
+
 	return &service{
-		storage: storage,
+		storage:   storage,
+		cfg:       cfg,
+		dummyHash: dummyHash,
 	}
 }
 
-func (s *service) Register(ctx context.Context, _ goappbuild.RegisterUserRequest) (goappbuild.User, error) {
-	u := goappbuild.User{}
+func (s *service) Register(ctx context.Context, req goappbuild.RegisterUserRequest) (goappbuild.User, error) {
+	u := goappbuild.User{
+		Email:    goappbuild.NormalizeEmail(req.Email),
+		Password: req.Password,
+	}
 
 	if err := u.Validate(); err != nil {
 		return goappbuild.User{}, err
 	}
 
+	if err := s.cfg.Guard.CheckPassword(ctx, u.Email, u.Password); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	hash, err := HashPassword(u.Password)
+	if err != nil {
+		return goappbuild.User{}, err
+	}
+
+	u.Password = ""
+	u.PasswordHash = hash
+
 	if err := s.storage.Users().Create(ctx, &u); err != nil {
 		return goappbuild.User{}, err
 	}
 
+	// the user is registered even if the email is not sent,
+	// a new link can be requested
+	if err := s.sendVerification(ctx, s.storage, u); err != nil {
+		s.cfg.Logger.Printf("users: sending the verification email to %s: %v", u.ID, err)
+	}
+
 	return u, nil
 }
+
+// Login returns the user with the given email and password.
+// The failures delay and lock out the email and the client.
+// Disabled users are rejected.
+func (s *service) Login(ctx context.Context, req goappbuild.LoginRequest) (goappbuild.User, error) {
+	attempt := goappbuild.LoginAttempt{Email: goappbuild.NormalizeEmail(req.Email)}
+
+	if err := s.cfg.Guard.Check(ctx, attempt); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	u, err := s.storage.Users().GetByEmail(ctx, attempt.Email)
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(req.Password))
+
+			return goappbuild.User{}, s.fail(ctx, attempt)
+		}
+
+		return goappbuild.User{}, err
+	}
+
+	attempt.UserID = u.ID
+
+	if !CheckPassword(u.PasswordHash, req.Password) {
+		return goappbuild.User{}, s.fail(ctx, attempt)
+	}
+
+	// only revealed to the callers that know the password
+	if u.IsDisabled() {
+		return goappbuild.User{}, errDisabled
+	}
+
+	if err := s.cfg.Guard.Succeed(ctx, attempt); err != nil {
+		return goappbuild.User{}, err
+	}
+
+	return u, nil
+}
+
+// fail records the failed login and returns the error for the caller
+func (s *service) fail(ctx context.Context, attempt goappbuild.LoginAttempt) error {
+	if err := s.cfg.Guard.Fail(ctx, attempt); err != nil {
+		return err
+	}
+
+	return errInvalidCredentials
+}
+
+// RequestEmailVerification sends a new verification link to the caller
+func (s *service) RequestEmailVerification(ctx context.Context) error {
+	identity, err := authz.User(ctx)
+	if err != nil {
+		return err
+	}
+
+	u, err := s.storage.Users().Get(ctx, identity.UserID)
+	if err != nil {
+		return err
+	}
+
+	if u.EmailVerifiedAt != nil {
+		return goappbuild.Errorf(goappbuild.EConflict, "email is already verified")
+	}
+
+	return s.sendVerification(ctx, s.storage, u)
+}
+
+// VerifyEmail marks the email of the user of the token as verified
+func (s *service) VerifyEmail(ctx context.Context, token string) error {
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	u, err := useToken(ctx, uw, goappbuild.PurposeVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+
+	if u.EmailVerifiedAt == nil {
+		now := time.Now().UTC()
+		u.EmailVerifiedAt = &now
+
+		if err := uw.Users().Update(ctx, &u); err != nil {
+			return err
+		}
+	}
+
+	return uw.Commit(ctx)
+}
+
+// RequestPasswordReset sends a password reset link to the email.
+// Unknown emails are ignored so that the response does not reveal them.
+func (s *service) RequestPasswordReset(ctx context.Context, email string) error {
+	u, err := s.storage.Users().GetByEmail(ctx, goappbuild.NormalizeEmail(email))
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return nil
+		}
+
+		return err
+	}
+
+	token, err := newToken(ctx, s.storage, u.ID, goappbuild.PurposeResetPassword, goappbuild.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.send(ctx, mailer.TemplateResetPassword, u, s.cfg.ResetPasswordURL, token, goappbuild.PasswordResetTTL)
+}
+
+// ResetPassword sets the new password and revokes the refresh tokens of the
+// user. Since the user received the token by email, the email is verified too.
+func (s *service) ResetPassword(ctx context.Context, req goappbuild.ResetPasswordRequest) error {
+	if err := goappbuild.ValidatePassword(req.Password); err != nil {
+		return err
+	}
+
+	hash, err := HashPassword(req.Password)
+	if err != nil {
+		return err
+	}
+
+	uw, err := s.storage.New(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer uw.Rollback(ctx)
+
+	u, err := useToken(ctx, uw, goappbuild.PurposeResetPassword, req.Token)
+	if err != nil {
+		return err
+	}
+
+	if err := s.cfg.Guard.CheckPassword(ctx, u.Email, req.Password); err != nil {
+		return err
+	}
+
+	u.PasswordHash = hash
+
+	if u.EmailVerifiedAt == nil {
+		now := time.Now().UTC()
+		u.EmailVerifiedAt = &now
+	}
+
+	if err := uw.Users().Update(ctx, &u); err != nil {
+		return err
+	}
+
+	if err := uw.RefreshTokens().RevokeUser(ctx, u.ID); err != nil {
+		return err
+	}
+
+	return uw.Commit(ctx)
+}
+
+func (s *service) sendVerification(ctx context.Context, storage goappbuild.Storage, u goappbuild.User) error {
+	token, err := newToken(ctx, storage, u.ID, goappbuild.PurposeVerifyEmail, goappbuild.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.send(ctx, mailer.TemplateVerifyEmail, u, s.cfg.VerifyEmailURL, token, goappbuild.EmailVerificationTTL)
+}
+
+// send emails the link with the token to the user
+func (s *service) send(
+	ctx context.Context,
+	template string,
+	u goappbuild.User,
+	link, token string,
+	ttl time.Duration,
+) error {
+	data := map[string]any{
+		"URL":       withToken(link, token),
+		"ExpiresIn": ttl.String(),
+	}
+
+	return s.mail(ctx, template, u, data)
+}
+
+// mail emails the template rendered with the data to the user
+func (s *service) mail(ctx context.Context, template string, u goappbuild.User, data map[string]any) error {
+	m, err := mailer.Render(template, u.Email, data)
+	if err != nil {
+		return err
+	}
+
+	return s.cfg.Mailer.Send(ctx, m)
+}
+
+// newToken stores a new single use token of the user and returns it
+func newToken(
+	ctx context.Context,
+	storage goappbuild.Storage,
+	userID uuid.UUID,
+	purpose goappbuild.TokenPurpose,
+	ttl time.Duration,
+) (string, error) {
+	token, err := securetoken.Generate("", securetoken.DefaultSize)
+	if err != nil {
+		return "", err
+	}
+
+	if err := createToken(ctx, storage, userID, purpose, securetoken.Hash(token), ttl); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// createToken stores a single use token of the user with the hash
+func createToken(
+	ctx context.Context,
+	storage goappbuild.Storage,
+	userID uuid.UUID,
+	purpose goappbuild.TokenPurpose,
+	hash string,
+	ttl time.Duration,
+) error {
+	t := goappbuild.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+
+	return storage.UserTokens().Create(ctx, &t)
+}
+
+// useToken consumes the token and returns its user
+func useToken(
+	ctx context.Context,
+	uw goappbuild.Storage,
+	purpose goappbuild.TokenPurpose,
+	token string,
+) (goappbuild.User, error) {
+	if token == "" {
+		return goappbuild.User{}, errInvalidToken
+	}
+
+	t, err := uw.UserTokens().Use(ctx, purpose, securetoken.Hash(token))
+	if err != nil {
+		if goappbuild.ErrorCode(err) == goappbuild.ENotFound {
+			return goappbuild.User{}, errInvalidToken
+		}
+
+		return goappbuild.User{}, err
+	}
+
+	return uw.Users().Get(ctx, t.UserID)
+}
+
+// withToken adds the token to the query of the link
+func withToken(link, token string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// HashPassword returns the bcrypt hash of the password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword returns true if the password matches the hash
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
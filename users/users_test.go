@@ -0,0 +1,463 @@
+package users_test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/goappbuild"
+	"github.com/gosom/goappbuild/auth"
+	"github.com/gosom/goappbuild/internal/memstore"
+	"github.com/gosom/goappbuild/mailer"
+	"github.com/gosom/goappbuild/mailer/smtptest"
+	"github.com/gosom/goappbuild/security"
+	"github.com/gosom/goappbuild/users"
+)
+
+var linkRe = regexp.MustCompile(`https://app\.example\.com/\S+`)
+
+func Test_EmailVerificationAndPasswordReset(t *testing.T) {
+	ctx := context.Background()
+
+	srv := smtptest.New()
+	defer srv.Close()
+
+	storage := memstore.New()
+
+	svc := users.New(storage, users.Config{
+		Mailer: mailer.NewSMTP(mailer.SMTPConfig{
+			Host: srv.Host(),
+			Port: srv.Port(),
+			From: "no-reply@example.com",
+		}),
+		VerifyEmailURL:   "https://app.example.com/verify-email",
+		ResetPasswordURL: "https://app.example.com/reset-password",
+	})
+
+	authSvc := auth.New(storage, svc, auth.Config{Secret: []byte("test-secret")})
+
+	// token returns the token of the link in the last email to the address
+	token := func(t *testing.T, to string) string {
+		t.Helper()
+
+		sent := srv.Messages()
+		require.NotEmpty(t, sent)
+
+		last := sent[len(sent)-1]
+		require.Equal(t, []string{to}, last.To)
+
+		link, err := url.Parse(linkRe.FindString(last.Text))
+		require.NoError(t, err)
+
+		return link.Query().Get("token")
+	}
+
+	u, err := svc.Register(ctx, goappbuild.RegisterUserRequest{
+		Email:    "jane@example.com",
+		Password: "correct-horse-battery",
+	})
+	require.NoError(t, err)
+	require.Nil(t, u.EmailVerifiedAt)
+
+	t.Run("test verify email", func(t *testing.T) {
+		require.Len(t, srv.Messages(), 1)
+		require.Equal(t, "Verify your email", srv.Messages()[0].Subject)
+
+		tok := token(t, "jane@example.com")
+
+		require.NoError(t, svc.VerifyEmail(ctx, tok))
+
+		got, err := storage.Users().Get(ctx, u.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got.EmailVerifiedAt)
+
+		err = svc.VerifyEmail(ctx, tok)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		err = svc.VerifyEmail(ctx, "invalid")
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test request verification of a verified email", func(t *testing.T) {
+		ctx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: u.ID})
+
+		err := svc.RequestEmailVerification(ctx)
+		require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test password reset of unknown email", func(t *testing.T) {
+		before := len(srv.Messages())
+
+		require.NoError(t, svc.RequestPasswordReset(ctx, "nobody@example.com"))
+		require.Len(t, srv.Messages(), before)
+	})
+
+	t.Run("test reset password", func(t *testing.T) {
+		pair, err := authSvc.Login(ctx, goappbuild.LoginRequest{
+			Email:    "jane@example.com",
+			Password: "correct-horse-battery",
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, svc.RequestPasswordReset(ctx, "Jane@Example.com"))
+
+		err = svc.ResetPassword(ctx, goappbuild.ResetPasswordRequest{
+			Token:    token(t, "jane@example.com"),
+			Password: "password123",
+		})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		require.NoError(t, svc.RequestPasswordReset(ctx, "Jane@Example.com"))
+
+		tok := token(t, "jane@example.com")
+
+		err = svc.ResetPassword(ctx, goappbuild.ResetPasswordRequest{Token: tok, Password: "short"})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		req := goappbuild.ResetPasswordRequest{Token: tok, Password: "new-correct-horse-battery"}
+
+		require.NoError(t, svc.ResetPassword(ctx, req))
+
+		err = svc.ResetPassword(ctx, req)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+		_, err = authSvc.Refresh(ctx, pair.RefreshToken)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		_, err = svc.Login(ctx, goappbuild.LoginRequest{Email: "jane@example.com", Password: "correct-horse-battery"})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		_, err = svc.Login(ctx, goappbuild.LoginRequest{Email: "jane@example.com", Password: "new-correct-horse-battery"})
+		require.NoError(t, err)
+	})
+}
+
+func Test_Login_BruteForce(t *testing.T) {
+	ctx := goappbuild.ContextWithClientIP(context.Background(), "198.51.100.1")
+	storage := memstore.New()
+	svc := users.New(storage, users.Config{})
+
+	_, err := svc.Register(ctx, goappbuild.RegisterUserRequest{
+		Email:    "jane@example.com",
+		Password: "iloveyou1",
+	})
+	require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+
+	_, err = svc.Register(ctx, goappbuild.RegisterUserRequest{
+		Email:    "jane@example.com",
+		Password: "correct-horse-battery",
+	})
+	require.NoError(t, err)
+
+	wrong := goappbuild.LoginRequest{Email: "jane@example.com", Password: "wrong password"}
+
+	for i := 0; i < security.DefaultDelayAfter; i++ {
+		_, err = svc.Login(ctx, wrong)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	}
+
+	// even the right password has to wait
+	_, err = svc.Login(ctx, goappbuild.LoginRequest{Email: "Jane@Example.com", Password: "correct-horse-battery"})
+	require.Equal(t, goappbuild.ETooManyRequests, goappbuild.ErrorCode(err))
+
+	events, err := storage.SecurityEventRepo.List(ctx, goappbuild.SecurityEventFilter{
+		Kind:  goappbuild.EventLoginFailed,
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, security.DefaultDelayAfter)
+	require.Equal(t, "198.51.100.1", events[0].IP)
+	require.NotEqual(t, uuid.Nil, events[0].UserID)
+}
+
+func Test_UserAdmin(t *testing.T) {
+	ctx := context.Background()
+	storage := memstore.New()
+	svc := users.New(storage, users.Config{})
+	authSvc := auth.New(storage, svc, auth.Config{Secret: []byte("test-secret")})
+
+	admin := goappbuild.User{Email: "admin@example.com", IsAdmin: true}
+	require.NoError(t, storage.UserRepo.Create(ctx, &admin))
+
+	adminCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: admin.ID})
+
+	register := func(t *testing.T, email string) goappbuild.User {
+		t.Helper()
+
+		u, err := svc.Register(ctx, goappbuild.RegisterUserRequest{Email: email, Password: "correct-horse-battery"})
+		require.NoError(t, err)
+
+		return u
+	}
+
+	jane := register(t, "jane@example.com")
+	john := register(t, "john@example.com")
+
+	projectID := uuid.New()
+	require.NoError(t, storage.MemberRepo.Create(ctx, &goappbuild.ProjectMember{
+		ProjectID:   projectID,
+		UserID:      jane.ID,
+		ProjectName: "shop",
+		Role:        goappbuild.RoleOwner,
+	}))
+
+	t.Run("test only admins", func(t *testing.T) {
+		janeCtx := goappbuild.ContextWithIdentity(ctx, goappbuild.Identity{UserID: jane.ID})
+
+		_, err := svc.List(janeCtx, goappbuild.UserFilter{})
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.Disable(janeCtx, john.ID)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		_, err = svc.Get(ctx, john.ID)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test list", func(t *testing.T) {
+		all, err := svc.List(adminCtx, goappbuild.UserFilter{})
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+		require.Equal(t, "admin@example.com", all[0].Email)
+
+		found, err := svc.List(adminCtx, goappbuild.UserFilter{Search: "JOHN"})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.Equal(t, john.ID, found[0].ID)
+
+		page, err := svc.List(adminCtx, goappbuild.UserFilter{Limit: 1, Offset: 1})
+		require.NoError(t, err)
+		require.Len(t, page, 1)
+		require.Equal(t, jane.ID, page[0].ID)
+
+		_, err = svc.List(adminCtx, goappbuild.UserFilter{Limit: goappbuild.MaxUsers + 1})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test projects", func(t *testing.T) {
+		projects, err := svc.Projects(adminCtx, jane.ID)
+		require.NoError(t, err)
+		require.Len(t, projects, 1)
+		require.Equal(t, "shop", projects[0].ProjectName)
+
+		_, err = svc.Projects(adminCtx, uuid.New())
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test disable and enable", func(t *testing.T) {
+		login := goappbuild.LoginRequest{Email: john.Email, Password: "correct-horse-battery"}
+
+		pair, err := authSvc.Login(ctx, login)
+		require.NoError(t, err)
+
+		u, err := svc.Disable(adminCtx, john.ID)
+		require.NoError(t, err)
+		require.True(t, u.IsDisabled())
+
+		_, err = svc.Disable(adminCtx, john.ID)
+		require.Equal(t, goappbuild.EConflict, goappbuild.ErrorCode(err))
+
+		_, err = authSvc.Refresh(ctx, pair.RefreshToken)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		_, err = authSvc.Authenticate(ctx, pair.AccessToken)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		_, err = authSvc.Login(ctx, login)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		disabled := true
+
+		found, err := svc.List(adminCtx, goappbuild.UserFilter{Disabled: &disabled})
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		require.Equal(t, john.ID, found[0].ID)
+
+		u, err = svc.Enable(adminCtx, john.ID)
+		require.NoError(t, err)
+		require.False(t, u.IsDisabled())
+
+		_, err = authSvc.Login(ctx, login)
+		require.NoError(t, err)
+	})
+
+	t.Run("test force logout", func(t *testing.T) {
+		pair, err := authSvc.Login(ctx, goappbuild.LoginRequest{Email: jane.Email, Password: "correct-horse-battery"})
+		require.NoError(t, err)
+
+		require.NoError(t, svc.ForceLogout(adminCtx, jane.ID))
+
+		_, err = authSvc.Refresh(ctx, pair.RefreshToken)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		_, err = authSvc.Authenticate(ctx, pair.AccessToken)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test delete", func(t *testing.T) {
+		err := svc.Delete(adminCtx, admin.ID)
+		require.Equal(t, goappbuild.EForbidden, goappbuild.ErrorCode(err))
+
+		require.NoError(t, svc.Delete(adminCtx, john.ID))
+
+		_, err = svc.Get(adminCtx, john.ID)
+		require.Equal(t, goappbuild.ENotFound, goappbuild.ErrorCode(err))
+	})
+
+	events, err := storage.SecurityEventRepo.List(ctx, goappbuild.SecurityEventFilter{
+		UserID: john.ID,
+		Limit:  10,
+	})
+	require.NoError(t, err)
+
+	kinds := make([]goappbuild.SecurityEventKind, len(events))
+	for i := range events {
+		kinds[i] = events[i].Kind
+		require.Equal(t, admin.ID, events[i].ActorID)
+	}
+
+	require.Equal(t, []goappbuild.SecurityEventKind{
+		goappbuild.EventUserDeleted,
+		goappbuild.EventUserEnabled,
+		goappbuild.EventUserDisabled,
+	}, kinds)
+}
+
+func Test_PasswordlessLogin(t *testing.T) {
+	ctx := context.Background()
+
+	srv := smtptest.New()
+	defer srv.Close()
+
+	storage := memstore.New()
+
+	svc := users.New(storage, users.Config{
+		Mailer: mailer.NewSMTP(mailer.SMTPConfig{
+			Host: srv.Host(),
+			Port: srv.Port(),
+			From: "no-reply@example.com",
+		}),
+		LoginLinkURL:      "https://app.example.com/login/link",
+		PasswordlessLimit: 4,
+	})
+
+	authSvc := auth.New(storage, svc, auth.Config{Secret: []byte("test-secret")})
+
+	codeRe := regexp.MustCompile(`\b\d{6}\b`)
+
+	// last returns the text of the last email
+	last := func(t *testing.T) string {
+		t.Helper()
+
+		sent := srv.Messages()
+		require.NotEmpty(t, sent)
+
+		return sent[len(sent)-1].Text
+	}
+
+	u, err := svc.Register(ctx, goappbuild.RegisterUserRequest{
+		Email:    "jane@example.com",
+		Password: "correct-horse-battery",
+	})
+	require.NoError(t, err)
+
+	t.Run("test invalid method", func(t *testing.T) {
+		_, err := svc.RequestLogin(ctx, goappbuild.PasswordlessRequest{Email: "jane@example.com", Method: "sms"})
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test unknown email", func(t *testing.T) {
+		before := len(srv.Messages())
+
+		challenge, err := svc.RequestLogin(ctx, goappbuild.PasswordlessRequest{
+			Email:      "nobody@example.com",
+			Method:     goappbuild.PasswordlessLink,
+			BindDevice: true,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, challenge.DeviceToken)
+		require.Len(t, srv.Messages(), before)
+	})
+
+	t.Run("test magic link", func(t *testing.T) {
+		challenge, err := svc.RequestLogin(ctx, goappbuild.PasswordlessRequest{
+			Email:  "Jane@Example.com",
+			Method: goappbuild.PasswordlessLink,
+		})
+		require.NoError(t, err)
+		require.Empty(t, challenge.DeviceToken)
+
+		link, err := url.Parse(linkRe.FindString(last(t)))
+		require.NoError(t, err)
+
+		req := goappbuild.LoginLinkRequest{Token: link.Query().Get("token")}
+
+		pair, err := authSvc.LoginWithLink(ctx, req)
+		require.NoError(t, err)
+		require.NotEmpty(t, pair.AccessToken)
+
+		got, err := storage.Users().Get(ctx, u.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got.EmailVerifiedAt)
+
+		_, err = authSvc.LoginWithLink(ctx, req)
+		require.Equal(t, goappbuild.EValidation, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test code bound to the device", func(t *testing.T) {
+		challenge, err := svc.RequestLogin(ctx, goappbuild.PasswordlessRequest{
+			Email:      "jane@example.com",
+			Method:     goappbuild.PasswordlessCode,
+			BindDevice: true,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, challenge.DeviceToken)
+
+		code := codeRe.FindString(last(t))
+		require.NotEmpty(t, code)
+
+		_, err = svc.LoginWithCode(ctx, goappbuild.LoginCodeRequest{Email: "jane@example.com", Code: code})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		_, err = svc.LoginWithCode(ctx, goappbuild.LoginCodeRequest{
+			Email:       "jane@example.com",
+			Code:        "abcdef",
+			DeviceToken: challenge.DeviceToken,
+		})
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+
+		req := goappbuild.LoginCodeRequest{
+			Email:       "jane@example.com",
+			Code:        code,
+			DeviceToken: challenge.DeviceToken,
+		}
+
+		pair, err := authSvc.LoginWithCode(ctx, req)
+		require.NoError(t, err)
+		require.NotEmpty(t, pair.AccessToken)
+
+		_, err = authSvc.LoginWithCode(ctx, req)
+		require.Equal(t, goappbuild.EUnauthorized, goappbuild.ErrorCode(err))
+	})
+
+	t.Run("test rate limit", func(t *testing.T) {
+		req := goappbuild.PasswordlessRequest{Email: "jane@example.com", Method: goappbuild.PasswordlessCode}
+
+		_, err := svc.RequestLogin(ctx, req)
+		require.NoError(t, err)
+
+		before := len(srv.Messages())
+
+		_, err = svc.RequestLogin(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, srv.Messages(), before+1)
+
+		_, err = svc.RequestLogin(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, srv.Messages(), before+1)
+	})
+}